@@ -0,0 +1,83 @@
+package subscribe
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/cln"
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// StartCln runs the background server for a Core Lightning node. Core Lightning has no streaming RPCs
+// so every stream polls the node. Streams without a Core Lightning counterpart (graph, HTLC and peer events, the
+// channel balance cache and in-flight payments) are not started so their status stays inactive.
+// The broadcaster isn't used for CLN nodes: the fee policy engine, workflows, rebalancer, channel risk and other
+// services that depend on the LND only streams or broadcasted events don't run for CLN nodes.
+func StartCln(ctx context.Context, client *cln_connect.Client, db *sqlx.DB, nodeId int,
+	eventChannel chan interface{}) error {
+
+	nodeSettings := commons.GetNodeSettingsByNodeId(nodeId)
+
+	err := channels.InitializeManagedChannelCache(db)
+	if err != nil {
+		return errors.Wrapf(err, "CLN Initialize ManagedChannelCache for nodeId: %v", nodeSettings.NodeId)
+	}
+
+	var wg sync.WaitGroup
+
+	streams := []struct {
+		subscriptionStream commons.SubscriptionStream
+		name               string
+		subscribe          func()
+	}{
+		{commons.ChannelEventStream, "ChannelEventStream", func() {
+			cln.SubscribeAndStoreChannelEvents(ctx, client, db, nodeSettings, eventChannel)
+		}},
+		{commons.TransactionStream, "TransactionStream", func() {
+			cln.SubscribeAndStoreTransactions(ctx, client, db, nodeSettings, eventChannel)
+		}},
+		{commons.ForwardStream, "ForwardStream", func() {
+			cln.SubscribeForwardingEvents(ctx, client, db, nodeSettings, eventChannel)
+		}},
+		{commons.PaymentStream, "PaymentStream", func() {
+			cln.SubscribeAndStorePayments(ctx, client, db, nodeSettings, eventChannel)
+		}},
+		{commons.InvoiceStream, "InvoiceStream", func() {
+			cln.SubscribeAndStoreInvoices(ctx, client, db, nodeSettings, eventChannel)
+		}},
+	}
+
+	for _, stream := range streams {
+		stream := stream
+		wg.Add(1)
+		go (func() {
+			defer wg.Done()
+			defer func() {
+				if panicError := recover(); panicError != nil {
+					log.Error().Msgf("Panic occurred in CLN %v (nodeId: %v) %v", stream.name, nodeId, panicError)
+					stream.subscribe()
+				}
+			}()
+			stream.subscribe()
+		})()
+
+		waitForReadyState(nodeSettings.NodeId, stream.subscriptionStream, stream.name, eventChannel)
+	}
+
+	log.Info().Msgf("CLN completely initialized for nodeId: %v", nodeId)
+	time.Sleep(commons.STREAM_CLN_CHANNELS_TICKER_SECONDS * time.Second)
+	if commons.RunningServices[commons.LndService].GetStatus(nodeId) != commons.Active {
+		log.Error().Msgf("Somehow a stream got out-of-sync for nodeId: %v", nodeId)
+	}
+
+	wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package subscribe
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// StartNode runs the background server of the implementation of the connected node
+func StartNode(ctx context.Context, connection settings.NodeConnection, db *sqlx.DB,
+	broadcaster broadcast.BroadcastServer, eventChannel chan interface{},
+	serviceChannel chan commons.ServiceChannelMessage) error {
+
+	switch connection := connection.(type) {
+	case settings.LndConnection:
+		return Start(ctx, connection.Conn, db, connection.NodeId, broadcaster, eventChannel, serviceChannel)
+	case settings.ClnConnection:
+		return StartCln(ctx, connection.Client, db, connection.NodeId, eventChannel)
+	}
+	return errors.Newf("Unsupported node connection for node id: %v", connection.GetNodeId())
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/fee_policies"
+	"github.com/lncapital/torq/internal/workflows"
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/lnd"
@@ -180,10 +182,10 @@ func Start(ctx context.Context, conn *grpc.ClientConn, db *sqlx.DB, nodeId int,
 		defer func() {
 			if panicError := recover(); panicError != nil {
 				log.Error().Msgf("Panic occurred in PeerEventStream (nodeId: %v) %v", nodeId, panicError)
-				lnd.SubscribePeerEvents(ctx, client, nodeSettings, eventChannel)
+				lnd.SubscribePeerEvents(ctx, client, db, nodeSettings, eventChannel)
 			}
 		}()
-		lnd.SubscribePeerEvents(ctx, client, nodeSettings, eventChannel)
+		lnd.SubscribePeerEvents(ctx, client, db, nodeSettings, eventChannel)
 	})()
 
 	waitForReadyState(nodeSettings.NodeId, commons.PeerEventStream, "PeerEventStream", eventChannel)
@@ -202,6 +204,34 @@ func Start(ctx context.Context, conn *grpc.ClientConn, db *sqlx.DB, nodeId int,
 	})()
 	// No need to waitForReadyState for ChannelBalanceCacheMaintenance
 
+	// Fee policies
+	wg.Add(1)
+	go (func() {
+		defer wg.Done()
+		defer func() {
+			if panicError := recover(); panicError != nil {
+				log.Error().Msgf("Panic occurred in FeePolicyEngine (nodeId: %v) %v", nodeId, panicError)
+				fee_policies.FeePolicyEngine(ctx, db, nodeSettings, eventChannel)
+			}
+		}()
+		fee_policies.FeePolicyEngine(ctx, db, nodeSettings, eventChannel)
+	})()
+	// No need to waitForReadyState for FeePolicyEngine
+
+	// Workflows
+	wg.Add(1)
+	go (func() {
+		defer wg.Done()
+		defer func() {
+			if panicError := recover(); panicError != nil {
+				log.Error().Msgf("Panic occurred in WorkflowEngine (nodeId: %v) %v", nodeId, panicError)
+				workflows.WorkflowEngine(ctx, db, nodeSettings, broadcaster, eventChannel)
+			}
+		}()
+		workflows.WorkflowEngine(ctx, db, nodeSettings, broadcaster, eventChannel)
+	})()
+	// No need to waitForReadyState for WorkflowEngine
+
 	// Transactions
 	wg.Add(1)
 	go (func() {
@@ -277,6 +307,30 @@ func Start(ctx context.Context, conn *grpc.ClientConn, db *sqlx.DB, nodeId int,
 
 	waitForReadyState(nodeSettings.NodeId, commons.InFlightPaymentStream, "InFlightPaymentStream", eventChannel)
 
+	wg.Add(1)
+	go (func() {
+		defer wg.Done()
+		defer func() {
+			if panicError := recover(); panicError != nil {
+				log.Error().Msgf("Panic occurred in Reconciliation (nodeId: %v) %v", nodeId, panicError)
+				lnd.ReconcileStoredEvents(ctx, client, db, nodeSettings)
+			}
+		}()
+		lnd.ReconcileStoredEvents(ctx, client, db, nodeSettings)
+	})()
+
+	wg.Add(1)
+	go (func() {
+		defer wg.Done()
+		defer func() {
+			if panicError := recover(); panicError != nil {
+				log.Error().Msgf("Panic occurred in ChannelLifecycle (nodeId: %v) %v", nodeId, panicError)
+				lnd.TrackChannelLifecycles(ctx, client, db, nodeSettings, eventChannel)
+			}
+		}()
+		lnd.TrackChannelLifecycles(ctx, client, db, nodeSettings, eventChannel)
+	})()
+
 	log.Info().Msgf("LND completely initialized for nodeId: %v", nodeId)
 	time.Sleep(commons.CHANNELBALANCE_TICKER_SECONDS * time.Second)
 	if commons.RunningServices[commons.LndService].GetStatus(nodeId) != commons.Active {
@@ -0,0 +1,99 @@
+package subscribe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestStartWithFakeLndServer(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, cancel, err := srv.NewTestDatabase(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	err = settings.InitializeManagedSettingsCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedSettings cache: %v", err)
+	}
+	err = settings.InitializeManagedNodeCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedNode cache: %v", err)
+	}
+	err = channels.InitializeManagedChannelCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedChannel cache: %v", err)
+	}
+	nodeId := commons.GetNodeIdByPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet)
+
+	server := testutil.NewFakeLndServer(t)
+	server.SetInfo(&lnrpc.GetInfoResponse{IdentityPubkey: testutil.TestPublicKey1, BlockHeight: 800000,
+		SyncedToChain: true, SyncedToGraph: true,
+		Chains: []*lnrpc.Chain{{Chain: "bitcoin", Network: "signet"}}})
+	server.SetChannels(&lnrpc.Channel{ChanId: 1111, ChannelPoint: testutil.TestFundingTransactionHash1 + ":3",
+		RemotePubkey: testutil.TestPublicKey2, Capacity: 1_000_000, Active: true, Initiator: true})
+	forwardTime := time.Now().UTC().Add(-time.Hour)
+	server.AddForwards(
+		&lnrpc.ForwardingEvent{ChanIdIn: 1111, ChanIdOut: 2222, AmtInMsat: 11000, AmtOutMsat: 10000, FeeMsat: 1000,
+			TimestampNs: uint64(forwardTime.UnixNano())},
+		&lnrpc.ForwardingEvent{ChanIdIn: 2222, ChanIdOut: 1111, AmtInMsat: 21000, AmtOutMsat: 20000, FeeMsat: 1000,
+			TimestampNs: uint64(forwardTime.Add(time.Minute).UnixNano())})
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	// The broadcaster consumes the event channel so the streams never block on their status updates
+	eventChannel := make(chan interface{})
+	broadcaster := broadcast.NewBroadcastServer(ctx, eventChannel)
+	serviceChannel := make(chan commons.ServiceChannelMessage)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Start(ctx, server.Dial(t), db, nodeId, broadcaster, eventChannel, serviceChannel)
+	}()
+
+	// Every stream waits for the previous one to be active so this can take a while
+	deadline := time.Now().Add(3 * time.Minute)
+	var openEvents, forwards int
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-errs:
+			t.Fatalf("Start returned before the data was stored: %v", err)
+		default:
+		}
+		err = db.QueryRow(`SELECT COUNT(*) FROM channel_event ce JOIN channel c ON c.channel_id = ce.channel_id
+			WHERE c.lnd_short_channel_id = 1111 AND ce.event_type = $1 AND ce.node_id = $2;`,
+			lnrpc.ChannelEventUpdate_OPEN_CHANNEL, nodeId).Scan(&openEvents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.QueryRow(`SELECT COUNT(*) FROM forward WHERE node_id = $1;`, nodeId).Scan(&forwards)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if openEvents == 1 && forwards == 2 {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if openEvents != 1 {
+		t.Errorf("expected the open channel of the node to be imported, got %v open events", openEvents)
+	}
+	if forwards != 2 {
+		t.Errorf("expected the forwarding history of the node to be stored, got %v forwards", forwards)
+	}
+	if len(server.Calls("ListChannels")) == 0 {
+		t.Errorf("expected Start to list the channels of the node")
+	}
+}
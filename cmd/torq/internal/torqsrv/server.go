@@ -2,8 +2,10 @@ package torqsrv
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/cockroachdb/errors"
@@ -16,29 +18,43 @@ import (
 	mgin "github.com/ulule/limiter/v3/drivers/middleware/gin"
 	"github.com/ulule/limiter/v3/drivers/store/memory"
 
+	"github.com/lncapital/torq/internal/accounting"
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/auth"
 	"github.com/lncapital/torq/internal/categories"
 	"github.com/lncapital/torq/internal/channel_groups"
+	"github.com/lncapital/torq/internal/channel_health"
 	"github.com/lncapital/torq/internal/channel_history"
+	"github.com/lncapital/torq/internal/channel_risk"
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/corridors"
+	"github.com/lncapital/torq/internal/fee_policies"
 	"github.com/lncapital/torq/internal/flow"
 	"github.com/lncapital/torq/internal/forwards"
+	"github.com/lncapital/torq/internal/htlcs"
 	"github.com/lncapital/torq/internal/invoices"
+	"github.com/lncapital/torq/internal/maintenance"
 	"github.com/lncapital/torq/internal/messages"
+	"github.com/lncapital/torq/internal/metrics"
 	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/internal/notifications"
 	"github.com/lncapital/torq/internal/on_chain_tx"
+	"github.com/lncapital/torq/internal/open_recommendations"
 	"github.com/lncapital/torq/internal/payments"
 	"github.com/lncapital/torq/internal/peers"
+	"github.com/lncapital/torq/internal/prices"
+	"github.com/lncapital/torq/internal/rebalances"
 	"github.com/lncapital/torq/internal/services"
 	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/internal/spending"
 	"github.com/lncapital/torq/internal/tags"
 	"github.com/lncapital/torq/internal/views"
+	"github.com/lncapital/torq/internal/workflows"
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
 )
 
-func Start(port int, apiPswd string, cookiePath string, db *sqlx.DB,
+func Start(port int, apiPswd string, cookiePath string, metricsUser string, metricsPswd string, db *sqlx.DB,
 	eventChannel chan interface{}, broadcaster broadcast.BroadcastServer,
 	serviceChannel chan commons.ServiceChannelMessage) error {
 
@@ -53,7 +69,7 @@ func Start(port int, apiPswd string, cookiePath string, db *sqlx.DB,
 		return errors.Wrap(err, "Creating Gin Session")
 	}
 
-	registerRoutes(r, db, apiPswd, cookiePath, eventChannel, broadcaster, serviceChannel)
+	registerRoutes(r, db, apiPswd, cookiePath, metricsUser, metricsPswd, eventChannel, broadcaster, serviceChannel)
 
 	fmt.Println("Listening on port " + strconv.Itoa(port))
 
@@ -112,7 +128,7 @@ func equalASCIIFold(s, t string) bool {
 	return s == t
 }
 
-func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, cookiePath string,
+func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, cookiePath string, metricsUser string, metricsPwd string,
 	eventChannel chan interface{}, broadcaster broadcast.BroadcastServer,
 	serviceChannel chan commons.ServiceChannelMessage) {
 
@@ -120,12 +136,19 @@ func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, cookiePath string
 	applyCors(r)
 	// Websocket
 	ws := r.Group("/ws")
-	ws.Use(auth.AuthRequired)
+	ws.Use(auth.AuthRequired(db))
 	ws.GET("", func(c *gin.Context) {
 		err := WebsocketHandler(c, db, eventChannel, broadcaster)
 		log.Debug().Msgf("WebsocketHandler: %v", err)
 	})
 
+	// Prometheus metrics, with basic auth when a metrics password is configured so it can be scraped
+	if metricsPwd != "" {
+		r.GET("/metrics", gin.BasicAuth(gin.Accounts{metricsUser: metricsPwd}), metrics.Handler())
+	} else {
+		r.GET("/metrics", auth.AuthRequired(db), metrics.Handler())
+	}
+
 	registerStaticRoutes(r)
 
 	api := r.Group("/api")
@@ -134,7 +157,7 @@ func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, cookiePath string
 
 	// Limit login attempts to 10 per minute.
 	rl := NewLoginRateLimitMiddleware()
-	api.POST("/login", rl, auth.Login(apiPwd))
+	api.POST("/login", rl, auth.Login(db, apiPwd))
 	api.POST("/cookie-login", rl, auth.CookieLogin(cookiePath))
 
 	unauthorisedSettingRoutes := api.Group("settings")
@@ -147,85 +170,190 @@ func registerRoutes(r *gin.Engine, db *sqlx.DB, apiPwd string, cookiePath string
 		services.RegisterUnauthenticatedRoutes(unauthorisedServicesRoutes, db)
 	}
 
-	api.Use(auth.AuthRequired).Use(auth.TorqRequired)
+	// Viewers can read everything (except secrets), operators can also operate the nodes and admins can also
+	// change the settings and manage the users. Every request that isn't read-only is logged with its actor.
+	api.Use(auth.AuthRequired(db)).Use(auth.TorqRequired).Use(auth.AuditMutations)
 	{
 
+		userRoutes := api.Group("/users")
+		userRoutes.Use(auth.RoleRequired(auth.Admin))
+		{
+			auth.RegisterUserRoutes(userRoutes, db)
+		}
+
+		apiTokenRoutes := api.Group("/api-tokens")
+		{
+			auth.RegisterApiTokenRoutes(apiTokenRoutes, db)
+		}
+
+		auditRoutes := api.Group("/audit")
+		auditRoutes.Use(auth.RoleRequired(auth.Admin))
+		{
+			audit.RegisterAuditRoutes(auditRoutes, db)
+		}
+
+		maintenanceRoutes := api.Group("/maintenance")
+		{
+			maintenance.RegisterMaintenanceRoutes(maintenanceRoutes, db)
+		}
+
+		servicesRoutes := api.Group("/services")
+		{
+			services.RegisterServiceRoutes(servicesRoutes, db)
+		}
+
+		spendingRoutes := api.Group("/spending")
+		spendingRoutes.Use(auth.MutationRoleRequired(auth.Operator))
+		{
+			spending.RegisterSpendingRoutes(spendingRoutes, db, eventChannel, spendingExecutors(db))
+		}
+
 		tableViewRoutes := api.Group("/table-views")
 		{
 			views.RegisterTableViewRoutes(tableViewRoutes, db)
 		}
 
 		categoryRoutes := api.Group("/categories")
+		categoryRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			categories.RegisterCategoryRoutes(categoryRoutes, db)
 		}
 
 		tagRoutes := api.Group("/tags")
+		tagRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			tags.RegisterTagRoutes(tagRoutes, db)
 		}
 
+		feePolicyRoutes := api.Group("/fee-policies")
+		feePolicyRoutes.Use(auth.MutationRoleRequired(auth.Operator))
+		{
+			fee_policies.RegisterFeePolicyRoutes(feePolicyRoutes, db)
+		}
+
+		workflowRoutes := api.Group("/workflows")
+		workflowRoutes.Use(auth.MutationRoleRequired(auth.Operator))
+		{
+			workflows.RegisterWorkflowRoutes(workflowRoutes, db)
+		}
+
 		channelGroupRoutes := api.Group("/channelGroups")
+		channelGroupRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			channel_groups.RegisterChannelGroupRoutes(channelGroupRoutes, db)
 		}
 
 		corridorRoutes := api.Group("/corridors")
+		corridorRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			corridors.RegisterCorridorRoutes(corridorRoutes, db)
 		}
 
 		paymentRoutes := api.Group("/payments")
+		paymentRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			payments.RegisterPaymentsRoutes(paymentRoutes, db)
 		}
 
+		notificationRoutes := api.Group("/notifications")
+		notificationRoutes.Use(auth.RoleRequired(auth.Admin))
+		{
+			notifications.RegisterNotificationRoutes(notificationRoutes, db)
+		}
+
+		rebalanceRoutes := api.Group("/rebalances")
+		rebalanceRoutes.Use(auth.MutationRoleRequired(auth.Operator))
+		{
+			rebalances.RegisterRebalanceRoutes(rebalanceRoutes, db)
+		}
+
 		invoiceRoutes := api.Group("/invoices")
+		invoiceRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			invoices.RegisterInvoicesRoutes(invoiceRoutes, db)
 		}
 
 		onChainTx := api.Group("/on-chain-tx")
+		onChainTx.Use(auth.MutationRoleRequired(auth.Operator))
 		{
-			on_chain_tx.RegisterOnChainTxsRoutes(onChainTx, db)
+			on_chain_tx.RegisterOnChainTxsRoutes(onChainTx, db, eventChannel)
 		}
 
 		peerRoutes := api.Group("/peers")
+		peerRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			peers.RegisterPeerRoutes(peerRoutes, db)
 		}
 
 		nodeRoutes := api.Group("/nodes")
+		nodeRoutes.Use(auth.MutationRoleRequired(auth.Admin))
 		{
 			nodes.RegisterNodeRoutes(nodeRoutes, db)
 		}
 
 		channelRoutes := api.Group("/channels")
+		channelRoutes.Use(auth.MutationRoleRequired(auth.Operator))
 		{
 			channel_history.RegisterChannelHistoryRoutes(channelRoutes, db)
 			channels.RegisterChannelRoutes(channelRoutes, db, eventChannel)
 		}
 
+		channelHealthRoutes := api.Group("/channel-health")
+		{
+			channel_health.RegisterChannelHealthRoutes(channelHealthRoutes, db)
+		}
+
+		channelRiskRoutes := api.Group("/channel-risk")
+		{
+			channel_risk.RegisterChannelRiskRoutes(channelRiskRoutes)
+		}
+
+		openRecommendationRoutes := api.Group("/open-recommendations")
+		{
+			open_recommendations.RegisterOpenRecommendationRoutes(openRecommendationRoutes, db)
+		}
+
 		forwardRoutes := api.Group("/forwards")
 		{
 			forwards.RegisterForwardsRoutes(forwardRoutes, db)
 		}
 
+		accountingRoutes := api.Group("/accounting")
+		{
+			accounting.RegisterAccountingRoutes(accountingRoutes, db)
+		}
+
+		priceRoutes := api.Group("/prices")
+		priceRoutes.Use(auth.MutationRoleRequired(auth.Operator))
+		{
+			prices.RegisterPriceRoutes(priceRoutes, db,
+				prices.NewCoinGeckoProvider(&http.Client{Timeout: 30 * time.Second}, ""))
+		}
+
+		htlcRoutes := api.Group("/htlcs")
+		{
+			htlcs.RegisterHtlcRoutes(htlcRoutes, db)
+		}
+
 		flowRoutes := api.Group("/flow")
 		{
 			flow.RegisterFlowRoutes(flowRoutes, db)
 		}
 
 		messageRoutes := api.Group("messages")
+		messageRoutes.Use(auth.RoleRequired(auth.Operator))
 		{
 			messages.RegisterMessagesRoutes(messageRoutes, db)
 		}
 
 		settingRoutes := api.Group("settings")
+		settingRoutes.Use(auth.MutationRoleRequired(auth.Admin))
 		{
 			settings.RegisterSettingRoutes(settingRoutes, db, serviceChannel)
 		}
 
+		api.GET("/me", auth.CurrentActorHandler)
+
 		api.GET("/ping", func(c *gin.Context) {
 			c.JSON(200, gin.H{
 				"message": "pong",
@@ -0,0 +1,96 @@
+package torqsrv
+
+import (
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/on_chain_tx"
+	"github.com/lncapital/torq/internal/payments"
+	"github.com/lncapital/torq/internal/rebalances"
+	"github.com/lncapital/torq/internal/spending"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type PendingApprovalResponse struct {
+	ReqId             string   `json:"reqId"`
+	Type              string   `json:"type"`
+	SpendingRequestId int      `json:"spendingRequestId"`
+	Reasons           []string `json:"reasons"`
+}
+
+// spendingExecutors execute the approved spending requests on behalf of the requester
+func spendingExecutors(db *sqlx.DB) spending.Executors {
+	return spending.Executors{
+		spending.Payment: func(request spending.SpendingRequest, eventChannel chan interface{}) error {
+			var npReq commons.NewPaymentRequest
+			if err := json.Unmarshal(request.Request, &npReq); err != nil {
+				return errors.Wrap(err, "JSON unmarshal")
+			}
+			return audit.Execute(db, request.Requester(), audit.SendPayment, npReq.NodeId, nil, npReq, eventChannel,
+				func(eventChannel chan interface{}) error {
+					return payments.SendNewPayment(eventChannel, db, nil, npReq, reqId(request))
+				})
+		},
+		spending.OnChain: func(request spending.SpendingRequest, eventChannel chan interface{}) error {
+			var payReq commons.PayOnChainRequest
+			if err := json.Unmarshal(request.Request, &payReq); err != nil {
+				return errors.Wrap(err, "JSON unmarshal")
+			}
+			return audit.Execute(db, request.Requester(), audit.PayOnChain, payReq.NodeId, nil, payReq, eventChannel,
+				func(eventChannel chan interface{}) error {
+					txId, err := on_chain_tx.PayOnChain(db, payReq)
+					if err != nil {
+						return err
+					}
+					eventChannel <- commons.PayOnChainResponse{Request: payReq, TxId: txId}
+					return nil
+				})
+		},
+		spending.ChannelOpen: func(request spending.SpendingRequest, eventChannel chan interface{}) error {
+			var ocReq commons.OpenChannelRequest
+			if err := json.Unmarshal(request.Request, &ocReq); err != nil {
+				return errors.Wrap(err, "JSON unmarshal")
+			}
+			return audit.Execute(db, request.Requester(), audit.OpenChannel, ocReq.NodeId, nil, ocReq, eventChannel,
+				func(eventChannel chan interface{}) error {
+					return channels.OpenChannel(eventChannel, db, ocReq, reqId(request))
+				})
+		},
+		spending.BatchChannelOpen: func(request spending.SpendingRequest, eventChannel chan interface{}) error {
+			var batchOpnReq commons.BatchOpenRequest
+			if err := json.Unmarshal(request.Request, &batchOpnReq); err != nil {
+				return errors.Wrap(err, "JSON unmarshal")
+			}
+			return audit.Execute(db, request.Requester(), audit.BatchOpenChannels, batchOpnReq.NodeId, nil,
+				batchOpnReq, eventChannel, func(eventChannel chan interface{}) error {
+					response, err := channels.BatchOpenChannels(db, batchOpnReq)
+					if err != nil {
+						return err
+					}
+					eventChannel <- response
+					return nil
+				})
+		},
+		spending.Rebalance: func(request spending.SpendingRequest, eventChannel chan interface{}) error {
+			var rbReq commons.RebalanceRequest
+			if err := json.Unmarshal(request.Request, &rbReq); err != nil {
+				return errors.Wrap(err, "JSON unmarshal")
+			}
+			return audit.Execute(db, request.Requester(), audit.Rebalance, rbReq.NodeId, nil, rbReq, eventChannel,
+				func(eventChannel chan interface{}) error {
+					return rebalances.SendRebalance(eventChannel, db, nil, rbReq, reqId(request))
+				})
+		},
+	}
+}
+
+func reqId(request spending.SpendingRequest) string {
+	if request.ReqId == nil {
+		return ""
+	}
+	return *request.ReqId
+}
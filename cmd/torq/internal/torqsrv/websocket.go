@@ -12,9 +12,13 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/auth"
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/on_chain_tx"
 	"github.com/lncapital/torq/internal/payments"
+	"github.com/lncapital/torq/internal/rebalances"
+	"github.com/lncapital/torq/internal/spending"
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
 )
@@ -28,6 +32,11 @@ type wsRequest struct {
 	CloseChannelRequest *commons.CloseChannelRequest `json:"closeChannelRequest"`
 	Password            *string                      `json:"password"`
 	NewAddressRequest   *commons.NewAddressRequest   `json:"newAddressRequest"`
+	RebalanceRequest    *commons.RebalanceRequest    `json:"rebalanceRequest"`
+	// Events, NodeIds and ChannelIds are used by subscribe and unsubscribe
+	Events     []string `json:"events"`
+	NodeIds    []int    `json:"nodeIds"`
+	ChannelIds []int    `json:"channelIds"`
 }
 
 type Pong struct {
@@ -44,10 +53,25 @@ type wsError struct {
 	Error string `json:"error"`
 }
 
-func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel chan interface{}, req wsRequest) {
-	if req.Type == "ping" {
+func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel chan interface{},
+	subscriptions *wsSubscriptions, req wsRequest) {
+
+	switch req.Type {
+	case "ping":
 		webSocketChannel <- Pong{Message: "pong"}
 		return
+	case "subscribe":
+		err := subscriptions.subscribe(req.Events, req.NodeIds, req.ChannelIds)
+		if err != nil {
+			sendError(err, req, webSocketChannel)
+			return
+		}
+		webSocketChannel <- SubscriptionResponse{ReqId: req.ReqId, Type: "subscribed", Events: subscriptions.getEvents()}
+		return
+	case "unsubscribe":
+		subscriptions.unsubscribe(req.Events)
+		webSocketChannel <- SubscriptionResponse{ReqId: req.ReqId, Type: "unsubscribed", Events: subscriptions.getEvents()}
+		return
 	}
 
 	if req.ReqId == "" {
@@ -55,13 +79,50 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 		return
 	}
 
+	// All the remaining request types operate the node
+	if !auth.HasRole(c, auth.Operator) {
+		sendError(fmt.Errorf("the %s role is required for type: %s", auth.Operator, req.Type), req, webSocketChannel)
+		return
+	}
+	auth.AuditAction(c, req.Type, req.ReqId)
+
 	switch req.Type {
 	case "newPayment":
 		if req.NewPaymentRequest == nil {
 			sendError(fmt.Errorf("unknown NewPaymentRequest for type: %s", req.Type), req, webSocketChannel)
 			break
 		}
-		sendError(payments.SendNewPayment(eventChannel, db, c, *req.NewPaymentRequest, req.ReqId), req, webSocketChannel)
+		npReq := *req.NewPaymentRequest
+		actor := audit.ContextActor(c, "")
+		spendingRequest, err := spending.CheckPayment(db, eventChannel, actor, npReq, req.ReqId)
+		if err != nil || spendingRequest.Status == spending.Pending {
+			sendPendingApprovalOrError(err, spendingRequest, req, webSocketChannel)
+			break
+		}
+		err = audit.Execute(db, actor, audit.SendPayment, npReq.NodeId, nil, npReq, eventChannel,
+			func(eventChannel chan interface{}) error {
+				return payments.SendNewPayment(eventChannel, db, c, npReq, req.ReqId)
+			})
+		spending.Finish(db, spendingRequest, err)
+		sendError(err, req, webSocketChannel)
+	case "rebalance":
+		if req.RebalanceRequest == nil {
+			sendError(fmt.Errorf("unknown RebalanceRequest for type: %s", req.Type), req, webSocketChannel)
+			break
+		}
+		rbReq := *req.RebalanceRequest
+		actor := audit.ContextActor(c, "")
+		spendingRequest, err := spending.CheckRebalance(db, eventChannel, actor, rbReq, req.ReqId)
+		if err != nil || spendingRequest.Status == spending.Pending {
+			sendPendingApprovalOrError(err, spendingRequest, req, webSocketChannel)
+			break
+		}
+		err = audit.Execute(db, actor, audit.Rebalance, rbReq.NodeId, nil, rbReq, eventChannel,
+			func(eventChannel chan interface{}) error {
+				return rebalances.SendRebalance(eventChannel, db, c, rbReq, req.ReqId)
+			})
+		spending.Finish(db, spendingRequest, err)
+		sendError(err, req, webSocketChannel)
 	case "newAddress":
 		if req.NewAddressRequest == nil {
 			sendError(fmt.Errorf("unknown NewAddressRequest for type: %s", req.Type), req, webSocketChannel)
@@ -73,13 +134,30 @@ func processWsReq(db *sqlx.DB, c *gin.Context, eventChannel, webSocketChannel ch
 			sendError(fmt.Errorf("unknown CloseChannelRequest for type: %s", req.Type), req, webSocketChannel)
 			break
 		}
-		sendError(channels.CloseChannel(eventChannel, db, c, *req.CloseChannelRequest, req.ReqId), req, webSocketChannel)
+		ccReq := *req.CloseChannelRequest
+		err := audit.Execute(db, audit.ContextActor(c, ""), audit.CloseChannel, ccReq.NodeId, &ccReq.ChannelId, ccReq,
+			eventChannel, func(eventChannel chan interface{}) error {
+				return channels.CloseChannel(eventChannel, db, c, ccReq, req.ReqId)
+			})
+		sendError(err, req, webSocketChannel)
 	case "openChannel":
 		if req.OpenChannelRequest == nil {
 			sendError(fmt.Errorf("unknown OpenChannelRequest for type: %s", req.Type), req, webSocketChannel)
 			break
 		}
-		sendError(channels.OpenChannel(eventChannel, db, *req.OpenChannelRequest, req.ReqId), req, webSocketChannel)
+		ocReq := *req.OpenChannelRequest
+		actor := audit.ContextActor(c, "")
+		spendingRequest, err := spending.CheckChannelOpen(db, eventChannel, actor, ocReq, req.ReqId)
+		if err != nil || spendingRequest.Status == spending.Pending {
+			sendPendingApprovalOrError(err, spendingRequest, req, webSocketChannel)
+			break
+		}
+		err = audit.Execute(db, actor, audit.OpenChannel, ocReq.NodeId, nil, ocReq, eventChannel,
+			func(eventChannel chan interface{}) error {
+				return channels.OpenChannel(eventChannel, db, ocReq, req.ReqId)
+			})
+		spending.Finish(db, spendingRequest, err)
+		sendError(err, req, webSocketChannel)
 	default:
 		sendError(fmt.Errorf("unknown request type: %s", req.Type), req, webSocketChannel)
 	}
@@ -113,6 +191,7 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 	defer conn.Close()
 
 	webSocketChannel := make(chan interface{})
+	subscriptions := newWsSubscriptions()
 
 	done := make(chan struct{})
 	go func() {
@@ -128,7 +207,7 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 				log.Debug().Err(err).Msg("WebSocket Handshake Error.")
 				return
 			case nil:
-				go processWsReq(db, c, eventChannel, webSocketChannel, req)
+				go processWsReq(db, c, eventChannel, webSocketChannel, subscriptions, req)
 			default:
 				wsr := wsError{
 					ReqId: req.ReqId,
@@ -145,19 +224,24 @@ func WebsocketHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}
 		for event := range listener {
 			select {
 			case <-done:
-				broadcaster.CancelSubscription(listener)
+				cancelSubscription(broadcaster, listener)
 				return
 			default:
 			}
-			// TODO FIXME FILTER OUT ONLY THE EVENTS THE USER ACTUALLY WANTS!!!
-			if openChannelEvent, ok := event.(commons.OpenChannelResponse); ok {
-				webSocketChannel <- openChannelEvent
-			} else if closeChannelEvent, ok := event.(commons.CloseChannelResponse); ok {
-				webSocketChannel <- closeChannelEvent
-			} else if newAddressEvent, ok := event.(commons.NewAddressResponse); ok {
-				webSocketChannel <- newAddressEvent
-			} else if newPaymentEvent, ok := event.(commons.NewPaymentResponse); ok {
-				webSocketChannel <- newPaymentEvent
+			// Responses to requests are always sent, other events only when subscribed
+			switch event.(type) {
+			case commons.OpenChannelResponse, commons.CloseChannelResponse, commons.NewAddressResponse,
+				commons.NewPaymentResponse, commons.RebalanceResponse:
+			default:
+				if !subscriptions.accepts(event) {
+					continue
+				}
+			}
+			select {
+			case webSocketChannel <- event:
+			case <-done:
+				cancelSubscription(broadcaster, listener)
+				return
 			}
 		}
 	}()
@@ -185,3 +269,27 @@ func sendError(err error, req wsRequest, webSocketChannel chan interface{}) {
 		}
 	}
 }
+
+// sendPendingApprovalOrError tells the requester the request waits for approval, the responses are sent once it's
+// approved and executed
+func sendPendingApprovalOrError(err error, spendingRequest spending.SpendingRequest, req wsRequest,
+	webSocketChannel chan interface{}) {
+
+	if err != nil {
+		sendError(err, req, webSocketChannel)
+		return
+	}
+	webSocketChannel <- PendingApprovalResponse{
+		ReqId:             req.ReqId,
+		Type:              "PendingApproval",
+		SpendingRequestId: spendingRequest.SpendingRequestId,
+		Reasons:           spendingRequest.Reasons,
+	}
+}
+
+// cancelSubscription keeps draining the listener until it is closed because the broadcaster could be waiting to send
+func cancelSubscription(broadcaster broadcast.BroadcastServer, listener <-chan interface{}) {
+	go broadcaster.CancelSubscription(listener)
+	for range listener {
+	}
+}
@@ -0,0 +1,175 @@
+package torqsrv
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const (
+	channelEventName         = "ChannelEvent"
+	channelGraphEventName    = "ChannelGraphEvent"
+	nodeGraphEventName       = "NodeGraphEvent"
+	forwardEventName         = "ForwardEvent"
+	htlcEventName            = "HtlcEvent"
+	invoiceEventName         = "InvoiceEvent"
+	paymentEventName         = "PaymentEvent"
+	peerEventName            = "PeerEvent"
+	serviceEventName         = "ServiceEvent"
+	transactionEventName     = "TransactionEvent"
+	blockEventName           = "BlockEvent"
+	channelBalanceEventName  = "ChannelBalanceEvent"
+	spendingRequestEventName = "SpendingRequestEvent"
+)
+
+type SubscriptionResponse struct {
+	ReqId  string   `json:"reqId"`
+	Type   string   `json:"type"`
+	Events []string `json:"events"`
+}
+
+// wsSubscription when nodeIds or channelIds are empty that filter is not applied
+type wsSubscription struct {
+	nodeIds    map[int]bool
+	channelIds map[int]bool
+}
+
+// wsSubscriptions contains the events a websocket connection subscribed to (by event name)
+type wsSubscriptions struct {
+	mu            sync.RWMutex
+	subscriptions map[string]wsSubscription
+}
+
+func newWsSubscriptions() *wsSubscriptions {
+	return &wsSubscriptions{subscriptions: make(map[string]wsSubscription)}
+}
+
+// subscribe replaces the filter of the events
+func (s *wsSubscriptions) subscribe(events []string, nodeIds []int, channelIds []int) error {
+	if len(events) == 0 {
+		return fmt.Errorf("no events to subscribe to")
+	}
+	for _, event := range events {
+		if !isSubscribableEvent(event) {
+			return fmt.Errorf("unknown event: %s", event)
+		}
+	}
+	subscription := wsSubscription{nodeIds: make(map[int]bool), channelIds: make(map[int]bool)}
+	for _, nodeId := range nodeIds {
+		subscription.nodeIds[nodeId] = true
+	}
+	for _, channelId := range channelIds {
+		subscription.channelIds[channelId] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		s.subscriptions[event] = subscription
+	}
+	return nil
+}
+
+// unsubscribe removes the subscriptions of the events or all subscriptions when no events are provided
+func (s *wsSubscriptions) unsubscribe(events []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(events) == 0 {
+		s.subscriptions = make(map[string]wsSubscription)
+		return
+	}
+	for _, event := range events {
+		delete(s.subscriptions, event)
+	}
+}
+
+// getEvents returns the event names the connection is subscribed to
+func (s *wsSubscriptions) getEvents() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := make([]string, 0, len(s.subscriptions))
+	for event := range s.subscriptions {
+		events = append(events, event)
+	}
+	return events
+}
+
+// accepts returns true when the connection subscribed to the event and it passes the node and channel filter.
+// Events that are not related to a channel (i.e. PeerEvent) are not filtered by channelIds.
+func (s *wsSubscriptions) accepts(event interface{}) bool {
+	eventName, nodeId, channelIds := getEventDetails(event)
+	if eventName == "" {
+		return false
+	}
+	s.mu.RLock()
+	subscription, exists := s.subscriptions[eventName]
+	s.mu.RUnlock()
+	if !exists {
+		return false
+	}
+	if len(subscription.nodeIds) != 0 && !subscription.nodeIds[nodeId] {
+		return false
+	}
+	if len(subscription.channelIds) == 0 || len(channelIds) == 0 {
+		return true
+	}
+	for _, channelId := range channelIds {
+		if subscription.channelIds[channelId] {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubscribableEvent(event string) bool {
+	switch event {
+	case channelEventName, channelGraphEventName, nodeGraphEventName, forwardEventName, htlcEventName,
+		invoiceEventName, paymentEventName, peerEventName, serviceEventName, transactionEventName, blockEventName,
+		channelBalanceEventName, spendingRequestEventName:
+		return true
+	}
+	return false
+}
+
+// getEventDetails returns the event name, the node and the channels of the event
+func getEventDetails(event interface{}) (string, int, []int) {
+	switch e := event.(type) {
+	case commons.ChannelEvent:
+		return channelEventName, e.NodeId, []int{e.ChannelId}
+	case commons.ChannelGraphEvent:
+		return channelGraphEventName, e.NodeId, optionalChannelIds(e.ChannelId)
+	case commons.NodeGraphEvent:
+		return nodeGraphEventName, e.NodeId, nil
+	case commons.ForwardEvent:
+		return forwardEventName, e.NodeId, optionalChannelIds(e.IncomingChannelId, e.OutgoingChannelId)
+	case commons.HtlcEvent:
+		return htlcEventName, e.NodeId, optionalChannelIds(e.IncomingChannelId, e.OutgoingChannelId)
+	case commons.InvoiceEvent:
+		return invoiceEventName, e.NodeId, []int{e.ChannelId}
+	case commons.PaymentEvent:
+		return paymentEventName, e.NodeId, optionalChannelIds(e.IncomingChannelId, e.OutgoingChannelId)
+	case commons.PeerEvent:
+		return peerEventName, e.NodeId, nil
+	case commons.ServiceEvent:
+		return serviceEventName, e.NodeId, nil
+	case commons.TransactionEvent:
+		return transactionEventName, e.NodeId, nil
+	case commons.BlockEvent:
+		return blockEventName, e.NodeId, nil
+	case commons.ChannelBalanceEvent:
+		return channelBalanceEventName, e.NodeId, []int{e.ChannelId}
+	case commons.SpendingRequestEvent:
+		return spendingRequestEventName, e.NodeId, nil
+	}
+	return "", 0, nil
+}
+
+func optionalChannelIds(channelIds ...*int) []int {
+	var result []int
+	for _, channelId := range channelIds {
+		if channelId != nil && *channelId != 0 {
+			result = append(result, *channelId)
+		}
+	}
+	return result
+}
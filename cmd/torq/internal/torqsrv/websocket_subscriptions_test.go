@@ -0,0 +1,66 @@
+package torqsrv
+
+import (
+	"testing"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestWsSubscriptions(t *testing.T) {
+	incomingChannelId := 10
+	outgoingChannelId := 11
+	forwardEvent := commons.ForwardEvent{
+		EventData:         commons.EventData{NodeId: 1},
+		IncomingChannelId: &incomingChannelId,
+		OutgoingChannelId: &outgoingChannelId,
+	}
+	otherNodeForwardEvent := forwardEvent
+	otherNodeForwardEvent.NodeId = 2
+	peerEvent := commons.PeerEvent{EventData: commons.EventData{NodeId: 1}, EventNodeId: 5}
+	balanceEvent := commons.ChannelBalanceEvent{EventData: commons.EventData{NodeId: 1}, ChannelId: 12}
+
+	subscriptions := newWsSubscriptions()
+	if subscriptions.accepts(forwardEvent) {
+		t.Fatalf("expected no events without subscriptions")
+	}
+
+	if err := subscriptions.subscribe([]string{"ForwardEvent", "PeerEvent", "ChannelBalanceEvent"},
+		[]int{1}, []int{11}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !subscriptions.accepts(forwardEvent) {
+		t.Errorf("expected the forward of channel 11 to be accepted")
+	}
+	if subscriptions.accepts(otherNodeForwardEvent) {
+		t.Errorf("expected the forward of another node to be filtered")
+	}
+	if !subscriptions.accepts(peerEvent) {
+		t.Errorf("expected the peer event without channels to ignore the channel filter")
+	}
+	if subscriptions.accepts(balanceEvent) {
+		t.Errorf("expected the balance event of channel 12 to be filtered")
+	}
+	if subscriptions.accepts(commons.ChannelEvent{EventData: commons.EventData{NodeId: 1}, ChannelId: 11}) {
+		t.Errorf("expected the channel event to be filtered because there is no subscription")
+	}
+
+	subscriptions.unsubscribe([]string{"PeerEvent"})
+	if subscriptions.accepts(peerEvent) {
+		t.Errorf("expected the peer event to be filtered after unsubscribing")
+	}
+	if !subscriptions.accepts(forwardEvent) {
+		t.Errorf("expected the other subscriptions to remain")
+	}
+
+	subscriptions.unsubscribe(nil)
+	if len(subscriptions.getEvents()) != 0 {
+		t.Errorf("expected all subscriptions to be removed")
+	}
+
+	if err := subscriptions.subscribe([]string{"UnknownEvent"}, nil, nil); err == nil {
+		t.Errorf("expected an error for an unknown event")
+	}
+	if err := subscriptions.subscribe(nil, nil, nil); err == nil {
+		t.Errorf("expected an error without events")
+	}
+}
@@ -20,13 +20,16 @@ import (
 	"github.com/lncapital/torq/cmd/torq/internal/subscribe"
 	"github.com/lncapital/torq/cmd/torq/internal/torqsrv"
 	"github.com/lncapital/torq/cmd/torq/internal/vector_ping"
+	"github.com/lncapital/torq/internal/channel_risk"
 	"github.com/lncapital/torq/internal/channels"
 	"github.com/lncapital/torq/internal/corridors"
 	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/internal/maintenance"
+	"github.com/lncapital/torq/internal/metrics"
+	"github.com/lncapital/torq/internal/notifications"
 	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/broadcast"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 var eventChannelGlobal = make(chan interface{})                     //nolint:gochecknoglobals
@@ -68,6 +71,15 @@ func main() {
 			Name:  "torq.password",
 			Usage: "Password used to access the API and frontend.",
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  "torq.metrics-username",
+			Value: "torq",
+			Usage: "Username used to scrape the Prometheus metrics.",
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  "torq.metrics-password",
+			Usage: "Password used to scrape the Prometheus metrics. When empty a logged in session is required.",
+		}),
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:  "torq.port",
 			Value: "8080",
@@ -78,6 +90,11 @@ func main() {
 			Value: false,
 			Usage: "Start the server without subscribing to node data.",
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:  "torq.archive-path",
+			Value: homedir + "/.torq/archive",
+			Usage: "Path on disk where the retention policies archive old partitions",
+		}),
 
 		// Torq database
 		altsrc.NewStringFlag(&cli.StringFlag{
@@ -155,6 +172,7 @@ func main() {
 			commons.RunningServices[commons.VectorService] = &commons.Services{ServiceType: commons.VectorService}
 			commons.RunningServices[commons.AmbossService] = &commons.Services{ServiceType: commons.AmbossService}
 			commons.RunningServices[commons.TorqService] = &commons.Services{ServiceType: commons.TorqService}
+			commons.RunningServices[commons.MaintenanceService] = &commons.Services{ServiceType: commons.MaintenanceService}
 
 			ctxGlobal, cancelGlobal := context.WithCancel(context.Background())
 			defer cancelGlobal()
@@ -167,6 +185,15 @@ func main() {
 			go commons.ManagedNodeCache(commons.ManagedNodeChannel, ctxGlobal)
 			go commons.ManagedChannelCache(commons.ManagedChannelChannel, ctxGlobal)
 
+			// Sends the broadcasted events to the configured webhooks and chat notification sinks
+			go notifications.NotificationDispatcher(ctxGlobal, db, broadcasterGlobal)
+
+			// Counts the broadcasted forwards, failed HTLCs and payments for the Prometheus metrics
+			go metrics.EventCounter(ctxGlobal, broadcasterGlobal)
+
+			// Flags channels with HTLCs close to their expiry or with a peer that is offline for too long
+			go channel_risk.Monitor(ctxGlobal, db, broadcasterGlobal, eventChannelGlobal)
+
 			// This listens to events:
 			// When Torq has status initializing it loads the caches and starts the LndServices
 			// When Torq has status inactive a panic is created (i.e. migration failed)
@@ -206,6 +233,7 @@ func main() {
 										log.Error().Err(err).Msg("Torq cannot be initialized (Loading caches in memory).")
 									}
 									serviceChannel <- commons.ServiceChannelMessage{ServiceCommand: commons.Boot, ServiceType: commons.LndService}
+									serviceChannel <- commons.ServiceChannelMessage{ServiceCommand: commons.Boot, ServiceType: commons.MaintenanceService, NodeId: commons.TorqDummyNodeId}
 								}
 							}
 							if serviceEvent.Type == commons.LndService {
@@ -358,13 +386,10 @@ func main() {
 
 												log.Info().Msgf("Subscribing to LND for node id: %v", node.NodeId)
 												services.AddSubscription(node.NodeId, cancel, eventChannel)
-												conn, err := lnd_connect.Connect(
-													node.GRPCAddress,
-													node.TLSFileBytes,
-													node.MacaroonFileBytes,
-												)
+												services.SetContext(node.NodeId, ctx)
+												connection, err := settings.ConnectNode(node)
 												if err != nil {
-													log.Error().Err(err).Msgf("Failed to connect to lnd for node id: %v", node.NodeId)
+													log.Error().Err(err).Msgf("Failed to connect to the node for node id: %v", node.NodeId)
 													services.RemoveSubscription(node.NodeId, eventChannel)
 													log.Info().Msgf("LND Subscription will be restarted (when active) in 10 seconds for node id: %v", node.NodeId)
 													time.Sleep(10 * time.Second)
@@ -374,8 +399,9 @@ func main() {
 
 												services.Booted(node.NodeId, bootLock, eventChannel)
 												commons.RunningServices[commons.LndService].SetIncludeIncomplete(node.NodeId, node.HasNodeConnectionDetailCustomSettings(commons.ImportFailedPayments))
+												commons.RunningServices[commons.LndService].SetStorePeerChannelPolicies(node.NodeId, node.HasNodeConnectionDetailCustomSettings(commons.StorePeerChannelPolicies))
 												log.Info().Msgf("LND Subscription booted for node id: %v", node.NodeId)
-												err = subscribe.Start(ctx, conn, db, node.NodeId, broadcaster, eventChannel, serviceChannel)
+												err = subscribe.StartNode(ctx, connection, db, broadcaster, eventChannel, serviceChannel)
 												if err != nil {
 													log.Error().Err(err).Send()
 													// only log the error, don't return
@@ -455,10 +481,7 @@ func main() {
 
 											log.Info().Msgf("Generating Vector ping service for node id: %v", node.NodeId)
 											services.AddSubscription(node.NodeId, cancel, eventChannel)
-											conn, err := lnd_connect.Connect(
-												node.GRPCAddress,
-												node.TLSFileBytes,
-												node.MacaroonFileBytes)
+											conn, err := settings.ConnectLnd(node)
 											if err != nil {
 												log.Error().Err(err).Msgf("Failed to connect to lnd for node id: %v", node.NodeId)
 												services.RemoveSubscription(node.NodeId, eventChannel)
@@ -544,10 +567,7 @@ func main() {
 
 											log.Info().Msgf("Generating Amboss ping service for node id: %v", node.NodeId)
 											services.AddSubscription(node.NodeId, cancel, eventChannel)
-											conn, err := lnd_connect.Connect(
-												node.GRPCAddress,
-												node.TLSFileBytes,
-												node.MacaroonFileBytes)
+											conn, err := settings.ConnectLnd(node)
 											if err != nil {
 												log.Error().Err(err).Msgf("Failed to connect to lnd for node id: %v", node.NodeId)
 												services.RemoveSubscription(node.NodeId, eventChannel)
@@ -579,6 +599,57 @@ func main() {
 								serviceCmd.Out <- services.Cancel(serviceCmd.NodeId, serviceCmd.EnforcedServiceStatus, serviceCmd.NoDelay, eventChannel)
 							}
 						}
+						if serviceCmd.ServiceType == commons.MaintenanceService {
+							if serviceCmd.ServiceCommand == commons.Boot {
+								enforcedServiceStatus = services.GetEnforcedServiceStatusCheck(commons.TorqDummyNodeId)
+								if serviceCmd.EnforcedServiceStatus != nil {
+									enforcedServiceStatus = serviceCmd.EnforcedServiceStatus
+								}
+								if enforcedServiceStatus != nil && *enforcedServiceStatus == commons.Inactive {
+									log.Info().Msgf("Maintenance Service is disabled.")
+									continue
+								}
+								bootLock := services.GetBootLock(commons.TorqDummyNodeId)
+								successful := bootLock.TryLock()
+								if successful {
+									go (func(db *sqlx.DB, archivePath string, bootLock *sync.Mutex,
+										services *commons.Services,
+										serviceChannel chan commons.ServiceChannelMessage,
+										eventChannel chan interface{}) {
+										defer func() {
+											if commons.MutexLocked(bootLock) {
+												bootLock.Unlock()
+											}
+										}()
+										ctx := context.Background()
+										ctx, cancel := context.WithCancel(ctx)
+
+										log.Info().Msg("Generating Maintenance Service.")
+										services.AddSubscription(commons.TorqDummyNodeId, cancel, eventChannel)
+										services.Booted(commons.TorqDummyNodeId, bootLock, eventChannel)
+										log.Info().Msg("Maintenance Service booted.")
+										err := maintenance.Start(ctx, db, archivePath)
+										if err != nil {
+											log.Error().Err(err).Msg("Maintenance Service ended.")
+										}
+										log.Info().Msg("Maintenance Service stopped.")
+										services.RemoveSubscription(commons.TorqDummyNodeId, eventChannel)
+										if services.IsNoDelay(commons.TorqDummyNodeId) || serviceCmd.NoDelay {
+											log.Info().Msg("Maintenance Service will be restarted (when active).")
+										} else {
+											log.Info().Msgf("Maintenance Service will be restarted (when active) in %v seconds.", commons.SERVICES_ERROR_SLEEP_SECONDS)
+											time.Sleep(commons.SERVICES_ERROR_SLEEP_SECONDS * time.Second)
+										}
+										serviceChannel <- commons.ServiceChannelMessage{ServiceCommand: commons.Boot, ServiceType: serviceCmd.ServiceType, NodeId: commons.TorqDummyNodeId}
+									})(db, c.String("torq.archive-path"), bootLock, services, serviceChannel, eventChannel)
+								} else {
+									log.Error().Msgf("Requested Maintenance Service start failed. A start is already running.")
+								}
+							}
+							if serviceCmd.ServiceCommand == commons.Kill {
+								serviceCmd.Out <- services.Cancel(commons.TorqDummyNodeId, serviceCmd.EnforcedServiceStatus, serviceCmd.NoDelay, eventChannel)
+							}
+						}
 					}
 				})(db, serviceChannelGlobal, eventChannelGlobal, broadcasterGlobal)
 			} else {
@@ -591,7 +662,7 @@ func main() {
 			}
 
 			if err = torqsrv.Start(c.Int("torq.port"), c.String("torq.password"), c.String("torq.cookie-path"),
-				db, eventChannelGlobal, broadcasterGlobal, serviceChannelGlobal); err != nil {
+				c.String("torq.metrics-username"), c.String("torq.metrics-password"), db, eventChannelGlobal, broadcasterGlobal, serviceChannelGlobal); err != nil {
 				return errors.Wrap(err, "Starting torq webserver")
 			}
 
@@ -0,0 +1,230 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+type LedgerEntryType string
+
+const (
+	ForwardFee       = LedgerEntryType("FORWARD_FEE")
+	InvoiceSettled   = LedgerEntryType("INVOICE_SETTLED")
+	PaymentFee       = LedgerEntryType("PAYMENT_FEE")
+	RebalanceFee     = LedgerEntryType("REBALANCE_FEE")
+	OnChainFee       = LedgerEntryType("ON_CHAIN_FEE")
+	ChannelOpenCost  = LedgerEntryType("CHANNEL_OPEN_COST")
+	ChannelCloseCost = LedgerEntryType("CHANNEL_CLOSE_COST")
+)
+
+const (
+	lightningAssetsAccount      = "Assets:Lightning"
+	onChainAssetsAccount        = "Assets:On-Chain"
+	routingIncomeAccount        = "Income:Routing Fees"
+	invoiceIncomeAccount        = "Income:Invoices"
+	paymentFeeExpenseAccount    = "Expenses:Payment Fees"
+	rebalanceFeeExpenseAccount  = "Expenses:Rebalancing Fees"
+	onChainFeeExpenseAccount    = "Expenses:On-Chain Fees"
+	channelOpenExpenseAccount   = "Expenses:Channel Opening Fees"
+	channelCloseExpenseAccount  = "Expenses:Channel Closing Fees"
+	msatPerBtc                  = 100_000_000_000
+	csvContentType              = "text/csv"
+	csvContentDispositionFormat = "attachment; filename=%v"
+)
+
+// LedgerEntry AmountMsat is positive for income and negative for costs
+type LedgerEntry struct {
+	Time       time.Time       `json:"time" db:"time"`
+	NodeId     int             `json:"nodeId" db:"node_id"`
+	Type       LedgerEntryType `json:"type" db:"entry_type"`
+	AmountMsat int64           `json:"amountMsat" db:"amount_msat"`
+	Reference  string          `json:"reference" db:"reference"`
+	// FiatPrice the price of one BTC in the requested currency at the time of the entry
+	FiatPrice  *float64 `json:"fiatPrice,omitempty" db:"fiat_price"`
+	AmountBtc  string   `json:"amountBtc" db:"-"`
+	AmountFiat *float64 `json:"amountFiat,omitempty" db:"-"`
+}
+
+// JournalLine one line of a double-entry journal, every entry results in a debit and a credit line
+type JournalLine struct {
+	Time        time.Time       `json:"time"`
+	EntryNumber int             `json:"entryNumber"`
+	Account     string          `json:"account"`
+	Type        LedgerEntryType `json:"type"`
+	Reference   string          `json:"reference"`
+	DebitMsat   int64           `json:"debitMsat"`
+	CreditMsat  int64           `json:"creditMsat"`
+	DebitBtc    string          `json:"debitBtc"`
+	CreditBtc   string          `json:"creditBtc"`
+	DebitFiat   *float64        `json:"debitFiat,omitempty"`
+	CreditFiat  *float64        `json:"creditFiat,omitempty"`
+}
+
+// prepareLedger converts the times to the location and adds the BTC and fiat amounts
+func prepareLedger(entries []LedgerEntry, location *time.Location) []LedgerEntry {
+	for i := range entries {
+		entries[i].Time = entries[i].Time.In(location)
+		entries[i].AmountBtc = formatBtc(entries[i].AmountMsat)
+		entries[i].AmountFiat = toFiat(entries[i].AmountMsat, entries[i].FiatPrice)
+	}
+	return entries
+}
+
+// buildJournal nodeAccountNames contains the account name suffix of each node (i.e. Assets:Lightning:<alias>)
+func buildJournal(entries []LedgerEntry, nodeAccountNames map[int]string) []JournalLine {
+	journal := make([]JournalLine, 0, len(entries)*2)
+	for i, entry := range entries {
+		debitAccount, creditAccount := getAccounts(entry.Type)
+		if entry.Type == ForwardFee || entry.Type == InvoiceSettled {
+			debitAccount = nodeAccount(debitAccount, entry.NodeId, nodeAccountNames)
+		} else {
+			creditAccount = nodeAccount(creditAccount, entry.NodeId, nodeAccountNames)
+		}
+		amountMsat := entry.AmountMsat
+		if amountMsat < 0 {
+			amountMsat = -amountMsat
+		}
+		line := JournalLine{
+			Time:        entry.Time,
+			EntryNumber: i + 1,
+			Type:        entry.Type,
+			Reference:   entry.Reference,
+		}
+		debit := line
+		debit.Account = debitAccount
+		debit.DebitMsat = amountMsat
+		debit.DebitBtc = formatBtc(amountMsat)
+		debit.DebitFiat = toFiat(amountMsat, entry.FiatPrice)
+		credit := line
+		credit.Account = creditAccount
+		credit.CreditMsat = amountMsat
+		credit.CreditBtc = formatBtc(amountMsat)
+		credit.CreditFiat = toFiat(amountMsat, entry.FiatPrice)
+		journal = append(journal, debit, credit)
+	}
+	return journal
+}
+
+// getAccounts returns the debit and the credit account of the entry type
+func getAccounts(entryType LedgerEntryType) (string, string) {
+	switch entryType {
+	case ForwardFee:
+		return lightningAssetsAccount, routingIncomeAccount
+	case InvoiceSettled:
+		return lightningAssetsAccount, invoiceIncomeAccount
+	case PaymentFee:
+		return paymentFeeExpenseAccount, lightningAssetsAccount
+	case RebalanceFee:
+		return rebalanceFeeExpenseAccount, lightningAssetsAccount
+	case ChannelOpenCost:
+		return channelOpenExpenseAccount, onChainAssetsAccount
+	case ChannelCloseCost:
+		return channelCloseExpenseAccount, onChainAssetsAccount
+	}
+	return onChainFeeExpenseAccount, onChainAssetsAccount
+}
+
+func nodeAccount(account string, nodeId int, nodeAccountNames map[int]string) string {
+	if name, exists := nodeAccountNames[nodeId]; exists && name != "" {
+		return account + ":" + name
+	}
+	return account + ":" + strconv.Itoa(nodeId)
+}
+
+// writeLedgerCsv when the currency is empty the fiat column is omitted
+func writeLedgerCsv(w io.Writer, entries []LedgerEntry, currency string) error {
+	writer := csv.NewWriter(w)
+	header := []string{"time", "node_id", "type", "reference", "amount_msat", "amount_btc"}
+	if currency != "" {
+		header = append(header, "amount_"+currency)
+	}
+	if err := writer.Write(header); err != nil {
+		return errors.Wrap(err, "Writing ledger csv header")
+	}
+	for _, entry := range entries {
+		record := []string{
+			entry.Time.Format(time.RFC3339),
+			strconv.Itoa(entry.NodeId),
+			string(entry.Type),
+			entry.Reference,
+			strconv.FormatInt(entry.AmountMsat, 10),
+			entry.AmountBtc,
+		}
+		if currency != "" {
+			record = append(record, formatFiat(entry.AmountFiat))
+		}
+		if err := writer.Write(record); err != nil {
+			return errors.Wrap(err, "Writing ledger csv record")
+		}
+	}
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "Flushing ledger csv")
+}
+
+// writeJournalCsv when the currency is empty the fiat columns are omitted
+func writeJournalCsv(w io.Writer, journal []JournalLine, currency string) error {
+	writer := csv.NewWriter(w)
+	header := []string{"entry", "time", "account", "type", "reference", "debit_btc", "credit_btc"}
+	if currency != "" {
+		header = append(header, "debit_"+currency, "credit_"+currency)
+	}
+	if err := writer.Write(header); err != nil {
+		return errors.Wrap(err, "Writing journal csv header")
+	}
+	for _, line := range journal {
+		record := []string{
+			strconv.Itoa(line.EntryNumber),
+			line.Time.Format(time.RFC3339),
+			line.Account,
+			string(line.Type),
+			line.Reference,
+			emptyWhenZero(line.DebitMsat, line.DebitBtc),
+			emptyWhenZero(line.CreditMsat, line.CreditBtc),
+		}
+		if currency != "" {
+			record = append(record, formatFiat(line.DebitFiat), formatFiat(line.CreditFiat))
+		}
+		if err := writer.Write(record); err != nil {
+			return errors.Wrap(err, "Writing journal csv record")
+		}
+	}
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "Flushing journal csv")
+}
+
+// formatBtc formats the msat amount as BTC without losing precision (11 decimals)
+func formatBtc(amountMsat int64) string {
+	sign := ""
+	if amountMsat < 0 {
+		sign = "-"
+		amountMsat = -amountMsat
+	}
+	return fmt.Sprintf("%v%d.%011d", sign, amountMsat/msatPerBtc, amountMsat%msatPerBtc)
+}
+
+func toFiat(amountMsat int64, fiatPrice *float64) *float64 {
+	if fiatPrice == nil {
+		return nil
+	}
+	amount := float64(amountMsat) / msatPerBtc * *fiatPrice
+	return &amount
+}
+
+func formatFiat(amount *float64) string {
+	if amount == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*amount, 'f', 2, 64)
+}
+
+func emptyWhenZero(amountMsat int64, value string) string {
+	if amountMsat == 0 {
+		return ""
+	}
+	return value
+}
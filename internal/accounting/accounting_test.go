@@ -0,0 +1,106 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestFormatBtc(t *testing.T) {
+	tests := []struct {
+		amountMsat int64
+		expected   string
+	}{
+		{0, "0.00000000000"},
+		{1, "0.00000000001"},
+		{1_000, "0.00000001000"},
+		{150_000_000_000, "1.50000000000"},
+		{-2_500, "-0.00000002500"},
+	}
+	for _, test := range tests {
+		if result := formatBtc(test.amountMsat); result != test.expected {
+			t.Errorf("formatBtc(%v) expected %v, got %v", test.amountMsat, test.expected, result)
+		}
+	}
+}
+
+func TestBuildJournal(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Brussels")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	price := 20000.0
+	nextDayPrice := 30000.0
+	entries := prepareLedger([]LedgerEntry{
+		{Time: time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC), NodeId: 1, Type: ForwardFee, AmountMsat: 5_000,
+			FiatPrice: &price},
+		{Time: time.Date(2022, 11, 1, 11, 0, 0, 0, time.UTC), NodeId: 1, Type: RebalanceFee, AmountMsat: -2_000,
+			FiatPrice: &price},
+		{Time: time.Date(2022, 11, 2, 9, 0, 0, 0, time.UTC), NodeId: 2, Type: ChannelOpenCost, AmountMsat: -1_500_000,
+			FiatPrice: &nextDayPrice},
+	}, location)
+
+	if entries[0].Time.Location() != location || entries[0].Time.Hour() != 11 {
+		t.Errorf("expected the time to be converted to the preferred time zone, got %v", entries[0].Time)
+	}
+	if entries[1].AmountBtc != "-0.00000002000" {
+		t.Errorf("unexpected BTC amount %v", entries[1].AmountBtc)
+	}
+
+	journal := buildJournal(entries, map[int]string{1: "mynode"})
+	if len(journal) != 6 {
+		t.Fatalf("expected a debit and a credit line per entry, got %v lines", len(journal))
+	}
+	expectedAccounts := []string{
+		lightningAssetsAccount + ":mynode", routingIncomeAccount,
+		rebalanceFeeExpenseAccount, lightningAssetsAccount + ":mynode",
+		channelOpenExpenseAccount, onChainAssetsAccount + ":2",
+	}
+	var debitMsat, creditMsat int64
+	for i, line := range journal {
+		if line.Account != expectedAccounts[i] {
+			t.Errorf("line %v expected account %v, got %v", i, expectedAccounts[i], line.Account)
+		}
+		debitMsat += line.DebitMsat
+		creditMsat += line.CreditMsat
+	}
+	if debitMsat != creditMsat || debitMsat != 1_507_000 {
+		t.Errorf("expected a balanced journal of 1507000 msat, got debit %v and credit %v", debitMsat, creditMsat)
+	}
+	// The fiat amount uses the price at the time of the entry
+	if journal[4].DebitFiat == nil || *journal[4].DebitFiat != 0.45 {
+		t.Errorf("expected a fiat amount of 0.45")
+	}
+
+	var buffer bytes.Buffer
+	if err = writeJournalCsv(&buffer, journal, "EUR"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read the journal csv: %v", err)
+	}
+	if len(records) != 7 || len(records[0]) != 9 || records[0][7] != "debit_EUR" {
+		t.Fatalf("unexpected journal csv header %v", records[0])
+	}
+	if records[2][5] != "" || records[2][6] != "0.00000005000" || records[2][8] != "0.00" {
+		t.Errorf("unexpected credit line %v", records[2])
+	}
+}
+
+func TestWriteLedgerCsvWithoutFiat(t *testing.T) {
+	entries := prepareLedger([]LedgerEntry{
+		{Time: time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC), NodeId: 1, Type: InvoiceSettled,
+			AmountMsat: 1_000_000, Reference: "hash"},
+	}, time.UTC)
+	var buffer bytes.Buffer
+	if err := writeLedgerCsv(&buffer, entries, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "time,node_id,type,reference,amount_msat,amount_btc\n" +
+		"2022-11-01T10:00:00Z,1,INVOICE_SETTLED,hash,1000000,0.00001000000\n"
+	if buffer.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buffer.String())
+	}
+}
@@ -0,0 +1,70 @@
+package accounting
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/internal/prices"
+)
+
+// getLedgerEntries returns the chronological ledger of the nodes. from and to are dates in the time zone.
+// Payments to one of our own nodes are rebalances (or internal transfers) so only the fee is a cost and the
+// settled invoice on the receiving node is not income.
+// On-chain costs are the fees of the transactions (like channel_history.getTotalOnChainCost), the open and close
+// channel transactions are recognised by their label.
+func getLedgerEntries(db *sqlx.DB, nodeIds []int, publicKeys []string, from time.Time, to time.Time,
+	timeZone string, currency *string) ([]LedgerEntry, error) {
+
+	var entries []LedgerEntry
+	err := db.Select(&entries, `
+		WITH internal_payment AS (
+			SELECT payment_hash
+			FROM payment
+			WHERE status = 'SUCCEEDED' AND
+				(payment_hash IN (SELECT payment_hash FROM rebalance_result WHERE status = 'SUCCEEDED') OR
+				htlcs->-1->'route'->'hops'->-1->>'pub_key' = ANY($4))
+		)
+		SELECT time, node_id, entry_type, amount_msat, reference,
+			`+prices.FiatPriceSql("ledger.time", "$6")+` AS fiat_price
+		FROM (
+			SELECT f.time, f.node_id, 'FORWARD_FEE' AS entry_type, ROUND(f.fee_msat)::BIGINT AS amount_msat,
+				f.incoming_short_channel_id || ' > ' || f.outgoing_short_channel_id AS reference
+			FROM forward f
+			WHERE f.node_id = ANY($3) AND f.fee_msat > 0 AND
+				f.time >= $1::timestamp AT TIME ZONE $5 AND f.time < $2::timestamp AT TIME ZONE $5
+			UNION ALL
+			SELECT p.creation_timestamp, p.node_id,
+				CASE WHEN p.payment_hash IN (SELECT payment_hash FROM internal_payment)
+					THEN 'REBALANCE_FEE' ELSE 'PAYMENT_FEE' END,
+				-ROUND(p.fee_msat)::BIGINT, p.payment_hash
+			FROM payment p
+			WHERE p.node_id = ANY($3) AND p.status = 'SUCCEEDED' AND p.fee_msat > 0 AND
+				p.creation_timestamp >= $1::timestamp AT TIME ZONE $5 AND
+				p.creation_timestamp < $2::timestamp AT TIME ZONE $5
+			UNION ALL
+			SELECT i.settle_date, i.node_id, 'INVOICE_SETTLED', ROUND(i.amt_paid_msat)::BIGINT, i.r_hash
+			FROM invoice i
+			WHERE i.node_id = ANY($3) AND i.invoice_state = 'SETTLED' AND
+				i.r_hash NOT IN (SELECT payment_hash FROM internal_payment) AND
+				i.settle_date >= $1::timestamp AT TIME ZONE $5 AND i.settle_date < $2::timestamp AT TIME ZONE $5
+			UNION ALL
+			SELECT t.timestamp, t.node_id,
+				CASE WHEN t.label LIKE '%openchannel%' THEN 'CHANNEL_OPEN_COST'
+					WHEN t.label LIKE '%closechannel%' THEN 'CHANNEL_CLOSE_COST'
+					ELSE 'ON_CHAIN_FEE' END,
+				-ROUND(t.total_fees * 1000)::BIGINT, t.tx_hash
+			FROM tx t
+			WHERE t.node_id = ANY($3) AND t.total_fees > 0 AND
+				t.timestamp >= $1::timestamp AT TIME ZONE $5 AND t.timestamp < $2::timestamp AT TIME ZONE $5
+		) AS ledger
+		ORDER BY time, entry_type, reference;`,
+		from, to, pq.Array(nodeIds), pq.Array(publicKeys), timeZone, currency)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return entries, nil
+}
@@ -0,0 +1,168 @@
+package accounting
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/prices"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+type exportParameters struct {
+	NodeIds  []int
+	From     time.Time
+	To       time.Time
+	Csv      bool
+	Currency *string
+}
+
+func getLedgerHandler(c *gin.Context, db *sqlx.DB) {
+	params, message, err := getExportParameters(c, db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting currency")
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+	entries, location, err := getLedger(db, params)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting ledger")
+		return
+	}
+	entries = prepareLedger(entries, location)
+	if !params.Csv {
+		c.JSON(http.StatusOK, entries)
+		return
+	}
+	c.Header("Content-Type", csvContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(csvContentDispositionFormat, exportFileName("ledger", params)))
+	if err = writeLedgerCsv(c.Writer, entries, params.currencyName()); err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Writing ledger csv")
+		return
+	}
+}
+
+func getJournalHandler(c *gin.Context, db *sqlx.DB) {
+	params, message, err := getExportParameters(c, db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting currency")
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+	entries, location, err := getLedger(db, params)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting ledger")
+		return
+	}
+	entries = prepareLedger(entries, location)
+	journal := buildJournal(entries, getNodeAccountNames(params.NodeIds))
+	if !params.Csv {
+		c.JSON(http.StatusOK, journal)
+		return
+	}
+	c.Header("Content-Type", csvContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(csvContentDispositionFormat, exportFileName("journal", params)))
+	if err = writeJournalCsv(c.Writer, journal, params.currencyName()); err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Writing journal csv")
+		return
+	}
+}
+
+func getLedger(db *sqlx.DB, params exportParameters) ([]LedgerEntry, *time.Location, error) {
+	timeZone := commons.GetSettings().PreferredTimeZone
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	var publicKeys []string
+	for _, nodeId := range params.NodeIds {
+		publicKeys = append(publicKeys, commons.GetNodeSettingsByNodeId(nodeId).PublicKey)
+	}
+	entries, err := getLedgerEntries(db, params.NodeIds, publicKeys, params.From, params.To, timeZone, params.Currency)
+	return entries, location, err
+}
+
+func getNodeAccountNames(nodeIds []int) map[int]string {
+	nodeAccountNames := make(map[int]string)
+	for _, nodeId := range nodeIds {
+		nodeSettings := commons.GetNodeSettingsByNodeId(nodeId)
+		if nodeSettings.Name != nil && *nodeSettings.Name != "" {
+			nodeAccountNames[nodeId] = *nodeSettings.Name
+			continue
+		}
+		nodeAccountNames[nodeId] = nodeSettings.PublicKey
+	}
+	return nodeAccountNames
+}
+
+func (params exportParameters) currencyName() string {
+	if params.Currency == nil {
+		return ""
+	}
+	return *params.Currency
+}
+
+func exportFileName(export string, params exportParameters) string {
+	return fmt.Sprintf("torq-%v-%v-%v.csv", export,
+		params.From.Format("2006-01-02"), params.To.AddDate(0, 0, -1).Format("2006-01-02"))
+}
+
+// getExportParameters parses the from, to (both yyyy-mm-dd and inclusive), network, optional nodeId,
+// format (json or csv) and optional currency query parameters.
+func getExportParameters(c *gin.Context, db *sqlx.DB) (exportParameters, string, error) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		return exportParameters{}, "Can't process from", nil
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		return exportParameters{}, "Can't process to", nil
+	}
+	if to.Before(from) {
+		return exportParameters{}, "To must be on or after from", nil
+	}
+	params := exportParameters{From: from, To: to.AddDate(0, 0, 1)}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+	case "csv":
+		params.Csv = true
+	default:
+		return exportParameters{}, "Format must be json or csv", nil
+	}
+
+	currency, message, err := prices.GetCurrencyParameter(c, db)
+	if err != nil || message != "" {
+		return exportParameters{}, message, err
+	}
+	params.Currency = currency
+
+	if c.Query("network") == "" {
+		return exportParameters{}, "Network missing", nil
+	}
+	network, err := strconv.Atoi(c.Query("network"))
+	if err != nil {
+		return exportParameters{}, "Can't process network", nil
+	}
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			return exportParameters{}, "Can't process nodeId", nil
+		}
+		params.NodeIds = []int{nodeId}
+		return params, "", nil
+	}
+	params.NodeIds = commons.GetAllTorqNodeIds(commons.Bitcoin, commons.Network(network))
+	return params, "", nil
+}
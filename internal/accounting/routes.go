@@ -0,0 +1,11 @@
+package accounting
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+func RegisterAccountingRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("ledger", func(c *gin.Context) { getLedgerHandler(c, db) })
+	r.GET("journal", func(c *gin.Context) { getJournalHandler(c, db) })
+}
@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/auth"
+)
+
+type Action string
+
+const (
+	OpenChannel       = Action("OPEN_CHANNEL")
+	BatchOpenChannels = Action("BATCH_OPEN_CHANNELS")
+	CloseChannel      = Action("CLOSE_CHANNEL")
+	UpdateChannels    = Action("UPDATE_CHANNELS")
+	SendPayment       = Action("SEND_PAYMENT")
+	PayOnChain        = Action("PAY_ON_CHAIN")
+	ConnectPeer       = Action("CONNECT_PEER")
+	BumpFee           = Action("BUMP_FEE")
+	Rebalance         = Action("REBALANCE")
+)
+
+type Status string
+
+const (
+	Requested = Status("REQUESTED")
+	Succeeded = Status("SUCCEEDED")
+	Failed    = Status("FAILED")
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys json keys (lowercase) that contain one of these are redacted before they are stored
+var secretKeys = []string{"password", "macaroon", "secret", "preimage", "seed", "token", "psbt", "tls"} //nolint:gochecknoglobals
+
+type AuditEvent struct {
+	AuditEventId        int             `json:"auditEventId" db:"audit_event_id"`
+	RequestAuditEventId *int            `json:"requestAuditEventId" db:"request_audit_event_id"`
+	Action              Action          `json:"action" db:"action"`
+	Status              Status          `json:"status" db:"status"`
+	Actor               string          `json:"actor" db:"actor"`
+	UserId              *int            `json:"userId" db:"torq_user_id"`
+	ApiTokenId          *int            `json:"apiTokenId" db:"api_token_id"`
+	NodeId              *int            `json:"nodeId" db:"node_id"`
+	ChannelId           *int            `json:"channelId" db:"channel_id"`
+	Payload             *types.JSONText `json:"payload" db:"payload"`
+	Error               *string         `json:"error" db:"error"`
+	CreatedOn           time.Time       `json:"createdOn" db:"created_on"`
+}
+
+// AuditedAction a REQUESTED event with its outcome (when there is one)
+type AuditedAction struct {
+	AuditEventId int             `json:"auditEventId" db:"audit_event_id"`
+	Action       Action          `json:"action" db:"action"`
+	Status       Status          `json:"status" db:"status"`
+	Actor        string          `json:"actor" db:"actor"`
+	UserId       *int            `json:"userId" db:"user_id"`
+	ApiTokenId   *int            `json:"apiTokenId" db:"api_token_id"`
+	NodeId       *int            `json:"nodeId" db:"node_id"`
+	ChannelId    *int            `json:"channelId" db:"channel_id"`
+	Force        bool            `json:"force" db:"force"`
+	Request      *types.JSONText `json:"request" db:"request"`
+	Response     *types.JSONText `json:"response" db:"response"`
+	Error        *string         `json:"error" db:"error"`
+	RequestedOn  time.Time       `json:"requestedOn" db:"requested_on"`
+	CompletedOn  *time.Time      `json:"completedOn" db:"completed_on"`
+}
+
+// ContextActor returns the authenticated actor of the request, when there is none the automation is the actor
+func ContextActor(c *gin.Context, automation string) auth.Actor {
+	if c != nil {
+		if actor, ok := auth.GetActor(c); ok {
+			return actor
+		}
+	}
+	return AutomationActor(automation)
+}
+
+// AutomationActor is the actor of actions that are triggered by Torq itself (i.e. workflows, fee policies)
+func AutomationActor(automation string) auth.Actor {
+	return auth.Actor{Username: automation}
+}
+
+// Start records the request of the action, the returned event is used by Finish to record the outcome.
+// When the request can't be recorded the action should not be executed.
+func Start(db *sqlx.DB, actor auth.Actor, action Action, nodeId int, channelId *int,
+	request interface{}) (AuditEvent, error) {
+
+	payload, err := redact(request)
+	if err != nil {
+		return AuditEvent{}, errors.Wrapf(err, "Redacting the %v request", action)
+	}
+	event := AuditEvent{
+		Action:     action,
+		Status:     Requested,
+		Actor:      actor.Username,
+		UserId:     actor.UserId,
+		ApiTokenId: actor.ApiTokenId,
+		ChannelId:  channelId,
+		Payload:    payload,
+		CreatedOn:  time.Now().UTC(),
+	}
+	if nodeId != 0 {
+		event.NodeId = &nodeId
+	}
+	return addAuditEvent(db, event)
+}
+
+// Finish records the outcome of the action, the response can be nil
+func Finish(db *sqlx.DB, requestEvent AuditEvent, response interface{}, actionErr error) {
+	event := requestEvent
+	event.RequestAuditEventId = &requestEvent.AuditEventId
+	event.Status = Succeeded
+	event.Error = nil
+	event.CreatedOn = time.Now().UTC()
+	if actionErr != nil {
+		event.Status = Failed
+		errorMessage := actionErr.Error()
+		event.Error = &errorMessage
+	}
+	payload, err := redact(response)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to redact the response of audit event %v", requestEvent.AuditEventId)
+		payload = nil
+	}
+	event.Payload = payload
+	if _, err = addAuditEvent(db, event); err != nil {
+		log.Error().Err(err).Msgf("Failed to record the outcome of audit event %v", requestEvent.AuditEventId)
+	}
+}
+
+// redact returns the value as json where the values of the secret keys are replaced, nil when the value is nil
+func redact(value interface{}) (*types.JSONText, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "JSON marshal")
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	var generic interface{}
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return nil, errors.Wrap(err, "JSON unmarshal")
+	}
+	data, err = json.Marshal(redactValue(generic))
+	if err != nil {
+		return nil, errors.Wrap(err, "JSON marshal")
+	}
+	payload := types.JSONText(data)
+	return &payload, nil
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if isSecretKey(key) && nested != nil {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(nested)
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+	}
+	return value
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secretKey := range secretKeys {
+		if strings.Contains(key, secretKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseRecorder forwards the responses that are sent to its EventChannel to the event channel and keeps them
+// so they can be recorded as outcome.
+type ResponseRecorder struct {
+	events    chan interface{}
+	done      chan struct{}
+	responses []interface{}
+}
+
+// NewResponseRecorder the eventChannel can be nil
+func NewResponseRecorder(eventChannel chan interface{}) *ResponseRecorder {
+	recorder := &ResponseRecorder{events: make(chan interface{}), done: make(chan struct{})}
+	go func() {
+		defer close(recorder.done)
+		for event := range recorder.events {
+			recorder.responses = append(recorder.responses, event)
+			if eventChannel != nil {
+				eventChannel <- event
+			}
+		}
+	}()
+	return recorder
+}
+
+func (recorder *ResponseRecorder) EventChannel() chan interface{} {
+	return recorder.events
+}
+
+// Close returns the recorded responses, the EventChannel can't be used after Close
+func (recorder *ResponseRecorder) Close() []interface{} {
+	close(recorder.events)
+	<-recorder.done
+	return recorder.responses
+}
+
+// Execute records the request, executes the action with an event channel that records its responses and records
+// the outcome. The action is not executed when the request can't be recorded.
+func Execute(db *sqlx.DB, actor auth.Actor, action Action, nodeId int, channelId *int, request interface{},
+	eventChannel chan interface{}, execute func(eventChannel chan interface{}) error) error {
+
+	requestEvent, err := Start(db, actor, action, nodeId, channelId, request)
+	if err != nil {
+		return errors.Wrap(err, "Recording audit event")
+	}
+	recorder := NewResponseRecorder(eventChannel)
+	err = execute(recorder.EventChannel())
+	Finish(db, requestEvent, recorder.Close(), err)
+	return err
+}
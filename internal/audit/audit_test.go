@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestRedact(t *testing.T) {
+	password := "hunter2"
+	payload, err := redact(map[string]interface{}{
+		"nodeId":   1,
+		"password": password,
+		"htlcs": []interface{}{
+			map[string]interface{}{"preimage": "abcd", "amountMsat": 1000},
+		},
+		"macaroonHex": nil,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var result map[string]interface{}
+	if err = json.Unmarshal(*payload, &result); err != nil {
+		t.Fatalf("failed to unmarshal the payload: %v", err)
+	}
+	if result["password"] != redacted || result["nodeId"] != float64(1) || result["macaroonHex"] != nil {
+		t.Errorf("unexpected redacted payload %v", string(*payload))
+	}
+	htlc := result["htlcs"].([]interface{})[0].(map[string]interface{})
+	if htlc["preimage"] != redacted || htlc["amountMsat"] != float64(1000) {
+		t.Errorf("expected nested secrets to be redacted, got %v", string(*payload))
+	}
+
+	force := true
+	payload, err = redact(commons.CloseChannelRequest{NodeId: 1, ChannelId: 12, Force: &force})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"channelId":12,"deliveryAddress":null,"force":true,"nodeId":1,"satPerVbyte":null,"targetConf":null}`
+	if string(*payload) != expected {
+		t.Errorf("expected %v, got %v", expected, string(*payload))
+	}
+
+	var responses []interface{}
+	if payload, err = redact(responses); err != nil || payload != nil {
+		t.Errorf("expected no payload for nil, got %v (%v)", payload, err)
+	}
+}
+
+func TestResponseRecorder(t *testing.T) {
+	eventChannel := make(chan interface{}, 2)
+	recorder := NewResponseRecorder(eventChannel)
+	recorder.EventChannel() <- commons.CloseChannelResponse{ReqId: "1", Status: commons.Closing}
+	recorder.EventChannel() <- commons.CloseChannelResponse{ReqId: "1", Status: commons.CooperativeClosed}
+	responses := recorder.Close()
+	if len(responses) != 2 || len(eventChannel) != 2 {
+		t.Fatalf("expected 2 recorded and forwarded responses, got %v and %v", len(responses), len(eventChannel))
+	}
+	if (<-eventChannel).(commons.CloseChannelResponse).Status != commons.Closing {
+		t.Errorf("expected the responses to be forwarded in order")
+	}
+
+	recorder = NewResponseRecorder(nil)
+	recorder.EventChannel() <- commons.NewPaymentResponse{ReqId: "2"}
+	if responses = recorder.Close(); len(responses) != 1 {
+		t.Errorf("expected the response to be recorded without event channel")
+	}
+}
+
+func TestContextActor(t *testing.T) {
+	actor := ContextActor(nil, "workflow 3")
+	if actor.Username != "workflow 3" || actor.UserId != nil || actor.Role != auth.Role("") {
+		t.Errorf("unexpected automation actor %+v", actor)
+	}
+}
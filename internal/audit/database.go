@@ -0,0 +1,81 @@
+package audit
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func addAuditEvent(db *sqlx.DB, event AuditEvent) (AuditEvent, error) {
+	err := db.QueryRowx(`INSERT INTO audit_event (request_audit_event_id, action, status, actor, torq_user_id,
+			api_token_id, node_id, channel_id, payload, error, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING audit_event_id;`,
+		event.RequestAuditEventId, event.Action, event.Status, event.Actor, event.UserId,
+		event.ApiTokenId, event.NodeId, event.ChannelId, event.Payload, event.Error, event.CreatedOn).
+		Scan(&event.AuditEventId)
+	if err != nil {
+		return AuditEvent{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return event, nil
+}
+
+// auditedActionsQuery combines every REQUESTED event with its outcome, an action without outcome has the status
+// REQUESTED (i.e. it's still running or Torq stopped before it finished).
+func auditedActionsQuery() sq.SelectBuilder {
+	return sq.Select(`
+			request.audit_event_id,
+			request.action,
+			COALESCE(outcome.status, request.status) AS status,
+			request.actor,
+			request.torq_user_id AS user_id,
+			request.api_token_id,
+			request.node_id,
+			request.channel_id,
+			COALESCE(request.payload->>'force' = 'true', false) AS force,
+			request.payload AS request,
+			outcome.payload AS response,
+			outcome.error,
+			request.created_on AS requested_on,
+			outcome.created_on AS completed_on
+		`).
+		From("audit_event request").
+		LeftJoin("audit_event outcome ON outcome.request_audit_event_id = request.audit_event_id").
+		Where(sq.Eq{"request.request_audit_event_id": nil})
+}
+
+func getAuditedActions(db *sqlx.DB, filter sq.Sqlizer, order []string, limit uint64,
+	offset uint64) ([]AuditedAction, uint64, error) {
+
+	qb := sq.Select("*").
+		FromSelect(auditedActionsQuery(), "subquery").
+		PlaceholderFormat(sq.Dollar).
+		Where(filter).
+		OrderBy(order...)
+	if limit > 0 {
+		qb = qb.Limit(limit).Offset(offset)
+	}
+	qs, args, err := qb.ToSql()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "Compiling SQL")
+	}
+	auditedActions := []AuditedAction{}
+	if err = db.Select(&auditedActions, qs, args...); err != nil {
+		return nil, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+
+	totalQs, args, err := sq.Select("count(*) AS total").
+		FromSelect(auditedActionsQuery(), "subquery").
+		PlaceholderFormat(sq.Dollar).
+		Where(filter).
+		ToSql()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "Compiling SQL")
+	}
+	var total uint64
+	if err = db.QueryRowx(totalQs, args...).Scan(&total); err != nil {
+		return nil, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return auditedActions, total, nil
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	qp "github.com/lncapital/torq/internal/query_parser"
+	ah "github.com/lncapital/torq/pkg/api_helpers"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterAuditRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	// i.e. who force closed channelId 12:
+	// filter={"$and":[{"$filter":{"funcName":"eq","key":"action","parameter":"CLOSE_CHANNEL"}},
+	//   {"$filter":{"funcName":"eq","key":"channelId","parameter":12}},
+	//   {"$filter":{"funcName":"eq","key":"force","parameter":true}}]}
+	r.GET("", func(c *gin.Context) { getAuditedActionsHandler(c, db) })
+}
+
+func getAuditedActionsHandler(c *gin.Context, db *sqlx.DB) {
+	// Filter parser with whitelisted columns
+	var filter sq.Sqlizer
+	var err error
+	if c.Query("filter") != "" {
+		filter, err = qp.ParseFilterParam(c.Query("filter"), []string{
+			"audit_event_id",
+			"action",
+			"status",
+			"actor",
+			"user_id",
+			"api_token_id",
+			"node_id",
+			"channel_id",
+			"force",
+			"error",
+			"requested_on",
+			"completed_on",
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+			return
+		}
+	}
+
+	sort := []string{"requested_on desc"}
+	if c.Query("order") != "" {
+		// Order parser with whitelisted columns
+		sort, err = qp.ParseOrderParams(c.Query("order"), []string{
+			"audit_event_id",
+			"action",
+			"status",
+			"actor",
+			"node_id",
+			"channel_id",
+			"requested_on",
+			"completed_on",
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": err.Error()})
+			return
+		}
+	}
+
+	var limit uint64 = 100
+	if c.Query("limit") != "" {
+		limit, err = strconv.ParseUint(c.Query("limit"), 10, 64)
+		if err != nil || limit == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": "Limit must be a at least 1"})
+			return
+		}
+	}
+
+	var offset uint64
+	if c.Query("offset") != "" {
+		offset, err = strconv.ParseUint(c.Query("offset"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"Error": "Offset must be a positive number"})
+			return
+		}
+	}
+
+	auditedActions, total, err := getAuditedActions(db, filter, sort, limit, offset)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting audited actions.")
+		return
+	}
+
+	c.JSON(http.StatusOK, ah.ApiResponse{
+		Data: auditedActions,
+		Pagination: ah.Pagination{
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		}})
+}
@@ -12,12 +12,18 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/lncapital/torq/pkg/commons"
 )
 
-const Userkey = "user"
+const (
+	Userkey   = "user"
+	userIdKey = "userId"
+	actorKey  = "actor"
+)
 
 func CreateSession(r *gin.Engine, apiPwd string) error {
 	cookiePwd := []byte(apiPwd)
@@ -71,21 +77,129 @@ func TorqRequired(c *gin.Context) {
 	c.Next()
 }
 
-// AuthRequired is a simple middleware to check the session
-func AuthRequired(c *gin.Context) {
+// AuthRequired is a middleware to check the session or the API token (Authorization: Bearer <token>).
+// The authenticated actor is available with GetActor.
+func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, err := authenticate(c, db)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to authenticate request")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate request"})
+			return
+		}
+		if actor == nil {
+			// Abort the request with the appropriate error code
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Set(actorKey, *actor)
+		// Continue down the chain to handler etc
+		c.Next()
+	}
+}
+
+func authenticate(c *gin.Context, db *sqlx.DB) (*Actor, error) {
+	if authorization := c.GetHeader("Authorization"); authorization != "" {
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if token == authorization || !strings.HasPrefix(token, apiTokenPrefix) {
+			return nil, nil
+		}
+		return getApiTokenActor(db, token)
+	}
 	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	if user == nil {
-		// Abort the request with the appropriate error code
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	username, ok := session.Get(Userkey).(string)
+	if !ok || username == "" {
+		return nil, nil
+	}
+	userId, ok := session.Get(userIdKey).(int)
+	if !ok || userId == 0 {
+		// The built-in admin user or the cookie (SSO) login
+		return &Actor{Username: username, Role: Admin}, nil
+	}
+	user, err := getUser(db, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Getting user for userId: %v", userId)
+	}
+	if user.UserId == 0 || user.Disabled {
+		return nil, nil
+	}
+	return &Actor{UserId: &user.UserId, Username: user.Username, Role: user.Role}, nil
+}
+
+// GetActor returns the actor that was authenticated by AuthRequired
+func GetActor(c *gin.Context) (Actor, bool) {
+	actor, exists := c.Get(actorKey)
+	if !exists {
+		return Actor{}, false
+	}
+	a, ok := actor.(Actor)
+	return a, ok
+}
+
+// HasRole returns true when the authenticated actor has at least the permissions of the role
+func HasRole(c *gin.Context, role Role) bool {
+	actor, ok := GetActor(c)
+	return ok && actor.Role.Allows(role)
+}
+
+// RoleRequired is a middleware that requires at least the role for every request
+func RoleRequired(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The " + string(role) + " role is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// MutationRoleRequired is a middleware that requires at least the role for every request that isn't read-only
+func MutationRoleRequired(role Role) gin.HandlerFunc {
+	requireRole := RoleRequired(role)
+	return func(c *gin.Context) {
+		if isReadOnly(c.Request.Method) {
+			c.Next()
+			return
+		}
+		requireRole(c)
+	}
+}
+
+func isReadOnly(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// AuditMutations is a middleware that logs which actor called which request that isn't read-only
+func AuditMutations(c *gin.Context) {
+	if isReadOnly(c.Request.Method) {
+		c.Next()
 		return
 	}
-	// Continue down the chain to handler etc
 	c.Next()
+	actor, _ := GetActor(c)
+	log.Info().
+		Str("actor", actor.String()).
+		Str("role", string(actor.Role)).
+		Str("method", c.Request.Method).
+		Str("path", c.Request.URL.Path).
+		Int("status", c.Writer.Status()).
+		Msg("API mutation")
 }
 
-// Login creates a user session, logging them in given the right username and password
-func Login(apiPwd string) gin.HandlerFunc {
+// AuditAction logs which actor requested the action outside of a http request (i.e. over the websocket)
+func AuditAction(c *gin.Context, action string, reqId string) {
+	actor, _ := GetActor(c)
+	log.Info().
+		Str("actor", actor.String()).
+		Str("role", string(actor.Role)).
+		Str("action", action).
+		Str("reqId", reqId).
+		Msg("Websocket action")
+}
+
+// Login creates a user session, logging them in given the right username and password.
+// The built-in admin user logs in with the configured torq password, other users are stored in the database.
+func Login(db *sqlx.DB, apiPwd string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := c.PostForm("username")
@@ -97,15 +211,30 @@ func Login(apiPwd string) gin.HandlerFunc {
 			return
 		}
 
-		// Check for username and password match, usually from a database
-		if username != "admin" || password != apiPwd {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
-			return
+		userId := 0
+		if username == builtInAdminUsername {
+			if apiPwd == "" || subtle.ConstantTimeCompare([]byte(password), []byte(apiPwd)) != 1 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
+				return
+			}
+		} else {
+			user, err := getUserByUsername(db, username)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to get user")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+				return
+			}
+			if user.UserId == 0 || user.Disabled ||
+				bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
+				return
+			}
+			userId = user.UserId
 		}
 
-		// Save the username in the session
-		// set this to the users ID when moving to multi users setup
+		// Save the username and the user id (0 for the built-in admin user) in the session
 		session.Set(Userkey, username)
+		session.Set(userIdKey, userId)
 		if err := session.Save(); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session"})
 			return
@@ -152,9 +281,9 @@ func CookieLogin(cookiePath string) gin.HandlerFunc {
 			return
 		}
 
-		// Save the username in the session
-		// set this to the users ID when moving to multi users setup
-		session.Set(Userkey, "SSOUser")
+		// Save the username in the session, the cookie login has the same permissions as the built-in admin user
+		session.Set(Userkey, ssoUsername)
+		session.Set(userIdKey, 0)
 		if err := session.Save(); err != nil {
 			log.Error().Err(err).Msg("Failed to save session")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session"})
@@ -168,6 +297,12 @@ func Logout(c *gin.Context) {
 	session := sessions.Default(c)
 
 	session.Delete(Userkey)
+	session.Delete(userIdKey)
+	if err := session.Save(); err != nil {
+		log.Error().Err(err).Msg("Failed to save session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 }
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		expected bool
+	}{
+		{Viewer, Viewer, true},
+		{Viewer, Operator, false},
+		{Operator, Viewer, true},
+		{Operator, Admin, false},
+		{Admin, Operator, true},
+		{Role("unknown"), Viewer, false},
+		{Role(""), Role(""), false},
+	}
+	for _, test := range tests {
+		if result := test.role.Allows(test.required); result != test.expected {
+			t.Errorf("%v allows %v expected %v, got %v", test.role, test.required, test.expected, result)
+		}
+	}
+	if minRole(Admin, Viewer) != Viewer || minRole(Operator, Admin) != Operator {
+		t.Errorf("expected the role with the least permissions")
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	tests := []struct {
+		name             string
+		user             User
+		passwordRequired bool
+		valid            bool
+	}{
+		{"valid", User{Username: "alice", Password: "12345678", Role: Operator}, true, true},
+		{"missing username", User{Password: "12345678", Role: Operator}, true, false},
+		{"reserved username", User{Username: "Admin", Password: "12345678", Role: Admin}, true, false},
+		{"invalid role", User{Username: "alice", Password: "12345678", Role: "root"}, true, false},
+		{"short password", User{Username: "alice", Password: "1234", Role: Viewer}, true, false},
+		{"missing password", User{Username: "alice", Role: Viewer}, true, false},
+		{"unchanged password", User{Username: "alice", Role: Viewer}, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := validateUser(test.user, test.passwordRequired)
+			if (message == "") != test.valid {
+				t.Errorf("expected valid %v, got message %q", test.valid, message)
+			}
+		})
+	}
+}
+
+func TestGenerateApiToken(t *testing.T) {
+	token, err := generateApiToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(token, apiTokenPrefix) || len(token) != len(apiTokenPrefix)+64 {
+		t.Errorf("unexpected token format %v", token)
+	}
+	other, _ := generateApiToken()
+	if token == other {
+		t.Errorf("expected unique tokens")
+	}
+	if hashApiToken(token) != hashApiToken(token) || hashApiToken(token) == hashApiToken(other) {
+		t.Errorf("expected a deterministic hash per token")
+	}
+}
+
+func TestRoleMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(actorKey, Actor{Username: "alice", Role: Role(c.GetHeader("X-Test-Role"))})
+	})
+	mutations := router.Group("/channels")
+	mutations.Use(MutationRoleRequired(Operator))
+	mutations.GET("", func(c *gin.Context) { c.Status(http.StatusOK) })
+	mutations.POST("", func(c *gin.Context) { c.Status(http.StatusOK) })
+	admin := router.Group("/users")
+	admin.Use(RoleRequired(Admin))
+	admin.GET("", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		role     Role
+		method   string
+		path     string
+		expected int
+	}{
+		{Viewer, http.MethodGet, "/channels", http.StatusOK},
+		{Viewer, http.MethodPost, "/channels", http.StatusForbidden},
+		{Operator, http.MethodPost, "/channels", http.StatusOK},
+		{Operator, http.MethodGet, "/users", http.StatusForbidden},
+		{Admin, http.MethodGet, "/users", http.StatusOK},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, test.path, nil)
+		request.Header.Set("X-Test-Role", string(test.role))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		if response.Code != test.expected {
+			t.Errorf("%v %v %v expected status %v, got %v", test.role, test.method, test.path, test.expected,
+				response.Code)
+		}
+	}
+}
+
+func TestAuthRequiredRejectsUnknownAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// Tokens without the torq prefix are rejected before the database is queried
+	router.GET("", AuthRequired(nil), func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Basic YWRtaW46YWRtaW4=")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %v, got %v", http.StatusUnauthorized, response.Code)
+	}
+}
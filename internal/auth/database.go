@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getUser(db *sqlx.DB, userId int) (User, error) {
+	var user User
+	err := db.Get(&user, `SELECT * FROM torq_user WHERE torq_user_id=$1;`, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, nil
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return user, nil
+}
+
+func getUserByUsername(db *sqlx.DB, username string) (User, error) {
+	var user User
+	err := db.Get(&user, `SELECT * FROM torq_user WHERE username=$1;`, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, nil
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return user, nil
+}
+
+func getUsers(db *sqlx.DB) ([]User, error) {
+	users := []User{}
+	err := db.Select(&users, `SELECT * FROM torq_user ORDER BY username;`)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return users, nil
+}
+
+func addUser(db *sqlx.DB, user User) (User, error) {
+	user.CreatedOn = time.Now().UTC()
+	user.UpdatedOn = user.CreatedOn
+	err := db.QueryRowx(`INSERT INTO torq_user (username, password_hash, role, disabled, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING torq_user_id;`,
+		user.Username, user.PasswordHash, user.Role, user.Disabled, user.CreatedOn, user.UpdatedOn).
+		Scan(&user.UserId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return User{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return user, nil
+}
+
+// setUser the password hash is only updated when it's not empty
+func setUser(db *sqlx.DB, user User) (User, error) {
+	user.UpdatedOn = time.Now().UTC()
+	_, err := db.Exec(`UPDATE torq_user
+		SET username=$1, password_hash=COALESCE(NULLIF($2, ''), password_hash), role=$3, disabled=$4, updated_on=$5
+		WHERE torq_user_id=$6;`,
+		user.Username, user.PasswordHash, user.Role, user.Disabled, user.UpdatedOn, user.UserId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return User{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return User{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return getUser(db, user.UserId)
+}
+
+func removeUser(db *sqlx.DB, userId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM torq_user WHERE torq_user_id = $1;`, userId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+// getApiTokens when the userId is nil the tokens of the built-in admin user are returned
+func getApiTokens(db *sqlx.DB, userId *int) ([]ApiToken, error) {
+	apiTokens := []ApiToken{}
+	err := db.Select(&apiTokens, `
+		SELECT * FROM api_token
+		WHERE torq_user_id IS NOT DISTINCT FROM $1
+		ORDER BY api_token_id;`, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return apiTokens, nil
+}
+
+func getAllApiTokens(db *sqlx.DB) ([]ApiToken, error) {
+	apiTokens := []ApiToken{}
+	err := db.Select(&apiTokens, `SELECT * FROM api_token ORDER BY api_token_id;`)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return apiTokens, nil
+}
+
+func addApiToken(db *sqlx.DB, apiToken ApiToken) (ApiToken, error) {
+	apiToken.CreatedOn = time.Now().UTC()
+	apiToken.UpdatedOn = apiToken.CreatedOn
+	err := db.QueryRowx(`INSERT INTO api_token (torq_user_id, name, token_hash, role, expires_on, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING api_token_id;`,
+		apiToken.UserId, apiToken.Name, apiToken.TokenHash, apiToken.Role, apiToken.ExpiresOn,
+		apiToken.CreatedOn, apiToken.UpdatedOn).Scan(&apiToken.ApiTokenId)
+	if err != nil {
+		return ApiToken{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return apiToken, nil
+}
+
+// removeApiToken when ownerOnly is true the token is only removed when it belongs to the userId
+func removeApiToken(db *sqlx.DB, apiTokenId int, userId *int, ownerOnly bool) (int64, error) {
+	res, err := db.Exec(`
+		DELETE FROM api_token
+		WHERE api_token_id = $1 AND (NOT $3 OR torq_user_id IS NOT DISTINCT FROM $2);`,
+		apiTokenId, userId, ownerOnly)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+// getApiTokenActor returns nil when the token is unknown, expired or when its user is disabled
+func getApiTokenActor(db *sqlx.DB, token string) (*Actor, error) {
+	var apiToken struct {
+		ApiTokenId int     `db:"api_token_id"`
+		UserId     *int    `db:"torq_user_id"`
+		Role       Role    `db:"role"`
+		Username   *string `db:"username"`
+		UserRole   *Role   `db:"user_role"`
+		Disabled   *bool   `db:"disabled"`
+	}
+	now := time.Now().UTC()
+	err := db.Get(&apiToken, `
+		SELECT t.api_token_id, t.torq_user_id, t.role, u.username, u.role AS user_role, u.disabled
+		FROM api_token t
+		LEFT JOIN torq_user u ON u.torq_user_id = t.torq_user_id
+		WHERE t.token_hash = $1 AND (t.expires_on IS NULL OR t.expires_on > $2);`, hashApiToken(token), now)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	actor := Actor{ApiTokenId: &apiToken.ApiTokenId, Username: builtInAdminUsername, Role: apiToken.Role}
+	if apiToken.UserId != nil {
+		if apiToken.Username == nil || apiToken.UserRole == nil || apiToken.Disabled == nil || *apiToken.Disabled {
+			return nil, nil
+		}
+		actor.UserId = apiToken.UserId
+		actor.Username = *apiToken.Username
+		actor.Role = minRole(apiToken.Role, *apiToken.UserRole)
+	}
+	_, err = db.Exec(`UPDATE api_token SET last_used_on=$1 WHERE api_token_id=$2;`, now, apiToken.ApiTokenId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return &actor, nil
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const minimumPasswordLength = 8
+
+// RegisterUserRoutes manages the users, these routes are meant for admins only
+func RegisterUserRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("all", func(c *gin.Context) { getUsersHandler(c, db) })
+	r.POST("add", func(c *gin.Context) { addUserHandler(c, db) })
+	r.PUT("set", func(c *gin.Context) { setUserHandler(c, db) })
+	r.DELETE(":userId", func(c *gin.Context) { removeUserHandler(c, db) })
+}
+
+// RegisterApiTokenRoutes manages the API tokens of the authenticated user (admins see all tokens)
+func RegisterApiTokenRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("all", func(c *gin.Context) { getApiTokensHandler(c, db) })
+	// add returns the token only once, only its hash is stored
+	r.POST("add", func(c *gin.Context) { addApiTokenHandler(c, db) })
+	r.DELETE(":apiTokenId", func(c *gin.Context) { removeApiTokenHandler(c, db) })
+}
+
+// CurrentActorHandler returns the authenticated user and its role
+func CurrentActorHandler(c *gin.Context) {
+	actor, ok := GetActor(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.JSON(http.StatusOK, actor)
+}
+
+func getUsersHandler(c *gin.Context, db *sqlx.DB) {
+	users, err := getUsers(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting users.")
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func addUserHandler(c *gin.Context, db *sqlx.DB) {
+	var user User
+	if err := c.BindJSON(&user); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if message := validateUser(user, true); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Hashing password.")
+		return
+	}
+	user.PasswordHash = string(passwordHash)
+	storedUser, err := addUser(db, user)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding user.")
+		return
+	}
+	c.JSON(http.StatusOK, storedUser)
+}
+
+// setUserHandler the password is only changed when it's provided
+func setUserHandler(c *gin.Context, db *sqlx.DB) {
+	var user User
+	if err := c.BindJSON(&user); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if user.UserId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Failed to find userId in the request.")
+		return
+	}
+	if message := validateUser(user, false); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	if user.Password != "" {
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, "Hashing password.")
+			return
+		}
+		user.PasswordHash = string(passwordHash)
+	}
+	storedUser, err := setUser(db, user)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting user for userId: %v", user.UserId))
+		return
+	}
+	c.JSON(http.StatusOK, storedUser)
+}
+
+func removeUserHandler(c *gin.Context, db *sqlx.DB) {
+	userId, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse userId in the request.")
+		return
+	}
+	count, err := removeUser(db, userId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing user for userId: %v", userId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v user(s).", count)})
+}
+
+func validateUser(user User, passwordRequired bool) string {
+	username := strings.TrimSpace(user.Username)
+	if username == "" {
+		return "Failed to find username in the request."
+	}
+	if username != user.Username {
+		return "The username can't start or end with spaces."
+	}
+	if strings.EqualFold(username, builtInAdminUsername) || strings.EqualFold(username, ssoUsername) {
+		return fmt.Sprintf("The username %v is reserved.", username)
+	}
+	if !user.Role.IsValid() {
+		return fmt.Sprintf("Invalid role %v, the role must be %v, %v or %v.", user.Role, Viewer, Operator, Admin)
+	}
+	if (passwordRequired || user.Password != "") && len(user.Password) < minimumPasswordLength {
+		return fmt.Sprintf("The password requires at least %v characters.", minimumPasswordLength)
+	}
+	return ""
+}
+
+func getApiTokensHandler(c *gin.Context, db *sqlx.DB) {
+	actor, ok := GetActor(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var apiTokens []ApiToken
+	var err error
+	if actor.Role.Allows(Admin) {
+		apiTokens, err = getAllApiTokens(db)
+	} else {
+		apiTokens, err = getApiTokens(db, actor.UserId)
+	}
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting API tokens.")
+		return
+	}
+	c.JSON(http.StatusOK, apiTokens)
+}
+
+func addApiTokenHandler(c *gin.Context, db *sqlx.DB) {
+	actor, ok := GetActor(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var apiToken ApiToken
+	if err := c.BindJSON(&apiToken); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if strings.TrimSpace(apiToken.Name) == "" {
+		server_errors.SendUnprocessableEntity(c, "Failed to find name in the request.")
+		return
+	}
+	if !apiToken.Role.IsValid() {
+		server_errors.SendUnprocessableEntity(c, fmt.Sprintf("Invalid role %v, the role must be %v, %v or %v.",
+			apiToken.Role, Viewer, Operator, Admin))
+		return
+	}
+	if !actor.Role.Allows(apiToken.Role) {
+		server_errors.SendUnprocessableEntity(c, "The role of the token can't exceed your own role.")
+		return
+	}
+	if apiToken.ExpiresOn != nil && !apiToken.ExpiresOn.After(time.Now()) {
+		server_errors.SendUnprocessableEntity(c, "The token can't expire in the past.")
+		return
+	}
+	token, err := generateApiToken()
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Generating API token.")
+		return
+	}
+	apiToken.UserId = actor.UserId
+	apiToken.TokenHash = hashApiToken(token)
+	storedApiToken, err := addApiToken(db, apiToken)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding API token.")
+		return
+	}
+	storedApiToken.Token = token
+	c.JSON(http.StatusOK, storedApiToken)
+}
+
+func removeApiTokenHandler(c *gin.Context, db *sqlx.DB) {
+	actor, ok := GetActor(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	apiTokenId, err := strconv.Atoi(c.Param("apiTokenId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse apiTokenId in the request.")
+		return
+	}
+	count, err := removeApiToken(db, apiTokenId, actor.UserId, !actor.Role.Allows(Admin))
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing API token for apiTokenId: %v", apiTokenId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v API token(s).", count)})
+}
+
+func generateApiToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", errors.Wrap(err, "Generating random token")
+	}
+	return apiTokenPrefix + hex.EncodeToString(token), nil
+}
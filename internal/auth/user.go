@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type Role string
+
+const (
+	// Viewer can read everything except secrets but can't change anything
+	Viewer = Role("viewer")
+	// Operator can also operate the nodes (i.e. open and close channels, send payments and coins)
+	Operator = Role("operator")
+	// Admin can also change the settings and manage the users
+	Admin = Role("admin")
+)
+
+const (
+	// builtInAdminUsername logs in with the configured torq password and has no database record
+	builtInAdminUsername = "admin"
+	ssoUsername          = "SSOUser"
+	apiTokenPrefix       = "torq_"
+)
+
+func (role Role) level() int {
+	switch role {
+	case Viewer:
+		return 1
+	case Operator:
+		return 2
+	case Admin:
+		return 3
+	}
+	return 0
+}
+
+func (role Role) IsValid() bool {
+	return role.level() > 0
+}
+
+// Allows returns true when the role has at least the permissions of the required role
+func (role Role) Allows(required Role) bool {
+	return role.IsValid() && role.level() >= required.level()
+}
+
+func minRole(role Role, other Role) Role {
+	if role.level() <= other.level() {
+		return role
+	}
+	return other
+}
+
+type User struct {
+	UserId       int    `json:"userId" db:"torq_user_id"`
+	Username     string `json:"username" db:"username"`
+	PasswordHash string `json:"-" db:"password_hash"`
+	// Password is only used to add a user or to change the password
+	Password  string    `json:"password,omitempty" db:"-"`
+	Role      Role      `json:"role" db:"role"`
+	Disabled  bool      `json:"disabled" db:"disabled"`
+	CreatedOn time.Time `json:"createdOn" db:"created_on"`
+	UpdatedOn time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+type ApiToken struct {
+	ApiTokenId int    `json:"apiTokenId" db:"api_token_id"`
+	UserId     *int   `json:"userId" db:"torq_user_id"`
+	Name       string `json:"name" db:"name"`
+	TokenHash  string `json:"-" db:"token_hash"`
+	// Token is only returned when the token is created
+	Token      string     `json:"token,omitempty" db:"-"`
+	Role       Role       `json:"role" db:"role"`
+	ExpiresOn  *time.Time `json:"expiresOn" db:"expires_on"`
+	LastUsedOn *time.Time `json:"lastUsedOn" db:"last_used_on"`
+	CreatedOn  time.Time  `json:"createdOn" db:"created_on"`
+	UpdatedOn  time.Time  `json:"updatedOn" db:"updated_on"`
+}
+
+// Actor is the authenticated user (or API token) of a request
+type Actor struct {
+	// UserId is nil for the built-in admin user
+	UserId     *int   `json:"userId"`
+	Username   string `json:"username"`
+	Role       Role   `json:"role"`
+	ApiTokenId *int   `json:"apiTokenId,omitempty"`
+}
+
+func (actor Actor) String() string {
+	if actor.ApiTokenId != nil {
+		return fmt.Sprintf("%v (token %v)", actor.Username, *actor.ApiTokenId)
+	}
+	return actor.Username
+}
+
+func hashApiToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -29,7 +29,7 @@ func getChannelGroup(db *sqlx.DB, channelGroupId int) (channelGroup, error) {
 	return cg, nil
 }
 
-func getChannelGroupsByChannelId(db *sqlx.DB, channelId int, include commons.ChannelGroupInclude) ([]commons.ChannelGroup, error) {
+func GetChannelGroupsByChannelId(db *sqlx.DB, channelId int, include commons.ChannelGroupInclude) ([]commons.ChannelGroup, error) {
 	cachedData := commons.GetChannelGroupsByChannelId(channelId, include)
 	if cachedData != nil {
 		return cachedData.ChannelGroups, nil
@@ -212,3 +212,85 @@ func removeCategory(db *sqlx.DB, categoryId int) (int64, error) {
 	}
 	return rowsAffected, nil
 }
+
+// addChannelGroup stores the corridor for the tag or category, the channel groups are regenerated in the background.
+func addChannelGroup(db *sqlx.DB, cg channelGroup) error {
+	var origin groupOrigin
+	var corridor corridors.Corridor
+	if cg.TagId != nil && *cg.TagId != 0 {
+		tag, err := tags.GetTag(db, *cg.TagId)
+		if err != nil {
+			return errors.Wrap(err, "Obtaining tag from tagId")
+		}
+		if tag.TagId == 0 {
+			return errors.New(fmt.Sprintf("Tag not found for tagId: %v", *cg.TagId))
+		}
+		corridor = corridors.Corridor{CorridorTypeId: corridors.Tag().CorridorTypeId, Flag: 1}
+		corridor.ReferenceId = &tag.TagId
+		if tag.CategoryId != nil {
+			corridor.FromCategoryId = tag.CategoryId
+		}
+		origin = tagCorridor
+	} else {
+		corridor = corridors.Corridor{CorridorTypeId: corridors.Category().CorridorTypeId, Flag: 1}
+		corridor.ReferenceId = cg.CategoryId
+		origin = categoryCorridor
+	}
+	if cg.NodeId != 0 {
+		corridor.FromNodeId = &cg.NodeId
+	}
+	if cg.ChannelId != 0 {
+		corridor.ChannelId = &cg.ChannelId
+	}
+	_, err := corridors.AddCorridor(db, corridor)
+	if err != nil {
+		return errors.Wrap(err, "Adding corridor")
+	}
+	err = corridors.RefreshCorridorCacheByTypeId(db, corridor.CorridorTypeId)
+	if err != nil {
+		return errors.Wrap(err, "Refresh Corridor Cache By Type")
+	}
+	go func() {
+		err := GenerateChannelGroupsByOrigin(db, origin)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate channel groups.")
+		}
+	}()
+	return nil
+}
+
+func AddChannelTag(db *sqlx.DB, nodeId int, channelId int, tagId int) error {
+	return addChannelGroup(db, channelGroup{NodeId: nodeId, ChannelId: channelId, TagId: &tagId})
+}
+
+// RemoveChannelTag only removes a tag that was added to this specific channel (not tags inherited from the node).
+func RemoveChannelTag(db *sqlx.DB, nodeId int, channelId int, tagId int) error {
+	tag, err := tags.GetTag(db, tagId)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining tag from tagId")
+	}
+	corridorKey := corridors.CorridorKey{CorridorType: corridors.Tag(), ReferenceId: tagId,
+		FromNodeId: nodeId, ChannelId: channelId}
+	if tag.CategoryId != nil {
+		corridorKey.FromCategoryId = *tag.CategoryId
+	}
+	corridor := corridors.GetBestCorridor(corridorKey)
+	if corridor.ChannelId == nil || *corridor.ChannelId != channelId {
+		return errors.New(fmt.Sprintf("Tag %v was not added to channelId: %v", tagId, channelId))
+	}
+	_, err = corridors.RemoveCorridor(db, corridor.CorridorId)
+	if err != nil {
+		return errors.Wrap(err, "Removing corridor")
+	}
+	err = corridors.RefreshCorridorCacheByType(db, corridors.Tag())
+	if err != nil {
+		return errors.Wrap(err, "Refresh Corridor Cache By Type")
+	}
+	go func() {
+		err := GenerateChannelGroupsByOrigin(db, tagCorridor)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate channel groups.")
+		}
+	}()
+	return nil
+}
@@ -41,7 +41,7 @@ func getChannelGroupsByChannelIdHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 	channelGroupInclude := commons.ChannelGroupInclude(include)
-	channelGroups, err := getChannelGroupsByChannelId(db, channelId, channelGroupInclude)
+	channelGroups, err := GetChannelGroupsByChannelId(db, channelId, channelGroupInclude)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting ChannelGroupCategories for channelId: %v", channelId))
 		return
@@ -68,47 +68,18 @@ func addChannelGroupHandler(c *gin.Context, db *sqlx.DB) {
 		server_errors.SendUnprocessableEntity(c, "Failed to find nodeId in the request.")
 		return
 	}
-	var origin groupOrigin
-	var corridor corridors.Corridor
 	if cg.TagId != nil && *cg.TagId != 0 {
 		tag, err := tags.GetTag(db, *cg.TagId)
-		if err != nil {
+		if err != nil || tag.TagId == 0 {
 			server_errors.SendUnprocessableEntity(c, "Failed to find tag from tagId.")
 			return
 		}
-		corridor = corridors.Corridor{CorridorTypeId: corridors.Tag().CorridorTypeId, Flag: 1}
-		corridor.ReferenceId = &tag.TagId
-		if tag.CategoryId != nil {
-			corridor.FromCategoryId = tag.CategoryId
-		}
-		origin = tagCorridor
-	} else {
-		corridor = corridors.Corridor{CorridorTypeId: corridors.Category().CorridorTypeId, Flag: 1}
-		corridor.ReferenceId = cg.CategoryId
-		origin = categoryCorridor
-	}
-	if cg.NodeId != 0 {
-		corridor.FromNodeId = &cg.NodeId
 	}
-	if cg.ChannelId != 0 {
-		corridor.ChannelId = &cg.ChannelId
-	}
-	_, err := corridors.AddCorridor(db, corridor)
+	err := addChannelGroup(db, cg)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "Adding corridor.")
+		server_errors.WrapLogAndSendServerError(c, err, "Adding channel group.")
 		return
 	}
-	err = corridors.RefreshCorridorCacheByTypeId(db, corridor.CorridorTypeId)
-	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "Refresh Corridor Cache By Type.")
-		return
-	}
-	go func() {
-		err := GenerateChannelGroupsByOrigin(db, origin)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to generate channel groups.")
-		}
-	}()
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Successfully added channel group configuration."})
 }
 
@@ -0,0 +1,309 @@
+package channel_health
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/channel_risk"
+	"github.com/lncapital/torq/internal/peers"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const DefaultDays = 30
+
+// The score is the weighted sum of the components, each component is between 0 and 1
+const (
+	activityWeight  = 40
+	profitWeight    = 30
+	uptimeWeight    = 20
+	stabilityWeight = 10
+)
+
+const (
+	// targetTurnoverPer30Days the capacity is expected to be forwarded (in and out) once a month
+	targetTurnoverPer30Days = 1.0
+	targetAnnualizedRoi     = 0.02
+	minimumUptime           = 0.9
+	// policyChangesPer30Days more remote policy changes reduce the stability
+	policyChangesPer30Days = 2
+	closeCandidateScore    = 40
+	// blockInterval estimates the age of a channel from its funding block height
+	blockInterval = 10 * time.Minute
+)
+
+type ChannelHealth struct {
+	NodeId         int    `json:"nodeId"`
+	ChannelId      int    `json:"channelId"`
+	ShortChannelId string `json:"shortChannelId"`
+	RemoteNodeId   int    `json:"remoteNodeId"`
+	Capacity       int64  `json:"capacity"`
+	Days           int    `json:"days"`
+
+	AmountOutMsat   int64 `json:"amountOutMsat"`
+	AmountInMsat    int64 `json:"amountInMsat"`
+	ForwardCountOut int64 `json:"forwardCountOut"`
+	ForwardCountIn  int64 `json:"forwardCountIn"`
+	// RevenueOutMsat the fees earned by forwarding over this channel
+	RevenueOutMsat int64 `json:"revenueOutMsat"`
+	// RevenueInMsat the fees earned by other channels for forwards coming in over this channel
+	RevenueInMsat int64 `json:"revenueInMsat"`
+	// RebalancingCostMsat half of the fee of every rebalance that starts or ends in this channel
+	RebalancingCostMsat int64 `json:"rebalancingCostMsat"`
+	// OnChainCostMsat the part of the open transaction fee that is attributed to the window
+	OnChainCostMsat int64 `json:"onChainCostMsat"`
+	ProfitMsat      int64 `json:"profitMsat"`
+	// Roi the profit relative to the capacity over the window
+	Roi           float64 `json:"roi"`
+	AnnualizedRoi float64 `json:"annualizedRoi"`
+	// Turnover the forwarded amount (in and out) relative to the capacity over the window
+	Turnover float64 `json:"turnover"`
+	// Uptime the fraction of the window the channel was active and the peer was online
+	Uptime              float64 `json:"uptime"`
+	RemotePolicyChanges int64   `json:"remotePolicyChanges"`
+	RemoteDisabled      bool    `json:"remoteDisabled"`
+
+	// Score between 0 (close it) and 100 (healthy)
+	Score float64 `json:"score"`
+	// CloseCandidate channels younger than the window are never a candidate
+	CloseCandidate bool     `json:"closeCandidate"`
+	Reasons        []string `json:"reasons"`
+}
+
+type cachedChannelHealth struct {
+	channels []ChannelHealth
+	on       time.Time
+}
+
+var (
+	channelHealthCacheMutex sync.Mutex                          //nolint:gochecknoglobals
+	channelHealthCache      = make(map[int]cachedChannelHealth) //nolint:gochecknoglobals
+)
+
+// GetCachedChannelHealth the channel health over the DefaultDays, the channels are only scored again when the cached
+// result is older than CHANNEL_HEALTH_CACHE_SECONDS
+func GetCachedChannelHealth(db *sqlx.DB, nodeId int) ([]ChannelHealth, error) {
+	channelHealthCacheMutex.Lock()
+	cached, exists := channelHealthCache[nodeId]
+	channelHealthCacheMutex.Unlock()
+	if exists && time.Since(cached.on) < commons.CHANNEL_HEALTH_CACHE_SECONDS*time.Second {
+		return cached.channels, nil
+	}
+	channels, err := GetChannelHealth(db, nodeId, DefaultDays)
+	if err != nil {
+		return nil, err
+	}
+	channelHealthCacheMutex.Lock()
+	channelHealthCache[nodeId] = cachedChannelHealth{channels: channels, on: time.Now()}
+	channelHealthCacheMutex.Unlock()
+	return channels, nil
+}
+
+// GetChannelHealth scores the open channels of the node over the last days
+func GetChannelHealth(db *sqlx.DB, nodeId int, days int) ([]ChannelHealth, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -days)
+
+	forwardTotals, err := getForwardTotals(db, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining forward totals")
+	}
+	transitions, err := getActivityTransitions(db, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining channel activity")
+	}
+	policyChanges, err := getRemotePolicyChanges(db, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining remote policy changes")
+	}
+	publicKey := commons.GetNodeSettingsByNodeId(nodeId).PublicKey
+	rebalancingCosts, err := getRebalancingCosts(db, nodeId, publicKey, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining rebalancing costs")
+	}
+	openCosts, err := getOpenCosts(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining channel open costs")
+	}
+	peerUptimes, err := peers.GetPeerUptimes(db, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining peer uptime")
+	}
+	openTimes, err := getChannelOpenTimes(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining channel open times")
+	}
+	blockHeight := channel_risk.GetBlockHeight(nodeId)
+
+	var result []ChannelHealth
+	for _, channel := range commons.GetChannelStates(nodeId, true) {
+		channelSettings := commons.GetChannelSettingByChannelId(channel.ChannelId)
+		lndShortChannelId := strconv.FormatUint(channelSettings.LndShortChannelId, 10)
+		openTime, opened := openTimes[channel.ChannelId]
+		age := channelAge(openTime, opened, uint32(channelSettings.LndShortChannelId>>40), blockHeight, to)
+		totals := forwardTotals[channel.ChannelId]
+		health := ChannelHealth{
+			NodeId:              nodeId,
+			ChannelId:           channel.ChannelId,
+			ShortChannelId:      channelSettings.ShortChannelId,
+			RemoteNodeId:        channel.RemoteNodeId,
+			Capacity:            channelSettings.Capacity,
+			Days:                days,
+			AmountOutMsat:       totals.AmountOutMsat,
+			AmountInMsat:        totals.AmountInMsat,
+			ForwardCountOut:     totals.CountOut,
+			ForwardCountIn:      totals.CountIn,
+			RevenueOutMsat:      totals.RevenueOutMsat,
+			RevenueInMsat:       totals.RevenueInMsat,
+			RebalancingCostMsat: rebalancingCosts[lndShortChannelId],
+			OnChainCostMsat:     amortizedOnChainCostMsat(openCosts[lndShortChannelId], days, age),
+			Uptime: math.Min(uptime(transitions[channel.ChannelId], from, to),
+				peerUptimeFraction(peerUptimes[channel.RemoteNodeId])),
+			RemotePolicyChanges: policyChanges[channel.ChannelId],
+			RemoteDisabled:      channel.RemoteDisabled,
+		}
+		evaluate(&health, age)
+		result = append(result, health)
+	}
+	return result, nil
+}
+
+// RankCloseCandidates the close candidates with the lowest score first
+func RankCloseCandidates(channels []ChannelHealth) []ChannelHealth {
+	candidates := []ChannelHealth{}
+	for _, channel := range channels {
+		if channel.CloseCandidate {
+			candidates = append(candidates, channel)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score < candidates[j].Score
+		}
+		return candidates[i].Roi < candidates[j].Roi
+	})
+	return candidates
+}
+
+// channelAge the age since the open channel event received from the stream. Without it the age is estimated from the
+// funding block height, when the node didn't report a block height yet the time since Torq first saw the channel is
+// used. The lifetime reported by LND can't be used, it's the uptime of its channel monitor and resets on a restart.
+func channelAge(openTime channelOpenTime, opened bool, fundingBlockHeight uint32, blockHeight uint32,
+	now time.Time) time.Duration {
+
+	if opened && openTime.OpenedOn != nil {
+		return now.Sub(*openTime.OpenedOn)
+	}
+	var age time.Duration
+	if opened {
+		age = now.Sub(openTime.FirstSeenOn)
+	}
+	if fundingBlockHeight != 0 && blockHeight > fundingBlockHeight {
+		if estimate := time.Duration(blockHeight-fundingBlockHeight) * blockInterval; estimate > age {
+			age = estimate
+		}
+	}
+	return age
+}
+
+// amortizedOnChainCostMsat the open cost is spread over the lifetime of the channel, a channel younger than the
+// window carries the full cost
+func amortizedOnChainCostMsat(openCostSat int64, days int, lifetime time.Duration) int64 {
+	window := time.Duration(days) * 24 * time.Hour
+	if lifetime <= window {
+		return openCostSat * 1000
+	}
+	return int64(math.Round(float64(openCostSat*1000) * window.Hours() / lifetime.Hours()))
+}
+
+// uptime the fraction of the window the channel was active. Transitions are ordered by time and can start with the
+// last transition before the window. Without any transition the channel is considered active.
+func uptime(transitions []activityTransition, from time.Time, to time.Time) float64 {
+	window := to.Sub(from)
+	if window <= 0 {
+		return 1
+	}
+	active := true
+	cursor := from
+	var activeDuration time.Duration
+	for _, transition := range transitions {
+		if transition.Time.Before(from) {
+			active = transition.Active
+			continue
+		}
+		if transition.Time.After(to) {
+			break
+		}
+		if active {
+			activeDuration += transition.Time.Sub(cursor)
+		}
+		cursor = transition.Time
+		active = transition.Active
+	}
+	if active {
+		activeDuration += to.Sub(cursor)
+	}
+	return activeDuration.Seconds() / window.Seconds()
+}
+
+// peerUptimeFraction a peer that was never observed is considered online
+func peerUptimeFraction(peerUptime peers.PeerUptime) float64 {
+	if peerUptime.UptimePercent == nil {
+		return 1
+	}
+	return *peerUptime.UptimePercent / 100
+}
+
+// evaluate sets the profit, ROI, score and reasons
+func evaluate(health *ChannelHealth, lifetime time.Duration) {
+	health.ProfitMsat = health.RevenueOutMsat - health.RebalancingCostMsat - health.OnChainCostMsat
+	if health.Capacity > 0 {
+		health.Roi = float64(health.ProfitMsat) / float64(health.Capacity*1000)
+		health.Turnover = float64(health.AmountOutMsat+health.AmountInMsat) / float64(health.Capacity*1000)
+	}
+	health.AnnualizedRoi = health.Roi * 365 / float64(health.Days)
+
+	targetTurnover := targetTurnoverPer30Days * float64(health.Days) / 30
+	activity := clamp(health.Turnover / targetTurnover)
+	profit := clamp(health.AnnualizedRoi / targetAnnualizedRoi)
+	allowedPolicyChanges := policyChangesPer30Days * float64(health.Days) / 30
+	stability := clamp(1 - (float64(health.RemotePolicyChanges)-allowedPolicyChanges)/10)
+	if health.RemoteDisabled {
+		stability = 0
+	}
+	health.Score = math.Round(activityWeight*activity + profitWeight*profit + uptimeWeight*clamp(health.Uptime) +
+		stabilityWeight*stability)
+
+	health.Reasons = []string{}
+	if health.ForwardCountOut+health.ForwardCountIn == 0 {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("No forwards in the last %v days.", health.Days))
+	} else if activity < 0.1 {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("Low turnover: %.1f%% of the capacity in %v days.",
+			health.Turnover*100, health.Days))
+	}
+	if health.ProfitMsat < 0 {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("Costs exceed the revenue by %v sat.",
+			-health.ProfitMsat/1000))
+	}
+	if health.Uptime < minimumUptime {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("Active %.0f%% of the time.", health.Uptime*100))
+	}
+	if health.RemoteDisabled {
+		health.Reasons = append(health.Reasons, "The peer disabled the channel.")
+	}
+	if float64(health.RemotePolicyChanges) > allowedPolicyChanges {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("The peer changed its policy %v times.",
+			health.RemotePolicyChanges))
+	}
+	health.CloseCandidate = lifetime >= time.Duration(health.Days)*24*time.Hour && health.Score < closeCandidateScore
+}
+
+func clamp(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
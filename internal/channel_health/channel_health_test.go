@@ -0,0 +1,123 @@
+package channel_health
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUptime(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(100 * time.Hour)
+	tests := []struct {
+		name        string
+		transitions []activityTransition
+		expected    float64
+	}{
+		{"no transitions", nil, 1},
+		{"inactive before the window", []activityTransition{{Time: from.Add(-time.Hour), Active: false}}, 0},
+		{"inactive during the window", []activityTransition{
+			{Time: from.Add(-time.Hour), Active: true},
+			{Time: from.Add(10 * time.Hour), Active: false},
+			{Time: from.Add(30 * time.Hour), Active: true},
+		}, 0.8},
+		{"inactive at the end", []activityTransition{{Time: from.Add(75 * time.Hour), Active: false}}, 0.75},
+		{"repeated active events", []activityTransition{
+			{Time: from.Add(-time.Hour), Active: false},
+			{Time: from.Add(50 * time.Hour), Active: true},
+			{Time: from.Add(60 * time.Hour), Active: true},
+		}, 0.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := uptime(test.transitions, from, to); math.Abs(result-test.expected) > 0.0001 {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestAmortizedOnChainCostMsat(t *testing.T) {
+	if cost := amortizedOnChainCostMsat(3000, 30, 10*24*time.Hour); cost != 3000000 {
+		t.Errorf("expected the full cost for a young channel, got %v", cost)
+	}
+	if cost := amortizedOnChainCostMsat(3000, 30, 90*24*time.Hour); cost != 1000000 {
+		t.Errorf("expected a third of the cost, got %v", cost)
+	}
+}
+
+func TestChannelAge(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	openedOn := now.Add(-100 * time.Hour)
+	firstSeenOn := now.Add(-time.Hour)
+	tests := []struct {
+		name        string
+		openTime    channelOpenTime
+		opened      bool
+		blockHeight uint32
+		expected    time.Duration
+	}{
+		{"open event from the stream", channelOpenTime{OpenedOn: &openedOn, FirstSeenOn: openedOn}, true, 800144,
+			100 * time.Hour},
+		{"imported open event", channelOpenTime{FirstSeenOn: firstSeenOn}, true, 800144, 24 * time.Hour},
+		{"imported open event without a block height", channelOpenTime{FirstSeenOn: firstSeenOn}, true, 0,
+			time.Hour},
+		{"no open event", channelOpenTime{}, false, 800144, 24 * time.Hour},
+		{"unknown", channelOpenTime{}, false, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if age := channelAge(test.openTime, test.opened, 800000, test.blockHeight, now); age != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, age)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	old := 365 * 24 * time.Hour
+
+	healthy := ChannelHealth{Days: 30, Capacity: 1000000, AmountOutMsat: 600000000, AmountInMsat: 600000000,
+		ForwardCountOut: 10, ForwardCountIn: 10, RevenueOutMsat: 5000000, Uptime: 1}
+	evaluate(&healthy, old)
+	if healthy.Score != 100 || healthy.CloseCandidate || len(healthy.Reasons) != 0 {
+		t.Errorf("expected a healthy channel, got %+v", healthy)
+	}
+
+	idle := ChannelHealth{Days: 30, Capacity: 1000000, OnChainCostMsat: 100000, Uptime: 0.5,
+		RemotePolicyChanges: 12}
+	evaluate(&idle, old)
+	if !idle.CloseCandidate {
+		t.Errorf("expected a close candidate, got %+v", idle)
+	}
+	if idle.ProfitMsat != -100000 || idle.Roi >= 0 {
+		t.Errorf("expected a negative return, got %+v", idle)
+	}
+	if len(idle.Reasons) != 4 {
+		t.Errorf("expected no forwards, costs, uptime and policy changes as reasons, got %v", idle.Reasons)
+	}
+
+	young := idle
+	evaluate(&young, 10*24*time.Hour)
+	if young.CloseCandidate {
+		t.Errorf("expected a channel younger than the window not to be a candidate")
+	}
+}
+
+func TestRankCloseCandidates(t *testing.T) {
+	channels := []ChannelHealth{
+		{ChannelId: 1, Score: 30, Roi: 0.01, CloseCandidate: true},
+		{ChannelId: 2, Score: 80},
+		{ChannelId: 3, Score: 10, CloseCandidate: true},
+		{ChannelId: 4, Score: 30, Roi: -0.01, CloseCandidate: true},
+	}
+	candidates := RankCloseCandidates(channels)
+	if len(candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %v", candidates)
+	}
+	for i, channelId := range []int{3, 4, 1} {
+		if candidates[i].ChannelId != channelId {
+			t.Errorf("expected channel %v at position %v, got %v", channelId, i, candidates[i].ChannelId)
+		}
+	}
+}
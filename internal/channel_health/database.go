@@ -0,0 +1,199 @@
+package channel_health
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+type forwardTotals struct {
+	ChannelId      int   `db:"channel_id"`
+	AmountOutMsat  int64 `db:"amount_out_msat"`
+	AmountInMsat   int64 `db:"amount_in_msat"`
+	RevenueOutMsat int64 `db:"revenue_out_msat"`
+	RevenueInMsat  int64 `db:"revenue_in_msat"`
+	CountOut       int64 `db:"count_out"`
+	CountIn        int64 `db:"count_in"`
+}
+
+// channelOpenTime OpenedOn is the time of the open channel event received from the stream, imported events are
+// stored when Torq first saw the channel so FirstSeenOn is only a lower bound of the age of the channel
+type channelOpenTime struct {
+	ChannelId   int        `db:"channel_id"`
+	OpenedOn    *time.Time `db:"opened_on"`
+	FirstSeenOn time.Time  `db:"first_seen_on"`
+}
+
+type activityTransition struct {
+	ChannelId int       `db:"channel_id"`
+	Time      time.Time `db:"time"`
+	Active    bool      `db:"active"`
+}
+
+func getForwardTotals(db *sqlx.DB, nodeId int, from time.Time, to time.Time) (map[int]forwardTotals, error) {
+	var rows []forwardTotals
+	err := db.Select(&rows, `
+		SELECT channel_id,
+			ROUND(SUM(amount_out_msat))::BIGINT AS amount_out_msat,
+			ROUND(SUM(amount_in_msat))::BIGINT AS amount_in_msat,
+			ROUND(SUM(revenue_out_msat))::BIGINT AS revenue_out_msat,
+			ROUND(SUM(revenue_in_msat))::BIGINT AS revenue_in_msat,
+			SUM(count_out) AS count_out,
+			SUM(count_in) AS count_in
+		FROM (
+			SELECT outgoing_channel_id AS channel_id, outgoing_amount_msat AS amount_out_msat, 0 AS amount_in_msat,
+				fee_msat AS revenue_out_msat, 0 AS revenue_in_msat, 1 AS count_out, 0 AS count_in
+			FROM forward
+			WHERE node_id = $1 AND time >= $2 AND time < $3 AND outgoing_channel_id IS NOT NULL
+			UNION ALL
+			SELECT incoming_channel_id, 0, incoming_amount_msat, 0, fee_msat, 0, 1
+			FROM forward
+			WHERE node_id = $1 AND time >= $2 AND time < $3 AND incoming_channel_id IS NOT NULL
+		) AS f
+		GROUP BY channel_id;`, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	result := make(map[int]forwardTotals, len(rows))
+	for _, row := range rows {
+		result[row.ChannelId] = row
+	}
+	return result, nil
+}
+
+func getChannelOpenTimes(db *sqlx.DB, nodeId int) (map[int]channelOpenTime, error) {
+	var rows []channelOpenTime
+	err := db.Select(&rows, `
+		SELECT channel_id, MIN(time) FILTER (WHERE NOT imported) AS opened_on, MIN(time) AS first_seen_on
+		FROM channel_event
+		WHERE node_id = $1 AND event_type = $2
+		GROUP BY channel_id;`, nodeId, int(lnrpc.ChannelEventUpdate_OPEN_CHANNEL))
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	result := make(map[int]channelOpenTime, len(rows))
+	for _, row := range rows {
+		result[row.ChannelId] = row
+	}
+	return result, nil
+}
+
+// getActivityTransitions the active and inactive channel events of the window ordered by time, preceded by the last
+// event before the window
+func getActivityTransitions(db *sqlx.DB, nodeId int, from time.Time,
+	to time.Time) (map[int][]activityTransition, error) {
+
+	var rows []activityTransition
+	err := db.Select(&rows, `
+		SELECT channel_id, time, active
+		FROM (
+			SELECT DISTINCT ON (channel_id) channel_id, time, event_type = $4 AS active
+			FROM channel_event
+			WHERE node_id = $1 AND event_type IN ($4, $5) AND time < $2
+			ORDER BY channel_id, time DESC
+		) AS before_window
+		UNION ALL
+		SELECT channel_id, time, event_type = $4
+		FROM channel_event
+		WHERE node_id = $1 AND event_type IN ($4, $5) AND time >= $2 AND time < $3
+		ORDER BY time;`, nodeId, from, to,
+		int(lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL), int(lnrpc.ChannelEventUpdate_INACTIVE_CHANNEL))
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	result := make(map[int][]activityTransition)
+	for _, row := range rows {
+		result[row.ChannelId] = append(result[row.ChannelId], row)
+	}
+	return result, nil
+}
+
+// getRemotePolicyChanges the number of routing policy updates announced by the peer per channel
+func getRemotePolicyChanges(db *sqlx.DB, nodeId int, from time.Time, to time.Time) (map[int]int64, error) {
+	rows, err := db.Queryx(`
+		SELECT channel_id, COUNT(*)
+		FROM routing_policy
+		WHERE node_id = $1 AND announcing_node_id != $1 AND ts >= $2 AND ts < $3
+		GROUP BY channel_id;`, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	defer rows.Close()
+	result := make(map[int]int64)
+	for rows.Next() {
+		var channelId int
+		var count int64
+		if err = rows.Scan(&channelId, &count); err != nil {
+			return nil, errors.Wrap(err, database.SqlScanResulSetError)
+		}
+		result[channelId] = count
+	}
+	return result, nil
+}
+
+// getRebalancingCosts half of the fee of a rebalance is attributed to its first and half to its last channel,
+// rebalances are recognised like channel_history.getChannelRebalancing. The key is the LND short channel id.
+func getRebalancingCosts(db *sqlx.DB, nodeId int, publicKey string, from time.Time,
+	to time.Time) (map[string]int64, error) {
+
+	rows, err := db.Queryx(`
+		SELECT lnd_short_channel_id, ROUND(SUM(fee_msat) / 2)::BIGINT
+		FROM (
+			SELECT htlcs->-1->'route'->'hops'->0->>'chan_id' AS lnd_short_channel_id, fee_msat, payment_hash,
+				htlcs->-1->'route'->'hops'->-1->>'pub_key' AS destination
+			FROM payment
+			WHERE node_id = $1 AND status = 'SUCCEEDED' AND creation_timestamp >= $3 AND creation_timestamp < $4
+			UNION ALL
+			SELECT htlcs->-1->'route'->'hops'->-1->>'chan_id', fee_msat, payment_hash,
+				htlcs->-1->'route'->'hops'->-1->>'pub_key'
+			FROM payment
+			WHERE node_id = $1 AND status = 'SUCCEEDED' AND creation_timestamp >= $3 AND creation_timestamp < $4
+		) AS p
+		WHERE payment_hash IN (SELECT payment_hash FROM rebalance_result WHERE status = 'SUCCEEDED') OR
+			destination = $2
+		GROUP BY lnd_short_channel_id;`, nodeId, publicKey, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	defer rows.Close()
+	result := make(map[string]int64)
+	for rows.Next() {
+		var lndShortChannelId *string
+		var costMsat int64
+		if err = rows.Scan(&lndShortChannelId, &costMsat); err != nil {
+			return nil, errors.Wrap(err, database.SqlScanResulSetError)
+		}
+		if lndShortChannelId != nil {
+			result[*lndShortChannelId] = costMsat
+		}
+	}
+	return result, nil
+}
+
+// getOpenCosts the fee (in sat) of the open channel transactions, recognised by their label. The key is the LND short
+// channel id.
+func getOpenCosts(db *sqlx.DB, nodeId int) (map[string]int64, error) {
+	rows, err := db.Queryx(`
+		SELECT split_part(label, '-', 2), ROUND(SUM(total_fees))::BIGINT
+		FROM tx
+		WHERE node_id = $1 AND label LIKE '%openchannel%'
+		GROUP BY split_part(label, '-', 2);`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	defer rows.Close()
+	result := make(map[string]int64)
+	for rows.Next() {
+		var lndShortChannelId string
+		var costSat int64
+		if err = rows.Scan(&lndShortChannelId, &costSat); err != nil {
+			return nil, errors.Wrap(err, database.SqlScanResulSetError)
+		}
+		result[lndShortChannelId] = costSat
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package channel_health
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterChannelHealthRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("", func(c *gin.Context) { getChannelHealthHandler(c, db, false) })
+	// i.e. close-candidates?days=30&nodeId=1
+	r.GET("close-candidates", func(c *gin.Context) { getChannelHealthHandler(c, db, true) })
+}
+
+func getChannelHealthHandler(c *gin.Context, db *sqlx.DB, closeCandidatesOnly bool) {
+	days := DefaultDays
+	if c.Query("days") != "" {
+		var err error
+		days, err = strconv.Atoi(c.Query("days"))
+		if err != nil || days < 1 {
+			server_errors.SendBadRequest(c, "Failed to parse days in the request.")
+			return
+		}
+	}
+	nodeIds := commons.GetAllActiveTorqNodeIds(nil, nil)
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+		nodeIds = []int{nodeId}
+	}
+
+	result := []ChannelHealth{}
+	for _, nodeId := range nodeIds {
+		channels, err := GetChannelHealth(db, nodeId, days)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Scoring channels for nodeId: %v", nodeId))
+			return
+		}
+		result = append(result, channels...)
+	}
+	if closeCandidatesOnly {
+		result = RankCloseCandidates(result)
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -1,6 +1,7 @@
 package channel_history
 
 import (
+	"database/sql"
 	"strconv"
 	"time"
 
@@ -22,7 +23,67 @@ type ChannelBalance struct {
 	Balances          []*Balance `json:"balances"`
 }
 
+// getChannelBalance the stored balance snapshots, the balance before the first snapshot is reconstructed from the
+// forwards, payments and invoices.
 func getChannelBalance(db *sqlx.DB, lndShortChannelIdString string, from time.Time, to time.Time) (ChannelBalance, error) {
+	lndShortChannelId, err := strconv.ParseUint(lndShortChannelIdString, 10, 64)
+	if err != nil {
+		return ChannelBalance{}, errors.Wrapf(err, "Converting LND short channel id %v", lndShortChannelId)
+	}
+	channelId := commons.GetChannelIdByShortChannelId(commons.ConvertLNDShortChannelID(lndShortChannelId))
+
+	var firstSnapshot sql.NullTime
+	err = db.Get(&firstSnapshot, `SELECT MIN(time) FROM channel_balance_snapshot WHERE channel_id=$1;`, channelId)
+	if err != nil {
+		return ChannelBalance{}, errors.Wrap(err, "SQL run query")
+	}
+	cb := ChannelBalance{LNDShortChannelId: lndShortChannelIdString}
+	if !firstSnapshot.Valid || firstSnapshot.Time.After(from) {
+		reconstructed, err := getReconstructedChannelBalance(db, lndShortChannelIdString, from, to)
+		if err != nil {
+			return ChannelBalance{}, err
+		}
+		for _, balance := range reconstructed.Balances {
+			if firstSnapshot.Valid && !balance.Date.Before(firstSnapshot.Time) {
+				break
+			}
+			cb.Balances = append(cb.Balances, balance)
+		}
+	}
+	if !firstSnapshot.Valid {
+		return cb, nil
+	}
+
+	// Only one torq node is used when both ends of the channel are managed by Torq
+	rows, err := db.Queryx(`
+		SELECT date, inbound_capacity, outbound_capacity, capacity_diff
+		FROM (
+			SELECT time AS date,
+				remote_balance AS inbound_capacity,
+				local_balance AS outbound_capacity,
+				local_balance - lag(local_balance) OVER (ORDER BY time) AS capacity_diff
+			FROM channel_balance_snapshot
+			WHERE channel_id=$1 AND node_id=(SELECT MIN(node_id) FROM channel_balance_snapshot WHERE channel_id=$1)
+		) snapshots
+		WHERE date::timestamp AT TIME ZONE ($4) BETWEEN $2 AND $3
+		ORDER BY date;`, channelId, from, to, commons.GetSettings().PreferredTimeZone)
+	if err != nil {
+		return cb, errors.Wrap(err, "SQL run query")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		b := Balance{}
+		err = rows.StructScan(&b)
+		if err != nil {
+			return cb, errors.Wrap(err, "SQL struct scan")
+		}
+		cb.Balances = append(cb.Balances, &b)
+	}
+	return cb, nil
+}
+
+func getReconstructedChannelBalance(db *sqlx.DB, lndShortChannelIdString string, from time.Time,
+	to time.Time) (ChannelBalance, error) {
 	lndShortChannelId, err := strconv.ParseUint(lndShortChannelIdString, 10, 64)
 	if err != nil {
 		return ChannelBalance{}, errors.Wrapf(err, "Converting LND short channel id %v", lndShortChannelId)
@@ -7,6 +7,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 
+	"github.com/lncapital/torq/internal/prices"
 	"github.com/lncapital/torq/pkg/commons"
 )
 
@@ -36,10 +37,19 @@ type ChannelHistoryRecords struct {
 	CountIn *uint64 `json:"countIn"`
 	// Number of total forwards.
 	CountTotal *uint64 `json:"countTotal"`
+
+	// The amounts and revenue in the requested currency with the price at the time of each forward.
+	// Empty when no currency was requested.
+	AmountOutFiat    *float64 `json:"amountOutFiat"`
+	AmountInFiat     *float64 `json:"amountInFiat"`
+	AmountTotalFiat  *float64 `json:"amountTotalFiat"`
+	RevenueOutFiat   *float64 `json:"revenueOutFiat"`
+	RevenueInFiat    *float64 `json:"revenueInFiat"`
+	RevenueTotalFiat *float64 `json:"revenueTotalFiat"`
 }
 
 func getChannelHistory(db *sqlx.DB, all bool, channelIds []int, from time.Time,
-	to time.Time) (r []*ChannelHistoryRecords,
+	to time.Time, currency *string) (r []*ChannelHistoryRecords,
 	err error) {
 
 	sql := `
@@ -54,13 +64,23 @@ func getChannelHistory(db *sqlx.DB, all bool, channelIds []int, from time.Time,
 			sum(coalesce((coalesce(i.revenue,0) + coalesce(o.revenue,0)), 0)) as revenue_total,
 			sum(coalesce(i.count,0)) as count_in,
 			sum(coalesce(o.count,0)) as count_out,
-			sum(coalesce((coalesce(i.count,0) + coalesce(o.count,0)), 0)) as count_total
+			sum(coalesce((coalesce(i.count,0) + coalesce(o.count,0)), 0)) as count_total,
+			case when $6::text is null then null else sum(coalesce(i.amount_fiat,0)) end as amount_in_fiat,
+			case when $6::text is null then null else sum(coalesce(o.amount_fiat,0)) end as amount_out_fiat,
+			case when $6::text is null then null
+				else sum(coalesce(i.amount_fiat,0) + coalesce(o.amount_fiat,0)) end as amount_total_fiat,
+			case when $6::text is null then null else sum(coalesce(i.revenue_fiat,0)) end as revenue_in_fiat,
+			case when $6::text is null then null else sum(coalesce(o.revenue_fiat,0)) end as revenue_out_fiat,
+			case when $6::text is null then null
+				else sum(coalesce(i.revenue_fiat,0) + coalesce(o.revenue_fiat,0)) end as revenue_total_fiat
 		from settings, (
 			select time_bucket_gapfill('1 days', time::timestamp AT TIME ZONE ($5), $1, $2) as date,
 				   outgoing_channel_id channel_id,
 				   floor(sum(outgoing_amount_msat)/1000) as amount,
 				   floor(sum(fee_msat)/1000) as revenue,
-				   count(time) as count
+				   count(time) as count,
+				   sum(` + prices.FiatAmountSql("outgoing_amount_msat", "forward.time", "$6") + `) as amount_fiat,
+				   sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$6") + `) as revenue_fiat
 			from forward
 			where ($3 or outgoing_channel_id = ANY ($4))
 				and time::timestamp AT TIME ZONE ($5) >= $1::timestamp
@@ -72,7 +92,9 @@ func getChannelHistory(db *sqlx.DB, all bool, channelIds []int, from time.Time,
 				   incoming_channel_id as channel_id,
 				   floor(sum(incoming_amount_msat)/1000) as amount,
 				   floor(sum(fee_msat)/1000) as revenue,
-				   count(time) as count
+				   count(time) as count,
+				   sum(` + prices.FiatAmountSql("incoming_amount_msat", "forward.time", "$6") + `) as amount_fiat,
+				   sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$6") + `) as revenue_fiat
 			from forward
 			where ($3 or incoming_channel_id = ANY ($4))
 				and time::timestamp AT TIME ZONE ($5) >= $1::timestamp
@@ -83,7 +105,8 @@ func getChannelHistory(db *sqlx.DB, all bool, channelIds []int, from time.Time,
 		order by date;
 	`
 
-	rows, err := db.Queryx(sql, from, to, all, pq.Array(channelIds), commons.GetSettings().PreferredTimeZone)
+	rows, err := db.Queryx(sql, from, to, all, pq.Array(channelIds), commons.GetSettings().PreferredTimeZone,
+		currency)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Getting channel history")
 	}
@@ -103,6 +126,13 @@ func getChannelHistory(db *sqlx.DB, all bool, channelIds []int, from time.Time,
 			&c.CountIn,
 			&c.CountOut,
 			&c.CountTotal,
+
+			&c.AmountInFiat,
+			&c.AmountOutFiat,
+			&c.AmountTotalFiat,
+			&c.RevenueInFiat,
+			&c.RevenueOutFiat,
+			&c.RevenueTotalFiat,
 		)
 		if err != nil {
 			return r, errors.Wrap(err, "SQL row scan")
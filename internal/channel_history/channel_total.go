@@ -6,9 +6,12 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/prices"
 )
 
-func getChannelTotal(db *sqlx.DB, all bool, channelIds []int, from time.Time, to time.Time) (r ChannelHistory, err error) {
+func getChannelTotal(db *sqlx.DB, all bool, channelIds []int, from time.Time, to time.Time,
+	currency *string) (r ChannelHistory, err error) {
 	sql := `
 		select
 			sum(coalesce(i.amount,0)) as amount_in,
@@ -21,12 +24,23 @@ func getChannelTotal(db *sqlx.DB, all bool, channelIds []int, from time.Time, to
 
 			sum(coalesce(i.count,0)) as count_in,
 			sum(coalesce(o.count,0)) as count_out,
-			sum(coalesce((i.count + o.count), 0)) as count_total
+			sum(coalesce((i.count + o.count), 0)) as count_total,
+
+			case when $5::text is null then null else sum(coalesce(i.amount_fiat,0)) end as amount_in_fiat,
+			case when $5::text is null then null else sum(coalesce(o.amount_fiat,0)) end as amount_out_fiat,
+			case when $5::text is null then null
+				else sum(coalesce(i.amount_fiat,0) + coalesce(o.amount_fiat,0)) end as amount_total_fiat,
+			case when $5::text is null then null else sum(coalesce(i.revenue_fiat,0)) end as revenue_in_fiat,
+			case when $5::text is null then null else sum(coalesce(o.revenue_fiat,0)) end as revenue_out_fiat,
+			case when $5::text is null then null
+				else sum(coalesce(i.revenue_fiat,0) + coalesce(o.revenue_fiat,0)) end as revenue_total_fiat
 		from (
 			select outgoing_channel_id,
 				   floor(sum(outgoing_amount_msat)/1000) as amount,
 				   floor(sum(fee_msat)/1000) as revenue,
-				   count(time) as count
+				   count(time) as count,
+				   sum(` + prices.FiatAmountSql("outgoing_amount_msat", "forward.time", "$5") + `) as amount_fiat,
+				   sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$5") + `) as revenue_fiat
 			from forward
 			where ($1 or outgoing_channel_id = ANY($2))
 			and time >= $3::timestamp
@@ -37,7 +51,9 @@ func getChannelTotal(db *sqlx.DB, all bool, channelIds []int, from time.Time, to
 			select incoming_channel_id,
 				   floor(sum(outgoing_amount_msat)/1000) as amount,
 				   floor(sum(fee_msat)/1000) as revenue,
-				   count(time) as count
+				   count(time) as count,
+				   sum(` + prices.FiatAmountSql("outgoing_amount_msat", "forward.time", "$5") + `) as amount_fiat,
+				   sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$5") + `) as revenue_fiat
 			from forward
 			where ($1 or incoming_channel_id = ANY($2))
 			and time >= $3::timestamp
@@ -47,7 +63,7 @@ func getChannelTotal(db *sqlx.DB, all bool, channelIds []int, from time.Time, to
 		on (i.incoming_channel_id = o.outgoing_channel_id);
 `
 
-	rows, err := db.Queryx(sql, all, pq.Array(channelIds), from, to)
+	rows, err := db.Queryx(sql, all, pq.Array(channelIds), from, to, currency)
 	if err != nil {
 		return ChannelHistory{}, errors.Wrap(err, "Getting channel total")
 	}
@@ -64,6 +80,13 @@ func getChannelTotal(db *sqlx.DB, all bool, channelIds []int, from time.Time, to
 			&r.CountIn,
 			&r.CountOut,
 			&r.CountTotal,
+
+			&r.AmountInFiat,
+			&r.AmountOutFiat,
+			&r.AmountTotalFiat,
+			&r.RevenueInFiat,
+			&r.RevenueOutFiat,
+			&r.RevenueTotalFiat,
 		)
 		if err != nil {
 			return r, errors.Wrap(err, "SQL row scan")
@@ -11,6 +11,7 @@ import (
 	"github.com/jmoiron/sqlx"
 
 	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/prices"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
@@ -45,6 +46,15 @@ type ChannelHistory struct {
 	// Number of total forwards.
 	CountTotal *uint64 `json:"countTotal"`
 
+	// The amounts and revenue in the requested currency with the price at the time of each forward.
+	// Empty when no currency was requested.
+	AmountOutFiat    *float64 `json:"amountOutFiat"`
+	AmountInFiat     *float64 `json:"amountInFiat"`
+	AmountTotalFiat  *float64 `json:"amountTotalFiat"`
+	RevenueOutFiat   *float64 `json:"revenueOutFiat"`
+	RevenueInFiat    *float64 `json:"revenueInFiat"`
+	RevenueTotalFiat *float64 `json:"revenueTotalFiat"`
+
 	// A list of channels included in this response
 	Channels []*channels.Channel      `json:"channels"`
 	History  []*ChannelHistoryRecords `json:"history"`
@@ -102,8 +112,18 @@ func getChannelHistoryHandler(c *gin.Context, db *sqlx.DB) {
 		}
 	}
 
+	currency, message, err := prices.GetCurrencyParameter(c, db)
+	if err != nil {
+		server_errors.LogAndSendServerError(c, err)
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+
 	// Get the total values for the whole requested time range (from - to)
-	r, err := getChannelTotal(db, all, channelIds, from, to)
+	r, err := getChannelTotal(db, all, channelIds, from, to, currency)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -118,7 +138,7 @@ func getChannelHistoryHandler(c *gin.Context, db *sqlx.DB) {
 	r.Channels = channels
 
 	// Get the daily values
-	chanHistory, err := getChannelHistory(db, all, channelIds, from, to)
+	chanHistory, err := getChannelHistory(db, all, channelIds, from, to, currency)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -244,13 +264,23 @@ func getChannelReBalancingHandler(c *gin.Context, db *sqlx.DB) {
 	network := c.Query("network")
 	chain := c.Query("chain")
 
+	currency, message, err := prices.GetCurrencyParameter(c, db)
+	if err != nil {
+		server_errors.LogAndSendServerError(c, err)
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+
 	var all = false
 	if len(lndShortChannelIdStrings) == 1 && lndShortChannelIdStrings[0] == "1" {
 		all = true
 	}
 
 	if all {
-		reb, err := getRebalancingCost(db, commons.GetAllTorqNodeIds(commons.GetChain(chain), commons.GetNetwork(network)), from, to)
+		reb, err := getRebalancingCost(db, commons.GetAllTorqNodeIds(commons.GetChain(chain), commons.GetNetwork(network)), from, to, currency)
 		r.RebalancingCost = &reb.TotalCostMsat
 		r.RebalancingDetails = reb
 		if err != nil {
@@ -258,7 +288,7 @@ func getChannelReBalancingHandler(c *gin.Context, db *sqlx.DB) {
 			return
 		}
 	} else {
-		reb, err := getChannelRebalancing(db, commons.GetAllTorqNodeIds(commons.GetChain(chain), commons.GetNetwork(network)), lndShortChannelIdStrings, from, to)
+		reb, err := getChannelRebalancing(db, commons.GetAllTorqNodeIds(commons.GetChain(chain), commons.GetNetwork(network)), lndShortChannelIdStrings, from, to, currency)
 		r.RebalancingCost = &reb.SplitCostMsat
 		r.RebalancingDetails = reb
 		if err != nil {
@@ -271,6 +301,9 @@ func getChannelReBalancingHandler(c *gin.Context, db *sqlx.DB) {
 
 type ChannelOnChainCost struct {
 	OnChainCost *uint64 `json:"onChainCost"`
+	// The cost in the requested currency with the price at the time of each transaction.
+	// Empty when no currency was requested.
+	OnChainCostFiat *float64 `json:"onChainCostFiat"`
 }
 
 func getTotalOnchainCostHandler(c *gin.Context, db *sqlx.DB) {
@@ -291,16 +324,52 @@ func getTotalOnchainCostHandler(c *gin.Context, db *sqlx.DB) {
 	network := c.Query("network")
 	chain := c.Query("chain")
 
+	currency, message, err := prices.GetCurrencyParameter(c, db)
+	if err != nil {
+		server_errors.LogAndSendServerError(c, err)
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+
 	var all = false
 	if len(lndShortChannelIdStrings) == 1 && lndShortChannelIdStrings[0] == "1" {
 		all = true
 	}
 
 	if all {
-		r.OnChainCost, err = getTotalOnChainCost(db, commons.GetAllTorqNodeIds(commons.GetChain(chain), commons.GetNetwork(network)), from, to)
+		r.OnChainCost, r.OnChainCostFiat, err = getTotalOnChainCost(db, commons.GetAllTorqNodeIds(commons.GetChain(chain), commons.GetNetwork(network)), from, to, currency)
 	} else {
-		r.OnChainCost, err = getChannelOnChainCost(db, lndShortChannelIdStrings)
+		r.OnChainCost, r.OnChainCostFiat, err = getChannelOnChainCost(db, lndShortChannelIdStrings, currency)
+	}
+	if err != nil {
+		server_errors.LogAndSendServerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+const WINDOW_DAYS_ERROR = "Invalid 'windowDays', it must be between 1 and 90."
+
+// getChannelPolicyHistoryHandler the policy history of a single channel, the window defaults to 7 days
+func getChannelPolicyHistoryHandler(c *gin.Context, db *sqlx.DB) {
+	windowDays := 7
+	if c.Query("windowDays") != "" {
+		var err error
+		windowDays, err = strconv.Atoi(c.Query("windowDays"))
+		if err != nil || windowDays < 1 || windowDays > 90 {
+			server_errors.SendBadRequest(c, WINDOW_DAYS_ERROR)
+			return
+		}
+	}
+	lndShortChannelId, err := strconv.ParseUint(c.Param("chanIds"), 10, 64)
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to parse the LND short channel id in the request.")
+		return
 	}
+	r, err := getChannelPolicyHistory(db, lndShortChannelId, windowDays, time.Now().UTC())
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -8,45 +8,54 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 
+	"github.com/lncapital/torq/internal/prices"
 	"github.com/lncapital/torq/pkg/commons"
 )
 
-func getTotalOnChainCost(db *sqlx.DB, nodeIds []int, from time.Time, to time.Time) (*uint64, error) {
+// getTotalOnChainCost the fiat cost is nil when no currency was requested
+func getTotalOnChainCost(db *sqlx.DB, nodeIds []int, from time.Time, to time.Time,
+	currency *string) (*uint64, *float64, error) {
 	var Cost uint64
+	var CostFiat *float64
 
 	q := `
-		select coalesce(sum(total_fees), 0) as cost
+		select coalesce(sum(total_fees), 0) as cost,
+			case when $5::text is null then null
+				else coalesce(sum(` + prices.FiatAmountSql("total_fees * 1000", "tx.timestamp", "$5") + `), 0) end as cost_fiat
 		from tx
 		where timestamp::timestamp AT TIME ZONE ($4) >= $1::timestamp
 			and timestamp::timestamp AT TIME ZONE ($4) <= $2::timestamp
 			AND node_id = ANY ($3)`
 
-	row := db.QueryRowx(q, from, to, pq.Array(nodeIds), commons.GetSettings().PreferredTimeZone)
-	err := row.Scan(&Cost)
+	row := db.QueryRowx(q, from, to, pq.Array(nodeIds), commons.GetSettings().PreferredTimeZone, currency)
+	err := row.Scan(&Cost, &CostFiat)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "SQL row scan for cost")
+		return nil, nil, errors.Wrap(err, "SQL row scan for cost")
 	}
 
-	return &Cost, nil
+	return &Cost, CostFiat, nil
 }
 
-func getChannelOnChainCost(db *sqlx.DB, lndShortChannelIdStrings []string) (cost *uint64, err error) {
+func getChannelOnChainCost(db *sqlx.DB, lndShortChannelIdStrings []string,
+	currency *string) (cost *uint64, costFiat *float64, err error) {
 
-	q := `select coalesce(sum(total_fees), 0) as on_chain_cost
+	q := `select coalesce(sum(total_fees), 0) as on_chain_cost,
+			case when $2::text is null then null
+				else coalesce(sum(` + prices.FiatAmountSql("total_fees * 1000", "tx.timestamp", "$2") + `), 0) end as on_chain_cost_fiat
 		from tx
 		where split_part(label, '-', 2) = ANY ($1)`
 
-	row := db.QueryRowx(q, pq.Array(lndShortChannelIdStrings))
-	err = row.Scan(&cost)
+	row := db.QueryRowx(q, pq.Array(lndShortChannelIdStrings), currency)
+	err = row.Scan(&cost, &costFiat)
 
 	if err == sql.ErrNoRows {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	if err != nil {
-		return nil, errors.Wrap(err, "SQL row scan for cost")
+		return nil, nil, errors.Wrap(err, "SQL row scan for cost")
 	}
 
-	return cost, nil
+	return cost, costFiat, nil
 }
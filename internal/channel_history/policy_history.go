@@ -0,0 +1,176 @@
+package channel_history
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type PolicyVersion struct {
+	Ts               time.Time `db:"ts" json:"ts"`
+	AnnouncingNodeId int       `db:"announcing_node_id" json:"announcingNodeId"`
+	Disabled         bool      `db:"disabled" json:"disabled"`
+	TimeLockDelta    uint32    `db:"time_lock_delta" json:"timeLockDelta"`
+	MinHtlc          uint64    `db:"min_htlc" json:"minHtlc"`
+	MaxHtlcMsat      uint64    `db:"max_htlc_msat" json:"maxHtlcMsat"`
+	FeeBaseMsat      uint64    `db:"fee_base_msat" json:"feeBaseMsat"`
+	FeeRateMillMsat  uint64    `db:"fee_rate_mill_msat" json:"feeRateMillMsat"`
+	// Local is true when the policy is announced by the Torq node
+	Local bool `db:"local" json:"-"`
+}
+
+type ForwardWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Number of outbound forwards, the local fee is only charged on those.
+	Count uint64 `json:"count"`
+	// The outbound amount in msat, fees are often below a sat so they aren't rounded to sats
+	AmountMsat uint64 `json:"amountMsat"`
+	// The outbound revenue in msat
+	RevenueMsat uint64 `json:"revenueMsat"`
+}
+
+// PolicyChangeImpact compares the forwards of equally long windows before and after a local policy change.
+// The windows are shortened when the previous or next change, or now, is closer than the requested window.
+type PolicyChangeImpact struct {
+	Previous PolicyVersion `json:"previous"`
+	Policy   PolicyVersion `json:"policy"`
+	Before   ForwardWindow `json:"before"`
+	After    ForwardWindow `json:"after"`
+}
+
+type ChannelPolicyHistory struct {
+	LNDShortChannelId string               `json:"lndShortChannelId"`
+	ChannelId         int                  `json:"channelId"`
+	WindowDays        int                  `json:"windowDays"`
+	LocalPolicies     []PolicyVersion      `json:"localPolicies"`
+	RemotePolicies    []PolicyVersion      `json:"remotePolicies"`
+	Impacts           []PolicyChangeImpact `json:"impacts"`
+}
+
+type policyForward struct {
+	Time       time.Time `db:"time"`
+	AmountMsat uint64    `db:"outgoing_amount_msat"`
+	FeeMsat    uint64    `db:"fee_msat"`
+}
+
+func getChannelPolicyHistory(db *sqlx.DB, lndShortChannelId uint64, windowDays int,
+	now time.Time) (ChannelPolicyHistory, error) {
+
+	channelId := commons.GetChannelIdByShortChannelId(commons.ConvertLNDShortChannelID(lndShortChannelId))
+	history := ChannelPolicyHistory{
+		LNDShortChannelId: strconv.FormatUint(lndShortChannelId, 10),
+		ChannelId:         channelId,
+		WindowDays:        windowDays,
+		LocalPolicies:     []PolicyVersion{},
+		RemotePolicies:    []PolicyVersion{},
+		Impacts:           []PolicyChangeImpact{},
+	}
+
+	// Only the updates and forwards seen by one torq node are used when both ends of the channel are managed by Torq
+	var nodeId *int
+	err := db.Get(&nodeId, `SELECT MIN(node_id) FROM routing_policy WHERE channel_id=$1;`, channelId)
+	if err != nil {
+		return ChannelPolicyHistory{}, errors.Wrap(err, "SQL run query")
+	}
+	if nodeId == nil {
+		return history, nil
+	}
+	var policies []PolicyVersion
+	err = db.Select(&policies, `
+		SELECT ts, announcing_node_id, disabled, time_lock_delta, min_htlc, max_htlc_msat, fee_base_msat,
+			fee_rate_mill_msat, announcing_node_id = node_id AS local
+		FROM routing_policy
+		WHERE channel_id=$1 AND node_id=$2
+		ORDER BY ts;`, channelId, *nodeId)
+	if err != nil {
+		return ChannelPolicyHistory{}, errors.Wrap(err, "SQL run query")
+	}
+	for _, policy := range policies {
+		if policy.Local {
+			history.LocalPolicies = append(history.LocalPolicies, policy)
+		} else {
+			history.RemotePolicies = append(history.RemotePolicies, policy)
+		}
+	}
+
+	window := time.Duration(windowDays) * 24 * time.Hour
+	windows := policyChangeWindows(history.LocalPolicies, window, now)
+	if len(windows) == 0 {
+		return history, nil
+	}
+	var forwards []policyForward
+	err = db.Select(&forwards, `
+		SELECT time, outgoing_amount_msat, fee_msat
+		FROM forward
+		WHERE outgoing_channel_id=$1 AND node_id=$4 AND time >= $2 AND time < $3
+		ORDER BY time;`, channelId, windows[0].Before.From, windows[len(windows)-1].After.To, *nodeId)
+	if err != nil {
+		return ChannelPolicyHistory{}, errors.Wrap(err, "SQL run query")
+	}
+	history.Impacts = addForwards(windows, forwards)
+	return history, nil
+}
+
+// policyChangeWindows the windows around the local versions that changed the fees or the disabled flag.
+// Updates of i.e. only the max HTLC are not considered a change.
+func policyChangeWindows(localPolicies []PolicyVersion, window time.Duration, now time.Time) []PolicyChangeImpact {
+	var changes []PolicyChangeImpact
+	for i := 1; i < len(localPolicies); i++ {
+		previous := localPolicies[0]
+		if len(changes) != 0 {
+			previous = changes[len(changes)-1].Policy
+		}
+		policy := localPolicies[i]
+		if policy.FeeBaseMsat == previous.FeeBaseMsat && policy.FeeRateMillMsat == previous.FeeRateMillMsat &&
+			policy.Disabled == previous.Disabled {
+			continue
+		}
+		changes = append(changes, PolicyChangeImpact{Previous: previous, Policy: policy})
+	}
+	for i := range changes {
+		change := changes[i].Policy.Ts
+		length := window
+		if change.Sub(changes[i].Previous.Ts) < length {
+			length = change.Sub(changes[i].Previous.Ts)
+		}
+		if i < len(changes)-1 && changes[i+1].Policy.Ts.Sub(change) < length {
+			length = changes[i+1].Policy.Ts.Sub(change)
+		}
+		if now.Sub(change) < length {
+			length = now.Sub(change)
+		}
+		if length < 0 {
+			length = 0
+		}
+		changes[i].Before = ForwardWindow{From: change.Add(-length), To: change}
+		changes[i].After = ForwardWindow{From: change, To: change.Add(length)}
+	}
+	return changes
+}
+
+// addForwards the forwards must be sorted by time
+func addForwards(changes []PolicyChangeImpact, forwards []policyForward) []PolicyChangeImpact {
+	add := func(forwardWindow *ForwardWindow) {
+		for _, forward := range forwards {
+			if forward.Time.Before(forwardWindow.From) {
+				continue
+			}
+			if !forward.Time.Before(forwardWindow.To) {
+				break
+			}
+			forwardWindow.Count++
+			forwardWindow.AmountMsat += forward.AmountMsat
+			forwardWindow.RevenueMsat += forward.FeeMsat
+		}
+	}
+	for i := range changes {
+		add(&changes[i].Before)
+		add(&changes[i].After)
+	}
+	return changes
+}
@@ -0,0 +1,68 @@
+package channel_history
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPolicyChangeWindows(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	local := []PolicyVersion{
+		{Ts: start, FeeRateMillMsat: 100},
+		// Only the max HTLC changed
+		{Ts: start.Add(2 * day), FeeRateMillMsat: 100, MaxHtlcMsat: 5000},
+		{Ts: start.Add(10 * day), FeeRateMillMsat: 200},
+		{Ts: start.Add(13 * day), FeeRateMillMsat: 300},
+	}
+	now := start.Add(14 * day)
+
+	changes := policyChangeWindows(local, 7*day, now)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes got %v", len(changes))
+	}
+	// The next change is 3 days later
+	if changes[0].Previous.Ts != start || changes[0].Before.From != start.Add(7*day) ||
+		changes[0].After.To != start.Add(13*day) {
+		t.Errorf("unexpected first windows %+v", changes[0])
+	}
+	// Now is 1 day later
+	if changes[1].Before.From != start.Add(12*day) || changes[1].After.To != now {
+		t.Errorf("unexpected second windows %+v", changes[1])
+	}
+
+	forwards := []policyForward{
+		{Time: start.Add(8 * day), AmountMsat: 1000000, FeeMsat: 100},
+		{Time: start.Add(9 * day), AmountMsat: 2000000, FeeMsat: 200},
+		{Time: start.Add(11 * day), AmountMsat: 1000000, FeeMsat: 200},
+		{Time: start.Add(13*day + time.Hour), AmountMsat: 1000000, FeeMsat: 300},
+	}
+	changes = addForwards(changes, forwards)
+	if changes[0].Before.Count != 2 || changes[0].Before.AmountMsat != 3000000 || changes[0].Before.RevenueMsat != 300 {
+		t.Errorf("unexpected before %+v", changes[0].Before)
+	}
+	if changes[0].After.Count != 1 || changes[0].After.RevenueMsat != 200 {
+		t.Errorf("unexpected after %+v", changes[0].After)
+	}
+	if changes[1].Before.Count != 0 || changes[1].After.Count != 1 || changes[1].After.RevenueMsat != 300 {
+		t.Errorf("unexpected second change %+v", changes[1])
+	}
+}
+
+func TestGetChannelPolicyHistoryHandlerInvalidChannelId(t *testing.T) {
+	for _, chanIds := range []string{"abc", "-1", "123x"} {
+		response := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(response)
+		c.Request = httptest.NewRequest("GET", "/api/channels/"+chanIds+"/policy-history", nil)
+		c.Params = gin.Params{{Key: "chanIds", Value: chanIds}}
+		// The database isn't used for an invalid channel id
+		getChannelPolicyHistoryHandler(c, nil)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("expected a bad request for %q, got %v", chanIds, response.Code)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 
+	"github.com/lncapital/torq/internal/prices"
 	"github.com/lncapital/torq/pkg/commons"
 )
 
@@ -16,9 +17,14 @@ type RebalancingDetails struct {
 	TotalCostMsat uint64 `db:"total_cost_msat" json:"totalCostMsat"`
 	SplitCostMsat uint64 `db:"split_cost_msat" json:"splitCostMsat"`
 	Count         uint64 `db:"count" json:"count"`
+	// The costs in the requested currency with the price at the time of each rebalance.
+	// Empty when no currency was requested.
+	TotalCostFiat *float64 `db:"total_cost_fiat" json:"totalCostFiat"`
+	SplitCostFiat *float64 `db:"split_cost_fiat" json:"splitCostFiat"`
 }
 
-func getRebalancingCost(db *sqlx.DB, nodeIds []int, from time.Time, to time.Time) (RebalancingDetails, error) {
+func getRebalancingCost(db *sqlx.DB, nodeIds []int, from time.Time, to time.Time,
+	currency *string) (RebalancingDetails, error) {
 	settings := commons.GetSettings()
 
 	var publicKeys []string
@@ -29,22 +35,28 @@ func getRebalancingCost(db *sqlx.DB, nodeIds []int, from time.Time, to time.Time
 	row := db.QueryRow(`
 		SELECT COALESCE(ROUND(SUM(amount_msat)),0) AS amount_msat,
 			   COALESCE(ROUND(SUM(total_fee_msat)),0) AS total_cost_msat,
-			   COALESCE(COUNT(*), 0) AS count
+			   COALESCE(COUNT(*), 0) AS count,
+			   CASE WHEN $5::text IS NULL OR COUNT(total_fee_fiat) < COUNT(*) THEN NULL
+				   ELSE COALESCE(SUM(total_fee_fiat),0) END AS total_cost_fiat
 		FROM (
 			SELECT creation_timestamp at time zone ($4),
 				   value_msat as amount_msat,
-				   fee_msat as total_fee_msat
+				   fee_msat as total_fee_msat,
+				   `+prices.FiatAmountSql("fee_msat", "p.creation_timestamp", "$5")+` as total_fee_fiat
 			FROM payment p
 			WHERE status = 'SUCCEEDED' AND
-				htlcs->-1->'route'->'hops'->-1->>'pub_key' = ANY($1) AND
+				-- Rebalances made by Torq are tagged, others are recognised by the last hop being one of our nodes
+				(payment_hash IN (SELECT payment_hash FROM rebalance_result WHERE status = 'SUCCEEDED') OR
+				htlcs->-1->'route'->'hops'->-1->>'pub_key' = ANY($1)) AND
 				creation_timestamp::timestamp AT TIME ZONE ($4) >= $2::timestamp AND
 				creation_timestamp::timestamp AT TIME ZONE ($4) <= $3::timestamp
-		) AS a;`, pq.Array(publicKeys), from, to, settings.PreferredTimeZone)
+		) AS a;`, pq.Array(publicKeys), from, to, settings.PreferredTimeZone, currency)
 	var cost RebalancingDetails
 	err := row.Scan(
 		&cost.AmountMsat,
 		&cost.TotalCostMsat,
 		&cost.Count,
+		&cost.TotalCostFiat,
 	)
 
 	if err == sql.ErrNoRows {
@@ -60,7 +72,7 @@ func getRebalancingCost(db *sqlx.DB, nodeIds []int, from time.Time, to time.Time
 }
 
 func getChannelRebalancing(db *sqlx.DB, nodeIds []int, lndShortChannelIdStrings []string,
-	from time.Time, to time.Time) (RebalancingDetails, error) {
+	from time.Time, to time.Time, currency *string) (RebalancingDetails, error) {
 
 	var publicKeys []string
 	for _, nodeId := range nodeIds {
@@ -73,34 +85,49 @@ func getChannelRebalancing(db *sqlx.DB, nodeIds []int, lndShortChannelIdStrings
 		SELECT COALESCE(ROUND(SUM(amount_msat)),0) AS amount_msat,
 			   COALESCE(ROUND(SUM(total_fee_msat)),0) AS total_cost_msat,
 			   COALESCE(ROUND(SUM(split_fee_msat)),0) AS split_cost_msat,
-			   COALESCE(COUNT(*), 0) AS count
+			   COALESCE(COUNT(*), 0) AS count,
+			   -- NULL when the price of a rebalance is missing
+			   CASE WHEN $7::text IS NULL OR COUNT(total_fee_fiat) < COUNT(*) THEN NULL
+				   ELSE COALESCE(SUM(total_fee_fiat),0) END AS total_cost_fiat,
+			   CASE WHEN $7::text IS NULL OR COUNT(split_fee_fiat) < COUNT(*) THEN NULL
+				   ELSE COALESCE(SUM(split_fee_fiat),0) END AS split_cost_fiat
 		from (
-			select creation_timestamp at time zone ($5),
-				   value_msat as amount_msat,
-				   fee_msat as total_fee_msat,
-				   case
-				   when
-					   -- When two channels in the same group is involved, return the full rebalancing cost.
-					   htlcs->-1->'route'->'hops'->0->>'chan_id' = ANY($1) and
-					   htlcs->-1->'route'->'hops'->-1->>'chan_id' = ANY($1)
-					   then fee_msat
-				   when
-					   -- When only one channel in the group is involved, return half the rebalancing cost.
-					   htlcs->-1->'route'->'hops'->0->>'chan_id' = ANY($1) or
-					   htlcs->-1->'route'->'hops'->-1->>'chan_id' = ANY($1)
-					   then fee_msat/2
-				   end as split_fee_msat
-			from payment p
-			where status = 'SUCCEEDED'
-			and (
-				htlcs->-1->'route'->'hops'->0->>'chan_id' = ANY($1)
-				or htlcs->-1->'route'->'hops'->-1->>'chan_id' = ANY($1)
-			)
-			and htlcs->-1->'route'->'hops'->-1->>'pub_key' = ANY($4)
-			and creation_timestamp::timestamp AT TIME ZONE ($5) >= ($2)::timestamp
-			and creation_timestamp::timestamp AT TIME ZONE ($5) <= ($3)::timestamp
-			and node_id = ANY ($6)
-		) AS a;`, pq.Array(lndShortChannelIdStrings), from, to, pq.Array(publicKeys), settings.PreferredTimeZone, pq.Array(nodeIds))
+			select amount_msat,
+				   total_fee_msat,
+				   split_fee_msat,
+				   `+prices.FiatAmountSql("total_fee_msat", "p.creation_timestamp", "$7")+` as total_fee_fiat,
+				   `+prices.FiatAmountSql("split_fee_msat", "p.creation_timestamp", "$7")+` as split_fee_fiat
+			from (
+				select creation_timestamp,
+					   value_msat as amount_msat,
+					   fee_msat as total_fee_msat,
+					   case
+					   when
+						   -- When two channels in the same group is involved, return the full rebalancing cost.
+						   htlcs->-1->'route'->'hops'->0->>'chan_id' = ANY($1) and
+						   htlcs->-1->'route'->'hops'->-1->>'chan_id' = ANY($1)
+						   then fee_msat
+					   when
+						   -- When only one channel in the group is involved, return half the rebalancing cost.
+						   htlcs->-1->'route'->'hops'->0->>'chan_id' = ANY($1) or
+						   htlcs->-1->'route'->'hops'->-1->>'chan_id' = ANY($1)
+						   then fee_msat/2
+					   end as split_fee_msat
+				from payment p
+				where status = 'SUCCEEDED'
+				and (
+					htlcs->-1->'route'->'hops'->0->>'chan_id' = ANY($1)
+					or htlcs->-1->'route'->'hops'->-1->>'chan_id' = ANY($1)
+				)
+				-- Rebalances made by Torq are tagged, others are recognised by the last hop being one of our nodes
+				and (payment_hash IN (SELECT payment_hash FROM rebalance_result WHERE status = 'SUCCEEDED')
+					or htlcs->-1->'route'->'hops'->-1->>'pub_key' = ANY($4))
+				and creation_timestamp::timestamp AT TIME ZONE ($5) >= ($2)::timestamp
+				and creation_timestamp::timestamp AT TIME ZONE ($5) <= ($3)::timestamp
+				and node_id = ANY ($6)
+			) AS p
+		) AS a;`, pq.Array(lndShortChannelIdStrings), from, to, pq.Array(publicKeys), settings.PreferredTimeZone, pq.Array(nodeIds),
+		currency)
 
 	var cost RebalancingDetails
 	err := row.Scan(
@@ -108,6 +135,8 @@ func getChannelRebalancing(db *sqlx.DB, nodeIds []int, lndShortChannelIdStrings
 		&cost.TotalCostMsat,
 		&cost.SplitCostMsat,
 		&cost.Count,
+		&cost.TotalCostFiat,
+		&cost.SplitCostFiat,
 	)
 
 	if err == sql.ErrNoRows {
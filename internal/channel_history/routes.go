@@ -11,4 +11,6 @@ func RegisterChannelHistoryRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET(":chanIds/balance", func(c *gin.Context) { getChannelBalanceHandler(c, db) })
 	r.GET(":chanIds/rebalancing", func(c *gin.Context) { getChannelReBalancingHandler(c, db) })
 	r.GET(":chanIds/onchaincost", func(c *gin.Context) { getTotalOnchainCostHandler(c, db) })
+	// Only a single LND short channel id is supported, the wildcard has the same name as the other routes for gin
+	r.GET(":chanIds/policy-history", func(c *gin.Context) { getChannelPolicyHistoryHandler(c, db) })
 }
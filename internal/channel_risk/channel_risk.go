@@ -0,0 +1,250 @@
+package channel_risk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/peers"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type RiskLevel string
+
+const (
+	Ok       = RiskLevel("OK")
+	Warning  = RiskLevel("WARNING")
+	Critical = RiskLevel("CRITICAL")
+)
+
+type ChannelRisk struct {
+	NodeId       int       `json:"nodeId"`
+	ChannelId    int       `json:"channelId"`
+	RemoteNodeId int       `json:"remoteNodeId"`
+	Level        RiskLevel `json:"level"`
+	Reasons      []string  `json:"reasons"`
+	// BlockHeight the height the HTLCs were checked against, 0 when no block was received yet
+	BlockHeight      uint32 `json:"blockHeight"`
+	PendingHtlcCount int    `json:"pendingHtlcCount"`
+	// ClosestHtlcExpiryBlocks the blocks until the first pending HTLC expires, nil without pending HTLCs
+	ClosestHtlcExpiryBlocks *int64 `json:"closestHtlcExpiryBlocks"`
+	// ExpiringHtlcCount the pending HTLCs within the warning threshold of their expiry
+	ExpiringHtlcCount  int   `json:"expiringHtlcCount"`
+	ExpiringHtlcAmount int64 `json:"expiringHtlcAmount"`
+	// PeerOfflineSeconds how long the peer is offline, 0 when it is online
+	PeerOfflineSeconds int64     `json:"peerOfflineSeconds"`
+	EvaluatedOn        time.Time `json:"evaluatedOn"`
+}
+
+// channelRiskKey a channel between two Torq nodes has a risk for each node
+type channelRiskKey struct {
+	nodeId    int
+	channelId int
+}
+
+var (
+	blockHeightsMutex sync.RWMutex                           //nolint:gochecknoglobals
+	blockHeights      = make(map[int]uint32)                 //nolint:gochecknoglobals
+	channelRisksMutex sync.RWMutex                           //nolint:gochecknoglobals
+	channelRisks      = make(map[channelRiskKey]ChannelRisk) //nolint:gochecknoglobals
+)
+
+// GetChannelRisks the latest risk of the channels of the node, by channel id
+func GetChannelRisks(nodeId int) map[int]ChannelRisk {
+	channelRisksMutex.RLock()
+	defer channelRisksMutex.RUnlock()
+	result := make(map[int]ChannelRisk)
+	for key, risk := range channelRisks {
+		if key.nodeId == nodeId {
+			result[key.channelId] = risk
+		}
+	}
+	return result
+}
+
+// GetBlockHeight the last block height received from the node, 0 when no block was received yet
+func GetBlockHeight(nodeId int) uint32 {
+	blockHeightsMutex.RLock()
+	defer blockHeightsMutex.RUnlock()
+	return blockHeights[nodeId]
+}
+
+// Monitor keeps the block height of every node and evaluates the channels on every block and periodically.
+// A ChannelRiskEvent is sent when the risk level of a channel changes.
+func Monitor(ctx context.Context, db *sqlx.DB, broadcaster broadcast.BroadcastServer,
+	eventChannel chan interface{}) {
+
+	// The evaluation sends events so it can't run in the listener loop (the broadcaster waits for the listener)
+	evaluate := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(commons.CHANNEL_RISK_TICKER_SECONDS * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-evaluate:
+			}
+			nodeIds := commons.GetAllActiveTorqNodeIds(nil, nil)
+			removeInactiveNodes(nodeIds)
+			for _, nodeId := range nodeIds {
+				err := evaluateNode(db, nodeId, time.Now().UTC(), eventChannel)
+				if err != nil {
+					log.Error().Err(err).Msgf("Evaluating channel risks failed for nodeId: %v", nodeId)
+				}
+			}
+		}
+	}()
+
+	listener := broadcaster.Subscribe()
+	defer broadcaster.CancelSubscription(listener)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-listener:
+			if !ok {
+				return
+			}
+			if blockEvent, isBlockEvent := event.(commons.BlockEvent); isBlockEvent {
+				blockHeightsMutex.Lock()
+				blockHeights[blockEvent.NodeId] = blockEvent.Height
+				blockHeightsMutex.Unlock()
+				select {
+				case evaluate <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func evaluateNode(db *sqlx.DB, nodeId int, now time.Time, eventChannel chan interface{}) error {
+	offlinePeers, err := peers.GetOfflinePeers(db, nodeId)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining offline peers")
+	}
+	blockHeight := GetBlockHeight(nodeId)
+	thresholds := commons.GetSettings().ChannelRisk
+	var events []commons.ChannelRiskEvent
+	channelRisksMutex.Lock()
+	openChannels := make(map[channelRiskKey]bool)
+	for _, channel := range commons.GetChannelStates(nodeId, true) {
+		var offlineSince *time.Time
+		if since, offline := offlinePeers[channel.RemoteNodeId]; offline {
+			offlineSince = &since
+		}
+		risk := evaluateChannel(channel, blockHeight, offlineSince, now, thresholds)
+		key := channelRiskKey{nodeId: nodeId, channelId: channel.ChannelId}
+		openChannels[key] = true
+		previousLevel := Ok
+		if previous, exists := channelRisks[key]; exists {
+			previousLevel = previous.Level
+		}
+		channelRisks[key] = risk
+		if risk.Level != previousLevel {
+			events = append(events, commons.ChannelRiskEvent{
+				EventData:     commons.EventData{EventTime: now, NodeId: nodeId},
+				ChannelId:     risk.ChannelId,
+				RemoteNodeId:  risk.RemoteNodeId,
+				Level:         string(risk.Level),
+				PreviousLevel: string(previousLevel),
+				Reasons:       risk.Reasons,
+			})
+		}
+	}
+	// Closed channels are no longer in the channel states
+	for key := range channelRisks {
+		if key.nodeId == nodeId && !openChannels[key] {
+			delete(channelRisks, key)
+		}
+	}
+	channelRisksMutex.Unlock()
+	if eventChannel != nil {
+		for _, event := range events {
+			eventChannel <- event
+		}
+	}
+	return nil
+}
+
+// removeInactiveNodes the risks of nodes that are no longer active are removed
+func removeInactiveNodes(activeNodeIds []int) {
+	active := make(map[int]bool, len(activeNodeIds))
+	for _, nodeId := range activeNodeIds {
+		active[nodeId] = true
+	}
+	channelRisksMutex.Lock()
+	defer channelRisksMutex.Unlock()
+	for key := range channelRisks {
+		if !active[key.nodeId] {
+			delete(channelRisks, key)
+		}
+	}
+}
+
+// evaluateChannel HTLCs are only checked when the block height is known
+func evaluateChannel(channel commons.ManagedChannelStateSettings, blockHeight uint32, offlineSince *time.Time,
+	now time.Time, thresholds commons.ChannelRiskSettings) ChannelRisk {
+
+	risk := ChannelRisk{
+		NodeId:           channel.NodeId,
+		ChannelId:        channel.ChannelId,
+		RemoteNodeId:     channel.RemoteNodeId,
+		Level:            Ok,
+		Reasons:          []string{},
+		BlockHeight:      blockHeight,
+		PendingHtlcCount: len(channel.PendingHtlcs),
+		EvaluatedOn:      now,
+	}
+	if blockHeight != 0 {
+		for _, htlc := range channel.PendingHtlcs {
+			blocksLeft := int64(htlc.ExpirationHeight) - int64(blockHeight)
+			if risk.ClosestHtlcExpiryBlocks == nil || blocksLeft < *risk.ClosestHtlcExpiryBlocks {
+				risk.ClosestHtlcExpiryBlocks = &blocksLeft
+			}
+			if blocksLeft <= thresholds.HtlcWarningBlocks {
+				risk.ExpiringHtlcCount++
+				risk.ExpiringHtlcAmount += htlc.Amount
+			}
+		}
+		if risk.ExpiringHtlcCount != 0 {
+			level := Warning
+			if *risk.ClosestHtlcExpiryBlocks <= thresholds.HtlcCriticalBlocks {
+				level = Critical
+			}
+			risk.raise(level, fmt.Sprintf("%v pending HTLC(s) of %v sat expire within %v blocks.",
+				risk.ExpiringHtlcCount, risk.ExpiringHtlcAmount, *risk.ClosestHtlcExpiryBlocks))
+		}
+	}
+	if offlineSince != nil {
+		offline := now.Sub(*offlineSince)
+		risk.PeerOfflineSeconds = int64(offline.Seconds())
+		switch {
+		case offline >= time.Duration(thresholds.PeerOfflineCriticalSeconds)*time.Second:
+			risk.raise(Critical, fmt.Sprintf("The peer is offline for %v.", offline.Round(time.Minute)))
+		case offline >= time.Duration(thresholds.PeerOfflineWarningSeconds)*time.Second:
+			risk.raise(Warning, fmt.Sprintf("The peer is offline for %v.", offline.Round(time.Minute)))
+		}
+		// HTLCs can only be resolved off-chain when the peer comes back
+		if risk.ExpiringHtlcCount != 0 {
+			risk.raise(Critical, "The peer is offline while HTLCs are about to expire.")
+		}
+	}
+	return risk
+}
+
+// raise the level never goes down by another reason
+func (risk *ChannelRisk) raise(level RiskLevel, reason string) {
+	if level == Critical || risk.Level == Ok {
+		risk.Level = level
+	}
+	risk.Reasons = append(risk.Reasons, reason)
+}
@@ -0,0 +1,108 @@
+package channel_risk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestEvaluateChannel(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	blockHeight := uint32(800000)
+	htlcs := func(blocksLeft ...uint32) []commons.Htlc {
+		var result []commons.Htlc
+		for _, blocks := range blocksLeft {
+			result = append(result, commons.Htlc{Amount: 1000, ExpirationHeight: blockHeight + blocks})
+		}
+		return result
+	}
+	recently := now.Add(-time.Hour)
+	longAgo := now.Add(-commons.CHANNEL_RISK_DEFAULT_PEER_OFFLINE_WARNING_SECONDS * time.Second)
+	veryLongAgo := now.Add(-commons.CHANNEL_RISK_DEFAULT_PEER_OFFLINE_CRITICAL_SECONDS * time.Second)
+
+	tests := []struct {
+		name          string
+		htlcs         []commons.Htlc
+		blockHeight   uint32
+		offlineSince  *time.Time
+		level         RiskLevel
+		expiringCount int
+	}{
+		{"no htlcs", nil, blockHeight, nil, Ok, 0},
+		{"htlcs far from expiry", htlcs(500, 200), blockHeight, nil, Ok, 0},
+		{"htlc within warning", htlcs(500, commons.CHANNEL_RISK_DEFAULT_HTLC_WARNING_BLOCKS), blockHeight, nil, Warning, 1},
+		{"htlc within critical", htlcs(50, commons.CHANNEL_RISK_DEFAULT_HTLC_CRITICAL_BLOCKS), blockHeight, nil, Critical, 2},
+		{"unknown block height", htlcs(1), 0, nil, Ok, 0},
+		{"peer recently offline", nil, blockHeight, &recently, Ok, 0},
+		{"peer offline", nil, blockHeight, &longAgo, Warning, 0},
+		{"peer offline for long", nil, blockHeight, &veryLongAgo, Critical, 0},
+		{"peer offline with expiring htlc", htlcs(50), blockHeight, &recently, Critical, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channel := commons.ManagedChannelStateSettings{NodeId: 1, ChannelId: 2, RemoteNodeId: 3,
+				PendingHtlcs: test.htlcs}
+			risk := evaluateChannel(channel, test.blockHeight, test.offlineSince, now,
+				commons.DefaultChannelRiskSettings())
+			if risk.Level != test.level {
+				t.Errorf("expected level %v, got %v (%v)", test.level, risk.Level, risk.Reasons)
+			}
+			if risk.ExpiringHtlcCount != test.expiringCount {
+				t.Errorf("expected %v expiring HTLCs, got %v", test.expiringCount, risk.ExpiringHtlcCount)
+			}
+			if risk.Level != Ok && len(risk.Reasons) == 0 {
+				t.Errorf("expected a reason for level %v", risk.Level)
+			}
+		})
+	}
+}
+
+func TestClosestHtlcExpiryBlocks(t *testing.T) {
+	channel := commons.ManagedChannelStateSettings{PendingHtlcs: []commons.Htlc{
+		{ExpirationHeight: 1200}, {ExpirationHeight: 1040}, {ExpirationHeight: 1100},
+	}}
+	risk := evaluateChannel(channel, 1000, nil, time.Now(), commons.DefaultChannelRiskSettings())
+	if risk.ClosestHtlcExpiryBlocks == nil || *risk.ClosestHtlcExpiryBlocks != 40 {
+		t.Errorf("expected the closest HTLC to expire in 40 blocks, got %v", risk.ClosestHtlcExpiryBlocks)
+	}
+	if risk.PendingHtlcCount != 3 {
+		t.Errorf("expected 3 pending HTLCs, got %v", risk.PendingHtlcCount)
+	}
+}
+
+func TestEvaluateChannelThresholds(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	offlineSince := now.Add(-2 * time.Hour)
+	channel := commons.ManagedChannelStateSettings{PendingHtlcs: []commons.Htlc{{ExpirationHeight: 1100}}}
+	thresholds := commons.ChannelRiskSettings{HtlcWarningBlocks: 144, HtlcCriticalBlocks: 100,
+		PeerOfflineWarningSeconds: 60 * 60, PeerOfflineCriticalSeconds: 24 * 60 * 60}
+
+	if risk := evaluateChannel(channel, 1000, nil, now, commons.DefaultChannelRiskSettings()); risk.Level != Ok {
+		t.Errorf("expected the default thresholds to ignore the HTLC, got %v", risk.Level)
+	}
+	if risk := evaluateChannel(channel, 1000, nil, now, thresholds); risk.Level != Critical {
+		t.Errorf("expected the HTLC to be critical with the configured thresholds, got %v", risk.Level)
+	}
+	channel.PendingHtlcs = nil
+	if risk := evaluateChannel(channel, 1000, &offlineSince, now, thresholds); risk.Level != Warning {
+		t.Errorf("expected the offline peer to be a warning with the configured thresholds, got %v", risk.Level)
+	}
+}
+
+func TestRemoveInactiveNodes(t *testing.T) {
+	channelRisksMutex.Lock()
+	channelRisks[channelRiskKey{nodeId: 1, channelId: 5}] = ChannelRisk{NodeId: 1, ChannelId: 5}
+	// The same channel seen from the other Torq node
+	channelRisks[channelRiskKey{nodeId: 2, channelId: 5}] = ChannelRisk{NodeId: 2, ChannelId: 5}
+	channelRisksMutex.Unlock()
+	defer removeInactiveNodes(nil)
+
+	if len(GetChannelRisks(1)) != 1 || len(GetChannelRisks(2)) != 1 {
+		t.Fatalf("expected a risk for the channel of both nodes")
+	}
+	removeInactiveNodes([]int{1})
+	if len(GetChannelRisks(1)) != 1 || len(GetChannelRisks(2)) != 0 {
+		t.Errorf("expected only the risks of the inactive node to be removed")
+	}
+}
@@ -0,0 +1,53 @@
+package channel_risk
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterChannelRiskRoutes(r *gin.RouterGroup) {
+	// i.e. ?nodeId=1
+	r.GET("", func(c *gin.Context) { getChannelRisksHandler(c) })
+}
+
+func getChannelRisksHandler(c *gin.Context) {
+	nodeIds := commons.GetAllActiveTorqNodeIds(nil, nil)
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequestFromError(c, errors.Wrap(err, "Getting node id"))
+			return
+		}
+		nodeIds = []int{nodeId}
+	}
+	result := []ChannelRisk{}
+	for _, nodeId := range nodeIds {
+		for _, risk := range GetChannelRisks(nodeId) {
+			result = append(result, risk)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if severity(result[i].Level) != severity(result[j].Level) {
+			return severity(result[i].Level) > severity(result[j].Level)
+		}
+		return result[i].ChannelId < result[j].ChannelId
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func severity(level RiskLevel) int {
+	switch level {
+	case Critical:
+		return 2
+	case Warning:
+		return 1
+	}
+	return 0
+}
@@ -9,33 +9,25 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
 
-	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
-func batchOpenChannels(db *sqlx.DB, req commons.BatchOpenRequest) (r commons.BatchOpenResponse, err error) {
-	bOpenChanReq, err := checkPrepareReq(req)
+func BatchOpenChannels(db *sqlx.DB, req commons.BatchOpenRequest) (r commons.BatchOpenResponse, err error) {
+	actions, err := getChannelActions(db, req.NodeId)
 	if err != nil {
 		return commons.BatchOpenResponse{}, err
 	}
+	return actions.batchOpenChannels(context.Background(), req)
+}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
-	if err != nil {
-		return commons.BatchOpenResponse{}, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+func (actions lndChannelActions) batchOpenChannels(ctx context.Context,
+	req commons.BatchOpenRequest) (r commons.BatchOpenResponse, err error) {
+	bOpenChanReq, err := checkPrepareReq(req)
 	if err != nil {
-		return commons.BatchOpenResponse{}, errors.Wrap(err, "Connecting to LND")
+		return commons.BatchOpenResponse{}, err
 	}
-	defer conn.Close()
 
-	client := lnrpc.NewLightningClient(conn)
-	ctx := context.Background()
+	client := lnrpc.NewLightningClient(actions.conn)
 
 	bocResponse, err := client.BatchOpenChannel(ctx, bOpenChanReq)
 	if err != nil {
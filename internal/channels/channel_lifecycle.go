@@ -0,0 +1,317 @@
+package channels
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+type ChannelLifecycleType string
+
+const (
+	LifecycleOpen  = ChannelLifecycleType("OPEN")
+	LifecycleClose = ChannelLifecycleType("CLOSE")
+)
+
+type ChannelLifecycleStatus string
+
+const (
+	LifecyclePending   = ChannelLifecycleStatus("PENDING")
+	LifecycleCompleted = ChannelLifecycleStatus("COMPLETED")
+	// LifecycleFailed the funding transaction never confirmed (i.e. the channel was abandoned)
+	LifecycleFailed = ChannelLifecycleStatus("FAILED")
+)
+
+type ChannelLifecycle struct {
+	ChannelLifecycleId int                    `json:"channelLifecycleId" db:"channel_lifecycle_id"`
+	NodeId             int                    `json:"nodeId" db:"node_id"`
+	ChannelPoint       string                 `json:"channelPoint" db:"channel_point"`
+	LifecycleType      ChannelLifecycleType   `json:"lifecycleType" db:"lifecycle_type"`
+	Status             ChannelLifecycleStatus `json:"status" db:"status"`
+	RemotePublicKey    string                 `json:"remotePublicKey" db:"remote_public_key"`
+	Capacity           int64                  `json:"capacity" db:"capacity"`
+	Txid               *string                `json:"txid" db:"txid"`
+	Confirmations      int64                  `json:"confirmations" db:"confirmations"`
+	FeeSat             *int64                 `json:"feeSat" db:"fee_sat"`
+	FeeRateSatPerVbyte *float64               `json:"feeRateSatPerVbyte" db:"fee_rate_sat_per_vbyte"`
+	BumpOutputIndex    *int                   `json:"bumpOutputIndex" db:"bump_output_index"`
+	BumpedOn           *time.Time             `json:"bumpedOn" db:"bumped_on"`
+	StuckAlertedOn     *time.Time             `json:"stuckAlertedOn" db:"stuck_alerted_on"`
+	PendingOn          time.Time              `json:"pendingOn" db:"pending_on"`
+	CompletedOn        *time.Time             `json:"completedOn" db:"completed_on"`
+	UpdatedOn          time.Time              `json:"updatedOn" db:"updated_on"`
+	// WaitingSeconds the time since the channel became pending until it completed (or until now)
+	WaitingSeconds int64 `json:"waitingSeconds" db:"-"`
+}
+
+type BumpFeeRequest struct {
+	ChannelLifecycleId int     `json:"channelLifecycleId"`
+	SatPerVbyte        *uint64 `json:"satPerVbyte"`
+	TargetConf         *uint32 `json:"targetConf"`
+}
+
+type walletClientBumpFee interface {
+	BumpFee(ctx context.Context, in *walletrpc.BumpFeeRequest,
+		opts ...grpc.CallOption) (*walletrpc.BumpFeeResponse, error)
+}
+
+// BumpChannelLifecycleFee spends the wallet output of the pending funding or closing transaction with a higher fee
+// so the transaction is mined together with its child (CPFP)
+func BumpChannelLifecycleFee(db *sqlx.DB, lifecycle ChannelLifecycle, req BumpFeeRequest) (ChannelLifecycle, error) {
+	bumpFeeRequest, err := prepareBumpFeeRequest(lifecycle, req)
+	if err != nil {
+		return ChannelLifecycle{}, err
+	}
+
+	connection, err := settings.GetNodeConnection(db, lifecycle.NodeId)
+	if err != nil {
+		return ChannelLifecycle{}, errors.Wrap(err, "Connecting to the node")
+	}
+	lndConnection, ok := connection.(settings.LndConnection)
+	if !ok {
+		return ChannelLifecycle{}, errors.New("Bumping the fee is only supported for LND nodes")
+	}
+
+	err = bumpFee(context.Background(), walletrpc.NewWalletKitClient(lndConnection.Conn), bumpFeeRequest)
+	if err != nil {
+		return ChannelLifecycle{}, err
+	}
+	err = setChannelLifecycleBumped(db, lifecycle.ChannelLifecycleId, time.Now().UTC())
+	if err != nil {
+		return ChannelLifecycle{}, errors.Wrap(err, "Marking channel lifecycle as bumped")
+	}
+	return GetChannelLifecycle(db, lifecycle.ChannelLifecycleId)
+}
+
+func prepareBumpFeeRequest(lifecycle ChannelLifecycle, req BumpFeeRequest) (*walletrpc.BumpFeeRequest, error) {
+	if lifecycle.Status != LifecyclePending {
+		return nil, errors.New("The channel is no longer pending")
+	}
+	if lifecycle.Txid == nil {
+		return nil, errors.New("The transaction is not broadcasted yet")
+	}
+	if lifecycle.Confirmations > 0 {
+		return nil, errors.New("The transaction is already confirmed")
+	}
+	if lifecycle.BumpOutputIndex == nil {
+		return nil, errors.New("The transaction has no output of the wallet to bump the fee with")
+	}
+	if (req.SatPerVbyte == nil) == (req.TargetConf == nil) {
+		return nil, errors.New("Either SatPerVbyte or TargetConf is required")
+	}
+	if _, err := chainhash.NewHashFromStr(*lifecycle.Txid); err != nil {
+		return nil, errors.Wrap(err, "Parsing the transaction id")
+	}
+	bumpFeeRequest := &walletrpc.BumpFeeRequest{
+		Outpoint: &lnrpc.OutPoint{
+			TxidStr:     *lifecycle.Txid,
+			OutputIndex: uint32(*lifecycle.BumpOutputIndex),
+		},
+	}
+	if req.SatPerVbyte != nil {
+		bumpFeeRequest.SatPerVbyte = *req.SatPerVbyte
+	}
+	if req.TargetConf != nil {
+		bumpFeeRequest.TargetConf = *req.TargetConf
+	}
+	return bumpFeeRequest, nil
+}
+
+func bumpFee(ctx context.Context, client walletClientBumpFee, req *walletrpc.BumpFeeRequest) error {
+	_, err := client.BumpFee(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "LND BumpFee")
+	}
+	return nil
+}
+
+func getChannelLifecyclesHandler(c *gin.Context, db *sqlx.DB) {
+	nodeIds := commons.GetAllActiveTorqNodeIds(nil, nil)
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+			return
+		}
+		nodeIds = []int{nodeId}
+	}
+	status := ChannelLifecycleStatus(c.Query("status"))
+	switch status {
+	case "", LifecyclePending, LifecycleCompleted, LifecycleFailed:
+	default:
+		server_errors.SendBadRequest(c, "Failed to parse status in the request.")
+		return
+	}
+
+	result := []ChannelLifecycle{}
+	for _, nodeId := range nodeIds {
+		lifecycles, err := GetChannelLifecycles(db, nodeId, status)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err,
+				fmt.Sprintf("Getting channel lifecycles for nodeId: %v", nodeId))
+			return
+		}
+		result = append(result, lifecycles...)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func bumpFeeHandler(c *gin.Context, db *sqlx.DB) {
+	channelLifecycleId, err := strconv.Atoi(c.Param("channelLifecycleId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to parse channelLifecycleId in the request.")
+		return
+	}
+	var req BumpFeeRequest
+	if err := c.BindJSON(&req); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	req.ChannelLifecycleId = channelLifecycleId
+
+	lifecycle, err := GetChannelLifecycle(db, channelLifecycleId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"Error": "Channel lifecycle not found",
+				"ChannelLifecycleId": channelLifecycleId})
+			return
+		}
+		server_errors.WrapLogAndSendServerError(c, err, "Getting channel lifecycle")
+		return
+	}
+	if _, err := prepareBumpFeeRequest(lifecycle, req); err != nil {
+		server_errors.SendBadRequest(c, err.Error())
+		return
+	}
+	var channelId *int
+	if id := commons.GetChannelIdByChannelPoint(lifecycle.ChannelPoint); id != 0 {
+		channelId = &id
+	}
+	auditEvent, err := audit.Start(db, audit.ContextActor(c, ""), audit.BumpFee, lifecycle.NodeId, channelId, req)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Recording audit event")
+		return
+	}
+	lifecycle, err = BumpChannelLifecycleFee(db, lifecycle, req)
+	audit.Finish(db, auditEvent, lifecycle, err)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Bump fee")
+		return
+	}
+	c.JSON(http.StatusOK, lifecycle)
+}
+
+func UpsertChannelLifecycle(db *sqlx.DB, lifecycle ChannelLifecycle) error {
+	_, err := db.NamedExec(`
+		INSERT INTO channel_lifecycle (node_id, channel_point, lifecycle_type, status, remote_public_key, capacity,
+			txid, confirmations, fee_sat, fee_rate_sat_per_vbyte, bump_output_index, pending_on, updated_on)
+		VALUES (:node_id, :channel_point, :lifecycle_type, :status, :remote_public_key, :capacity,
+			:txid, :confirmations, :fee_sat, :fee_rate_sat_per_vbyte, :bump_output_index, :pending_on, :updated_on)
+		ON CONFLICT (node_id, lifecycle_type, channel_point) DO UPDATE SET
+			status=EXCLUDED.status,
+			txid=COALESCE(EXCLUDED.txid, channel_lifecycle.txid),
+			confirmations=EXCLUDED.confirmations,
+			fee_sat=COALESCE(EXCLUDED.fee_sat, channel_lifecycle.fee_sat),
+			fee_rate_sat_per_vbyte=COALESCE(EXCLUDED.fee_rate_sat_per_vbyte, channel_lifecycle.fee_rate_sat_per_vbyte),
+			bump_output_index=COALESCE(EXCLUDED.bump_output_index, channel_lifecycle.bump_output_index),
+			completed_on=NULL,
+			updated_on=EXCLUDED.updated_on;`, lifecycle)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func CompleteChannelLifecycle(db *sqlx.DB, channelLifecycleId int, status ChannelLifecycleStatus,
+	now time.Time) error {
+	_, err := db.Exec(`
+		UPDATE channel_lifecycle
+		SET status=$1, completed_on=$2, updated_on=$2
+		WHERE channel_lifecycle_id=$3;`, status, now, channelLifecycleId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func SetChannelLifecycleStuckAlerted(db *sqlx.DB, channelLifecycleId int, now time.Time) error {
+	_, err := db.Exec(`
+		UPDATE channel_lifecycle
+		SET stuck_alerted_on=$1
+		WHERE channel_lifecycle_id=$2;`, now, channelLifecycleId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// SetChannelLifecycleBumped the stuck alert is sent again when the bumped transaction doesn't confirm either
+func setChannelLifecycleBumped(db *sqlx.DB, channelLifecycleId int, now time.Time) error {
+	_, err := db.Exec(`
+		UPDATE channel_lifecycle
+		SET bumped_on=$1, updated_on=$1
+		WHERE channel_lifecycle_id=$2;`, now, channelLifecycleId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// GetChannelLifecycles the lifecycles of the node, all statuses when status is empty
+func GetChannelLifecycles(db *sqlx.DB, nodeId int, status ChannelLifecycleStatus) ([]ChannelLifecycle, error) {
+	var lifecycles []ChannelLifecycle
+	err := db.Select(&lifecycles, `
+		SELECT channel_lifecycle_id, node_id, channel_point, lifecycle_type, status, remote_public_key, capacity,
+			txid, confirmations, fee_sat, fee_rate_sat_per_vbyte, bump_output_index, bumped_on, stuck_alerted_on,
+			pending_on, completed_on, updated_on
+		FROM channel_lifecycle
+		WHERE node_id=$1 AND ($2 = '' OR status=$2)
+		ORDER BY pending_on DESC;`, nodeId, status)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	now := time.Now().UTC()
+	for i := range lifecycles {
+		lifecycles[i].WaitingSeconds = waitingSeconds(lifecycles[i], now)
+	}
+	return lifecycles, nil
+}
+
+func GetChannelLifecycle(db *sqlx.DB, channelLifecycleId int) (ChannelLifecycle, error) {
+	var lifecycle ChannelLifecycle
+	err := db.Get(&lifecycle, `
+		SELECT channel_lifecycle_id, node_id, channel_point, lifecycle_type, status, remote_public_key, capacity,
+			txid, confirmations, fee_sat, fee_rate_sat_per_vbyte, bump_output_index, bumped_on, stuck_alerted_on,
+			pending_on, completed_on, updated_on
+		FROM channel_lifecycle
+		WHERE channel_lifecycle_id=$1;`, channelLifecycleId)
+	if err != nil {
+		return ChannelLifecycle{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	lifecycle.WaitingSeconds = waitingSeconds(lifecycle, time.Now().UTC())
+	return lifecycle, nil
+}
+
+func waitingSeconds(lifecycle ChannelLifecycle, now time.Time) int64 {
+	if lifecycle.CompletedOn != nil {
+		return int64(lifecycle.CompletedOn.Sub(lifecycle.PendingOn).Seconds())
+	}
+	return int64(now.Sub(lifecycle.PendingOn).Seconds())
+}
@@ -0,0 +1,76 @@
+package channels
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestPrepareBumpFeeRequest(t *testing.T) {
+	txid := "a3e2e5a9b5c0d8f7e6d5c4b3a29180706f5e4d3c2b1a09f8e7d6c5b4a3928170"
+	outputIndex := 1
+	satPerVbyte := uint64(20)
+	targetConf := uint32(3)
+	pending := ChannelLifecycle{Status: LifecyclePending, Txid: &txid, BumpOutputIndex: &outputIndex}
+
+	tests := []struct {
+		name      string
+		lifecycle ChannelLifecycle
+		req       BumpFeeRequest
+		expectErr bool
+	}{
+		{"fee rate", pending, BumpFeeRequest{SatPerVbyte: &satPerVbyte}, false},
+		{"target conf", pending, BumpFeeRequest{TargetConf: &targetConf}, false},
+		{"fee rate and target conf", pending, BumpFeeRequest{SatPerVbyte: &satPerVbyte, TargetConf: &targetConf}, true},
+		{"completed", ChannelLifecycle{Status: LifecycleCompleted, Txid: &txid, BumpOutputIndex: &outputIndex},
+			BumpFeeRequest{SatPerVbyte: &satPerVbyte}, true},
+		{"confirmed", ChannelLifecycle{Status: LifecyclePending, Txid: &txid, BumpOutputIndex: &outputIndex,
+			Confirmations: 1}, BumpFeeRequest{SatPerVbyte: &satPerVbyte}, true},
+		{"no wallet output", ChannelLifecycle{Status: LifecyclePending, Txid: &txid},
+			BumpFeeRequest{SatPerVbyte: &satPerVbyte}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := prepareBumpFeeRequest(test.lifecycle, test.req)
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", req)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if req.Outpoint.TxidStr != txid || req.Outpoint.OutputIndex != 1 {
+				t.Errorf("expected the wallet output of the transaction, got %v", req.Outpoint)
+			}
+		})
+	}
+}
+
+func TestBumpFeeHandlerUnknownLifecycle(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, cancel, err := srv.NewTestDatabase(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterChannelRoutes(router.Group("/api/channels"), db, nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/channels/lifecycle/12345/bump-fee",
+		strings.NewReader(`{"satPerVbyte":20}`)))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown channel lifecycle, got %v: %v", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,255 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/cockroachdb/errors"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// clnFeeRate converts the LND style fee settings into a Core Lightning feerate.
+func clnFeeRate(satPerVbyte *uint64, targetConf *int32) interface{} {
+	if satPerVbyte != nil {
+		return fmt.Sprintf("%vperkb", *satPerVbyte*1000)
+	}
+	if targetConf != nil {
+		return fmt.Sprintf("%vblocks", *targetConf)
+	}
+	return nil
+}
+
+func updateChannelsCln(ctx context.Context, client cln_connect.RpcClient, req commons.UpdateChannelRequest,
+	eventChannel chan interface{}) (commons.UpdateChannelResponse, error) {
+
+	if req.NodeId == 0 {
+		return commons.UpdateChannelResponse{}, errors.New("Node id is missing")
+	}
+
+	params := map[string]interface{}{"id": "all"}
+	if req.ChannelId != nil && *req.ChannelId != 0 {
+		channelSettings := commons.GetChannelSettingByChannelId(*req.ChannelId)
+		if channelSettings.ShortChannelId == "" {
+			return commons.UpdateChannelResponse{}, errors.Newf("Short channel id is missing for channelId: %v", *req.ChannelId)
+		}
+		params["id"] = channelSettings.ShortChannelId
+	}
+	// Core Lightning only supports a node wide cltv delta so TimeLockDelta is ignored.
+	if req.FeeBaseMsat != nil {
+		params["feebase"] = *req.FeeBaseMsat
+	}
+	if req.FeeRateMilliMsat != nil {
+		params["feeppm"] = *req.FeeRateMilliMsat
+	}
+	if req.MinHtlcMsat != nil {
+		params["htlcmin"] = *req.MinHtlcMsat
+	}
+	if req.MaxHtlcMsat != nil {
+		params["htlcmax"] = *req.MaxHtlcMsat
+	}
+
+	var resp cln_connect.SetChannelResponse
+	err := client.Call(ctx, "setchannel", params, &resp)
+	if err != nil {
+		return commons.UpdateChannelResponse{}, errors.Wrap(err, "Updating channel policy")
+	}
+
+	r := commons.UpdateChannelResponse{
+		Request: req,
+		Status:  commons.Active,
+	}
+	for _, channel := range resp.Channels {
+		for _, warning := range []string{channel.WarningHtlcMin, channel.WarningHtlcMax} {
+			if warning != "" {
+				r.FailedUpdates = append(r.FailedUpdates, commons.FailedRequest{Reason: warning, Error: warning})
+			}
+		}
+	}
+	if len(r.FailedUpdates) > 0 {
+		r.Status = commons.Inactive
+	}
+	if eventChannel != nil {
+		eventChannel <- r
+	}
+	return r, nil
+}
+
+func openChannelCln(ctx context.Context, client cln_connect.RpcClient, req commons.OpenChannelRequest,
+	reqId string, eventChannel chan interface{}) error {
+
+	if req.NodeId == 0 {
+		return errors.New("Node id is missing")
+	}
+	if req.SatPerVbyte != nil && req.TargetConf != nil {
+		return errors.New("Cannot set both SatPerVbyte and TargetConf")
+	}
+
+	if req.NodePubKey != "" && req.Host != nil {
+		err := client.Call(ctx, "connect", map[string]interface{}{"id": req.NodePubKey + "@" + *req.Host}, &struct{}{})
+		if err != nil {
+			return errors.Wrap(err, "Connect peer")
+		}
+	}
+
+	params := map[string]interface{}{
+		"id":     req.NodePubKey,
+		"amount": req.LocalFundingAmount,
+	}
+	if feeRate := clnFeeRate(req.SatPerVbyte, req.TargetConf); feeRate != nil {
+		params["feerate"] = feeRate
+	}
+	if req.Private != nil {
+		params["announce"] = !*req.Private
+	}
+	if req.PushSat != nil {
+		params["push_msat"] = *req.PushSat * 1000
+	}
+	if req.MinConfs != nil {
+		params["minconf"] = *req.MinConfs
+	}
+	if req.CloseAddress != nil {
+		params["close_to"] = *req.CloseAddress
+	}
+
+	var resp cln_connect.FundChannelResponse
+	err := client.Call(ctx, "fundchannel", params, &resp)
+	if err != nil {
+		return errors.Wrap(err, "CLN Open channel")
+	}
+	if eventChannel != nil {
+		eventChannel <- commons.OpenChannelResponse{
+			ReqId:               reqId,
+			Request:             req,
+			Status:              commons.Opening,
+			PendingChannelPoint: fmt.Sprintf("%s:%d", resp.TxId, resp.Outnum),
+		}
+	}
+	return nil
+}
+
+func closeChannelCln(ctx context.Context, client cln_connect.RpcClient, ccReq commons.CloseChannelRequest,
+	reqId string, eventChannel chan interface{}) error {
+
+	if ccReq.NodeId == 0 {
+		return errors.New("Node id is missing")
+	}
+
+	channelSettings := commons.GetChannelSettingByChannelId(ccReq.ChannelId)
+	if channelSettings.ShortChannelId == "" {
+		return errors.Newf("Short channel id is missing for channelId: %v", ccReq.ChannelId)
+	}
+
+	params := map[string]interface{}{"id": channelSettings.ShortChannelId}
+	if ccReq.Force != nil && *ccReq.Force {
+		// Give up on a mutual close right away
+		params["unilateraltimeout"] = 1
+	}
+	if ccReq.DeliveryAddress != nil {
+		params["destination"] = *ccReq.DeliveryAddress
+	}
+	if ccReq.SatPerVbyte != nil {
+		feeRate := fmt.Sprintf("%vperkb", *ccReq.SatPerVbyte*1000)
+		params["feerange"] = []string{feeRate, feeRate}
+	}
+
+	var resp cln_connect.CloseResponse
+	err := client.Call(ctx, "close", params, &resp)
+	if err != nil {
+		return errors.Wrap(err, "Closing channel")
+	}
+
+	r := commons.CloseChannelResponse{
+		ReqId:   reqId,
+		Request: ccReq,
+		Status:  commons.Closing,
+	}
+	if resp.TxId != "" {
+		txId, err := chainhash.NewHashFromStr(resp.TxId)
+		if err != nil {
+			return errors.Wrap(err, "Chainhash new hash")
+		}
+		r.ClosePendingChannelPoint = commons.ChannelPoint{TxId: txId[:]}
+	}
+	if eventChannel != nil {
+		eventChannel <- r
+	}
+	return nil
+}
+
+func batchOpenChannelsCln(ctx context.Context, client cln_connect.RpcClient,
+	req commons.BatchOpenRequest) (commons.BatchOpenResponse, error) {
+
+	if req.NodeId == 0 {
+		return commons.BatchOpenResponse{}, errors.New("Node id is missing")
+	}
+	if len(req.Channels) == 0 {
+		return commons.BatchOpenResponse{}, errors.New("Channels array is empty")
+	}
+	if req.TargetConf != nil && req.SatPerVbyte != nil {
+		return commons.BatchOpenResponse{}, errors.New("Either targetConf or satPerVbyte accepted")
+	}
+
+	var destinations []map[string]interface{}
+	for _, channel := range req.Channels {
+		if channel.LocalFundingAmount == 0 {
+			return commons.BatchOpenResponse{}, errors.New("Local funding amount 0")
+		}
+		destination := map[string]interface{}{
+			"id":     channel.NodePubkey,
+			"amount": channel.LocalFundingAmount,
+		}
+		if channel.Private != nil {
+			destination["announce"] = !*channel.Private
+		}
+		if channel.PushSat != nil {
+			destination["push_msat"] = *channel.PushSat * 1000
+		}
+		destinations = append(destinations, destination)
+	}
+	params := map[string]interface{}{"destinations": destinations}
+	var satPerVbyte *uint64
+	if req.SatPerVbyte != nil {
+		s := uint64(*req.SatPerVbyte)
+		satPerVbyte = &s
+	}
+	if feeRate := clnFeeRate(satPerVbyte, req.TargetConf); feeRate != nil {
+		params["feerate"] = feeRate
+	}
+
+	var resp cln_connect.MultiFundChannelResponse
+	err := client.Call(ctx, "multifundchannel", params, &resp)
+	if err != nil {
+		return commons.BatchOpenResponse{}, errors.Wrap(err, "Batch open channel")
+	}
+
+	var r commons.BatchOpenResponse
+	for _, channel := range resp.ChannelIds {
+		r.PendingChannels = append(r.PendingChannels, commons.PendingChannel{
+			PendingChannelPoint: fmt.Sprintf("%s:%d", resp.TxId, channel.Outnum),
+		})
+	}
+	return r, nil
+}
+
+func (actions clnChannelActions) openChannel(ctx context.Context, req commons.OpenChannelRequest, reqId string,
+	eventChannel chan interface{}) error {
+	return openChannelCln(ctx, actions.client, req, reqId, eventChannel)
+}
+
+func (actions clnChannelActions) batchOpenChannels(ctx context.Context,
+	req commons.BatchOpenRequest) (commons.BatchOpenResponse, error) {
+	return batchOpenChannelsCln(ctx, actions.client, req)
+}
+
+func (actions clnChannelActions) closeChannel(ctx context.Context, req commons.CloseChannelRequest, reqId string,
+	eventChannel chan interface{}) error {
+	return closeChannelCln(ctx, actions.client, req, reqId, eventChannel)
+}
+
+func (actions clnChannelActions) updateChannels(ctx context.Context, req commons.UpdateChannelRequest,
+	eventChannel chan interface{}) (commons.UpdateChannelResponse, error) {
+	return updateChannelsCln(ctx, actions.client, req, eventChannel)
+}
@@ -0,0 +1,92 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestUpdateChannelsCln(t *testing.T) {
+	server := testutil.NewFakeClnServer(t)
+	server.HandleResult("setchannel", map[string]interface{}{
+		"channels": []map[string]interface{}{
+			{"peer_id": "02aa", "short_channel_id": "103x1x0", "warning_htlcmin_too_low": "htlcmin too low"},
+		},
+	})
+	client, err := cln_connect.Connect(server.Address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	feeRate := uint64(100)
+	feeBase := uint64(1000)
+	r, err := updateChannelsCln(context.Background(), client, commons.UpdateChannelRequest{
+		NodeId:           1,
+		FeeRateMilliMsat: &feeRate,
+		FeeBaseMsat:      &feeBase,
+	}, nil)
+	if err != nil {
+		t.Fatalf("updateChannelsCln: %v", err)
+	}
+	if r.Status != commons.Inactive || len(r.FailedUpdates) != 1 {
+		t.Errorf("expected the warning as failed update got %+v", r)
+	}
+
+	var params map[string]interface{}
+	calls := server.Calls("setchannel")
+	if len(calls) != 1 {
+		t.Fatalf("expected one setchannel call got %v", len(calls))
+	}
+	if err := json.Unmarshal(calls[0], &params); err != nil {
+		t.Fatal(err)
+	}
+	if params["id"] != "all" || params["feeppm"] != float64(100) || params["feebase"] != float64(1000) {
+		t.Errorf("unexpected setchannel params %v", params)
+	}
+
+	_, err = updateChannelsCln(context.Background(), client, commons.UpdateChannelRequest{}, nil)
+	if err == nil {
+		t.Errorf("expected an error for a missing node id")
+	}
+}
+
+func TestBatchOpenChannelsCln(t *testing.T) {
+	server := testutil.NewFakeClnServer(t)
+	server.HandleResult("multifundchannel", map[string]interface{}{
+		"txid":        "f4c2a2f2a1f5f8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3",
+		"channel_ids": []map[string]interface{}{{"id": "02aa", "outnum": 1}, {"id": "02bb", "outnum": 0}},
+	})
+	client, err := cln_connect.Connect(server.Address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	satPerVbyte := int64(5)
+	r, err := batchOpenChannelsCln(context.Background(), client, commons.BatchOpenRequest{
+		NodeId: 1,
+		Channels: []commons.BatchOpenChannel{
+			{NodePubkey: "02aa", LocalFundingAmount: 100000},
+			{NodePubkey: "02bb", LocalFundingAmount: 200000},
+		},
+		SatPerVbyte: &satPerVbyte,
+	})
+	if err != nil {
+		t.Fatalf("batchOpenChannelsCln: %v", err)
+	}
+	if len(r.PendingChannels) != 2 ||
+		r.PendingChannels[0].PendingChannelPoint != "f4c2a2f2a1f5f8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3:1" {
+		t.Errorf("unexpected pending channels %+v", r.PendingChannels)
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal(server.Calls("multifundchannel")[0], &params); err != nil {
+		t.Fatal(err)
+	}
+	if params["feerate"] != "5000perkb" {
+		t.Errorf("unexpected feerate %v", params["feerate"])
+	}
+}
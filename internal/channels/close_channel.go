@@ -13,9 +13,6 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"google.golang.org/grpc"
-
-	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 type lndClientCloseChannel interface {
@@ -23,27 +20,21 @@ type lndClientCloseChannel interface {
 }
 
 func CloseChannel(eventChannel chan interface{}, db *sqlx.DB, c *gin.Context, ccReq commons.CloseChannelRequest, reqId string) (err error) {
-	connectionDetails, err := settings.GetConnectionDetailsById(db, ccReq.NodeId)
+	actions, err := getChannelActions(db, ccReq.NodeId)
 	if err != nil {
-		return errors.New("Getting node connection details from the db")
+		return err
 	}
+	return actions.closeChannel(context.Background(), ccReq, reqId, eventChannel)
+}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return errors.Wrap(err, "Connecting to LND")
-	}
-	defer conn.Close()
-	client := lnrpc.NewLightningClient(conn)
-
+func (actions lndChannelActions) closeChannel(ctx context.Context, ccReq commons.CloseChannelRequest, reqId string,
+	eventChannel chan interface{}) error {
 	closeChanReq, err := prepareCloseRequest(ccReq)
 	if err != nil {
 		return errors.Wrap(err, "Preparing close request")
 	}
 
-	return closeChannelResp(client, closeChanReq, eventChannel, ccReq, reqId)
+	return closeChannelResp(ctx, lnrpc.NewLightningClient(actions.conn), closeChanReq, eventChannel, ccReq, reqId)
 }
 
 func prepareCloseRequest(ccReq commons.CloseChannelRequest) (r *lnrpc.CloseChannelRequest, err error) {
@@ -87,10 +78,9 @@ func prepareCloseRequest(ccReq commons.CloseChannelRequest) (r *lnrpc.CloseChann
 	return closeChanReq, nil
 }
 
-func closeChannelResp(client lndClientCloseChannel, closeChanReq *lnrpc.CloseChannelRequest, eventChannel chan interface{},
-	ccReq commons.CloseChannelRequest, reqId string) error {
+func closeChannelResp(ctx context.Context, client lndClientCloseChannel, closeChanReq *lnrpc.CloseChannelRequest,
+	eventChannel chan interface{}, ccReq commons.CloseChannelRequest, reqId string) error {
 
-	ctx := context.Background()
 	closeChanRes, err := client.CloseChannel(ctx, closeChanReq)
 	if err != nil {
 		return errors.Wrap(err, "Closing channel")
@@ -11,7 +11,12 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
 
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/channel_health"
+	"github.com/lncapital/torq/internal/channel_risk"
+	"github.com/lncapital/torq/internal/peers"
 	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/internal/spending"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
@@ -64,6 +69,18 @@ type channelBody struct {
 	AmbossSpace                  string               `json:"ambossSpace"`
 	OneMl                        string               `json:"oneMl"`
 	PeerAlias                    string               `json:"peerAlias"`
+	// HealthScore, AnnualizedRoi and CloseCandidate over the default window of channel_health
+	HealthScore    *float64 `json:"healthScore"`
+	AnnualizedRoi  *float64 `json:"annualizedRoi"`
+	CloseCandidate bool     `json:"closeCandidate"`
+	// The peer uptime over the last peers.DefaultUptimeDays
+	PeerUptimePercent        *float64 `json:"peerUptimePercent"`
+	PeerDisconnects          int64    `json:"peerDisconnects"`
+	PeerLongestOutageSeconds int64    `json:"peerLongestOutageSeconds"`
+	// RiskLevel and RiskReasons of the last evaluation of the channel_risk monitor
+	RiskLevel               *string  `json:"riskLevel"`
+	RiskReasons             []string `json:"riskReasons"`
+	ClosestHtlcExpiryBlocks *int64   `json:"closestHtlcExpiryBlocks"`
 }
 
 type PendingHtlcs struct {
@@ -94,7 +111,14 @@ func updateChannelsHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interf
 		return
 	}
 
-	response, err := updateChannels(db, requestBody, eventChannel)
+	auditEvent, err := audit.Start(db, audit.ContextActor(c, ""), audit.UpdateChannels, requestBody.NodeId,
+		requestBody.ChannelId, requestBody)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Recording audit event")
+		return
+	}
+	response, err := UpdateChannels(db, requestBody, eventChannel)
+	audit.Finish(db, auditEvent, response, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Update channel/s policy")
 		return
@@ -103,14 +127,32 @@ func updateChannelsHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interf
 	c.JSON(http.StatusOK, response)
 }
 
-func batchOpenHandler(c *gin.Context, db *sqlx.DB) {
+func batchOpenHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}) {
 	var batchOpnReq commons.BatchOpenRequest
 	if err := c.BindJSON(&batchOpnReq); err != nil {
 		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
 		return
 	}
 
-	response, err := batchOpenChannels(db, batchOpnReq)
+	actor := audit.ContextActor(c, "")
+	spendingRequest, err := spending.CheckBatchChannelOpen(db, eventChannel, actor, batchOpnReq)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Checking spending limits")
+		return
+	}
+	if spendingRequest.Status == spending.Pending {
+		c.JSON(http.StatusAccepted, spendingRequest)
+		return
+	}
+	auditEvent, err := audit.Start(db, actor, audit.BatchOpenChannels, batchOpnReq.NodeId, nil, batchOpnReq)
+	if err != nil {
+		spending.Finish(db, spendingRequest, err)
+		server_errors.WrapLogAndSendServerError(c, err, "Recording audit event")
+		return
+	}
+	response, err := BatchOpenChannels(db, batchOpnReq)
+	audit.Finish(db, auditEvent, response, err)
+	spending.Finish(db, spendingRequest, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Batch open channels")
 		return
@@ -131,6 +173,19 @@ func getChannelListHandler(c *gin.Context, db *sqlx.DB) {
 			// Force Response because we don't care about balance accuracy
 			channelBalanceStates := commons.GetChannelStates(ncd.NodeId, true)
 			nodeSettings := commons.GetNodeSettingsByNodeId(ncd.NodeId)
+			channelHealthByChannelId := make(map[int]channel_health.ChannelHealth)
+			channelHealth, err := channel_health.GetCachedChannelHealth(db, ncd.NodeId)
+			if err != nil {
+				log.Error().Err(err).Msgf("Could not score the channels for nodeId: %v", ncd.NodeId)
+			}
+			for _, health := range channelHealth {
+				channelHealthByChannelId[health.ChannelId] = health
+			}
+			peerUptimes, err := peers.GetCachedPeerUptimes(db, ncd.NodeId)
+			if err != nil {
+				log.Error().Err(err).Msgf("Could not obtain the peer uptime for nodeId: %v", ncd.NodeId)
+			}
+			channelRisks := channel_risk.GetChannelRisks(ncd.NodeId)
 			for _, channel := range channelBalanceStates {
 				channelSettings := commons.GetChannelSettingByChannelId(channel.ChannelId)
 				lndShortChannelIdString := strconv.FormatUint(channelSettings.LndShortChannelId, 10)
@@ -187,6 +242,23 @@ func getChannelListHandler(c *gin.Context, db *sqlx.DB) {
 					OneMl:                        commons.ONEML + lndShortChannelIdString,
 				}
 
+				if health, exists := channelHealthByChannelId[channel.ChannelId]; exists {
+					chanBody.HealthScore = &health.Score
+					chanBody.AnnualizedRoi = &health.AnnualizedRoi
+					chanBody.CloseCandidate = health.CloseCandidate
+				}
+				if peerUptime, exists := peerUptimes[channel.RemoteNodeId]; exists {
+					chanBody.PeerUptimePercent = peerUptime.UptimePercent
+					chanBody.PeerDisconnects = peerUptime.Disconnects
+					chanBody.PeerLongestOutageSeconds = peerUptime.LongestOutageSeconds
+				}
+				if risk, exists := channelRisks[channel.ChannelId]; exists {
+					riskLevel := string(risk.Level)
+					chanBody.RiskLevel = &riskLevel
+					chanBody.RiskReasons = risk.Reasons
+					chanBody.ClosestHtlcExpiryBlocks = risk.ClosestHtlcExpiryBlocks
+				}
+
 				peerInfo, err := GetNodePeerAlias(ncd.NodeId, channel.RemoteNodeId, db)
 				if err == nil {
 					chanBody.PeerAlias = peerInfo
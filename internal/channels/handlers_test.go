@@ -0,0 +1,83 @@
+package channels
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestUpdateChannelsHandlerWithFakeLndServer(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, cancel, err := srv.NewTestDatabase(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	err = settings.InitializeManagedSettingsCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedSettings cache: %v", err)
+	}
+	err = settings.InitializeManagedNodeCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedNode cache: %v", err)
+	}
+	err = InitializeManagedChannelCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedChannel cache: %v", err)
+	}
+	nodeId := commons.GetNodeIdByPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet)
+	channelId := commons.GetChannelIdByShortChannelId(commons.ConvertLNDShortChannelID(1111))
+
+	server := testutil.NewFakeLndServer(t)
+	settings.SetLndConnection(nodeId, server.Dial(t))
+	defer settings.RemoveLndConnection(nodeId)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterChannelRoutes(router.Group("/api/channels"), db, nil)
+
+	feeRate := uint64(250)
+	timeLockDelta := uint32(40)
+	body, err := json.Marshal(commons.UpdateChannelRequest{NodeId: nodeId, ChannelId: &channelId,
+		FeeRateMilliMsat: &feeRate, TimeLockDelta: &timeLockDelta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/api/channels/update", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %v", recorder.Code, recorder.Body.String())
+	}
+	var response commons.UpdateChannelResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != commons.Active {
+		t.Errorf("expected a successful update, got %+v", response)
+	}
+
+	calls := server.Calls("UpdateChannelPolicy")
+	if len(calls) != 1 {
+		t.Fatalf("expected a single policy update, got %v", calls)
+	}
+	policyUpdate := calls[0].(*lnrpc.PolicyUpdateRequest)
+	chanPoint := policyUpdate.GetChanPoint()
+	if policyUpdate.FeeRatePpm != 250 || policyUpdate.TimeLockDelta != 40 || chanPoint == nil ||
+		chanPoint.GetFundingTxidStr() != testutil.TestFundingTransactionHash1 || chanPoint.OutputIndex != 3 {
+		t.Errorf("expected the policy update of the channel, got %v", policyUpdate)
+	}
+}
@@ -0,0 +1,44 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// channelActions the channel actions of a node, implemented per node implementation
+type channelActions interface {
+	openChannel(ctx context.Context, req commons.OpenChannelRequest, reqId string, eventChannel chan interface{}) error
+	batchOpenChannels(ctx context.Context, req commons.BatchOpenRequest) (commons.BatchOpenResponse, error)
+	closeChannel(ctx context.Context, req commons.CloseChannelRequest, reqId string, eventChannel chan interface{}) error
+	updateChannels(ctx context.Context, req commons.UpdateChannelRequest,
+		eventChannel chan interface{}) (commons.UpdateChannelResponse, error)
+}
+
+type lndChannelActions struct {
+	conn *grpc.ClientConn
+}
+
+type clnChannelActions struct {
+	client cln_connect.RpcClient
+}
+
+func getChannelActions(db *sqlx.DB, nodeId int) (channelActions, error) {
+	connection, err := settings.GetNodeConnection(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Connecting to the node")
+	}
+	switch connection := connection.(type) {
+	case settings.LndConnection:
+		return lndChannelActions{conn: connection.Conn}, nil
+	case settings.ClnConnection:
+		return clnChannelActions{client: connection.Client}, nil
+	}
+	return nil, errors.Newf("Channel actions are not supported for node %v", nodeId)
+}
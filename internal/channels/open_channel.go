@@ -13,9 +13,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/lncapital/torq/internal/peers"
-	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 type PsbtDetails struct {
@@ -25,28 +23,21 @@ type PsbtDetails struct {
 }
 
 func OpenChannel(eventChannel chan interface{}, db *sqlx.DB, req commons.OpenChannelRequest, reqId string) (err error) {
-	openChanReq, err := prepareOpenRequest(req)
-	if err != nil {
-		return errors.Wrap(err, "Preparing open request")
-	}
-
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	actions, err := getChannelActions(db, req.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return err
 	}
+	return actions.openChannel(context.Background(), req, reqId, eventChannel)
+}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+func (actions lndChannelActions) openChannel(ctx context.Context, req commons.OpenChannelRequest, reqId string,
+	eventChannel chan interface{}) error {
+	openChanReq, err := prepareOpenRequest(req)
 	if err != nil {
-		return errors.Wrap(err, "Connecting to LND")
+		return errors.Wrap(err, "Preparing open request")
 	}
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
 
-	ctx := context.Background()
+	client := lnrpc.NewLightningClient(actions.conn)
 
 	//If host provided - check if node is connected to peer and if not, connect peer
 	if req.NodePubKey != "" && req.Host != nil {
@@ -7,6 +7,9 @@ import (
 
 func RegisterChannelRoutes(r *gin.RouterGroup, db *sqlx.DB, eventChannel chan interface{}) {
 	r.PUT("update", func(c *gin.Context) { updateChannelsHandler(c, db, eventChannel) })
-	r.POST("openbatch", func(c *gin.Context) { batchOpenHandler(c, db) })
+	r.POST("openbatch", func(c *gin.Context) { batchOpenHandler(c, db, eventChannel) })
 	r.GET("", func(c *gin.Context) { getChannelListHandler(c, db) })
+	// i.e. lifecycle?nodeId=1&status=PENDING
+	r.GET("lifecycle", func(c *gin.Context) { getChannelLifecyclesHandler(c, db) })
+	r.POST("lifecycle/:channelLifecycleId/bump-fee", func(c *gin.Context) { bumpFeeHandler(c, db) })
 }
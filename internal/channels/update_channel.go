@@ -7,38 +7,27 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
 
-	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
-// UpdateChannel
+// UpdateChannels
 // Returns status, failed updates array
-func updateChannels(db *sqlx.DB, req commons.UpdateChannelRequest, eventChannel chan interface{}) (r commons.UpdateChannelResponse, err error) {
-
-	policyReq, err := createPolicyRequest(req)
+func UpdateChannels(db *sqlx.DB, req commons.UpdateChannelRequest, eventChannel chan interface{}) (r commons.UpdateChannelResponse, err error) {
+	actions, err := getChannelActions(db, req.NodeId)
 	if err != nil {
-		return commons.UpdateChannelResponse{}, errors.Wrap(err, "Create policy request")
-	}
-
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
-
-	if err != nil {
-		return commons.UpdateChannelResponse{}, errors.Wrap(err, "Getting node connection details from the db")
+		return commons.UpdateChannelResponse{}, err
 	}
+	return actions.updateChannels(context.Background(), req, eventChannel)
+}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+func (actions lndChannelActions) updateChannels(ctx context.Context, req commons.UpdateChannelRequest,
+	eventChannel chan interface{}) (r commons.UpdateChannelResponse, err error) {
+	policyReq, err := createPolicyRequest(req)
 	if err != nil {
-		return commons.UpdateChannelResponse{}, errors.Wrap(err, "Getting node connection details from the db")
+		return commons.UpdateChannelResponse{}, errors.Wrap(err, "Create policy request")
 	}
 
-	defer conn.Close()
-
-	ctx := context.Background()
-	client := lnrpc.NewLightningClient(conn)
+	client := lnrpc.NewLightningClient(actions.conn)
 
 	resp, err := client.UpdateChannelPolicy(ctx, policyReq)
 	if err != nil {
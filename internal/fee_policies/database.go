@@ -0,0 +1,284 @@
+package fee_policies
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func getFeePolicy(db *sqlx.DB, feePolicyId int) (FeePolicy, error) {
+	var feePolicy FeePolicy
+	err := db.Get(&feePolicy, `SELECT * FROM fee_policy WHERE fee_policy_id=$1;`, feePolicyId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FeePolicy{}, nil
+		}
+		return FeePolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	feePolicy.Rules, err = getFeePolicyRules(db, feePolicyId)
+	if err != nil {
+		return FeePolicy{}, err
+	}
+	return feePolicy, nil
+}
+
+func getFeePolicies(db *sqlx.DB) ([]FeePolicy, error) {
+	var feePolicies []FeePolicy
+	err := db.Select(&feePolicies, `SELECT * FROM fee_policy ORDER BY node_id, priority;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []FeePolicy{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	for i := range feePolicies {
+		feePolicies[i].Rules, err = getFeePolicyRules(db, feePolicies[i].FeePolicyId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return feePolicies, nil
+}
+
+func getActiveFeePoliciesByNodeId(db *sqlx.DB, nodeId int) ([]FeePolicy, error) {
+	var feePolicies []FeePolicy
+	err := db.Select(&feePolicies, `
+		SELECT * FROM fee_policy WHERE node_id=$1 AND status=$2 ORDER BY priority, fee_policy_id;`,
+		nodeId, commons.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []FeePolicy{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	for i := range feePolicies {
+		feePolicies[i].Rules, err = getFeePolicyRules(db, feePolicies[i].FeePolicyId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return feePolicies, nil
+}
+
+func getFeePolicyRules(db *sqlx.DB, feePolicyId int) ([]FeePolicyRule, error) {
+	var rules []FeePolicyRule
+	err := db.Select(&rules, `
+		SELECT * FROM fee_policy_rule WHERE fee_policy_id=$1 ORDER BY sort_order, fee_policy_rule_id;`,
+		feePolicyId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []FeePolicyRule{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rules, nil
+}
+
+func addFeePolicy(db *sqlx.DB, feePolicy FeePolicy) (FeePolicy, error) {
+	feePolicy.CreatedOn = time.Now().UTC()
+	feePolicy.UpdateOn = feePolicy.CreatedOn
+	tx, err := db.Beginx()
+	if err != nil {
+		return FeePolicy{}, errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	err = tx.QueryRowx(`
+		INSERT INTO fee_policy (name, node_id, category_id, tag_id, priority, status, dry_run,
+			flow_lookback_minutes, min_change_interval_minutes,
+			min_fee_rate_milli_msat, max_fee_rate_milli_msat, min_fee_base_msat, max_fee_base_msat,
+			created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING fee_policy_id;`,
+		feePolicy.Name, feePolicy.NodeId, feePolicy.CategoryId, feePolicy.TagId, feePolicy.Priority, feePolicy.Status,
+		feePolicy.DryRun, feePolicy.FlowLookbackMinutes, feePolicy.MinChangeIntervalMinutes,
+		feePolicy.MinFeeRateMilliMsat, feePolicy.MaxFeeRateMilliMsat, feePolicy.MinFeeBaseMsat, feePolicy.MaxFeeBaseMsat,
+		feePolicy.CreatedOn, feePolicy.UpdateOn).Scan(&feePolicy.FeePolicyId)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return FeePolicy{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return FeePolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	feePolicy.Rules, err = addFeePolicyRules(tx, feePolicy.FeePolicyId, feePolicy.Rules, feePolicy.CreatedOn)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return FeePolicy{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return FeePolicy{}, errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return feePolicy, nil
+}
+
+// setFeePolicy replaces all the rules of the policy. Changes made by a replaced rule keep a copy of the rule.
+func setFeePolicy(db *sqlx.DB, feePolicy FeePolicy) (FeePolicy, error) {
+	feePolicy.UpdateOn = time.Now().UTC()
+	tx, err := db.Beginx()
+	if err != nil {
+		return FeePolicy{}, errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	_, err = tx.Exec(`
+		UPDATE fee_policy
+		SET name=$1, node_id=$2, category_id=$3, tag_id=$4, priority=$5, status=$6, dry_run=$7,
+			flow_lookback_minutes=$8, min_change_interval_minutes=$9,
+			min_fee_rate_milli_msat=$10, max_fee_rate_milli_msat=$11, min_fee_base_msat=$12, max_fee_base_msat=$13,
+			updated_on=$14
+		WHERE fee_policy_id=$15;`,
+		feePolicy.Name, feePolicy.NodeId, feePolicy.CategoryId, feePolicy.TagId, feePolicy.Priority, feePolicy.Status,
+		feePolicy.DryRun, feePolicy.FlowLookbackMinutes, feePolicy.MinChangeIntervalMinutes,
+		feePolicy.MinFeeRateMilliMsat, feePolicy.MaxFeeRateMilliMsat, feePolicy.MinFeeBaseMsat, feePolicy.MaxFeeBaseMsat,
+		feePolicy.UpdateOn, feePolicy.FeePolicyId)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return FeePolicy{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return FeePolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = tx.Exec(`DELETE FROM fee_policy_rule WHERE fee_policy_id=$1;`, feePolicy.FeePolicyId)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return FeePolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	feePolicy.Rules, err = addFeePolicyRules(tx, feePolicy.FeePolicyId, feePolicy.Rules, feePolicy.UpdateOn)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return FeePolicy{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return FeePolicy{}, errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return feePolicy, nil
+}
+
+func addFeePolicyRules(tx *sqlx.Tx, feePolicyId int, rules []FeePolicyRule, createdOn time.Time) ([]FeePolicyRule, error) {
+	var storedRules []FeePolicyRule
+	for _, rule := range rules {
+		rule.FeePolicyId = feePolicyId
+		rule.CreatedOn = createdOn
+		rule.UpdateOn = createdOn
+		err := tx.QueryRowx(`
+			INSERT INTO fee_policy_rule (fee_policy_id, sort_order,
+				min_local_balance_per_mille, max_local_balance_per_mille,
+				min_outgoing_forwards_sat, max_outgoing_forwards_sat,
+				min_incoming_forwards_sat, max_incoming_forwards_sat,
+				adjustment_type, fee_rate_milli_msat, fee_base_msat, created_on, updated_on)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING fee_policy_rule_id;`,
+			rule.FeePolicyId, rule.SortOrder,
+			rule.MinLocalBalancePerMille, rule.MaxLocalBalancePerMille,
+			rule.MinOutgoingForwardsSat, rule.MaxOutgoingForwardsSat,
+			rule.MinIncomingForwardsSat, rule.MaxIncomingForwardsSat,
+			rule.AdjustmentType, rule.FeeRateMilliMsat, rule.FeeBaseMsat, rule.CreatedOn, rule.UpdateOn).
+			Scan(&rule.FeePolicyRuleId)
+		if err != nil {
+			return nil, errors.Wrap(err, database.SqlExecutionError)
+		}
+		storedRules = append(storedRules, rule)
+	}
+	return storedRules, nil
+}
+
+func getFeePolicyChanges(db *sqlx.DB, feePolicyId *int, channelId *int) ([]FeePolicyChange, error) {
+	var changes []FeePolicyChange
+	err := db.Select(&changes, `
+		SELECT * FROM fee_policy_change
+		WHERE ($1::INTEGER IS NULL OR fee_policy_id=$1) AND ($2::INTEGER IS NULL OR channel_id=$2)
+		ORDER BY created_on DESC;`, feePolicyId, channelId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []FeePolicyChange{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return changes, nil
+}
+
+// getLastFeePolicyChangeTime failed changes count as well so a failing channel is only retried after the interval,
+// dry run changes are only compared to other dry run changes.
+func getLastFeePolicyChangeTime(db *sqlx.DB, channelId int, dryRun bool) (*time.Time, error) {
+	var lastChange *time.Time
+	err := db.Get(&lastChange, `
+		SELECT MAX(created_on) FROM fee_policy_change WHERE channel_id=$1 AND dry_run=$2;`,
+		channelId, dryRun)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return lastChange, nil
+}
+
+func addFeePolicyChange(db *sqlx.DB, rule FeePolicyRule, change FeePolicyChange) error {
+	ruleJson, err := json.Marshal(rule)
+	if err != nil {
+		return errors.Wrap(err, "JSON Marshal fee policy rule")
+	}
+	_, err = db.Exec(`
+		INSERT INTO fee_policy_change (fee_policy_id, fee_policy_rule_id, rule, node_id, channel_id, dry_run,
+			local_balance_per_mille, outgoing_forwards_sat, incoming_forwards_sat,
+			previous_fee_rate_milli_msat, previous_fee_base_msat, fee_rate_milli_msat, fee_base_msat,
+			status, error, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16);`,
+		rule.FeePolicyId, rule.FeePolicyRuleId, ruleJson, change.NodeId, change.ChannelId, change.DryRun,
+		change.LocalBalancePerMille, change.OutgoingForwardsSat, change.IncomingForwardsSat,
+		change.PreviousFeeRateMilliMsat, change.PreviousFeeBaseMsat, change.FeeRateMilliMsat, change.FeeBaseMsat,
+		change.Status, change.Error, change.CreatedOn)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+// getChannelFlows returns the forwarded amount in satoshis per channel since the provided time
+func getChannelFlows(db *sqlx.DB, nodeId int, from time.Time) (map[int]int64, map[int]int64, error) {
+	var outgoing []channelFlow
+	err := db.Select(&outgoing, `
+		SELECT outgoing_channel_id AS channel_id, SUM(outgoing_amount_msat)/1000 AS amount_sat
+		FROM forward
+		WHERE node_id=$1 AND time>=$2 AND outgoing_channel_id IS NOT NULL
+		GROUP BY outgoing_channel_id;`, nodeId, from)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	var incoming []channelFlow
+	err = db.Select(&incoming, `
+		SELECT incoming_channel_id AS channel_id, SUM(incoming_amount_msat)/1000 AS amount_sat
+		FROM forward
+		WHERE node_id=$1 AND time>=$2 AND incoming_channel_id IS NOT NULL
+		GROUP BY incoming_channel_id;`, nodeId, from)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	outgoingByChannelId := make(map[int]int64)
+	for _, flow := range outgoing {
+		outgoingByChannelId[flow.ChannelId] = flow.AmountSat
+	}
+	incomingByChannelId := make(map[int]int64)
+	for _, flow := range incoming {
+		incomingByChannelId[flow.ChannelId] = flow.AmountSat
+	}
+	return outgoingByChannelId, incomingByChannelId, nil
+}
@@ -0,0 +1,266 @@
+package fee_policies
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/channel_groups"
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// FeePolicyEngine periodically applies the active fee policies of the node to its channels.
+func FeePolicyEngine(ctx context.Context, db *sqlx.DB, nodeSettings commons.ManagedNodeSettings,
+	eventChannel chan interface{}) {
+
+	ticker := clock.New().Tick(commons.FEE_POLICY_TICKER_SECONDS * time.Second)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+			err := applyFeePolicies(db, nodeSettings.NodeId, time.Now().UTC(), eventChannel)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to apply fee policies for nodeId: %v", nodeSettings.NodeId)
+			}
+		}
+	}
+}
+
+func applyFeePolicies(db *sqlx.DB, nodeId int, now time.Time, eventChannel chan interface{}) error {
+	feePolicies, err := getActiveFeePoliciesByNodeId(db, nodeId)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining active fee policies")
+	}
+	if len(feePolicies) == 0 {
+		return nil
+	}
+
+	balanceStates := commons.GetChannelBalanceStates(nodeId, false, commons.ALL_LOCAL_ACTIVE_CHANNELS,
+		commons.PENDING_HTLCS_IGNORED)
+	if len(balanceStates) == 0 {
+		return nil
+	}
+
+	flowsByLookback := make(map[int][2]map[int]int64)
+	for _, balanceState := range balanceStates {
+		feePolicy, err := getFeePolicyForChannel(db, feePolicies, balanceState.ChannelId)
+		if err != nil {
+			return errors.Wrapf(err, "Obtaining fee policy for channelId: %v", balanceState.ChannelId)
+		}
+		if feePolicy == nil {
+			continue
+		}
+
+		flows, exists := flowsByLookback[feePolicy.FlowLookbackMinutes]
+		if !exists {
+			from := now.Add(-time.Duration(feePolicy.FlowLookbackMinutes) * time.Minute)
+			outgoing, incoming, err := getChannelFlows(db, nodeId, from)
+			if err != nil {
+				return errors.Wrap(err, "Obtaining channel flows")
+			}
+			flows = [2]map[int]int64{outgoing, incoming}
+			flowsByLookback[feePolicy.FlowLookbackMinutes] = flows
+		}
+
+		err = applyFeePolicy(db, *feePolicy, balanceState, flows[0][balanceState.ChannelId],
+			flows[1][balanceState.ChannelId], now, eventChannel)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to apply fee policy %v for channelId: %v",
+				feePolicy.FeePolicyId, balanceState.ChannelId)
+		}
+	}
+	return nil
+}
+
+func applyFeePolicy(db *sqlx.DB, feePolicy FeePolicy, balanceState commons.ManagedChannelBalanceStateSettings,
+	outgoingSat int64, incomingSat int64, now time.Time, eventChannel chan interface{}) error {
+
+	rule := getMatchingRule(feePolicy.Rules, balanceState.LocalBalancePerMilleRatio, outgoingSat, incomingSat)
+	if rule == nil {
+		return nil
+	}
+
+	channelState := commons.GetChannelState(balanceState.NodeId, balanceState.ChannelId, false)
+	if channelState == nil {
+		return nil
+	}
+	currentFeeRate := int64(channelState.LocalFeeRateMilliMsat)
+	currentFeeBase := int64(channelState.LocalFeeBaseMsat)
+	feeRate, feeBase := calculateFees(feePolicy, *rule, currentFeeRate, currentFeeBase)
+	if feeRate == currentFeeRate && feeBase == currentFeeBase {
+		return nil
+	}
+
+	lastChange, err := getLastFeePolicyChangeTime(db, balanceState.ChannelId, feePolicy.DryRun)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining last fee policy change")
+	}
+	if lastChange != nil &&
+		now.Sub(*lastChange) < time.Duration(feePolicy.MinChangeIntervalMinutes)*time.Minute {
+		return nil
+	}
+
+	change := FeePolicyChange{
+		NodeId:                   balanceState.NodeId,
+		ChannelId:                balanceState.ChannelId,
+		DryRun:                   feePolicy.DryRun,
+		LocalBalancePerMille:     balanceState.LocalBalancePerMilleRatio,
+		OutgoingForwardsSat:      outgoingSat,
+		IncomingForwardsSat:      incomingSat,
+		PreviousFeeRateMilliMsat: currentFeeRate,
+		PreviousFeeBaseMsat:      currentFeeBase,
+		FeeRateMilliMsat:         feeRate,
+		FeeBaseMsat:              feeBase,
+		Status:                   commons.Active,
+		CreatedOn:                now,
+	}
+	if !feePolicy.DryRun {
+		channelId := balanceState.ChannelId
+		feeRateMilliMsat := uint64(feeRate)
+		feeBaseMsat := uint64(feeBase)
+		updateChannelRequest := commons.UpdateChannelRequest{
+			NodeId:           balanceState.NodeId,
+			ChannelId:        &channelId,
+			FeeRateMilliMsat: &feeRateMilliMsat,
+			FeeBaseMsat:      &feeBaseMsat,
+		}
+		actor := audit.AutomationActor(fmt.Sprintf("fee policy %v", feePolicy.FeePolicyId))
+		auditEvent, err := audit.Start(db, actor, audit.UpdateChannels, balanceState.NodeId, &channelId,
+			updateChannelRequest)
+		if err != nil {
+			return errors.Wrap(err, "Recording audit event")
+		}
+		response, err := channels.UpdateChannels(db, updateChannelRequest, eventChannel)
+		audit.Finish(db, auditEvent, response, err)
+		if err != nil {
+			change.Status = commons.Inactive
+			errorMessage := err.Error()
+			change.Error = &errorMessage
+		} else if response.Status != commons.Active {
+			change.Status = commons.Inactive
+			if len(response.FailedUpdates) > 0 {
+				change.Error = &response.FailedUpdates[0].Reason
+			}
+		}
+	}
+	log.Info().Msgf("Fee policy %v (dry run: %v) changed channelId: %v from %v/%v to %v/%v (rate/base)",
+		feePolicy.FeePolicyId, feePolicy.DryRun, balanceState.ChannelId,
+		currentFeeRate, currentFeeBase, feeRate, feeBase)
+	return addFeePolicyChange(db, *rule, change)
+}
+
+// getFeePolicyForChannel returns the first policy (policies are sorted by priority) that targets the channel.
+func getFeePolicyForChannel(db *sqlx.DB, feePolicies []FeePolicy, channelId int) (*FeePolicy, error) {
+	var channelGroups []commons.ChannelGroup
+	for i, feePolicy := range feePolicies {
+		if feePolicy.CategoryId != nil || feePolicy.TagId != nil {
+			if channelGroups == nil {
+				categories, err := channel_groups.GetChannelGroupsByChannelId(db, channelId,
+					commons.ALL_REGULAR_AND_TAG_CATEGORIES)
+				if err != nil {
+					return nil, err
+				}
+				tags, err := channel_groups.GetChannelGroupsByChannelId(db, channelId, commons.TAGS_ONLY)
+				if err != nil {
+					return nil, err
+				}
+				channelGroups = append(append([]commons.ChannelGroup{}, categories...), tags...)
+			}
+		}
+		if policyTargetsChannelGroups(feePolicy, channelGroups) {
+			return &feePolicies[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func policyTargetsChannelGroups(feePolicy FeePolicy, channelGroups []commons.ChannelGroup) bool {
+	if feePolicy.TagId != nil {
+		for _, channelGroup := range channelGroups {
+			if channelGroup.TagId != nil && *channelGroup.TagId == *feePolicy.TagId {
+				return true
+			}
+		}
+		return false
+	}
+	if feePolicy.CategoryId != nil {
+		for _, channelGroup := range channelGroups {
+			if channelGroup.CategoryId != nil && *channelGroup.CategoryId == *feePolicy.CategoryId {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// getMatchingRule returns the first rule (rules are sorted by sort order) whose conditions are all met.
+func getMatchingRule(rules []FeePolicyRule, localBalancePerMille int, outgoingSat int64, incomingSat int64) *FeePolicyRule {
+	for i, rule := range rules {
+		if rule.MinLocalBalancePerMille != nil && localBalancePerMille < *rule.MinLocalBalancePerMille {
+			continue
+		}
+		if rule.MaxLocalBalancePerMille != nil && localBalancePerMille > *rule.MaxLocalBalancePerMille {
+			continue
+		}
+		if rule.MinOutgoingForwardsSat != nil && outgoingSat < *rule.MinOutgoingForwardsSat {
+			continue
+		}
+		if rule.MaxOutgoingForwardsSat != nil && outgoingSat > *rule.MaxOutgoingForwardsSat {
+			continue
+		}
+		if rule.MinIncomingForwardsSat != nil && incomingSat < *rule.MinIncomingForwardsSat {
+			continue
+		}
+		if rule.MaxIncomingForwardsSat != nil && incomingSat > *rule.MaxIncomingForwardsSat {
+			continue
+		}
+		return &rules[i]
+	}
+	return nil
+}
+
+// calculateFees applies the rule to the current fees and keeps the result within the bounds of the policy.
+func calculateFees(feePolicy FeePolicy, rule FeePolicyRule, currentFeeRate int64, currentFeeBase int64) (int64, int64) {
+	feeRate := currentFeeRate
+	feeBase := currentFeeBase
+	if rule.FeeRateMilliMsat != nil {
+		if rule.AdjustmentType == relativeAdjustment {
+			feeRate = currentFeeRate + *rule.FeeRateMilliMsat
+		} else {
+			feeRate = *rule.FeeRateMilliMsat
+		}
+		feeRate = clamp(feeRate, feePolicy.MinFeeRateMilliMsat, feePolicy.MaxFeeRateMilliMsat)
+	}
+	if rule.FeeBaseMsat != nil {
+		if rule.AdjustmentType == relativeAdjustment {
+			feeBase = currentFeeBase + *rule.FeeBaseMsat
+		} else {
+			feeBase = *rule.FeeBaseMsat
+		}
+		feeBase = clamp(feeBase, feePolicy.MinFeeBaseMsat, feePolicy.MaxFeeBaseMsat)
+	}
+	return feeRate, feeBase
+}
+
+func clamp(value int64, min int64, max int64) int64 {
+	if value > max {
+		value = max
+	}
+	if value < min {
+		value = min
+	}
+	if value < 0 {
+		value = 0
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package fee_policies
+
+import (
+	"testing"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+func TestGetMatchingRule(t *testing.T) {
+	rules := []FeePolicyRule{
+		{FeePolicyRuleId: 1, MaxLocalBalancePerMille: intPointer(200), FeeRateMilliMsat: int64Pointer(1000)},
+		{FeePolicyRuleId: 2, MinLocalBalancePerMille: intPointer(800), MaxOutgoingForwardsSat: int64Pointer(0),
+			FeeRateMilliMsat: int64Pointer(-50)},
+		{FeePolicyRuleId: 3, MinOutgoingForwardsSat: int64Pointer(1_000_000), FeeRateMilliMsat: int64Pointer(25)},
+	}
+	tests := []struct {
+		name                 string
+		localBalancePerMille int
+		outgoingSat          int64
+		want                 int
+	}{
+		{"Depleted", 100, 0, 1},
+		{"Full without flow", 900, 0, 2},
+		{"Full with flow", 900, 2_000_000, 3},
+		{"Balanced without flow", 500, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := getMatchingRule(rules, test.localBalancePerMille, test.outgoingSat, 0)
+			got := 0
+			if rule != nil {
+				got = rule.FeePolicyRuleId
+			}
+			if got != test.want {
+				t.Errorf("got rule %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCalculateFees(t *testing.T) {
+	feePolicy := FeePolicy{
+		MinFeeRateMilliMsat: 10,
+		MaxFeeRateMilliMsat: 2000,
+		MinFeeBaseMsat:      0,
+		MaxFeeBaseMsat:      1000,
+	}
+	tests := []struct {
+		name     string
+		rule     FeePolicyRule
+		wantRate int64
+		wantBase int64
+	}{
+		{"Absolute rate only",
+			FeePolicyRule{AdjustmentType: absoluteAdjustment, FeeRateMilliMsat: int64Pointer(500)}, 500, 1000},
+		{"Absolute above bounds",
+			FeePolicyRule{AdjustmentType: absoluteAdjustment, FeeRateMilliMsat: int64Pointer(5000), FeeBaseMsat: int64Pointer(0)},
+			2000, 0},
+		{"Relative decrease below bounds",
+			FeePolicyRule{AdjustmentType: relativeAdjustment, FeeRateMilliMsat: int64Pointer(-200)}, 10, 1000},
+		{"Relative increase",
+			FeePolicyRule{AdjustmentType: relativeAdjustment, FeeRateMilliMsat: int64Pointer(50), FeeBaseMsat: int64Pointer(-100)},
+			150, 900},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feeRate, feeBase := calculateFees(feePolicy, test.rule, 100, 1000)
+			if feeRate != test.wantRate || feeBase != test.wantBase {
+				t.Errorf("got %v/%v, want %v/%v", feeRate, feeBase, test.wantRate, test.wantBase)
+			}
+		})
+	}
+}
+
+func TestPolicyTargetsChannelGroups(t *testing.T) {
+	channelGroups := []commons.ChannelGroup{
+		{CategoryId: intPointer(1)},
+		{CategoryId: intPointer(2), TagId: intPointer(5)},
+	}
+	tests := []struct {
+		name      string
+		feePolicy FeePolicy
+		want      bool
+	}{
+		{"All channels", FeePolicy{}, true},
+		{"Matching category", FeePolicy{CategoryId: intPointer(2)}, true},
+		{"Other category", FeePolicy{CategoryId: intPointer(3)}, false},
+		{"Matching tag", FeePolicy{TagId: intPointer(5)}, true},
+		{"Other tag", FeePolicy{TagId: intPointer(6)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := policyTargetsChannelGroups(test.feePolicy, channelGroups); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
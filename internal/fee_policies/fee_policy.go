@@ -0,0 +1,81 @@
+package fee_policies
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type adjustmentType int
+
+const (
+	// absoluteAdjustment sets the fee rate and/or base fee to the values of the rule
+	absoluteAdjustment = adjustmentType(iota)
+	// relativeAdjustment adds the (signed) values of the rule to the current fee rate and/or base fee
+	relativeAdjustment
+)
+
+type FeePolicy struct {
+	FeePolicyId              int             `json:"feePolicyId" db:"fee_policy_id"`
+	Name                     string          `json:"name" db:"name"`
+	NodeId                   int             `json:"nodeId" db:"node_id"`
+	CategoryId               *int            `json:"categoryId" db:"category_id"`
+	TagId                    *int            `json:"tagId" db:"tag_id"`
+	Priority                 int             `json:"priority" db:"priority"`
+	Status                   commons.Status  `json:"status" db:"status"`
+	DryRun                   bool            `json:"dryRun" db:"dry_run"`
+	FlowLookbackMinutes      int             `json:"flowLookbackMinutes" db:"flow_lookback_minutes"`
+	MinChangeIntervalMinutes int             `json:"minChangeIntervalMinutes" db:"min_change_interval_minutes"`
+	MinFeeRateMilliMsat      int64           `json:"minFeeRateMilliMsat" db:"min_fee_rate_milli_msat"`
+	MaxFeeRateMilliMsat      int64           `json:"maxFeeRateMilliMsat" db:"max_fee_rate_milli_msat"`
+	MinFeeBaseMsat           int64           `json:"minFeeBaseMsat" db:"min_fee_base_msat"`
+	MaxFeeBaseMsat           int64           `json:"maxFeeBaseMsat" db:"max_fee_base_msat"`
+	CreatedOn                time.Time       `json:"createdOn" db:"created_on"`
+	UpdateOn                 time.Time       `json:"updatedOn" db:"updated_on"`
+	Rules                    []FeePolicyRule `json:"rules"`
+}
+
+// FeePolicyRule conditions that are nil are ignored, a rule without conditions always matches.
+type FeePolicyRule struct {
+	FeePolicyRuleId         int            `json:"feePolicyRuleId" db:"fee_policy_rule_id"`
+	FeePolicyId             int            `json:"feePolicyId" db:"fee_policy_id"`
+	SortOrder               int            `json:"sortOrder" db:"sort_order"`
+	MinLocalBalancePerMille *int           `json:"minLocalBalancePerMille" db:"min_local_balance_per_mille"`
+	MaxLocalBalancePerMille *int           `json:"maxLocalBalancePerMille" db:"max_local_balance_per_mille"`
+	MinOutgoingForwardsSat  *int64         `json:"minOutgoingForwardsSat" db:"min_outgoing_forwards_sat"`
+	MaxOutgoingForwardsSat  *int64         `json:"maxOutgoingForwardsSat" db:"max_outgoing_forwards_sat"`
+	MinIncomingForwardsSat  *int64         `json:"minIncomingForwardsSat" db:"min_incoming_forwards_sat"`
+	MaxIncomingForwardsSat  *int64         `json:"maxIncomingForwardsSat" db:"max_incoming_forwards_sat"`
+	AdjustmentType          adjustmentType `json:"adjustmentType" db:"adjustment_type"`
+	FeeRateMilliMsat        *int64         `json:"feeRateMilliMsat" db:"fee_rate_milli_msat"`
+	FeeBaseMsat             *int64         `json:"feeBaseMsat" db:"fee_base_msat"`
+	CreatedOn               time.Time      `json:"createdOn" db:"created_on"`
+	UpdateOn                time.Time      `json:"updatedOn" db:"updated_on"`
+}
+
+type FeePolicyChange struct {
+	FeePolicyChangeId        int            `json:"feePolicyChangeId" db:"fee_policy_change_id"`
+	FeePolicyId              *int           `json:"feePolicyId" db:"fee_policy_id"`
+	FeePolicyRuleId          *int           `json:"feePolicyRuleId" db:"fee_policy_rule_id"`
+	Rule                     types.JSONText `json:"rule" db:"rule"`
+	NodeId                   int            `json:"nodeId" db:"node_id"`
+	ChannelId                int            `json:"channelId" db:"channel_id"`
+	DryRun                   bool           `json:"dryRun" db:"dry_run"`
+	LocalBalancePerMille     int            `json:"localBalancePerMille" db:"local_balance_per_mille"`
+	OutgoingForwardsSat      int64          `json:"outgoingForwardsSat" db:"outgoing_forwards_sat"`
+	IncomingForwardsSat      int64          `json:"incomingForwardsSat" db:"incoming_forwards_sat"`
+	PreviousFeeRateMilliMsat int64          `json:"previousFeeRateMilliMsat" db:"previous_fee_rate_milli_msat"`
+	PreviousFeeBaseMsat      int64          `json:"previousFeeBaseMsat" db:"previous_fee_base_msat"`
+	FeeRateMilliMsat         int64          `json:"feeRateMilliMsat" db:"fee_rate_milli_msat"`
+	FeeBaseMsat              int64          `json:"feeBaseMsat" db:"fee_base_msat"`
+	Status                   commons.Status `json:"status" db:"status"`
+	Error                    *string        `json:"error" db:"error"`
+	CreatedOn                time.Time      `json:"createdOn" db:"created_on"`
+}
+
+type channelFlow struct {
+	ChannelId int   `db:"channel_id"`
+	AmountSat int64 `db:"amount_sat"`
+}
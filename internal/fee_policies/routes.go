@@ -0,0 +1,150 @@
+package fee_policies
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterFeePolicyRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("get/:feePolicyId", func(c *gin.Context) { getFeePolicyHandler(c, db) })
+	r.GET("all", func(c *gin.Context) { getFeePoliciesHandler(c, db) })
+	r.POST("add", func(c *gin.Context) { addFeePolicyHandler(c, db) })
+	// setFeePolicyHandler replaces all the rules of the fee policy
+	r.PUT("set", func(c *gin.Context) { setFeePolicyHandler(c, db) })
+	// changes can be filtered with the query parameters feePolicyId and channelId
+	r.GET("changes", func(c *gin.Context) { getFeePolicyChangesHandler(c, db) })
+}
+
+func getFeePolicyHandler(c *gin.Context, db *sqlx.DB) {
+	feePolicyId, err := strconv.Atoi(c.Param("feePolicyId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse feePolicyId in the request.")
+		return
+	}
+	feePolicy, err := getFeePolicy(db, feePolicyId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting fee policy for feePolicyId: %v", feePolicyId))
+		return
+	}
+	if feePolicy.FeePolicyId == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"Error": "Fee policy not found", "FeePolicyId": feePolicyId})
+		return
+	}
+	c.JSON(http.StatusOK, feePolicy)
+}
+
+func getFeePoliciesHandler(c *gin.Context, db *sqlx.DB) {
+	feePolicies, err := getFeePolicies(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting fee policies.")
+		return
+	}
+	c.JSON(http.StatusOK, feePolicies)
+}
+
+func addFeePolicyHandler(c *gin.Context, db *sqlx.DB) {
+	var feePolicy FeePolicy
+	if err := c.BindJSON(&feePolicy); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if message := validateFeePolicy(feePolicy); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	storedFeePolicy, err := addFeePolicy(db, feePolicy)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding fee policy.")
+		return
+	}
+	c.JSON(http.StatusOK, storedFeePolicy)
+}
+
+func setFeePolicyHandler(c *gin.Context, db *sqlx.DB) {
+	var feePolicy FeePolicy
+	if err := c.BindJSON(&feePolicy); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if feePolicy.FeePolicyId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Failed to find feePolicyId in the request.")
+		return
+	}
+	if message := validateFeePolicy(feePolicy); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	storedFeePolicy, err := setFeePolicy(db, feePolicy)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting fee policy for feePolicyId: %v", feePolicy.FeePolicyId))
+		return
+	}
+	c.JSON(http.StatusOK, storedFeePolicy)
+}
+
+func getFeePolicyChangesHandler(c *gin.Context, db *sqlx.DB) {
+	var feePolicyId *int
+	if c.Query("feePolicyId") != "" {
+		id, err := strconv.Atoi(c.Query("feePolicyId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse feePolicyId in the request.")
+			return
+		}
+		feePolicyId = &id
+	}
+	var channelId *int
+	if c.Query("channelId") != "" {
+		id, err := strconv.Atoi(c.Query("channelId"))
+		if err != nil {
+			server_errors.SendBadRequest(c, "Failed to parse channelId in the request.")
+			return
+		}
+		channelId = &id
+	}
+	changes, err := getFeePolicyChanges(db, feePolicyId, channelId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting fee policy changes.")
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+func validateFeePolicy(feePolicy FeePolicy) string {
+	if feePolicy.Name == "" {
+		return "Failed to find name in the request."
+	}
+	if feePolicy.NodeId == 0 {
+		return "Failed to find nodeId in the request."
+	}
+	if feePolicy.CategoryId != nil && feePolicy.TagId != nil {
+		return "A fee policy can target a category or a tag but not both."
+	}
+	if feePolicy.FlowLookbackMinutes <= 0 {
+		return "The flow lookback should be at least one minute."
+	}
+	if feePolicy.MinChangeIntervalMinutes < 0 {
+		return "The minimum change interval cannot be negative."
+	}
+	if feePolicy.MinFeeRateMilliMsat < 0 || feePolicy.MaxFeeRateMilliMsat < feePolicy.MinFeeRateMilliMsat {
+		return "Invalid fee rate bounds."
+	}
+	if feePolicy.MinFeeBaseMsat < 0 || feePolicy.MaxFeeBaseMsat < feePolicy.MinFeeBaseMsat {
+		return "Invalid base fee bounds."
+	}
+	for _, rule := range feePolicy.Rules {
+		if rule.AdjustmentType != absoluteAdjustment && rule.AdjustmentType != relativeAdjustment {
+			return fmt.Sprintf("Unknown adjustment type: %v", rule.AdjustmentType)
+		}
+		if rule.FeeRateMilliMsat == nil && rule.FeeBaseMsat == nil {
+			return "A fee policy rule requires a fee rate or a base fee."
+		}
+	}
+	return ""
+}
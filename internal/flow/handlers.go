@@ -12,6 +12,7 @@ import (
 	"github.com/lib/pq"
 	"gopkg.in/guregu/null.v4"
 
+	"github.com/lncapital/torq/internal/prices"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
@@ -42,6 +43,13 @@ type channelFlowData struct {
 	CountOut uint64 `json:"countOut"`
 	// Number of inbound forwards.
 	CountIn uint64 `json:"countIn"`
+
+	// The amounts and revenue in the requested currency with the price at the time of each forward.
+	// Empty when no currency was requested.
+	AmountOutFiat  null.Float `json:"amountOutFiat"`
+	AmountInFiat   null.Float `json:"amountInFiat"`
+	RevenueOutFiat null.Float `json:"revenueOutFiat"`
+	RevenueInFiat  null.Float `json:"revenueInFiat"`
 }
 
 func getFlowHandler(c *gin.Context, db *sqlx.DB) {
@@ -63,7 +71,17 @@ func getFlowHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
-	r, err := getFlow(db, chanIds, from, to)
+	currency, message, err := prices.GetCurrencyParameter(c, db)
+	if err != nil {
+		server_errors.LogAndSendServerError(c, err)
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+
+	r, err := getFlow(db, chanIds, from, to, currency)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -72,7 +90,7 @@ func getFlowHandler(c *gin.Context, db *sqlx.DB) {
 }
 
 func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
-	toTime time.Time) (r []*channelFlowData,
+	toTime time.Time, currency *string) (r []*channelFlowData,
 	err error) {
 
 	var channelIds []int
@@ -91,7 +109,7 @@ func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
 		}
 	}
 
-	const sql = `
+	sql := `
 		select
 			ne.alias,
 			fw.channel_id,
@@ -105,7 +123,12 @@ func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
 
 			coalesce(fw.amount_out, 0) as amount_out,
 			coalesce(fw.revenue_out, 0) as revenue_out,
-			coalesce(fw.count_out, 0) as count_out
+			coalesce(fw.count_out, 0) as count_out,
+
+			case when $5::text is null then null else coalesce(fw.amount_in_fiat, 0) end as amount_in_fiat,
+			case when $5::text is null then null else coalesce(fw.revenue_in_fiat, 0) end as revenue_in_fiat,
+			case when $5::text is null then null else coalesce(fw.amount_out_fiat, 0) end as amount_out_fiat,
+			case when $5::text is null then null else coalesce(fw.revenue_out_fiat, 0) end as revenue_out_fiat
 		from (
 			select
 				coalesce(o.outgoing_channel_id, i.incoming_channel_id) as channel_id,
@@ -114,13 +137,19 @@ func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
 				i.revenue as revenue_in,
 				o.revenue as revenue_out,
 				i.count as count_in,
-				o.count as count_out
+				o.count as count_out,
+				i.amount_fiat as amount_in_fiat,
+				o.amount_fiat as amount_out_fiat,
+				i.revenue_fiat as revenue_in_fiat,
+				o.revenue_fiat as revenue_out_fiat
 			from (
 				select
 					outgoing_channel_id,
 					floor(sum(outgoing_amount_msat)/1000) as amount,
 					floor(sum(fee_msat)/1000) as revenue,
-					count(time) as count
+					count(time) as count,
+					sum(` + prices.FiatAmountSql("outgoing_amount_msat", "forward.time", "$5") + `) as amount_fiat,
+					sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$5") + `) as revenue_fiat
 				from forward
 				where time >= $1
 					and time <= $2
@@ -132,7 +161,9 @@ func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
 					incoming_channel_id,
 					floor(sum(outgoing_amount_msat)/1000) as amount,
 					floor(sum(fee_msat)/1000) as revenue,
-					count(time) as count
+					count(time) as count,
+					sum(` + prices.FiatAmountSql("outgoing_amount_msat", "forward.time", "$5") + `) as amount_fiat,
+					sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$5") + `) as revenue_fiat
 				from forward
 				where time >= $1
 					and time <= $2
@@ -163,7 +194,7 @@ func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
 		left join node n on ne.event_node_id = n.node_id
 	`
 
-	rows, err := db.Queryx(sql, fromTime, toTime, getAll, pq.Array(channelIds))
+	rows, err := db.Queryx(sql, fromTime, toTime, getAll, pq.Array(channelIds), currency)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error running flow query")
 	}
@@ -184,6 +215,11 @@ func getFlow(db *sqlx.DB, lndShortChannelIdStrings []string, fromTime time.Time,
 			&c.AmountIn,
 			&c.RevenueIn,
 			&c.CountIn,
+
+			&c.AmountOutFiat,
+			&c.RevenueOutFiat,
+			&c.AmountInFiat,
+			&c.RevenueInFiat,
 		)
 		if err != nil {
 			return r, errors.Wrap(err, "SQL row scan")
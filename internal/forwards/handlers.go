@@ -13,6 +13,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v4"
 
+	"github.com/lncapital/torq/internal/prices"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
@@ -38,11 +39,21 @@ func getForwardsTableHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
+	currency, message, err := prices.GetCurrencyParameter(c, db)
+	if err != nil {
+		server_errors.LogAndSendServerError(c, err)
+		return
+	}
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+
 	chain := commons.Bitcoin
 
 	log.Debug().Msgf("%v", commons.GetAllTorqNodeIds(chain, commons.Network(network)))
 
-	r, err := getForwardsTableData(db, commons.GetAllTorqNodeIds(chain, commons.Network(network)), from, to)
+	r, err := getForwardsTableData(db, commons.GetAllTorqNodeIds(chain, commons.Network(network)), from, to, currency)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
 		return
@@ -102,10 +113,19 @@ type forwardsTableRow struct {
 	TurnoverOut   float32 `json:"turnoverOut"`
 	TurnoverIn    float32 `json:"turnoverIn"`
 	TurnoverTotal float32 `json:"turnoverTotal"`
+
+	// The amounts and revenue in the requested currency with the price at the time of each forward.
+	// Empty when no currency was requested.
+	AmountOutFiat    null.Float `json:"amountOutFiat"`
+	AmountInFiat     null.Float `json:"amountInFiat"`
+	AmountTotalFiat  null.Float `json:"amountTotalFiat"`
+	RevenueOutFiat   null.Float `json:"revenueOutFiat"`
+	RevenueInFiat    null.Float `json:"revenueInFiat"`
+	RevenueTotalFiat null.Float `json:"revenueTotalFiat"`
 }
 
 func getForwardsTableData(db *sqlx.DB, nodeIds []int,
-	fromTime time.Time, toTime time.Time) (r []*forwardsTableRow, err error) {
+	fromTime time.Time, toTime time.Time, currency *string) (r []*forwardsTableRow, err error) {
 
 	var sqlString = `
 		select
@@ -140,7 +160,16 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 
 			coalesce(round(fw.amount_out / ce.capacity::numeric, 2), 0) as turnover_out,
 			coalesce(round(fw.amount_in / ce.capacity::numeric, 2), 0) as turnover_in,
-			coalesce(round((fw.amount_in + fw.amount_out) / ce.capacity::numeric, 2), 0) as turnover_total
+			coalesce(round((fw.amount_in + fw.amount_out) / ce.capacity::numeric, 2), 0) as turnover_total,
+
+			case when $5::text is null then null else coalesce(fw.amount_out_fiat, 0) end as amount_out_fiat,
+			case when $5::text is null then null else coalesce(fw.amount_in_fiat, 0) end as amount_in_fiat,
+			case when $5::text is null then null
+				else coalesce(fw.amount_in_fiat + fw.amount_out_fiat, 0) end as amount_total_fiat,
+			case when $5::text is null then null else coalesce(fw.revenue_out_fiat, 0) end as revenue_out_fiat,
+			case when $5::text is null then null else coalesce(fw.revenue_in_fiat, 0) end as revenue_in_fiat,
+			case when $5::text is null then null
+				else coalesce(fw.revenue_in_fiat + fw.revenue_out_fiat, 0) end as revenue_total_fiat
 
 		from channel as c
 		left join (
@@ -174,12 +203,18 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 				coalesce(o.count,0) as count_out,
 				coalesce(i.amount,0) as amount_in,
 				coalesce(i.revenue,0) as revenue_in,
-				coalesce(i.count,0) as count_in
+				coalesce(i.count,0) as count_in,
+				coalesce(o.amount_fiat,0) as amount_out_fiat,
+				coalesce(o.revenue_fiat,0) as revenue_out_fiat,
+				coalesce(i.amount_fiat,0) as amount_in_fiat,
+				coalesce(i.revenue_fiat,0) as revenue_in_fiat
 			from (
 				select outgoing_channel_id channel_id,
 					   floor(sum(outgoing_amount_msat)/1000) as amount,
 					   floor(sum(fee_msat)/1000) as revenue,
-					   count(time) as count
+					   count(time) as count,
+					   sum(` + prices.FiatAmountSql("outgoing_amount_msat", "forward.time", "$5") + `) as amount_fiat,
+					   sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$5") + `) as revenue_fiat
 				from forward
 				where time::timestamp AT TIME ZONE $3 >= $1::timestamp AT TIME ZONE $3
 					and time::timestamp AT TIME ZONE $3 <= $2::timestamp AT TIME ZONE $3
@@ -189,7 +224,9 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 				select incoming_channel_id as channel_id,
 					   floor(sum(incoming_amount_msat)/1000) as amount,
 					   floor(sum(fee_msat)/1000) as revenue,
-					   count(time) as count
+					   count(time) as count,
+					   sum(` + prices.FiatAmountSql("incoming_amount_msat", "forward.time", "$5") + `) as amount_fiat,
+					   sum(` + prices.FiatAmountSql("fee_msat", "forward.time", "$5") + `) as revenue_fiat
 				from forward
 				where time::timestamp AT TIME ZONE $3 >= $1::timestamp AT TIME ZONE $3
 					and time::timestamp AT TIME ZONE $3 <= $2::timestamp AT TIME ZONE $3
@@ -200,7 +237,7 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 		WHERE ( c.first_node_id = ANY($4) OR c.second_node_id = ANY($4) )
 `
 
-	rows, err := db.Queryx(sqlString, fromTime, toTime, commons.GetSettings().PreferredTimeZone, pq.Array(nodeIds))
+	rows, err := db.Queryx(sqlString, fromTime, toTime, commons.GetSettings().PreferredTimeZone, pq.Array(nodeIds), currency)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Running aggregated forwards query")
 	}
@@ -239,6 +276,13 @@ func getForwardsTableData(db *sqlx.DB, nodeIds []int,
 			&c.TurnoverOut,
 			&c.TurnoverIn,
 			&c.TurnoverTotal,
+
+			&c.AmountOutFiat,
+			&c.AmountInFiat,
+			&c.AmountTotalFiat,
+			&c.RevenueOutFiat,
+			&c.RevenueInFiat,
+			&c.RevenueTotalFiat,
 		)
 		if err != nil {
 			return r, errors.Wrap(err, "SQL row scan")
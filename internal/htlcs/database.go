@@ -0,0 +1,128 @@
+package htlcs
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// HtlcFailure the failed HTLCs of a channel pair aggregated by failure reason.
+// ForwardFailEvents don't contain the amount so it's obtained from the matching ForwardEvent.
+type HtlcFailure struct {
+	NodeId            int    `json:"nodeId" db:"node_id"`
+	IncomingChannelId *int   `json:"incomingChannelId" db:"incoming_channel_id"`
+	OutgoingChannelId *int   `json:"outgoingChannelId" db:"outgoing_channel_id"`
+	EventType         string `json:"eventType" db:"event_type"`
+	BoltFailureCode   string `json:"boltFailureCode" db:"bolt_failure_code"`
+	LndFailureDetail  string `json:"lndFailureDetail" db:"lnd_failure_detail"`
+	Count             int64  `json:"count" db:"count"`
+	AmountMsat        int64  `json:"amountMsat" db:"amount_msat"`
+}
+
+// MissedLiquidity the HTLCs that failed because the outgoing channel had insufficient local balance
+type MissedLiquidity struct {
+	NodeId            int     `json:"nodeId" db:"node_id"`
+	OutgoingChannelId int     `json:"outgoingChannelId" db:"outgoing_channel_id"`
+	ShortChannelId    *string `json:"shortChannelId" db:"short_channel_id"`
+	Count             int64   `json:"count" db:"count"`
+	AmountMsat        int64   `json:"amountMsat" db:"amount_msat"`
+	MaxAmountMsat     int64   `json:"maxAmountMsat" db:"max_amount_msat"`
+}
+
+// FailingCorridor a channel pair ordered by the number of failed HTLCs
+type FailingCorridor struct {
+	NodeId                 int            `json:"nodeId" db:"node_id"`
+	IncomingChannelId      *int           `json:"incomingChannelId" db:"incoming_channel_id"`
+	IncomingShortChannelId *string        `json:"incomingShortChannelId" db:"incoming_short_channel_id"`
+	OutgoingChannelId      *int           `json:"outgoingChannelId" db:"outgoing_channel_id"`
+	OutgoingShortChannelId *string        `json:"outgoingShortChannelId" db:"outgoing_short_channel_id"`
+	Count                  int64          `json:"count" db:"count"`
+	AmountMsat             int64          `json:"amountMsat" db:"amount_msat"`
+	FailureReasons         pq.StringArray `json:"failureReasons" db:"failure_reasons"`
+}
+
+// failedHtlcsQuery the failed HTLCs of the nodes within the time range ($1 from, $2 to, $3 time zone, $4 node ids).
+// From and to are local times of the time zone, the column isn't converted so the time index is used.
+// The amount of a ForwardFailEvent is obtained from the ForwardEvent of the same HTLC.
+const failedHtlcsQuery = `
+	SELECT he.node_id,
+		he.incoming_channel_id,
+		he.outgoing_channel_id,
+		he.event_type,
+		COALESCE(he.bolt_failure_code, '') AS bolt_failure_code,
+		COALESCE(he.lnd_failure_detail, '') AS lnd_failure_detail,
+		COALESCE(he.outgoing_amt_msat, fe.outgoing_amt_msat, 0) AS amount_msat
+	FROM htlc_event he
+	LEFT JOIN LATERAL (
+		SELECT f.outgoing_amt_msat
+		FROM htlc_event f
+		WHERE he.event_type = 'ForwardFailEvent' AND f.event_type = 'ForwardEvent' AND
+			f.node_id = he.node_id AND
+			f.incoming_channel_id = he.incoming_channel_id AND f.incoming_htlc_id = he.incoming_htlc_id AND
+			f.outgoing_channel_id = he.outgoing_channel_id AND f.outgoing_htlc_id = he.outgoing_htlc_id AND
+			f.time <= he.time
+		ORDER BY f.time DESC
+		LIMIT 1
+	) fe ON TRUE
+	WHERE he.event_type IN ('LinkFailEvent', 'ForwardFailEvent') AND
+		he.node_id = ANY($4) AND
+		he.time >= ($1::timestamp AT TIME ZONE $3) AND
+		he.time < ($2::timestamp AT TIME ZONE $3)`
+
+func getHtlcFailures(db *sqlx.DB, params htlcAnalyticsParameters) ([]HtlcFailure, error) {
+	var htlcFailures []HtlcFailure
+	err := db.Select(&htlcFailures, `
+		SELECT node_id, incoming_channel_id, outgoing_channel_id, event_type, bolt_failure_code, lnd_failure_detail,
+			COUNT(*) AS count, SUM(amount_msat) AS amount_msat
+		FROM (`+failedHtlcsQuery+`) failed
+		GROUP BY node_id, incoming_channel_id, outgoing_channel_id, event_type, bolt_failure_code, lnd_failure_detail
+		ORDER BY count DESC, amount_msat DESC;`,
+		params.From, params.To, commons.GetSettings().PreferredTimeZone, pq.Array(params.NodeIds))
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return htlcFailures, nil
+}
+
+func getMissedLiquidity(db *sqlx.DB, params htlcAnalyticsParameters) ([]MissedLiquidity, error) {
+	var missedLiquidity []MissedLiquidity
+	err := db.Select(&missedLiquidity, `
+		SELECT failed.node_id, failed.outgoing_channel_id, c.short_channel_id,
+			COUNT(*) AS count, SUM(failed.amount_msat) AS amount_msat, MAX(failed.amount_msat) AS max_amount_msat
+		FROM (`+failedHtlcsQuery+`) failed
+		LEFT JOIN channel c ON c.channel_id = failed.outgoing_channel_id
+		WHERE failed.lnd_failure_detail = 'INSUFFICIENT_BALANCE' AND failed.outgoing_channel_id IS NOT NULL
+		GROUP BY failed.node_id, failed.outgoing_channel_id, c.short_channel_id
+		ORDER BY amount_msat DESC;`,
+		params.From, params.To, commons.GetSettings().PreferredTimeZone, pq.Array(params.NodeIds))
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return missedLiquidity, nil
+}
+
+func getFailingCorridors(db *sqlx.DB, params htlcAnalyticsParameters, limit int) ([]FailingCorridor, error) {
+	var failingCorridors []FailingCorridor
+	err := db.Select(&failingCorridors, `
+		SELECT failed.node_id,
+			failed.incoming_channel_id, ic.short_channel_id AS incoming_short_channel_id,
+			failed.outgoing_channel_id, oc.short_channel_id AS outgoing_short_channel_id,
+			COUNT(*) AS count, SUM(failed.amount_msat) AS amount_msat,
+			ARRAY_AGG(DISTINCT COALESCE(NULLIF(failed.lnd_failure_detail, ''), NULLIF(failed.bolt_failure_code, ''),
+				failed.event_type)) AS failure_reasons
+		FROM (`+failedHtlcsQuery+`) failed
+		LEFT JOIN channel ic ON ic.channel_id = failed.incoming_channel_id
+		LEFT JOIN channel oc ON oc.channel_id = failed.outgoing_channel_id
+		GROUP BY failed.node_id, failed.incoming_channel_id, ic.short_channel_id,
+			failed.outgoing_channel_id, oc.short_channel_id
+		ORDER BY count DESC, amount_msat DESC
+		LIMIT $5;`,
+		params.From, params.To, commons.GetSettings().PreferredTimeZone, pq.Array(params.NodeIds), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return failingCorridors, nil
+}
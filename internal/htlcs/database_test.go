@@ -0,0 +1,65 @@
+package htlcs
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestGetHtlcFailuresInPreferredTimezone(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, cancel, err := srv.NewTestDatabase(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	_, err = db.Exec(`UPDATE settings SET preferred_timezone = 'America/New_York';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = settings.InitializeManagedSettingsCache(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = settings.InitializeManagedNodeCache(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeId := commons.GetNodeIdByPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet)
+
+	// 2022-11-01 in New York is from 04:00 UTC till 04:00 UTC the next day
+	for _, eventTime := range []time.Time{
+		time.Date(2022, 11, 1, 2, 0, 0, 0, time.UTC),
+		time.Date(2022, 11, 1, 5, 0, 0, 0, time.UTC),
+		time.Date(2022, 11, 2, 3, 0, 0, 0, time.UTC),
+		time.Date(2022, 11, 2, 5, 0, 0, 0, time.UTC),
+	} {
+		_, err = db.Exec(`
+			INSERT INTO htlc_event (time, event_origin, timestamp_ns, data, event_type, outgoing_amt_msat, node_id)
+			VALUES ($1, 'FORWARD', $2, '{}', 'LinkFailEvent', 1000, $3);`,
+			eventTime, eventTime.UnixNano(), nodeId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failures, err := getHtlcFailures(db, htlcAnalyticsParameters{
+		NodeIds: []int{nodeId},
+		From:    time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2022, 11, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].Count != 2 || failures[0].AmountMsat != 2000 {
+		t.Errorf("expected the 2 failures within the day in New York, got %+v", failures)
+	}
+}
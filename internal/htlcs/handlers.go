@@ -0,0 +1,106 @@
+package htlcs
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+const defaultCorridorLimit = 10
+
+type htlcAnalyticsParameters struct {
+	NodeIds []int
+	From    time.Time
+	To      time.Time
+}
+
+func getHtlcFailuresHandler(c *gin.Context, db *sqlx.DB) {
+	params, message := getHtlcAnalyticsParameters(c)
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+	r, err := getHtlcFailures(db, params)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting HTLC failures")
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func getMissedLiquidityHandler(c *gin.Context, db *sqlx.DB) {
+	params, message := getHtlcAnalyticsParameters(c)
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+	r, err := getMissedLiquidity(db, params)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting missed liquidity")
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func getFailingCorridorsHandler(c *gin.Context, db *sqlx.DB) {
+	params, message := getHtlcAnalyticsParameters(c)
+	if message != "" {
+		server_errors.SendBadRequest(c, message)
+		return
+	}
+	limit := defaultCorridorLimit
+	if c.Query("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 {
+			server_errors.SendBadRequest(c, "Limit must be a at least 1")
+			return
+		}
+	}
+	r, err := getFailingCorridors(db, params, limit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting failing corridors")
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// getHtlcAnalyticsParameters parses the from, to (both yyyy-mm-dd and inclusive), network and optional nodeId query
+// parameters. When nodeId is not provided all the torq nodes of the network are included.
+func getHtlcAnalyticsParameters(c *gin.Context) (htlcAnalyticsParameters, string) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		return htlcAnalyticsParameters{}, "Can't process from"
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		return htlcAnalyticsParameters{}, "Can't process to"
+	}
+	if to.Before(from) {
+		return htlcAnalyticsParameters{}, "To must be on or after from"
+	}
+	if c.Query("network") == "" {
+		return htlcAnalyticsParameters{}, "Network missing"
+	}
+	network, err := strconv.Atoi(c.Query("network"))
+	if err != nil {
+		return htlcAnalyticsParameters{}, "Can't process network"
+	}
+	params := htlcAnalyticsParameters{From: from, To: to.AddDate(0, 0, 1)}
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			return htlcAnalyticsParameters{}, "Can't process nodeId"
+		}
+		params.NodeIds = []int{nodeId}
+		return params, ""
+	}
+	params.NodeIds = commons.GetAllTorqNodeIds(commons.Bitcoin, commons.Network(network))
+	return params, ""
+}
@@ -0,0 +1,43 @@
+package htlcs
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetHtlcAnalyticsParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{"valid", "from=2022-11-01&to=2022-11-30&network=0&nodeId=1", ""},
+		{"missing from", "to=2022-11-30&network=0&nodeId=1", "Can't process from"},
+		{"to before from", "from=2022-11-30&to=2022-11-01&network=0&nodeId=1", "To must be on or after from"},
+		{"missing network", "from=2022-11-01&to=2022-11-30&nodeId=1", "Network missing"},
+		{"invalid node", "from=2022-11-01&to=2022-11-30&network=0&nodeId=abc", "Can't process nodeId"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/api/htlcs/failures?"+test.query, nil)
+			params, message := getHtlcAnalyticsParameters(c)
+			if message != test.message {
+				t.Fatalf("expected message %q, got %q", test.message, message)
+			}
+			if message != "" {
+				return
+			}
+			if len(params.NodeIds) != 1 || params.NodeIds[0] != 1 {
+				t.Errorf("expected node 1, got %v", params.NodeIds)
+			}
+			// to is inclusive so the range ends at the start of the next day
+			if !params.To.Equal(time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("expected the range to end on 2022-12-01, got %v", params.To)
+			}
+		})
+	}
+}
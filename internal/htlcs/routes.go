@@ -0,0 +1,12 @@
+package htlcs
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+func RegisterHtlcRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("failures", func(c *gin.Context) { getHtlcFailuresHandler(c, db) })
+	r.GET("missed-liquidity", func(c *gin.Context) { getMissedLiquidityHandler(c, db) })
+	r.GET("corridors", func(c *gin.Context) { getFailingCorridorsHandler(c, db) })
+}
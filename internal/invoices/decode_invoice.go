@@ -15,7 +15,6 @@ import (
 
 	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -115,19 +114,10 @@ func constructDecodedInvoice(decodedInvoice *lnrpc.PayReq) *DecodedInvoice {
 func decodeInvoice(db *sqlx.DB, invoice string, nodeId int) (*DecodedInvoice, error) {
 	//log.Info().Msgf("Decoding invoice: %s", invoice)
 	// Get lnd client
-	connectionDetails, err := settings.GetConnectionDetailsById(db, nodeId)
-	if err != nil {
-		return nil, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	conn, err := settings.GetLndConnection(db, nodeId)
 	if err != nil {
 		return nil, errors.Wrap(err, "Connecting to LND")
 	}
-	defer conn.Close()
 
 	client := lnrpc.NewLightningClient(conn)
 	// Decode invoice
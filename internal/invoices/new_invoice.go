@@ -9,7 +9,6 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 type newInvoiceRequest struct {
@@ -36,21 +35,11 @@ func newInvoice(db *sqlx.DB, req newInvoiceRequest) (r newInvoiceResponse, err e
 		return r, err
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
-	if err != nil {
-		return r, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	conn, err := settings.GetLndConnection(db, req.NodeId)
 	if err != nil {
 		return r, errors.Wrap(err, "Connecting to LND")
 	}
 
-	defer conn.Close()
-
 	client := lnrpc.NewLightningClient(conn)
 
 	ctx := context.Background()
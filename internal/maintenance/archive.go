@@ -0,0 +1,120 @@
+package maintenance
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+const archiveTimeFormat = "20060102T150405Z"
+
+// rowScanner is satisfied by *sqlx.Rows
+type rowScanner interface {
+	Next() bool
+	SliceScan() ([]interface{}, error)
+	Err() error
+}
+
+// archiveFileName the name is derived from the range of the chunk so a chunk is only archived once
+func archiveFileName(tableName string, c chunk) string {
+	return fmt.Sprintf("%v_%v_%v.csv.gz", tableName,
+		c.RangeStart.UTC().Format(archiveTimeFormat), c.RangeEnd.UTC().Format(archiveTimeFormat))
+}
+
+// archiveChunk writes the rows of the chunk to a compressed CSV file in archivePath.
+// It returns the path of the file or an empty string when the chunk was already archived.
+func archiveChunk(db *sqlx.DB, archivePath string, tableName string, c chunk) (string, error) {
+	path := filepath.Join(archivePath, archiveFileName(tableName, c))
+	if _, err := os.Stat(path); err == nil {
+		return "", nil
+	}
+	if err := os.MkdirAll(archivePath, 0700); err != nil {
+		return "", errors.Wrap(err, "Creating archive directory")
+	}
+
+	rows, err := db.Queryx(`SELECT * FROM ` + pq.QuoteIdentifier(c.Schema) + `.` + pq.QuoteIdentifier(c.Name) + `;`)
+	if err != nil {
+		return "", errors.Wrap(err, database.SqlExecutionError)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", errors.Wrap(err, database.SqlExecutionError)
+	}
+
+	// the file only gets its final name once it's complete
+	temporaryPath := path + ".tmp"
+	file, err := os.OpenFile(temporaryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", errors.Wrap(err, "Creating archive file")
+	}
+	err = writeArchive(file, columns, rows)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "Closing archive file")
+	}
+	if err != nil {
+		_ = os.Remove(temporaryPath)
+		return "", err
+	}
+	if err = os.Rename(temporaryPath, path); err != nil {
+		return "", errors.Wrap(err, "Renaming archive file")
+	}
+	return path, nil
+}
+
+// writeArchive writes a header with the columns followed by the rows as gzip compressed CSV
+func writeArchive(w io.Writer, columns []string, rows rowScanner) error {
+	gzipWriter := gzip.NewWriter(w)
+	csvWriter := csv.NewWriter(gzipWriter)
+	if err := csvWriter.Write(columns); err != nil {
+		return errors.Wrap(err, "Writing archive header")
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return errors.Wrap(err, database.SqlScanResulSetError)
+		}
+		for i, value := range values {
+			record[i] = formatArchiveValue(value)
+		}
+		if err = csvWriter.Write(record); err != nil {
+			return errors.Wrap(err, "Writing archive row")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return errors.Wrap(err, "Flushing archive")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "Compressing archive")
+	}
+	return nil
+}
+
+// formatArchiveValue NULL is written as an empty value
+func formatArchiveValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,237 @@
+package maintenance
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+type tableDefinition struct {
+	timeColumn string
+	// rawDataColumn is empty when the table has no raw data
+	rawDataColumn string
+	// aggregateQuery upserts the hourly aggregates of the events from $1 (inclusive) till $2 (exclusive)
+	aggregateQuery string
+	// latestKey when set the latest row per key is kept, otherwise whole chunks are dropped
+	latestKey []string
+}
+
+var tableDefinitions = map[string]tableDefinition{ //nolint:gochecknoglobals
+	HtlcEvent: {
+		timeColumn:    "time",
+		rawDataColumn: "data",
+		aggregateQuery: `
+			INSERT INTO htlc_event_hourly (hour, node_id, event_type, incoming_channel_id, outgoing_channel_id,
+				event_count, incoming_amt_msat, outgoing_amt_msat)
+			SELECT time_bucket('1 hour', time), node_id, COALESCE(event_type, ''), COALESCE(incoming_channel_id, 0),
+				COALESCE(outgoing_channel_id, 0), COUNT(*), COALESCE(SUM(incoming_amt_msat), 0),
+				COALESCE(SUM(outgoing_amt_msat), 0)
+			FROM htlc_event
+			WHERE time >= $1 AND time < $2
+			GROUP BY 1, 2, 3, 4, 5
+			ON CONFLICT (hour, node_id, event_type, incoming_channel_id, outgoing_channel_id) DO UPDATE SET
+				event_count=EXCLUDED.event_count,
+				incoming_amt_msat=EXCLUDED.incoming_amt_msat,
+				outgoing_amt_msat=EXCLUDED.outgoing_amt_msat;`,
+	},
+	RoutingPolicy: {
+		timeColumn: "ts",
+		aggregateQuery: `
+			INSERT INTO routing_policy_hourly (hour, node_id, channel_id, announcing_node_id, connecting_node_id,
+				update_count, min_fee_rate_mill_msat, max_fee_rate_mill_msat, min_fee_base_msat, max_fee_base_msat,
+				disabled, time_lock_delta, min_htlc, max_htlc_msat, fee_base_msat, fee_rate_mill_msat)
+			SELECT time_bucket('1 hour', ts), node_id, channel_id, announcing_node_id, connecting_node_id,
+				COUNT(*), MIN(fee_rate_mill_msat), MAX(fee_rate_mill_msat), MIN(fee_base_msat), MAX(fee_base_msat),
+				last(disabled, ts), last(time_lock_delta, ts), last(min_htlc, ts), last(max_htlc_msat, ts),
+				last(fee_base_msat, ts), last(fee_rate_mill_msat, ts)
+			FROM routing_policy
+			WHERE ts >= $1 AND ts < $2
+			GROUP BY 1, 2, 3, 4, 5
+			ON CONFLICT (hour, node_id, channel_id, announcing_node_id, connecting_node_id) DO UPDATE SET
+				update_count=EXCLUDED.update_count,
+				min_fee_rate_mill_msat=EXCLUDED.min_fee_rate_mill_msat,
+				max_fee_rate_mill_msat=EXCLUDED.max_fee_rate_mill_msat,
+				min_fee_base_msat=EXCLUDED.min_fee_base_msat,
+				max_fee_base_msat=EXCLUDED.max_fee_base_msat,
+				disabled=EXCLUDED.disabled,
+				time_lock_delta=EXCLUDED.time_lock_delta,
+				min_htlc=EXCLUDED.min_htlc,
+				max_htlc_msat=EXCLUDED.max_htlc_msat,
+				fee_base_msat=EXCLUDED.fee_base_msat,
+				fee_rate_mill_msat=EXCLUDED.fee_rate_mill_msat;`,
+		latestKey: []string{"channel_id", "announcing_node_id", "connecting_node_id"},
+	},
+	NodeEvent: {
+		timeColumn: "timestamp",
+		aggregateQuery: `
+			INSERT INTO node_event_hourly (hour, node_id, event_node_id, update_count,
+				alias, color, node_addresses, features)
+			SELECT time_bucket('1 hour', timestamp), node_id, event_node_id, COUNT(*),
+				last(alias, timestamp), last(color, timestamp), last(node_addresses, timestamp),
+				last(features, timestamp)
+			FROM node_event
+			WHERE timestamp >= $1 AND timestamp < $2
+			GROUP BY 1, 2, 3
+			ON CONFLICT (hour, node_id, event_node_id) DO UPDATE SET
+				update_count=EXCLUDED.update_count,
+				alias=EXCLUDED.alias,
+				color=EXCLUDED.color,
+				node_addresses=EXCLUDED.node_addresses,
+				features=EXCLUDED.features;`,
+		latestKey: []string{"node_id", "event_node_id"},
+	},
+}
+
+// chunk a TimescaleDB partition of a hypertable
+type chunk struct {
+	Schema     string    `db:"chunk_schema"`
+	Name       string    `db:"chunk_name"`
+	RangeStart time.Time `db:"range_start"`
+	RangeEnd   time.Time `db:"range_end"`
+}
+
+func getRetentionPolicies(db *sqlx.DB) ([]RetentionPolicy, error) {
+	var policies []RetentionPolicy
+	err := db.Select(&policies, `SELECT * FROM retention_policy ORDER BY table_name;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []RetentionPolicy{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return policies, nil
+}
+
+func setRetentionPolicy(db *sqlx.DB, policy RetentionPolicy) (RetentionPolicy, error) {
+	var result RetentionPolicy
+	err := db.Get(&result, `
+		UPDATE retention_policy
+		SET raw_data_days=$2, aggregate_after_days=$3, retention_days=$4, archive=$5, updated_on=$6
+		WHERE table_name=$1
+		RETURNING *;`,
+		policy.TableName, policy.RawDataDays, policy.AggregateAfterDays, policy.RetentionDays, policy.Archive,
+		time.Now().UTC())
+	if err != nil {
+		return RetentionPolicy{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return result, nil
+}
+
+func clearRawData(db *sqlx.DB, tableName string, definition tableDefinition, before time.Time) (int64, error) {
+	result, err := db.Exec(`
+		UPDATE `+tableName+`
+		SET `+definition.rawDataColumn+`=NULL
+		WHERE `+definition.timeColumn+` < $1 AND `+definition.rawDataColumn+` IS NOT NULL;`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	cleared, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return cleared, nil
+}
+
+// aggregationStart the first hour that isn't aggregated yet, the zero time when there are no events
+func aggregationStart(db *sqlx.DB, tableName string, definition tableDefinition,
+	aggregatedTill *time.Time) (time.Time, error) {
+
+	if aggregatedTill != nil {
+		return *aggregatedTill, nil
+	}
+	var first sql.NullTime
+	err := db.Get(&first, `SELECT MIN(`+definition.timeColumn+`) FROM `+tableName+`;`)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	if !first.Valid {
+		return time.Time{}, nil
+	}
+	return first.Time.UTC().Truncate(time.Hour), nil
+}
+
+// aggregate upserts the hourly aggregates and moves the aggregation watermark in one transaction
+func aggregate(db *sqlx.DB, tableName string, definition tableDefinition, from time.Time,
+	till time.Time) (int64, error) {
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	defer func() {
+		// Rollback is a no-op when the transaction is committed
+		_ = tx.Rollback()
+	}()
+	result, err := tx.Exec(definition.aggregateQuery, from, till)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = tx.Exec(`UPDATE retention_policy SET aggregated_till=$2 WHERE table_name=$1;`, tableName, till)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return rows, nil
+}
+
+// getChunksBefore the chunks that only contain events before the given time
+func getChunksBefore(db *sqlx.DB, tableName string, before time.Time) ([]chunk, error) {
+	var chunks []chunk
+	err := db.Select(&chunks, `
+		SELECT chunk_schema, chunk_name, range_start, range_end
+		FROM timescaledb_information.chunks
+		WHERE hypertable_name=$1 AND range_end <= $2
+		ORDER BY range_end;`, tableName, before)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []chunk{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return chunks, nil
+}
+
+// removeChunks drops the chunks before the given time, when the table keeps the latest row per key only the
+// other rows of these chunks are removed
+func removeChunks(db *sqlx.DB, tableName string, definition tableDefinition,
+	before time.Time) (int64, int64, error) {
+
+	if len(definition.latestKey) == 0 {
+		var droppedChunks []string
+		err := db.Select(&droppedChunks, `SELECT drop_chunks($1, older_than => $2::timestamptz);`,
+			tableName, before)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, database.SqlExecutionError)
+		}
+		return int64(len(droppedChunks)), 0, nil
+	}
+	query := `
+		DELETE FROM ` + tableName + ` old
+		WHERE old.` + definition.timeColumn + ` < $1 AND EXISTS (
+			SELECT 1
+			FROM ` + tableName + ` newer
+			WHERE newer.` + definition.timeColumn + ` > old.` + definition.timeColumn
+	for _, column := range definition.latestKey {
+		query += ` AND newer.` + column + `=old.` + column
+	}
+	query += `);`
+	result, err := db.Exec(query, before)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	removedRows, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return 0, removedRows, nil
+}
@@ -0,0 +1,244 @@
+package maintenance
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const (
+	HtlcEvent     = "htlc_event"
+	RoutingPolicy = "routing_policy"
+	NodeEvent     = "node_event"
+)
+
+// aggregationWindow hours are aggregated per window so a large backlog doesn't end up in a single transaction
+const aggregationWindow = 24 * time.Hour
+
+// RetentionPolicy NULL days disable the step, by default nothing is removed
+type RetentionPolicy struct {
+	TableName string `json:"tableName" db:"table_name"`
+	// RawDataDays only for htlc_event: the raw JSON of older events is removed
+	RawDataDays *int `json:"rawDataDays" db:"raw_data_days"`
+	// AggregateAfterDays older events are rolled up into hourly aggregates
+	AggregateAfterDays *int `json:"aggregateAfterDays" db:"aggregate_after_days"`
+	// RetentionDays older partitions are removed (and archived when Archive is set)
+	RetentionDays  *int       `json:"retentionDays" db:"retention_days"`
+	Archive        bool       `json:"archive" db:"archive"`
+	AggregatedTill *time.Time `json:"aggregatedTill" db:"aggregated_till"`
+	UpdatedOn      time.Time  `json:"updatedOn" db:"updated_on"`
+}
+
+type MaintenanceReport struct {
+	TableName   string     `json:"tableName"`
+	StartedOn   time.Time  `json:"startedOn"`
+	CompletedOn *time.Time `json:"completedOn"`
+	// RawDataCleared the number of events of which the raw JSON was removed
+	RawDataCleared int64 `json:"rawDataCleared"`
+	// AggregatedRows the number of hourly aggregate rows that were written
+	AggregatedRows int64      `json:"aggregatedRows"`
+	AggregatedTill *time.Time `json:"aggregatedTill"`
+	ArchivedFiles  []string   `json:"archivedFiles"`
+	RemovedChunks  int64      `json:"removedChunks"`
+	RemovedRows    int64      `json:"removedRows"`
+	Error          *string    `json:"error"`
+}
+
+var maintenanceReports = struct { //nolint:gochecknoglobals
+	sync.RWMutex
+	reports map[string]MaintenanceReport
+}{reports: make(map[string]MaintenanceReport)}
+
+// GetMaintenanceReports returns the report of the latest run of every table
+func GetMaintenanceReports() []MaintenanceReport {
+	maintenanceReports.RLock()
+	defer maintenanceReports.RUnlock()
+	reports := make([]MaintenanceReport, 0, len(maintenanceReports.reports))
+	for _, report := range maintenanceReports.reports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].TableName < reports[j].TableName })
+	return reports
+}
+
+func setMaintenanceReport(report MaintenanceReport) {
+	maintenanceReports.Lock()
+	defer maintenanceReports.Unlock()
+	maintenanceReports.reports[report.TableName] = report
+}
+
+// Start periodically applies the retention policies until the context is cancelled.
+// Archives are written to archivePath.
+func Start(ctx context.Context, db *sqlx.DB, archivePath string) error {
+	delay := time.NewTimer(commons.MAINTENANCE_BOOTSTRAP_DELAY_SECONDS * time.Second)
+	defer delay.Stop()
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-delay.C:
+	}
+
+	ticker := time.NewTicker(commons.MAINTENANCE_TICKER_SECONDS * time.Second)
+	defer ticker.Stop()
+	for {
+		policies, err := getRetentionPolicies(db)
+		if err != nil {
+			return errors.Wrap(err, "Obtaining retention policies")
+		}
+		for _, policy := range policies {
+			if ctx.Err() != nil {
+				return nil
+			}
+			report := applyRetentionPolicy(ctx, db, archivePath, policy, time.Now().UTC())
+			setMaintenanceReport(report)
+			if report.Error != nil {
+				log.Error().Msgf("Maintenance failed for table: %v (%v)", policy.TableName, *report.Error)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func applyRetentionPolicy(ctx context.Context, db *sqlx.DB, archivePath string, policy RetentionPolicy,
+	now time.Time) MaintenanceReport {
+
+	report := MaintenanceReport{
+		TableName:      policy.TableName,
+		StartedOn:      now,
+		AggregatedTill: policy.AggregatedTill,
+	}
+	err := applySteps(ctx, db, archivePath, policy, now, &report)
+	if err != nil {
+		errorMessage := err.Error()
+		report.Error = &errorMessage
+	} else {
+		completedOn := time.Now().UTC()
+		report.CompletedOn = &completedOn
+	}
+	return report
+}
+
+// applySteps the aggregation runs before the removal so removed events are part of the aggregates
+func applySteps(ctx context.Context, db *sqlx.DB, archivePath string, policy RetentionPolicy, now time.Time,
+	report *MaintenanceReport) error {
+
+	definition, exists := tableDefinitions[policy.TableName]
+	if !exists {
+		return errors.Newf("Unknown table: %v", policy.TableName)
+	}
+	if policy.RawDataDays != nil && definition.rawDataColumn != "" {
+		cleared, err := clearRawData(db, policy.TableName, definition, cutoff(now, *policy.RawDataDays))
+		if err != nil {
+			return errors.Wrap(err, "Clearing raw data")
+		}
+		report.RawDataCleared = cleared
+	}
+	if policy.AggregateAfterDays != nil {
+		till := cutoff(now, *policy.AggregateAfterDays).Truncate(time.Hour)
+		from, err := aggregationStart(db, policy.TableName, definition, policy.AggregatedTill)
+		if err != nil {
+			return errors.Wrap(err, "Obtaining aggregation start")
+		}
+		if from.IsZero() {
+			from = till
+		}
+		for _, window := range aggregationWindows(from, till) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			rows, err := aggregate(db, policy.TableName, definition, window[0], window[1])
+			if err != nil {
+				return errors.Wrapf(err, "Aggregating from %v till %v", window[0], window[1])
+			}
+			report.AggregatedRows += rows
+			aggregatedTill := window[1]
+			report.AggregatedTill = &aggregatedTill
+		}
+	}
+	if policy.RetentionDays != nil {
+		chunks, err := getChunksBefore(db, policy.TableName, cutoff(now, *policy.RetentionDays))
+		if err != nil {
+			return errors.Wrap(err, "Obtaining chunks")
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		if policy.Archive {
+			if archivePath == "" {
+				return errors.New("Archiving is enabled but the archive path is not configured")
+			}
+			for _, chunk := range chunks {
+				if ctx.Err() != nil {
+					return nil
+				}
+				file, err := archiveChunk(db, archivePath, policy.TableName, chunk)
+				if err != nil {
+					return errors.Wrapf(err, "Archiving chunk %v", chunk.Name)
+				}
+				if file != "" {
+					report.ArchivedFiles = append(report.ArchivedFiles, file)
+				}
+			}
+		}
+		removedChunks, removedRows, err := removeChunks(db, policy.TableName, definition, chunks[len(chunks)-1].RangeEnd)
+		if err != nil {
+			return errors.Wrap(err, "Removing chunks")
+		}
+		report.RemovedChunks = removedChunks
+		report.RemovedRows = removedRows
+	}
+	return nil
+}
+
+// cutoff the events before the cutoff are older than the given number of days
+func cutoff(now time.Time, days int) time.Time {
+	return now.AddDate(0, 0, -days)
+}
+
+// aggregationWindows splits the hours from (inclusive) till (exclusive) into windows of at most aggregationWindow
+func aggregationWindows(from time.Time, till time.Time) [][2]time.Time {
+	var windows [][2]time.Time
+	for windowStart := from; windowStart.Before(till); windowStart = windowStart.Add(aggregationWindow) {
+		windowEnd := windowStart.Add(aggregationWindow)
+		if windowEnd.After(till) {
+			windowEnd = till
+		}
+		windows = append(windows, [2]time.Time{windowStart, windowEnd})
+	}
+	return windows
+}
+
+func validateRetentionPolicy(policy RetentionPolicy) error {
+	definition, exists := tableDefinitions[policy.TableName]
+	if !exists {
+		return errors.Newf("Retention policies are only supported for %v, %v and %v.",
+			HtlcEvent, RoutingPolicy, NodeEvent)
+	}
+	if policy.RawDataDays != nil && definition.rawDataColumn == "" {
+		return errors.Newf("%v has no raw data.", policy.TableName)
+	}
+	for _, days := range []*int{policy.RawDataDays, policy.AggregateAfterDays, policy.RetentionDays} {
+		if days != nil && *days < 1 {
+			return errors.New("The number of days should be at least 1.")
+		}
+	}
+	if policy.AggregateAfterDays != nil && policy.RetentionDays != nil &&
+		*policy.RetentionDays < *policy.AggregateAfterDays {
+		return errors.New("Events can't be removed before they are aggregated.")
+	}
+	if policy.Archive && policy.RetentionDays == nil {
+		return errors.New("Archiving requires the retention days.")
+	}
+	return nil
+}
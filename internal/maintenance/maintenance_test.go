@@ -0,0 +1,117 @@
+package maintenance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestAggregationWindows(t *testing.T) {
+	from := time.Date(2023, 1, 1, 5, 0, 0, 0, time.UTC)
+	till := from.Add(50 * time.Hour)
+	windows := aggregationWindows(from, till)
+	if len(windows) != 3 {
+		t.Fatalf("expected 3 windows, got %v", windows)
+	}
+	if !windows[0][0].Equal(from) || !windows[0][1].Equal(from.Add(24*time.Hour)) {
+		t.Errorf("expected the first window to be a full day, got %v", windows[0])
+	}
+	if !windows[2][0].Equal(from.Add(48*time.Hour)) || !windows[2][1].Equal(till) {
+		t.Errorf("expected the last window to end at till, got %v", windows[2])
+	}
+	if windows := aggregationWindows(till, from); len(windows) != 0 {
+		t.Errorf("expected no windows when everything is aggregated, got %v", windows)
+	}
+}
+
+func TestValidateRetentionPolicy(t *testing.T) {
+	one := 1
+	seven := 7
+	thirty := 30
+	zero := 0
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		valid  bool
+	}{
+		{"disabled", RetentionPolicy{TableName: HtlcEvent}, true},
+		{"all steps", RetentionPolicy{TableName: HtlcEvent, RawDataDays: &one, AggregateAfterDays: &seven,
+			RetentionDays: &thirty, Archive: true}, true},
+		{"unknown table", RetentionPolicy{TableName: "forward"}, false},
+		{"raw data without raw data", RetentionPolicy{TableName: RoutingPolicy, RawDataDays: &one}, false},
+		{"zero days", RetentionPolicy{TableName: NodeEvent, RetentionDays: &zero}, false},
+		{"removed before aggregated", RetentionPolicy{TableName: NodeEvent, AggregateAfterDays: &thirty,
+			RetentionDays: &seven}, false},
+		{"archive without retention", RetentionPolicy{TableName: NodeEvent, Archive: true}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateRetentionPolicy(test.policy)
+			if test.valid && err != nil {
+				t.Errorf("expected a valid policy, got %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected an invalid policy")
+			}
+		})
+	}
+}
+
+func TestArchiveFileName(t *testing.T) {
+	c := chunk{
+		RangeStart: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		RangeEnd:   time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC),
+	}
+	expected := "htlc_event_20230101T000000Z_20230108T000000Z.csv.gz"
+	if name := archiveFileName(HtlcEvent, c); name != expected {
+		t.Errorf("expected %v, got %v", expected, name)
+	}
+}
+
+type fakeRows struct {
+	rows  [][]interface{}
+	index int
+}
+
+func (r *fakeRows) Next() bool {
+	r.index++
+	return r.index <= len(r.rows)
+}
+
+func (r *fakeRows) SliceScan() ([]interface{}, error) {
+	return r.rows[r.index-1], nil
+}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+func TestWriteArchive(t *testing.T) {
+	eventTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	rows := &fakeRows{rows: [][]interface{}{
+		{eventTime, int64(42), []byte(`{"a":1}`), nil},
+	}}
+	var buffer bytes.Buffer
+	if err := writeArchive(&buffer, []string{"time", "node_id", "data", "event_type"}, rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gzipReader, err := gzip.NewReader(&buffer)
+	if err != nil {
+		t.Fatalf("expected gzip compressed output: %v", err)
+	}
+	records, err := csv.NewReader(gzipReader).ReadAll()
+	if err != nil {
+		t.Fatalf("expected csv output: %v", err)
+	}
+	if len(records) != 2 || records[0][0] != "time" {
+		t.Fatalf("expected a header and one row, got %v", records)
+	}
+	expected := []string{"2023-01-01T11:00:00Z", "42", `{"a":1}`, ""}
+	for i, value := range expected {
+		if records[1][i] != value {
+			t.Errorf("expected %v for column %v, got %v", value, records[0][i], records[1][i])
+		}
+	}
+}
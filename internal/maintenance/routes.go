@@ -0,0 +1,50 @@
+package maintenance
+
+import (
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterMaintenanceRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("retention-policies", func(c *gin.Context) { getRetentionPoliciesHandler(c, db) })
+	r.PUT("retention-policies", auth.RoleRequired(auth.Admin), func(c *gin.Context) { setRetentionPolicyHandler(c, db) })
+	r.GET("reports", getMaintenanceReportsHandler)
+}
+
+// getMaintenanceReportsHandler the result of the latest run per table
+func getMaintenanceReportsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, GetMaintenanceReports())
+}
+
+func getRetentionPoliciesHandler(c *gin.Context, db *sqlx.DB) {
+	policies, err := getRetentionPolicies(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting retention policies.")
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+func setRetentionPolicyHandler(c *gin.Context, db *sqlx.DB) {
+	var policy RetentionPolicy
+	if err := c.BindJSON(&policy); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if err := validateRetentionPolicy(policy); err != nil {
+		server_errors.SendUnprocessableEntity(c, err.Error())
+		return
+	}
+	policy, err := setRetentionPolicy(db, policy)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Setting retention policy.")
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
@@ -8,26 +8,16 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func signMessage(db *sqlx.DB, req SignMessageRequest) (r SignMessageResponse, err error) {
 	if req.NodeId == 0 {
 		return SignMessageResponse{}, errors.New("Node Id missing")
 	}
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
-	if err != nil {
-		return SignMessageResponse{}, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	conn, err := settings.GetLndConnection(db, req.NodeId)
 	if err != nil {
 		return SignMessageResponse{}, errors.Wrap(err, "Connecting to LND")
 	}
-	defer conn.Close()
 
 	client := lnrpc.NewLightningClient(conn)
 
@@ -8,7 +8,6 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func verifyMessage(db *sqlx.DB, req VerifyMessageRequest) (r VerifyMessageResponse, err error) {
@@ -16,19 +15,10 @@ func verifyMessage(db *sqlx.DB, req VerifyMessageRequest) (r VerifyMessageRespon
 		return VerifyMessageResponse{}, errors.Wrap(err, "Node Id missing")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
-	if err != nil {
-		return VerifyMessageResponse{}, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	conn, err := settings.GetLndConnection(db, req.NodeId)
 	if err != nil {
 		return VerifyMessageResponse{}, errors.Wrap(err, "Connecting to LND")
 	}
-	defer conn.Close()
 
 	client := lnrpc.NewLightningClient(conn)
 
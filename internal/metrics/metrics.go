@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const namespace = "torq"
+
+var streamNames = map[commons.SubscriptionStream]string{ //nolint:gochecknoglobals
+	commons.TransactionStream:         "transaction",
+	commons.HtlcEventStream:           "htlc_event",
+	commons.ChannelEventStream:        "channel_event",
+	commons.GraphEventStream:          "graph_event",
+	commons.ForwardStream:             "forward",
+	commons.InvoiceStream:             "invoice",
+	commons.PaymentStream:             "payment",
+	commons.InFlightPaymentStream:     "in_flight_payment",
+	commons.PeerEventStream:           "peer_event",
+	commons.ChannelBalanceCacheStream: "channel_balance_cache",
+}
+
+var channelLabels = []string{"node_id", "channel_id", "short_channel_id", "tags"} //nolint:gochecknoglobals
+
+var (
+	registry = prometheus.NewRegistry()           //nolint:gochecknoglobals
+	counters = newEventCounters(getChannelLabels) //nolint:gochecknoglobals
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newStateCollector(),
+	)
+	counters.register(registry)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// EventCounter counts the broadcasted forwards, failed HTLCs and payments.
+func EventCounter(ctx context.Context, broadcaster broadcast.BroadcastServer) {
+	listener := broadcaster.Subscribe()
+	defer broadcaster.CancelSubscription(listener)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-listener:
+			if !ok {
+				return
+			}
+			counters.process(event)
+		}
+	}
+}
+
+type eventCounters struct {
+	channelLabels func(nodeId int, channelId *int) prometheus.Labels
+	forwards      *prometheus.CounterVec
+	feesEarned    *prometheus.CounterVec
+	failedHtlcs   *prometheus.CounterVec
+	payments      *prometheus.CounterVec
+}
+
+// newEventCounters channelLabels returns the node, channel and tag labels of a channel
+func newEventCounters(channelLabels func(nodeId int, channelId *int) prometheus.Labels) *eventCounters {
+	return &eventCounters{
+		channelLabels: channelLabels,
+		forwards: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "forwards_total",
+			Help:      "Number of forwards by outgoing channel.",
+		}, channelLabelNames()),
+		feesEarned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "forward_fees_earned_msat_total",
+			Help:      "Fees earned by forwarding in msat by outgoing channel.",
+		}, channelLabelNames()),
+		failedHtlcs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_htlcs_total",
+			Help:      "Number of failed HTLCs by outgoing (or incoming when unknown) channel and BOLT failure code.",
+		}, channelLabelNames("bolt_failure_code")),
+		payments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_total",
+			Help:      "Number of completed payments by outgoing channel and status.",
+		}, channelLabelNames("status")),
+	}
+}
+
+func (ec *eventCounters) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(ec.forwards, ec.feesEarned, ec.failedHtlcs, ec.payments)
+}
+
+func (ec *eventCounters) process(event interface{}) {
+	switch e := event.(type) {
+	case commons.ForwardEvent:
+		labels := ec.channelLabels(e.NodeId, e.OutgoingChannelId)
+		ec.forwards.With(labels).Inc()
+		ec.feesEarned.With(labels).Add(float64(e.FeeMsat))
+	case commons.HtlcEvent:
+		if e.BoltFailureCode == nil || *e.BoltFailureCode == "" {
+			return
+		}
+		channelId := e.OutgoingChannelId
+		if channelId == nil || *channelId == 0 {
+			channelId = e.IncomingChannelId
+		}
+		labels := ec.channelLabels(e.NodeId, channelId)
+		labels["bolt_failure_code"] = *e.BoltFailureCode
+		ec.failedHtlcs.With(labels).Inc()
+	case commons.PaymentEvent:
+		if e.PaymentStatus != lnrpc.Payment_SUCCEEDED && e.PaymentStatus != lnrpc.Payment_FAILED {
+			return
+		}
+		labels := ec.channelLabels(e.NodeId, e.OutgoingChannelId)
+		labels["status"] = e.PaymentStatus.String()
+		ec.payments.With(labels).Inc()
+	}
+}
+
+// stateCollector reads the stream statuses and the channel states from the caches when Prometheus scrapes.
+type stateCollector struct {
+	streamStatus        *prometheus.Desc
+	localBalance        *prometheus.Desc
+	remoteBalance       *prometheus.Desc
+	pendingHtlcs        *prometheus.Desc
+	pendingHtlcsBalance *prometheus.Desc
+}
+
+func newStateCollector() *stateCollector {
+	return &stateCollector{
+		streamStatus: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "stream_status"),
+			"Status of the LND subscription stream (0 inactive, 1 active, 2 pending, 4 initializing).",
+			[]string{"node_id", "stream"}, nil),
+		localBalance: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "channel_local_balance_sat"),
+			"Local balance of the channel in sat.", channelLabelNames(), nil),
+		remoteBalance: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "channel_remote_balance_sat"),
+			"Remote balance of the channel in sat.", channelLabelNames(), nil),
+		pendingHtlcs: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "channel_pending_htlcs"),
+			"Number of pending HTLCs of the channel by direction.", channelLabelNames("direction"), nil),
+		pendingHtlcsBalance: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "channel_pending_htlcs_balance_sat"),
+			"Amount of the pending HTLCs of the channel in sat by direction.", channelLabelNames("direction"), nil),
+	}
+}
+
+func (sc *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.streamStatus
+	ch <- sc.localBalance
+	ch <- sc.remoteBalance
+	ch <- sc.pendingHtlcs
+	ch <- sc.pendingHtlcsBalance
+}
+
+func (sc *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	lndService, exists := commons.RunningServices[commons.LndService]
+	if !exists {
+		return
+	}
+	for _, nodeId := range lndService.GetNodeIds() {
+		for _, stream := range commons.SubscriptionStreams {
+			ch <- prometheus.MustNewConstMetric(sc.streamStatus, prometheus.GaugeValue,
+				float64(lndService.GetStreamStatus(nodeId, stream)), strconv.Itoa(nodeId), streamNames[stream])
+		}
+	}
+	for _, nodeId := range lndService.GetActiveNodeIds() {
+		for _, channelState := range commons.GetChannelStates(nodeId, true) {
+			channelId := channelState.ChannelId
+			values := labelValues(getChannelLabels(nodeId, &channelId))
+			ch <- prometheus.MustNewConstMetric(sc.localBalance, prometheus.GaugeValue,
+				float64(channelState.LocalBalance), values...)
+			ch <- prometheus.MustNewConstMetric(sc.remoteBalance, prometheus.GaugeValue,
+				float64(channelState.RemoteBalance), values...)
+			ch <- prometheus.MustNewConstMetric(sc.pendingHtlcs, prometheus.GaugeValue,
+				float64(channelState.PendingIncomingHtlcCount), append(values, "incoming")...)
+			ch <- prometheus.MustNewConstMetric(sc.pendingHtlcs, prometheus.GaugeValue,
+				float64(channelState.PendingOutgoingHtlcCount), append(values, "outgoing")...)
+			ch <- prometheus.MustNewConstMetric(sc.pendingHtlcsBalance, prometheus.GaugeValue,
+				float64(channelState.PendingIncomingHtlcAmount), append(values, "incoming")...)
+			ch <- prometheus.MustNewConstMetric(sc.pendingHtlcsBalance, prometheus.GaugeValue,
+				float64(channelState.PendingOutgoingHtlcAmount), append(values, "outgoing")...)
+		}
+	}
+}
+
+func channelLabelNames(extraLabels ...string) []string {
+	return append(append([]string{}, channelLabels...), extraLabels...)
+}
+
+// labelValues returns the values of the channel labels in the order of the label names
+func labelValues(labels prometheus.Labels) []string {
+	values := make([]string, 0, len(channelLabels)+1)
+	for _, label := range channelLabels {
+		values = append(values, labels[label])
+	}
+	return values
+}
+
+// getChannelLabels the tags label contains the sorted tag names of the channel separated by a comma
+func getChannelLabels(nodeId int, channelId *int) prometheus.Labels {
+	labels := prometheus.Labels{"node_id": strconv.Itoa(nodeId), "channel_id": "", "short_channel_id": "", "tags": ""}
+	if channelId == nil || *channelId == 0 {
+		return labels
+	}
+	labels["channel_id"] = strconv.Itoa(*channelId)
+	labels["short_channel_id"] = commons.GetChannelSettingByChannelId(*channelId).ShortChannelId
+	channelGroups := commons.GetChannelGroupsByChannelId(*channelId, commons.TAGS_ONLY)
+	if channelGroups == nil {
+		return labels
+	}
+	var tagNames []string
+	for _, channelGroup := range channelGroups.ChannelGroups {
+		if channelGroup.TagName != nil {
+			tagNames = append(tagNames, *channelGroup.TagName)
+		}
+	}
+	sort.Strings(tagNames)
+	labels["tags"] = strings.Join(tagNames, ",")
+	return labels
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestEventCounters(t *testing.T) {
+	stubLabels := func(nodeId int, channelId *int) prometheus.Labels {
+		labels := prometheus.Labels{"node_id": strconv.Itoa(nodeId), "channel_id": "", "short_channel_id": "", "tags": ""}
+		if channelId != nil {
+			labels["channel_id"] = strconv.Itoa(*channelId)
+			labels["tags"] = "routing,sink"
+		}
+		return labels
+	}
+	ec := newEventCounters(stubLabels)
+	ec.register(prometheus.NewRegistry())
+
+	incomingChannelId := 10
+	outgoingChannelId := 11
+	ec.process(commons.ForwardEvent{EventData: commons.EventData{NodeId: 1}, FeeMsat: 1500,
+		IncomingChannelId: &incomingChannelId, OutgoingChannelId: &outgoingChannelId})
+	ec.process(commons.ForwardEvent{EventData: commons.EventData{NodeId: 1}, FeeMsat: 500,
+		IncomingChannelId: &incomingChannelId, OutgoingChannelId: &outgoingChannelId})
+
+	forwardLabels := []string{"1", "11", "", "routing,sink"}
+	if count := testutil.ToFloat64(ec.forwards.WithLabelValues(forwardLabels...)); count != 2 {
+		t.Errorf("expected 2 forwards, got %v", count)
+	}
+	if fees := testutil.ToFloat64(ec.feesEarned.WithLabelValues(forwardLabels...)); fees != 2000 {
+		t.Errorf("expected 2000 msat fees, got %v", fees)
+	}
+
+	failureCode := "TEMPORARY_CHANNEL_FAILURE"
+	ec.process(commons.HtlcEvent{EventData: commons.EventData{NodeId: 1}, BoltFailureCode: &failureCode,
+		IncomingChannelId: &incomingChannelId})
+	ec.process(commons.HtlcEvent{EventData: commons.EventData{NodeId: 1}, IncomingChannelId: &incomingChannelId})
+	if count := testutil.ToFloat64(ec.failedHtlcs.WithLabelValues("1", "10", "", "routing,sink", failureCode)); count != 1 {
+		t.Errorf("expected 1 failed HTLC on the incoming channel, got %v", count)
+	}
+	if count := testutil.CollectAndCount(ec.failedHtlcs); count != 1 {
+		t.Errorf("expected the HTLC without failure code to be ignored, got %v series", count)
+	}
+
+	ec.process(commons.PaymentEvent{EventData: commons.EventData{NodeId: 2}, PaymentStatus: lnrpc.Payment_SUCCEEDED})
+	ec.process(commons.PaymentEvent{EventData: commons.EventData{NodeId: 2}, PaymentStatus: lnrpc.Payment_IN_FLIGHT})
+	if count := testutil.ToFloat64(ec.payments.WithLabelValues("2", "", "", "", "SUCCEEDED")); count != 1 {
+		t.Errorf("expected 1 succeeded payment, got %v", count)
+	}
+	if count := testutil.CollectAndCount(ec.payments); count != 1 {
+		t.Errorf("expected the in flight payment to be ignored, got %v series", count)
+	}
+}
+
+func TestLabelValues(t *testing.T) {
+	values := labelValues(prometheus.Labels{"tags": "a,b", "node_id": "1", "channel_id": "2", "short_channel_id": "1x2x3"})
+	expected := []string{"1", "2", "1x2x3", "a,b"}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, values)
+		}
+	}
+	if len(channelLabelNames("direction")) != len(channelLabels)+1 || len(channelLabels) != 4 {
+		t.Errorf("channelLabelNames should not modify the channel labels")
+	}
+}
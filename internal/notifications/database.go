@@ -0,0 +1,175 @@
+package notifications
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func getNotificationSink(db *sqlx.DB, notificationSinkId int) (NotificationSink, error) {
+	var ns NotificationSink
+	err := db.Get(&ns, `SELECT * FROM notification_sink WHERE notification_sink_id=$1;`, notificationSinkId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return NotificationSink{}, nil
+		}
+		return NotificationSink{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return ns, nil
+}
+
+func getNotificationSinks(db *sqlx.DB) ([]NotificationSink, error) {
+	var nss []NotificationSink
+	err := db.Select(&nss, `SELECT * FROM notification_sink ORDER BY notification_sink_id;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []NotificationSink{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nss, nil
+}
+
+func getActiveNotificationSinks(db *sqlx.DB) ([]NotificationSink, error) {
+	var nss []NotificationSink
+	err := db.Select(&nss, `SELECT * FROM notification_sink WHERE status=$1 ORDER BY notification_sink_id;`,
+		commons.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []NotificationSink{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nss, nil
+}
+
+func addNotificationSink(db *sqlx.DB, ns NotificationSink) (NotificationSink, error) {
+	ns.CreatedOn = time.Now().UTC()
+	ns.UpdateOn = ns.CreatedOn
+	if ns.EventTypes == nil {
+		ns.EventTypes = pq.StringArray{}
+	}
+	err := db.QueryRowx(`
+		INSERT INTO notification_sink (name, sink_type, url, secret, telegram_chat_id, event_types, max_retries, status,
+			created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING notification_sink_id;`,
+		ns.Name, ns.SinkType, ns.Url, ns.Secret, ns.TelegramChatId, ns.EventTypes, ns.MaxRetries, ns.Status,
+		ns.CreatedOn, ns.UpdateOn).Scan(&ns.NotificationSinkId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return NotificationSink{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return NotificationSink{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return ns, nil
+}
+
+func setNotificationSink(db *sqlx.DB, ns NotificationSink) (NotificationSink, error) {
+	ns.UpdateOn = time.Now().UTC()
+	if ns.EventTypes == nil {
+		ns.EventTypes = pq.StringArray{}
+	}
+	_, err := db.Exec(`
+		UPDATE notification_sink
+		SET name=$1, sink_type=$2, url=$3, secret=$4, telegram_chat_id=$5, event_types=$6, max_retries=$7, status=$8,
+			updated_on=$9
+		WHERE notification_sink_id=$10;`,
+		ns.Name, ns.SinkType, ns.Url, ns.Secret, ns.TelegramChatId, ns.EventTypes, ns.MaxRetries, ns.Status,
+		ns.UpdateOn, ns.NotificationSinkId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return NotificationSink{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return NotificationSink{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return ns, nil
+}
+
+func removeNotificationSink(db *sqlx.DB, notificationSinkId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM notification_sink WHERE notification_sink_id=$1;`, notificationSinkId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+func getNotificationDeliveries(db *sqlx.DB, notificationSinkId int) ([]NotificationDelivery, error) {
+	var nds []NotificationDelivery
+	err := db.Select(&nds, `
+		SELECT * FROM notification_delivery
+		WHERE notification_sink_id=$1
+		ORDER BY created_on DESC, notification_delivery_id DESC;`, notificationSinkId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []NotificationDelivery{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nds, nil
+}
+
+// addNotificationDelivery stores the delivery and only keeps the last NOTIFICATION_DELIVERY_COUNT deliveries of the sink.
+func addNotificationDelivery(db *sqlx.DB, nd NotificationDelivery) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO notification_delivery (notification_sink_id, event_type, payload, attempts, status_code, status,
+			error, created_on, delivered_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`,
+		nd.NotificationSinkId, nd.EventType, nd.Payload, nd.Attempts, nd.StatusCode, nd.Status,
+		nd.Error, nd.CreatedOn, nd.DeliveredOn)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = tx.Exec(`
+		DELETE FROM notification_delivery
+		WHERE notification_sink_id=$1 AND notification_delivery_id NOT IN (
+			SELECT notification_delivery_id FROM notification_delivery WHERE notification_sink_id=$1
+			ORDER BY created_on DESC, notification_delivery_id DESC LIMIT $2);`,
+		nd.NotificationSinkId, commons.NOTIFICATION_DELIVERY_COUNT)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return nil
+}
+
+// removeNotificationDeliveries removes the deliveries created before the time
+func removeNotificationDeliveries(db *sqlx.DB, before time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM notification_delivery WHERE created_on < $1;`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
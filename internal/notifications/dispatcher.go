@@ -0,0 +1,312 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const initialBackoff = 2 * time.Second
+const maxBackoff = 5 * time.Minute
+
+// NotificationDispatcher sends the broadcasted events to the active notification sinks.
+// Every sink has its own worker with a bounded queue so a slow or failing sink can't hold up the others.
+func NotificationDispatcher(ctx context.Context, db *sqlx.DB, broadcaster broadcast.BroadcastServer) {
+	ticker := clock.New().Tick(commons.NOTIFICATION_SINK_REFRESH_SECONDS * time.Second)
+	pruneTicker := clock.New().Tick(commons.NOTIFICATION_DELIVERY_PRUNE_SECONDS * time.Second)
+	client := &http.Client{Timeout: 30 * time.Second}
+	store := func(delivery NotificationDelivery) {
+		err := addNotificationDelivery(db, delivery)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to store notification delivery for notificationSinkId: %v",
+				delivery.NotificationSinkId)
+		}
+	}
+
+	workers := make(map[int]*sinkWorker)
+	defer func() {
+		for _, worker := range workers {
+			worker.cancel()
+		}
+	}()
+	sinks, err := getActiveNotificationSinks(db)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to obtain notification sinks")
+	}
+	refreshSinkWorkers(ctx, client, store, workers, sinks)
+
+	listener := broadcaster.Subscribe()
+	defer broadcaster.CancelSubscription(listener)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+			activeSinks, err := getActiveNotificationSinks(db)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to obtain notification sinks")
+				continue
+			}
+			refreshSinkWorkers(ctx, client, store, workers, activeSinks)
+		case <-pruneTicker:
+			retention := commons.NOTIFICATION_DELIVERY_RETENTION_DAYS * 24 * time.Hour
+			_, err := removeNotificationDeliveries(db, time.Now().UTC().Add(-retention))
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to remove old notification deliveries")
+			}
+		case event, ok := <-listener:
+			if !ok {
+				return
+			}
+			eventType := getEventType(event)
+			if eventType == "" {
+				continue
+			}
+			for _, worker := range workers {
+				if !sinkAcceptsEventType(worker.sink, eventType) {
+					continue
+				}
+				if !worker.enqueue(notification{eventType: eventType, event: event}) {
+					log.Warn().Msgf("Dropped %v notification, the queue of notificationSinkId: %v is full",
+						eventType, worker.sink.NotificationSinkId)
+				}
+			}
+		}
+	}
+}
+
+type notification struct {
+	eventType string
+	event     interface{}
+}
+
+type sinkWorker struct {
+	sink   NotificationSink
+	queue  chan notification
+	cancel context.CancelFunc
+}
+
+// startSinkWorker delivers the queued notifications of the sink one at a time
+func startSinkWorker(ctx context.Context, client *http.Client, store func(delivery NotificationDelivery),
+	sink NotificationSink) *sinkWorker {
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	worker := &sinkWorker{
+		sink:   sink,
+		queue:  make(chan notification, commons.NOTIFICATION_QUEUE_SIZE),
+		cancel: cancel,
+	}
+	go func() {
+		for {
+			select {
+			case <-workerCtx.Done():
+				return
+			case n := <-worker.queue:
+				store(deliver(workerCtx, client, sink, n.eventType, n.event, initialBackoff))
+			}
+		}
+	}()
+	return worker
+}
+
+// enqueue returns false when the queue of the sink is full
+func (worker *sinkWorker) enqueue(n notification) bool {
+	select {
+	case worker.queue <- n:
+		return true
+	default:
+		return false
+	}
+}
+
+// refreshSinkWorkers starts a worker for new sinks, restarts the worker of updated sinks and stops the worker of
+// sinks that are no longer active
+func refreshSinkWorkers(ctx context.Context, client *http.Client, store func(delivery NotificationDelivery),
+	workers map[int]*sinkWorker, sinks []NotificationSink) {
+
+	activeSinkIds := make(map[int]bool)
+	for _, sink := range sinks {
+		activeSinkIds[sink.NotificationSinkId] = true
+		worker, exists := workers[sink.NotificationSinkId]
+		if exists && worker.sink.UpdateOn.Equal(sink.UpdateOn) {
+			continue
+		}
+		if exists {
+			worker.cancel()
+		}
+		workers[sink.NotificationSinkId] = startSinkWorker(ctx, client, store, sink)
+	}
+	for notificationSinkId, worker := range workers {
+		if !activeSinkIds[notificationSinkId] {
+			worker.cancel()
+			delete(workers, notificationSinkId)
+		}
+	}
+}
+
+func getEventType(event interface{}) string {
+	switch event.(type) {
+	case commons.ChannelEvent:
+		return channelEventType
+	case commons.PeerEvent:
+		return peerEventType
+	case commons.InvoiceEvent:
+		return invoiceEventType
+	case commons.PaymentEvent:
+		return paymentEventType
+	case commons.ServiceEvent:
+		return serviceEventType
+	case commons.ChannelGraphEvent:
+		return channelGraphEventType
+	case commons.StuckTransactionEvent:
+		return stuckTransactionEventType
+	case commons.ChannelRiskEvent:
+		return channelRiskEventType
+	}
+	return ""
+}
+
+// sinkAcceptsEventType when the sink has no event types configured all events are accepted
+func sinkAcceptsEventType(sink NotificationSink, eventType string) bool {
+	if len(sink.EventTypes) == 0 {
+		return true
+	}
+	for _, sinkEventType := range sink.EventTypes {
+		if sinkEventType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver posts the event to the sink and retries with exponential backoff on network errors,
+// rate limiting (429) and server errors (5xx).
+func deliver(ctx context.Context, client *http.Client, sink NotificationSink, eventType string, event interface{},
+	backoff time.Duration) NotificationDelivery {
+
+	delivery := NotificationDelivery{
+		NotificationSinkId: sink.NotificationSinkId,
+		EventType:          eventType,
+		Payload:            []byte("{}"),
+		Status:             commons.Inactive,
+		CreatedOn:          time.Now().UTC(),
+	}
+	body, err := buildPayload(sink, eventType, event)
+	if err != nil {
+		errorMessage := err.Error()
+		delivery.Error = &errorMessage
+		return delivery
+	}
+	delivery.Payload = body
+
+	for {
+		delivery.Attempts++
+		statusCode, err := post(ctx, client, sink, eventType, body)
+		if statusCode != 0 {
+			delivery.StatusCode = &statusCode
+		}
+		if err == nil {
+			deliveredOn := time.Now().UTC()
+			delivery.DeliveredOn = &deliveredOn
+			delivery.Status = commons.Active
+			delivery.Error = nil
+			return delivery
+		}
+		errorMessage := err.Error()
+		delivery.Error = &errorMessage
+		if !isRetryable(statusCode) || delivery.Attempts > sink.MaxRetries {
+			return delivery
+		}
+		select {
+		case <-ctx.Done():
+			return delivery
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func buildPayload(sink NotificationSink, eventType string, event interface{}) ([]byte, error) {
+	var payload interface{}
+	switch sink.SinkType {
+	case webhookSink:
+		payload = webhookPayload{Type: eventType, Event: event}
+	case telegramSink:
+		chatId := ""
+		if sink.TelegramChatId != nil {
+			chatId = *sink.TelegramChatId
+		}
+		payload = telegramPayload{ChatId: chatId, Text: formatMessage(eventType, event)}
+	case slackSink:
+		payload = slackPayload{Text: formatMessage(eventType, event)}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown sink type: %v", sink.SinkType))
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "JSON Marshal notification payload")
+	}
+	return body, nil
+}
+
+func post(ctx context.Context, client *http.Client, sink NotificationSink, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "Creating notification request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sink.SinkType == webhookSink {
+		req.Header.Set(eventTypeHeader, eventType)
+		if sink.Secret != nil && *sink.Secret != "" {
+			req.Header.Set(signatureHeader, sign(*sink.Secret, body))
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error contains the url and the delivery errors are returned by the API
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactUrl(sink.SinkType, urlErr.URL)
+		}
+		return 0, errors.Wrap(err, "Posting notification")
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.New(fmt.Sprintf("Notification sink responded with status code: %v", resp.StatusCode))
+	}
+	return resp.StatusCode, nil
+}
+
+// sign returns the HMAC-SHA256 signature of the body as: sha256=<hex>
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// isRetryable statusCode 0 means the request did not get a response
+func isRetryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
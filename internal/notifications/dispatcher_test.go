@@ -0,0 +1,229 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestDeliverWebhookSigned(t *testing.T) {
+	secret := "super-secret"
+	var receivedBody []byte
+	var receivedSignature string
+	var receivedEventType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		receivedSignature = r.Header.Get(signatureHeader)
+		receivedEventType = r.Header.Get(eventTypeHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := NotificationSink{NotificationSinkId: 1, SinkType: webhookSink, Url: server.URL, Secret: &secret}
+	event := commons.ChannelEvent{
+		EventData: commons.EventData{NodeId: 1},
+		Type:      lnrpc.ChannelEventUpdate_CLOSED_CHANNEL,
+		ChannelId: 42,
+	}
+	delivery := deliver(context.Background(), server.Client(), sink, channelEventType, event, time.Millisecond)
+
+	if delivery.Status != commons.Active || delivery.Attempts != 1 {
+		t.Fatalf("expected a delivery in one attempt, got status %v in %v attempts", delivery.Status, delivery.Attempts)
+	}
+	if receivedEventType != channelEventType {
+		t.Errorf("expected event type header %v, got %v", channelEventType, receivedEventType)
+	}
+	if receivedSignature != sign(secret, receivedBody) {
+		t.Errorf("signature %v does not match the body", receivedSignature)
+	}
+	var payload struct {
+		Type  string               `json:"type"`
+		Event commons.ChannelEvent `json:"event"`
+	}
+	if err := json.Unmarshal(receivedBody, &payload); err != nil {
+		t.Fatalf("failed to parse the webhook payload: %v", err)
+	}
+	if payload.Type != channelEventType || payload.Event.ChannelId != 42 {
+		t.Errorf("unexpected webhook payload: %s", receivedBody)
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NotificationSink{NotificationSinkId: 1, SinkType: slackSink, Url: server.URL, MaxRetries: 3}
+	delivery := deliver(context.Background(), server.Client(), sink, peerEventType, commons.PeerEvent{}, time.Millisecond)
+	if delivery.Status != commons.Active || delivery.Attempts != 3 {
+		t.Fatalf("expected a delivery in 3 attempts, got status %v in %v attempts", delivery.Status, delivery.Attempts)
+	}
+	if delivery.StatusCode == nil || *delivery.StatusCode != http.StatusNoContent {
+		t.Errorf("expected the last status code to be stored")
+	}
+	if delivery.Error != nil {
+		t.Errorf("expected the error of the failed attempts to be cleared, got %v", *delivery.Error)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink := NotificationSink{NotificationSinkId: 1, SinkType: webhookSink, Url: server.URL, MaxRetries: 2}
+	delivery := deliver(context.Background(), server.Client(), sink, peerEventType, commons.PeerEvent{}, time.Millisecond)
+	if delivery.Status != commons.Inactive || delivery.Attempts != 3 || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected a failed delivery after 3 attempts, got status %v in %v attempts",
+			delivery.Status, delivery.Attempts)
+	}
+	if delivery.Error == nil {
+		t.Errorf("expected the error to be stored")
+	}
+
+	// Client errors are not retried
+	calls = 0
+	badRequestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer badRequestServer.Close()
+	sink.Url = badRequestServer.URL
+	delivery = deliver(context.Background(), badRequestServer.Client(), sink, peerEventType, commons.PeerEvent{},
+		time.Millisecond)
+	if delivery.Status != commons.Inactive || delivery.Attempts != 1 {
+		t.Fatalf("expected a failed delivery after 1 attempt, got status %v in %v attempts",
+			delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestDeliverTelegram(t *testing.T) {
+	var payload telegramPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if r.Header.Get(signatureHeader) != "" {
+			t.Errorf("chat sinks should not be signed")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	chatId := "-1001234"
+	sink := NotificationSink{NotificationSinkId: 1, SinkType: telegramSink, Url: server.URL, TelegramChatId: &chatId}
+	event := commons.PaymentEvent{
+		EventData:            commons.EventData{NodeId: 1},
+		AmountPaid:           1000,
+		PaymentStatus:        lnrpc.Payment_FAILED,
+		PaymentFailureReason: lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE,
+	}
+	delivery := deliver(context.Background(), server.Client(), sink, paymentEventType, event, time.Millisecond)
+	if delivery.Status != commons.Active {
+		t.Fatalf("expected a delivery, got status %v", delivery.Status)
+	}
+	if payload.ChatId != chatId {
+		t.Errorf("expected chat id %v, got %v", chatId, payload.ChatId)
+	}
+	if !strings.Contains(payload.Text, "FAILED") || !strings.Contains(payload.Text, "FAILURE_REASON_NO_ROUTE") {
+		t.Errorf("unexpected message: %v", payload.Text)
+	}
+}
+
+func TestSinkAcceptsEventType(t *testing.T) {
+	if !sinkAcceptsEventType(NotificationSink{}, peerEventType) {
+		t.Errorf("a sink without event types should accept all events")
+	}
+	sink := NotificationSink{EventTypes: []string{channelEventType}}
+	if !sinkAcceptsEventType(sink, channelEventType) {
+		t.Errorf("expected %v to be accepted", channelEventType)
+	}
+	if sinkAcceptsEventType(sink, peerEventType) {
+		t.Errorf("expected %v to be filtered", peerEventType)
+	}
+	if getEventType(commons.ChannelGraphEvent{}) != channelGraphEventType || getEventType(commons.BlockEvent{}) != "" ||
+		getEventType(commons.StuckTransactionEvent{}) != stuckTransactionEventType ||
+		getEventType(commons.ChannelRiskEvent{}) != channelRiskEventType {
+		t.Errorf("unexpected event type mapping")
+	}
+}
+
+func TestSinkWorkerQueueIsBounded(t *testing.T) {
+	// The worker isn't started so the queue isn't drained
+	worker := &sinkWorker{queue: make(chan notification, commons.NOTIFICATION_QUEUE_SIZE)}
+	for i := 0; i < commons.NOTIFICATION_QUEUE_SIZE; i++ {
+		if !worker.enqueue(notification{eventType: channelEventType}) {
+			t.Fatalf("expected notification %v to be queued", i)
+		}
+	}
+	if worker.enqueue(notification{eventType: channelEventType}) {
+		t.Errorf("expected the notification to be dropped when the queue is full")
+	}
+}
+
+func TestRefreshSinkWorkers(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	deliveries := make(chan NotificationDelivery, 10)
+	store := func(delivery NotificationDelivery) { deliveries <- delivery }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updatedOn := time.Now()
+	workers := make(map[int]*sinkWorker)
+	sinks := []NotificationSink{
+		{NotificationSinkId: 1, SinkType: webhookSink, Url: server.URL, UpdateOn: updatedOn},
+		{NotificationSinkId: 2, SinkType: webhookSink, Url: server.URL, UpdateOn: updatedOn},
+	}
+	refreshSinkWorkers(ctx, server.Client(), store, workers, sinks)
+	if len(workers) != 2 {
+		t.Fatalf("expected a worker per sink, got %v", len(workers))
+	}
+	first := workers[1]
+	if !first.enqueue(notification{eventType: peerEventType, event: commons.PeerEvent{}}) {
+		t.Fatalf("expected the notification to be queued")
+	}
+	select {
+	case delivery := <-deliveries:
+		if delivery.NotificationSinkId != 1 || delivery.Status != commons.Active {
+			t.Errorf("unexpected delivery %+v", delivery)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the worker to deliver the notification")
+	}
+
+	// Sink 1 is unchanged, sink 2 is no longer active
+	refreshSinkWorkers(ctx, server.Client(), store, workers, sinks[:1])
+	if len(workers) != 1 || workers[1] != first {
+		t.Errorf("expected the worker of the unchanged sink to be kept and the other one to be stopped")
+	}
+	sinks[0].UpdateOn = updatedOn.Add(time.Second)
+	refreshSinkWorkers(ctx, server.Client(), store, workers, sinks[:1])
+	if workers[1] == first || workers[1].sink.UpdateOn != sinks[0].UpdateOn {
+		t.Errorf("expected the worker of the updated sink to be restarted")
+	}
+	if atomic.LoadInt32(&received) != 1 {
+		t.Errorf("expected one notification, got %v", received)
+	}
+}
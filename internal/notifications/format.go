@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// formatMessage returns a short human readable description of the event for chat sinks (Telegram/Slack).
+func formatMessage(eventType string, event interface{}) string {
+	switch e := event.(type) {
+	case commons.ChannelEvent:
+		return fmt.Sprintf("Channel %v on node %v: %v", e.ChannelId, e.NodeId, e.Type.String())
+	case commons.PeerEvent:
+		return fmt.Sprintf("Peer %v of node %v: %v", e.EventNodeId, e.NodeId, e.Type.String())
+	case commons.InvoiceEvent:
+		return fmt.Sprintf("Invoice %v on node %v: %v (paid %v of %v msat)",
+			e.AddIndex, e.NodeId, e.State.String(), e.AmountPaidMsat, e.ValueMSat)
+	case commons.PaymentEvent:
+		message := fmt.Sprintf("Payment on node %v: %v (amount %v sat, fee %v msat)",
+			e.NodeId, e.PaymentStatus.String(), e.AmountPaid, e.FeeMsat)
+		if e.PaymentStatus == lnrpc.Payment_FAILED {
+			message += fmt.Sprintf(" reason: %v", e.PaymentFailureReason.String())
+		}
+		return message
+	case commons.ServiceEvent:
+		return fmt.Sprintf("Service %v on node %v changed status from %v to %v",
+			e.Type, e.NodeId, e.PreviousStatus, e.Status)
+	case commons.ChannelGraphEvent:
+		if e.ChannelId == nil {
+			return fmt.Sprintf("Channel graph update on node %v", e.NodeId)
+		}
+		return fmt.Sprintf("Channel graph update for channel %v on node %v: fee rate %v milli msat, base fee %v msat, disabled %v",
+			*e.ChannelId, e.NodeId, e.FeeRateMilliMsat, e.FeeBaseMsat, e.Disabled)
+	case commons.StuckTransactionEvent:
+		message := fmt.Sprintf("The %v transaction of channel %v on node %v has no confirmations after %v",
+			strings.ToLower(e.LifecycleType), e.ChannelPoint, e.NodeId,
+			time.Duration(e.WaitingSeconds)*time.Second)
+		if e.FeeRateSatPerVbyte != nil {
+			message += fmt.Sprintf(" (fee rate %.1f sat/vbyte)", *e.FeeRateSatPerVbyte)
+		}
+		return message
+	case commons.ChannelRiskEvent:
+		message := fmt.Sprintf("Risk of channel %v on node %v changed from %v to %v",
+			e.ChannelId, e.NodeId, e.PreviousLevel, e.Level)
+		if len(e.Reasons) != 0 {
+			message += ": " + strings.Join(e.Reasons, " ")
+		}
+		return message
+	}
+	return fmt.Sprintf("%v received", eventType)
+}
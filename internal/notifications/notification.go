@@ -0,0 +1,135 @@
+package notifications
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type sinkType int
+
+const (
+	// webhookSink posts the event as JSON, signed with HMAC-SHA256 when a secret is configured
+	webhookSink = sinkType(iota)
+	// telegramSink posts a formatted message to the Telegram Bot API sendMessage url
+	telegramSink
+	// slackSink posts a formatted message to a Slack incoming webhook url
+	slackSink
+)
+
+const (
+	channelEventType          = "ChannelEvent"
+	peerEventType             = "PeerEvent"
+	invoiceEventType          = "InvoiceEvent"
+	paymentEventType          = "PaymentEvent"
+	serviceEventType          = "ServiceEvent"
+	channelGraphEventType     = "ChannelGraphEvent"
+	stuckTransactionEventType = "StuckTransactionEvent"
+	channelRiskEventType      = "ChannelRiskEvent"
+)
+
+const redacted = "[REDACTED]"
+
+const signatureHeader = "X-Torq-Signature"
+const eventTypeHeader = "X-Torq-Event"
+
+// NotificationSink the API never returns the secret (only HasSecret) and returns the url with its tokens redacted
+type NotificationSink struct {
+	NotificationSinkId int            `json:"notificationSinkId" db:"notification_sink_id"`
+	Name               string         `json:"name" db:"name"`
+	SinkType           sinkType       `json:"sinkType" db:"sink_type"`
+	Url                string         `json:"url" db:"url"`
+	Secret             *string        `json:"-" db:"secret"`
+	HasSecret          bool           `json:"hasSecret" db:"-"`
+	TelegramChatId     *string        `json:"telegramChatId" db:"telegram_chat_id"`
+	EventTypes         pq.StringArray `json:"eventTypes" db:"event_types"`
+	MaxRetries         int            `json:"maxRetries" db:"max_retries"`
+	Status             commons.Status `json:"status" db:"status"`
+	CreatedOn          time.Time      `json:"createdOn" db:"created_on"`
+	UpdateOn           time.Time      `json:"updatedOn" db:"updated_on"`
+}
+
+// NotificationDelivery status is Active when delivered and Inactive when all attempts failed.
+type NotificationDelivery struct {
+	NotificationDeliveryId int            `json:"notificationDeliveryId" db:"notification_delivery_id"`
+	NotificationSinkId     int            `json:"notificationSinkId" db:"notification_sink_id"`
+	EventType              string         `json:"eventType" db:"event_type"`
+	Payload                types.JSONText `json:"payload" db:"payload"`
+	Attempts               int            `json:"attempts" db:"attempts"`
+	StatusCode             *int           `json:"statusCode" db:"status_code"`
+	Status                 commons.Status `json:"status" db:"status"`
+	Error                  *string        `json:"error" db:"error"`
+	CreatedOn              time.Time      `json:"createdOn" db:"created_on"`
+	DeliveredOn            *time.Time     `json:"deliveredOn" db:"delivered_on"`
+}
+
+type webhookPayload struct {
+	Type  string      `json:"type"`
+	Event interface{} `json:"event"`
+}
+
+type telegramPayload struct {
+	ChatId string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// notificationSinkRequest the secret can only be set, when it's omitted the stored secret is kept
+type notificationSinkRequest struct {
+	NotificationSink
+	Secret *string `json:"secret"`
+}
+
+// redacted the sink as it's returned by the API
+func (ns NotificationSink) redacted() NotificationSink {
+	ns.HasSecret = ns.Secret != nil && *ns.Secret != ""
+	ns.Secret = nil
+	ns.Url = redactUrl(ns.SinkType, ns.Url)
+	return ns
+}
+
+// redactUrl hides the tokens in the url: the bot token of a Telegram url, the path of a Slack webhook url and
+// the password and the query values of any url.
+func redactUrl(sinkType sinkType, rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return redacted
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		parameters := strings.Split(u.RawQuery, "&")
+		for i, parameter := range parameters {
+			key, _, _ := strings.Cut(parameter, "=")
+			parameters[i] = key + "=" + redacted
+		}
+		u.RawQuery = strings.Join(parameters, "&")
+	}
+	// RawPath keeps the placeholder readable
+	switch sinkType {
+	case telegramSink:
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, "bot") && len(segment) > len("bot") {
+				segments[i] = "bot" + redacted
+			}
+		}
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = u.Path
+	case slackSink:
+		if strings.HasPrefix(u.Path, "/services/") {
+			u.Path = "/services/" + redacted
+			u.RawPath = u.Path
+		}
+	}
+	return u.String()
+}
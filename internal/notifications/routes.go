@@ -0,0 +1,171 @@
+package notifications
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterNotificationRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("get/:notificationSinkId", func(c *gin.Context) { getNotificationSinkHandler(c, db) })
+	r.GET("all", func(c *gin.Context) { getNotificationSinksHandler(c, db) })
+	r.POST("add", func(c *gin.Context) { addNotificationSinkHandler(c, db) })
+	r.PUT("set", func(c *gin.Context) { setNotificationSinkHandler(c, db) })
+	r.DELETE(":notificationSinkId", func(c *gin.Context) { removeNotificationSinkHandler(c, db) })
+	// deliveries returns the last deliveries of the sink (most recent first)
+	r.GET("deliveries/:notificationSinkId", func(c *gin.Context) { getNotificationDeliveriesHandler(c, db) })
+}
+
+func getNotificationSinkHandler(c *gin.Context, db *sqlx.DB) {
+	notificationSinkId, err := strconv.Atoi(c.Param("notificationSinkId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse notificationSinkId in the request.")
+		return
+	}
+	ns, err := getNotificationSink(db, notificationSinkId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Getting notification sink for notificationSinkId: %v", notificationSinkId))
+		return
+	}
+	c.JSON(http.StatusOK, ns.redacted())
+}
+
+func getNotificationSinksHandler(c *gin.Context, db *sqlx.DB) {
+	nss, err := getNotificationSinks(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting notification sinks.")
+		return
+	}
+	redactedNotificationSinks := make([]NotificationSink, 0, len(nss))
+	for _, ns := range nss {
+		redactedNotificationSinks = append(redactedNotificationSinks, ns.redacted())
+	}
+	c.JSON(http.StatusOK, redactedNotificationSinks)
+}
+
+func addNotificationSinkHandler(c *gin.Context, db *sqlx.DB) {
+	var nsr notificationSinkRequest
+	if err := c.BindJSON(&nsr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	ns := nsr.NotificationSink
+	ns.Secret = nsr.Secret
+	if message := validateNotificationSink(ns); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	storedNotificationSink, err := addNotificationSink(db, ns)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding notification sink.")
+		return
+	}
+	c.JSON(http.StatusOK, storedNotificationSink.redacted())
+}
+
+func setNotificationSinkHandler(c *gin.Context, db *sqlx.DB) {
+	var nsr notificationSinkRequest
+	if err := c.BindJSON(&nsr); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	ns := nsr.NotificationSink
+	if ns.NotificationSinkId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Failed to find notificationSinkId in the request.")
+		return
+	}
+	existingNotificationSink, err := getNotificationSink(db, ns.NotificationSinkId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Getting notification sink for notificationSinkId: %v", ns.NotificationSinkId))
+		return
+	}
+	ns.Secret = existingNotificationSink.Secret
+	if nsr.Secret != nil {
+		ns.Secret = nsr.Secret
+	}
+	// The redacted url is sent back when the url wasn't changed
+	if ns.Url == redactUrl(existingNotificationSink.SinkType, existingNotificationSink.Url) {
+		ns.Url = existingNotificationSink.Url
+	}
+	if message := validateNotificationSink(ns); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	storedNotificationSink, err := setNotificationSink(db, ns)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Setting notification sink for notificationSinkId: %v", ns.NotificationSinkId))
+		return
+	}
+	c.JSON(http.StatusOK, storedNotificationSink.redacted())
+}
+
+func removeNotificationSinkHandler(c *gin.Context, db *sqlx.DB) {
+	notificationSinkId, err := strconv.Atoi(c.Param("notificationSinkId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse notificationSinkId in the request.")
+		return
+	}
+	count, err := removeNotificationSink(db, notificationSinkId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Removing notification sink for notificationSinkId: %v", notificationSinkId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v notification sink(s).", count)})
+}
+
+func getNotificationDeliveriesHandler(c *gin.Context, db *sqlx.DB) {
+	notificationSinkId, err := strconv.Atoi(c.Param("notificationSinkId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse notificationSinkId in the request.")
+		return
+	}
+	nds, err := getNotificationDeliveries(db, notificationSinkId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Getting notification deliveries for notificationSinkId: %v", notificationSinkId))
+		return
+	}
+	c.JSON(http.StatusOK, nds)
+}
+
+func validateNotificationSink(ns NotificationSink) string {
+	if ns.Name == "" {
+		return "Failed to find name in the request."
+	}
+	u, err := url.Parse(ns.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "A valid http(s) url is required."
+	}
+	switch ns.SinkType {
+	case webhookSink, slackSink:
+	case telegramSink:
+		if ns.TelegramChatId == nil || *ns.TelegramChatId == "" {
+			return "A telegram sink requires a telegramChatId."
+		}
+	default:
+		return fmt.Sprintf("Unknown sink type: %v", ns.SinkType)
+	}
+	if ns.MaxRetries < 0 {
+		return "The max retries cannot be negative."
+	}
+	for _, eventType := range ns.EventTypes {
+		switch eventType {
+		case channelEventType, peerEventType, invoiceEventType, paymentEventType, serviceEventType,
+			channelGraphEventType, stuckTransactionEventType, channelRiskEventType:
+		default:
+			return fmt.Sprintf("Unknown event type: %v", eventType)
+		}
+	}
+	return ""
+}
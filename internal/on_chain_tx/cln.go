@@ -0,0 +1,82 @@
+package on_chain_tx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func payOnChainCln(ctx context.Context, client cln_connect.RpcClient, req commons.PayOnChainRequest) (string, error) {
+	params := map[string]interface{}{
+		"destination": req.Address,
+		"satoshi":     req.AmountSat,
+	}
+	if req.SendAll != nil && *req.SendAll {
+		params["satoshi"] = "all"
+	}
+	if req.SatPerVbyte != nil {
+		params["feerate"] = fmt.Sprintf("%vperkb", *req.SatPerVbyte*1000)
+	}
+	if req.TargetConf != nil {
+		params["feerate"] = fmt.Sprintf("%vblocks", *req.TargetConf)
+	}
+	if req.MinConfs != nil {
+		params["minconf"] = *req.MinConfs
+	}
+	if req.SpendUnconfirmed != nil && *req.SpendUnconfirmed {
+		params["minconf"] = 0
+	}
+
+	var resp cln_connect.WithdrawResponse
+	err := client.Call(ctx, "withdraw", params, &resp)
+	if err != nil {
+		return "", errors.Wrap(err, "Sending coins")
+	}
+	return resp.TxId, nil
+}
+
+func newAddressCln(ctx context.Context, client cln_connect.RpcClient, newAddressRequest commons.NewAddressRequest,
+	eventChannel chan interface{}, reqId string) error {
+
+	// Core Lightning only supports segwit and taproot addresses
+	addressType := "bech32"
+	switch newAddressRequest.Type {
+	case P2WPKH:
+	case P2TR:
+		addressType = "p2tr"
+	default:
+		return errors.Newf("Address type %v is not supported by CLN", newAddressRequest.Type)
+	}
+
+	var resp cln_connect.NewAddrResponse
+	err := client.Call(ctx, "newaddr", map[string]interface{}{"addresstype": addressType}, &resp)
+	if err != nil {
+		return errors.Wrap(err, "New address")
+	}
+
+	address := resp.Bech32
+	if addressType == "p2tr" {
+		address = resp.P2tr
+	}
+	if eventChannel != nil {
+		eventChannel <- commons.NewAddressResponse{
+			ReqId:   reqId,
+			Request: newAddressRequest,
+			Address: address,
+		}
+	}
+	return nil
+}
+
+func (actions clnOnChainActions) payOnChain(ctx context.Context, req commons.PayOnChainRequest) (string, error) {
+	return payOnChainCln(ctx, actions.client, req)
+}
+
+func (actions clnOnChainActions) newAddress(ctx context.Context, newAddressRequest commons.NewAddressRequest,
+	eventChannel chan interface{}, reqId string) error {
+	return newAddressCln(ctx, actions.client, newAddressRequest, eventChannel, reqId)
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/lncapital/torq/internal/audit"
 	qp "github.com/lncapital/torq/internal/query_parser"
+	"github.com/lncapital/torq/internal/spending"
 	ah "github.com/lncapital/torq/pkg/api_helpers"
 	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/server_errors"
@@ -102,7 +104,7 @@ func getOnChainTxsHandler(c *gin.Context, db *sqlx.DB) {
 		}})
 }
 
-func sendCoinsHandler(c *gin.Context, db *sqlx.DB) {
+func sendCoinsHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}) {
 	var requestBody commons.PayOnChainRequest
 
 	if err := c.BindJSON(&requestBody); err != nil {
@@ -110,13 +112,30 @@ func sendCoinsHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
+	actor := audit.ContextActor(c, "")
+	spendingRequest, err := spending.CheckOnChain(db, eventChannel, actor, requestBody)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Checking spending limits")
+		return
+	}
+	if spendingRequest.Status == spending.Pending {
+		c.JSON(http.StatusAccepted, spendingRequest)
+		return
+	}
+	auditEvent, err := audit.Start(db, actor, audit.PayOnChain, requestBody.NodeId, nil, requestBody)
+	if err != nil {
+		spending.Finish(db, spendingRequest, err)
+		server_errors.WrapLogAndSendServerError(c, err, "Recording audit event")
+		return
+	}
 	resp, err := PayOnChain(db, requestBody)
+	sendCoinsResp := commons.PayOnChainResponse{Request: requestBody, TxId: resp}
+	audit.Finish(db, auditEvent, sendCoinsResp, err)
+	spending.Finish(db, spendingRequest, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "Sending on-chain payment")
 		return
 	}
 
-	sendCoinsResp := commons.PayOnChainResponse{Request: requestBody, TxId: resp}
-
 	c.JSON(http.StatusOK, sendCoinsResp)
 }
@@ -9,9 +9,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
 	"google.golang.org/grpc"
 
-	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 const (
@@ -29,7 +27,7 @@ type rpcClientNewAddress interface {
 func NewAddress(
 	eventChannel chan interface{},
 	db *sqlx.DB,
-	context *gin.Context,
+	c *gin.Context,
 	newAddressRequest commons.NewAddressRequest,
 	reqId string,
 ) (err error) {
@@ -38,20 +36,16 @@ func NewAddress(
 		return errors.New("Node id is missing")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, newAddressRequest.NodeId)
+	actions, err := getOnChainActions(db, newAddressRequest.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
-	}
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return err
 	}
-	defer conn.Close()
-	client := walletrpc.NewWalletKitClient(conn)
-	return newAddress(client, newAddressRequest, eventChannel, reqId)
+	return actions.newAddress(context.Background(), newAddressRequest, eventChannel, reqId)
+}
+
+func (actions lndOnChainActions) newAddress(ctx context.Context, newAddressRequest commons.NewAddressRequest,
+	eventChannel chan interface{}, reqId string) error {
+	return newAddress(ctx, walletrpc.NewWalletKitClient(actions.conn), newAddressRequest, eventChannel, reqId)
 }
 
 func createLndAddressRequest(newAddressRequest commons.NewAddressRequest) (r *walletrpc.AddrRequest, err error) {
@@ -72,14 +66,13 @@ func createLndAddressRequest(newAddressRequest commons.NewAddressRequest) (r *wa
 	return lndAddressRequest, nil
 }
 
-func newAddress(client rpcClientNewAddress, newAddressRequest commons.NewAddressRequest, eventChannel chan interface{}, reqId string) (err error) {
+func newAddress(ctx context.Context, client rpcClientNewAddress, newAddressRequest commons.NewAddressRequest, eventChannel chan interface{}, reqId string) (err error) {
 	// Create and validate payment request details
 	lndAddressRequest, err := createLndAddressRequest(newAddressRequest)
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
 	lndResponse, err := client.NextAddr(ctx, lndAddressRequest)
 	if err != nil {
 		return errors.Wrap(err, "New address")
@@ -0,0 +1,42 @@
+package on_chain_tx
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// onChainActions the on-chain actions of a node, implemented per node implementation
+type onChainActions interface {
+	payOnChain(ctx context.Context, req commons.PayOnChainRequest) (string, error)
+	newAddress(ctx context.Context, newAddressRequest commons.NewAddressRequest, eventChannel chan interface{},
+		reqId string) error
+}
+
+type lndOnChainActions struct {
+	conn *grpc.ClientConn
+}
+
+type clnOnChainActions struct {
+	client cln_connect.RpcClient
+}
+
+func getOnChainActions(db *sqlx.DB, nodeId int) (onChainActions, error) {
+	connection, err := settings.GetNodeConnection(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Connecting to the node")
+	}
+	switch connection := connection.(type) {
+	case settings.LndConnection:
+		return lndOnChainActions{conn: connection.Conn}, nil
+	case settings.ClnConnection:
+		return clnOnChainActions{client: connection.Client}, nil
+	}
+	return nil, errors.Newf("On-chain actions are not supported for node %v", nodeId)
+}
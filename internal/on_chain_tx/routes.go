@@ -6,7 +6,7 @@ import (
 )
 
 
-func RegisterOnChainTxsRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+func RegisterOnChainTxsRoutes(r *gin.RouterGroup, db *sqlx.DB, eventChannel chan interface{}) {
 	r.GET("", func(c *gin.Context) { getOnChainTxsHandler(c, db) })
-	r.POST("sendcoins", func(c *gin.Context) { sendCoinsHandler(c, db) })
+	r.POST("sendcoins", func(c *gin.Context) { sendCoinsHandler(c, db, eventChannel) })
 }
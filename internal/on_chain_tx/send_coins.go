@@ -8,65 +8,63 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
 
-	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 func PayOnChain(db *sqlx.DB, req commons.PayOnChainRequest) (r string, err error) {
-
-	sendCoinsReq, err := processSendRequest(req)
-	if err != nil {
+	if err := validatePayOnChainRequest(req); err != nil {
 		return "", errors.Wrap(err, "Process send request")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, req.NodeId)
+	actions, err := getOnChainActions(db, req.NodeId)
 	if err != nil {
-		return "", errors.New("Error getting node connection details from the db")
+		return "", err
 	}
+	return actions.payOnChain(context.Background(), req)
+}
 
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+func (actions lndOnChainActions) payOnChain(ctx context.Context, req commons.PayOnChainRequest) (string, error) {
+	sendCoinsReq, err := processSendRequest(req)
 	if err != nil {
-		return "", errors.Wrap(err, "Connecting to LND")
+		return "", errors.Wrap(err, "Process send request")
 	}
 
-	defer conn.Close()
-
-	client := lnrpc.NewLightningClient(conn)
-	ctx := context.Background()
-
+	client := lnrpc.NewLightningClient(actions.conn)
 	resp, err := client.SendCoins(ctx, sendCoinsReq)
 	if err != nil {
 		return "", errors.Wrap(err, "Sending coins")
 	}
 
 	return resp.Txid, nil
-
 }
 
-func processSendRequest(req commons.PayOnChainRequest) (r *lnrpc.SendCoinsRequest, err error) {
-	r = &lnrpc.SendCoinsRequest{}
-
+func validatePayOnChainRequest(req commons.PayOnChainRequest) error {
 	if req.NodeId == 0 {
-		return &lnrpc.SendCoinsRequest{}, errors.New("Node id is missing")
+		return errors.New("Node id is missing")
 	}
 
 	if req.Address == "" {
 		log.Error().Msgf("Address must be provided")
-		return &lnrpc.SendCoinsRequest{}, errors.New("Address must be provided")
+		return errors.New("Address must be provided")
 	}
 
 	if req.AmountSat <= 0 {
 		log.Error().Msgf("Invalid amount")
-		return &lnrpc.SendCoinsRequest{}, errors.New("Invalid amount")
+		return errors.New("Invalid amount")
 	}
 
 	if req.TargetConf != nil && req.SatPerVbyte != nil {
 		log.Error().Msgf("Either targetConf or satPerVbyte accepted")
-		return &lnrpc.SendCoinsRequest{}, errors.New("Either targetConf or satPerVbyte accepted")
+		return errors.New("Either targetConf or satPerVbyte accepted")
+	}
+	return nil
+}
+
+func processSendRequest(req commons.PayOnChainRequest) (r *lnrpc.SendCoinsRequest, err error) {
+	r = &lnrpc.SendCoinsRequest{}
+
+	if err := validatePayOnChainRequest(req); err != nil {
+		return &lnrpc.SendCoinsRequest{}, err
 	}
 
 	r.Addr = req.Address
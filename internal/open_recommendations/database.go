@@ -0,0 +1,112 @@
+package open_recommendations
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+func getGraphSnapshot(db *sqlx.DB, nodeId int) (lnd.GraphSnapshot, error) {
+	var snapshot lnd.GraphSnapshot
+	err := db.Get(&snapshot, `
+		SELECT node_id, imported_on, node_count, edge_count
+		FROM graph_snapshot
+		WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return lnd.GraphSnapshot{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return snapshot, nil
+}
+
+func getGraphNodes(db *sqlx.DB, nodeId int) ([]graphNode, error) {
+	rows, err := db.Queryx(`
+		SELECT public_key, alias, last_update, addresses
+		FROM graph_snapshot_node
+		WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	defer rows.Close()
+	var nodes []graphNode
+	for rows.Next() {
+		var node graphNode
+		var addresses []byte
+		err = rows.Scan(&node.PublicKey, &node.Alias, &node.LastUpdate, &addresses)
+		if err != nil {
+			return nil, errors.Wrap(err, database.SqlScanResulSetError)
+		}
+		var nodeAddresses []struct {
+			Addr string `json:"addr"`
+		}
+		err = json.Unmarshal(addresses, &nodeAddresses)
+		if err != nil {
+			return nil, errors.Wrapf(err, "JSON Unmarshal addresses of %v", node.PublicKey)
+		}
+		for _, nodeAddress := range nodeAddresses {
+			node.Addresses = append(node.Addresses, nodeAddress.Addr)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, errors.Wrap(rows.Err(), database.SqlScanResulSetError)
+}
+
+func getGraphEdges(db *sqlx.DB, nodeId int) ([]graphEdge, error) {
+	var edges []graphEdge
+	err := db.Select(&edges, `
+		SELECT capacity, last_update, node1_public_key, node2_public_key,
+			node1_fee_rate_mill_msat, node1_disabled, node2_fee_rate_mill_msat, node2_disabled
+		FROM graph_snapshot_edge
+		WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return edges, nil
+}
+
+// getPeerPublicKeys the public keys of the nodes with a channel that is not closed
+func getPeerPublicKeys(db *sqlx.DB, nodeId int) ([]string, error) {
+	var publicKeys []string
+	err := db.Select(&publicKeys, `
+		SELECT DISTINCT n.public_key
+		FROM channel c
+		JOIN node n ON n.node_id = CASE WHEN c.first_node_id=$1 THEN c.second_node_id ELSE c.first_node_id END
+		WHERE (c.first_node_id=$1 OR c.second_node_id=$1) AND c.status_id < $2;`,
+		nodeId, commons.CooperativeClosed)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return publicKeys, nil
+}
+
+// getFailedForwardDemand the failed forward amount by public key of the peer of the outgoing channel
+func getFailedForwardDemand(db *sqlx.DB, nodeId int, from time.Time) (map[string]int64, error) {
+	rows, err := db.Queryx(`
+		SELECT n.public_key, COALESCE(SUM(he.outgoing_amt_msat), 0)
+		FROM htlc_event he
+		JOIN channel c ON c.channel_id = he.outgoing_channel_id
+		JOIN node n ON n.node_id = CASE WHEN c.first_node_id=$1 THEN c.second_node_id ELSE c.first_node_id END
+		WHERE he.node_id=$1 AND he.time >= $2 AND he.event_origin='FORWARD'
+			AND he.event_type IN ('LinkFailEvent', 'ForwardFailEvent')
+		GROUP BY n.public_key;`, nodeId, from)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	defer rows.Close()
+	demand := make(map[string]int64)
+	for rows.Next() {
+		var publicKey string
+		var amountMsat int64
+		err = rows.Scan(&publicKey, &amountMsat)
+		if err != nil {
+			return nil, errors.Wrap(err, database.SqlScanResulSetError)
+		}
+		demand[publicKey] = amountMsat
+	}
+	return demand, errors.Wrap(rows.Err(), database.SqlScanResulSetError)
+}
@@ -0,0 +1,340 @@
+package open_recommendations
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+const (
+	DefaultLimit              = 5
+	DefaultFundingAmount      = 1000000
+	DefaultFailedForwardsDays = 30
+)
+
+// The score is the weighted sum of the components, each component is between 0 and 1
+const (
+	centralityWeight = 40
+	inboundFeeWeight = 25
+	demandWeight     = 20
+	uptimeWeight     = 15
+)
+
+const (
+	// maxCandidates only the best connected nodes are evaluated, a breadth first search is done for each of them
+	maxCandidates       = 200
+	minimumChannels     = 5
+	targetInboundFeePpm = 1000
+	// staleAfter a node that did not update its announcement or channels for this long is considered unreliable
+	staleAfter = 14 * 24 * time.Hour
+	// unreachableHops the distance used for nodes that can't be reached
+	unreachableHops = 10
+)
+
+type OpenRecommendation struct {
+	PublicKey    string   `json:"publicKey"`
+	Alias        string   `json:"alias"`
+	Addresses    []string `json:"addresses"`
+	ChannelCount int      `json:"channelCount"`
+	Capacity     int64    `json:"capacity"`
+	// CentralityGain the sum of the hops saved to every node in the graph when opening a channel with this node
+	CentralityGain int64 `json:"centralityGain"`
+	// InboundFeeRateMilliMsat the median fee rate the peers of this node charge to forward into it
+	InboundFeeRateMilliMsat int64 `json:"inboundFeeRateMilliMsat"`
+	// EnabledChannelsRatio the fraction of the channels this node did not disable
+	EnabledChannelsRatio float64   `json:"enabledChannelsRatio"`
+	LastUpdate           time.Time `json:"lastUpdate"`
+	// FailedForwardDemandMsat the amount of forwards that failed on our channels with peers of this node
+	FailedForwardDemandMsat int64 `json:"failedForwardDemandMsat"`
+
+	// Score between 0 and 100
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+type OpenRecommendations struct {
+	NodeId           int                      `json:"nodeId"`
+	GraphSnapshot    lnd.GraphSnapshot        `json:"graphSnapshot"`
+	Recommendations  []OpenRecommendation     `json:"recommendations"`
+	BatchOpenRequest commons.BatchOpenRequest `json:"batchOpenRequest"`
+}
+
+type graphNode struct {
+	PublicKey  string    `db:"public_key"`
+	Alias      string    `db:"alias"`
+	LastUpdate time.Time `db:"last_update"`
+	Addresses  []string  `db:"-"`
+}
+
+type graphEdge struct {
+	Capacity              int64     `db:"capacity"`
+	LastUpdate            time.Time `db:"last_update"`
+	Node1PublicKey        string    `db:"node1_public_key"`
+	Node2PublicKey        string    `db:"node2_public_key"`
+	Node1FeeRateMilliMsat *int64    `db:"node1_fee_rate_mill_msat"`
+	Node1Disabled         *bool     `db:"node1_disabled"`
+	Node2FeeRateMilliMsat *int64    `db:"node2_fee_rate_mill_msat"`
+	Node2Disabled         *bool     `db:"node2_disabled"`
+}
+
+// GetOpenRecommendations ranks the nodes of the stored graph snapshot as peers for new channels of the node
+func GetOpenRecommendations(db *sqlx.DB, nodeId int, limit int, fundingAmount int64) (OpenRecommendations, error) {
+	snapshot, err := getGraphSnapshot(db, nodeId)
+	if err != nil {
+		return OpenRecommendations{}, errors.Wrap(err, "Obtaining graph snapshot")
+	}
+	nodes, err := getGraphNodes(db, nodeId)
+	if err != nil {
+		return OpenRecommendations{}, errors.Wrap(err, "Obtaining graph nodes")
+	}
+	edges, err := getGraphEdges(db, nodeId)
+	if err != nil {
+		return OpenRecommendations{}, errors.Wrap(err, "Obtaining graph edges")
+	}
+	peerPublicKeys, err := getPeerPublicKeys(db, nodeId)
+	if err != nil {
+		return OpenRecommendations{}, errors.Wrap(err, "Obtaining peers")
+	}
+	demand, err := getFailedForwardDemand(db, nodeId,
+		time.Now().UTC().AddDate(0, 0, -DefaultFailedForwardsDays))
+	if err != nil {
+		return OpenRecommendations{}, errors.Wrap(err, "Obtaining failed forwards")
+	}
+
+	ownPublicKey := commons.GetNodeSettingsByNodeId(nodeId).PublicKey
+	excluded := map[string]bool{ownPublicKey: true}
+	for _, publicKey := range peerPublicKeys {
+		excluded[publicKey] = true
+	}
+	recommendations := recommend(nodes, edges, ownPublicKey, excluded, demand, time.Now().UTC())
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	batchOpenRequest := commons.BatchOpenRequest{NodeId: nodeId, Channels: []commons.BatchOpenChannel{}}
+	for _, recommendation := range recommendations {
+		batchOpenRequest.Channels = append(batchOpenRequest.Channels, commons.BatchOpenChannel{
+			NodePubkey:         recommendation.PublicKey,
+			LocalFundingAmount: fundingAmount,
+		})
+	}
+	return OpenRecommendations{
+		NodeId:           nodeId,
+		GraphSnapshot:    snapshot,
+		Recommendations:  recommendations,
+		BatchOpenRequest: batchOpenRequest,
+	}, nil
+}
+
+// recommend scores the eligible nodes and returns them with the best score first. demand is the failed forward
+// amount by public key of our peers.
+func recommend(nodes []graphNode, edges []graphEdge, ownPublicKey string, excluded map[string]bool,
+	demand map[string]int64, now time.Time) []OpenRecommendation {
+
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.PublicKey] = i
+	}
+	adjacency := make([][]int, len(nodes))
+	channelCount := make([]int, len(nodes))
+	enabledCount := make([]int, len(nodes))
+	capacity := make([]int64, len(nodes))
+	lastUpdate := make([]time.Time, len(nodes))
+	inboundFeeRates := make([][]int64, len(nodes))
+	for i, node := range nodes {
+		lastUpdate[i] = node.LastUpdate
+	}
+	for _, edge := range edges {
+		node1, ok1 := index[edge.Node1PublicKey]
+		node2, ok2 := index[edge.Node2PublicKey]
+		if !ok1 || !ok2 || node1 == node2 {
+			continue
+		}
+		node1Enabled := edge.Node1Disabled != nil && !*edge.Node1Disabled
+		node2Enabled := edge.Node2Disabled != nil && !*edge.Node2Disabled
+		for _, side := range []struct {
+			node    int
+			enabled bool
+			// otherFeeRate what the other side charges to forward into the node
+			otherFeeRate *int64
+			otherEnabled bool
+		}{
+			{node1, node1Enabled, edge.Node2FeeRateMilliMsat, node2Enabled},
+			{node2, node2Enabled, edge.Node1FeeRateMilliMsat, node1Enabled},
+		} {
+			channelCount[side.node]++
+			capacity[side.node] += edge.Capacity
+			if side.enabled {
+				enabledCount[side.node]++
+			}
+			if side.otherEnabled && side.otherFeeRate != nil {
+				inboundFeeRates[side.node] = append(inboundFeeRates[side.node], *side.otherFeeRate)
+			}
+			if edge.LastUpdate.After(lastUpdate[side.node]) {
+				lastUpdate[side.node] = edge.LastUpdate
+			}
+		}
+		if node1Enabled || node2Enabled {
+			adjacency[node1] = append(adjacency[node1], node2)
+			adjacency[node2] = append(adjacency[node2], node1)
+		}
+	}
+
+	var candidates []int
+	for i, node := range nodes {
+		if excluded[node.PublicKey] || len(node.Addresses) == 0 || enabledCount[i] < minimumChannels {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if len(adjacency[candidates[i]]) != len(adjacency[candidates[j]]) {
+			return len(adjacency[candidates[i]]) > len(adjacency[candidates[j]])
+		}
+		return capacity[candidates[i]] > capacity[candidates[j]]
+	})
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+
+	ownDistances := make([]int, len(nodes))
+	for i := range ownDistances {
+		ownDistances[i] = unreachableHops
+	}
+	if own, exists := index[ownPublicKey]; exists {
+		ownDistances = distances(adjacency, own)
+	}
+
+	recommendations := make([]OpenRecommendation, 0, len(candidates))
+	var maxCentralityGain, maxDemand int64
+	for _, candidate := range candidates {
+		recommendation := OpenRecommendation{
+			PublicKey:               nodes[candidate].PublicKey,
+			Alias:                   nodes[candidate].Alias,
+			Addresses:               nodes[candidate].Addresses,
+			ChannelCount:            channelCount[candidate],
+			Capacity:                capacity[candidate],
+			CentralityGain:          centralityGain(ownDistances, distances(adjacency, candidate)),
+			InboundFeeRateMilliMsat: median(inboundFeeRates[candidate]),
+			EnabledChannelsRatio:    float64(enabledCount[candidate]) / float64(channelCount[candidate]),
+			LastUpdate:              lastUpdate[candidate],
+		}
+		for _, neighbour := range adjacency[candidate] {
+			recommendation.FailedForwardDemandMsat += demand[nodes[neighbour].PublicKey]
+		}
+		if recommendation.CentralityGain > maxCentralityGain {
+			maxCentralityGain = recommendation.CentralityGain
+		}
+		if recommendation.FailedForwardDemandMsat > maxDemand {
+			maxDemand = recommendation.FailedForwardDemandMsat
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+	for i := range recommendations {
+		evaluate(&recommendations[i], maxCentralityGain, maxDemand, now)
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	return recommendations
+}
+
+// evaluate sets the score and reasons, the centrality gain and demand are relative to the best candidate
+func evaluate(recommendation *OpenRecommendation, maxCentralityGain int64, maxDemand int64, now time.Time) {
+	var centrality, demand float64
+	if maxCentralityGain > 0 {
+		centrality = float64(recommendation.CentralityGain) / float64(maxCentralityGain)
+	}
+	if maxDemand > 0 {
+		demand = float64(recommendation.FailedForwardDemandMsat) / float64(maxDemand)
+	}
+	inboundFee := clamp(float64(recommendation.InboundFeeRateMilliMsat) / targetInboundFeePpm)
+	uptime := recommendation.EnabledChannelsRatio
+	stale := now.Sub(recommendation.LastUpdate) > staleAfter
+	if stale {
+		uptime = 0
+	}
+	recommendation.Score = math.Round(centralityWeight*centrality + inboundFeeWeight*inboundFee +
+		demandWeight*demand + uptimeWeight*uptime)
+
+	recommendation.Reasons = []string{}
+	if centrality >= 0.5 {
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("Saves %v hops to the rest of the graph.", recommendation.CentralityGain))
+	}
+	if inboundFee >= 0.5 {
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("Its peers charge a median of %v ppm to forward into it.",
+				recommendation.InboundFeeRateMilliMsat))
+	}
+	if recommendation.FailedForwardDemandMsat > 0 {
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("%v sat failed to forward to its neighbours.", recommendation.FailedForwardDemandMsat/1000))
+	}
+	if stale {
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("No updates since %v.", recommendation.LastUpdate.Format("2006-01-02")))
+	} else if uptime < 0.9 {
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("Only %.0f%% of its channels are enabled.", uptime*100))
+	}
+}
+
+// distances the hops from the source to every node, capped at unreachableHops
+func distances(adjacency [][]int, source int) []int {
+	result := make([]int, len(adjacency))
+	for i := range result {
+		result[i] = unreachableHops
+	}
+	result[source] = 0
+	queue := []int{source}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if result[current]+1 >= unreachableHops {
+			continue
+		}
+		for _, neighbour := range adjacency[current] {
+			if result[neighbour] > result[current]+1 {
+				result[neighbour] = result[current] + 1
+				queue = append(queue, neighbour)
+			}
+		}
+	}
+	return result
+}
+
+// centralityGain the hops saved when every node can also be reached over a direct channel with the candidate
+func centralityGain(ownDistances []int, candidateDistances []int) int64 {
+	var gain int64
+	for i, ownDistance := range ownDistances {
+		if viaCandidate := candidateDistances[i] + 1; viaCandidate < ownDistance {
+			gain += int64(ownDistance - viaCandidate)
+		}
+	}
+	return gain
+}
+
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func clamp(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
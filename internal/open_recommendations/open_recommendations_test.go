@@ -0,0 +1,88 @@
+package open_recommendations
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDistancesAndCentralityGain(t *testing.T) {
+	// 0 - 1 - 2 - 3 and 4 is not connected
+	adjacency := [][]int{{1}, {0, 2}, {1, 3}, {2}, {}}
+	ownDistances := distances(adjacency, 0)
+	for i, expected := range []int{0, 1, 2, 3, unreachableHops} {
+		if ownDistances[i] != expected {
+			t.Errorf("expected distance %v to node %v, got %v", expected, i, ownDistances[i])
+		}
+	}
+	// A channel with node 3 saves two hops to node 3, node 2 stays two hops away
+	if gain := centralityGain(ownDistances, distances(adjacency, 3)); gain != 2 {
+		t.Errorf("expected a gain of 2, got %v", gain)
+	}
+	if gain := centralityGain(ownDistances, distances(adjacency, 1)); gain != 0 {
+		t.Errorf("expected no gain for an existing peer, got %v", gain)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if result := median(nil); result != 0 {
+		t.Errorf("expected 0, got %v", result)
+	}
+	if result := median([]int64{500, 1, 100}); result != 100 {
+		t.Errorf("expected 100, got %v", result)
+	}
+	if result := median([]int64{400, 100, 200, 300}); result != 250 {
+		t.Errorf("expected 250, got %v", result)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	enabled := false
+	feeRate := int64(1000)
+	node := func(publicKey string) graphNode {
+		return graphNode{PublicKey: publicKey, Alias: publicKey, LastUpdate: now, Addresses: []string{"127.0.0.1:9735"}}
+	}
+	edge := func(node1 string, node2 string) graphEdge {
+		return graphEdge{Capacity: 1000000, LastUpdate: now, Node1PublicKey: node1, Node2PublicKey: node2,
+			Node1FeeRateMilliMsat: &feeRate, Node1Disabled: &enabled, Node2FeeRateMilliMsat: &feeRate,
+			Node2Disabled: &enabled}
+	}
+
+	// We only have a channel with our peer, the hub is connected to many leaves the small node only to a few
+	nodes := []graphNode{node("us"), node("peer"), node("hub"), node("small")}
+	edges := []graphEdge{edge("us", "peer"), edge("peer", "small")}
+	for i := 0; i < 10; i++ {
+		leaf := fmt.Sprintf("leaf%v", i)
+		nodes = append(nodes, node(leaf))
+		edges = append(edges, edge("hub", leaf))
+		if i < minimumChannels-1 {
+			edges = append(edges, edge("small", leaf))
+		}
+	}
+
+	recommendations := recommend(nodes, edges, "us", map[string]bool{"us": true, "peer": true},
+		map[string]int64{"peer": 5000000}, now)
+	if len(recommendations) != 2 {
+		t.Fatalf("expected the hub and the small node as candidates, got %+v", recommendations)
+	}
+	if recommendations[0].PublicKey != "hub" || recommendations[0].CentralityGain <= recommendations[1].CentralityGain {
+		t.Errorf("expected the hub first, got %+v", recommendations)
+	}
+	if recommendations[0].Score != 80 {
+		t.Errorf("expected full centrality, fee and uptime scores for the hub, got %v", recommendations[0].Score)
+	}
+	if recommendations[1].FailedForwardDemandMsat != 5000000 {
+		t.Errorf("expected the failed forwards to our peer to count for its neighbour, got %v",
+			recommendations[1].FailedForwardDemandMsat)
+	}
+
+	stale := now.Add(2 * staleAfter)
+	recommendations = recommend(nodes, edges, "us", map[string]bool{"us": true, "peer": true}, nil, stale)
+	for _, recommendation := range recommendations {
+		if len(recommendation.Reasons) == 0 || recommendation.Reasons[len(recommendation.Reasons)-1] !=
+			"No updates since 2023-01-01." {
+			t.Errorf("expected a stale reason, got %v", recommendation.Reasons)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package open_recommendations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/lnd"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterOpenRecommendationRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	// i.e. ?nodeId=1&limit=5&amount=1000000
+	r.GET("", func(c *gin.Context) { getOpenRecommendationsHandler(c, db) })
+	r.GET("graph-snapshot", func(c *gin.Context) { getGraphSnapshotHandler(c, db) })
+	// The full graph import is optional and can take a while on mainnet
+	r.POST("graph-snapshot", auth.RoleRequired(auth.Admin), func(c *gin.Context) { importGraphSnapshotHandler(c, db) })
+}
+
+func getOpenRecommendationsHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Query("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+		return
+	}
+	limit := DefaultLimit
+	if c.Query("limit") != "" {
+		limit, err = strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 {
+			server_errors.SendBadRequest(c, "Failed to parse limit in the request.")
+			return
+		}
+	}
+	fundingAmount := int64(DefaultFundingAmount)
+	if c.Query("amount") != "" {
+		fundingAmount, err = strconv.ParseInt(c.Query("amount"), 10, 64)
+		if err != nil || fundingAmount < 1 {
+			server_errors.SendBadRequest(c, "Failed to parse amount in the request.")
+			return
+		}
+	}
+	recommendations, err := GetOpenRecommendations(db, nodeId, limit, fundingAmount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			server_errors.SendBadRequest(c, "No graph snapshot found for this node, import one first.")
+			return
+		}
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Recommending peers for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, recommendations)
+}
+
+func getGraphSnapshotHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Query("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+		return
+	}
+	snapshot, err := getGraphSnapshot(db, nodeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusOK, nil)
+			return
+		}
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting graph snapshot for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
+
+func importGraphSnapshotHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Query("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to parse nodeId in the request.")
+		return
+	}
+	connection, err := settings.GetNodeConnection(db, nodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Connecting to the node")
+		return
+	}
+	lndConnection, ok := connection.(settings.LndConnection)
+	if !ok {
+		server_errors.SendBadRequest(c, "Graph snapshots are only supported for LND nodes.")
+		return
+	}
+
+	snapshot, err := lnd.ImportGraphSnapshot(context.Background(), lnrpc.NewLightningClient(lndConnection.Conn), db, nodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Importing graph snapshot for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
@@ -0,0 +1,122 @@
+package payments
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// Core Lightning pay error codes
+const (
+	clnPayInvalidParams       = -32602
+	clnPayInProgress          = 200
+	clnPayRhashAlreadyUsed    = 201
+	clnPayDestinationPermFail = 203
+	clnPayNoRouteFound        = 205
+	clnPayInvoiceExpired      = 207
+	clnPayStoppedRetrying     = 210
+)
+
+func sendPaymentCln(ctx context.Context, client cln_connect.RpcClient, npReq commons.NewPaymentRequest,
+	eventChannel chan interface{}, reqId string) error {
+
+	if npReq.Invoice == nil || *npReq.Invoice == "" {
+		// TODO: Add support for Keysend
+		return errors.New("INVALID_PAYMENT_REQUEST")
+	}
+
+	params := map[string]interface{}{"bolt11": *npReq.Invoice}
+	if npReq.AmtMSat != nil {
+		params["amount_msat"] = *npReq.AmtMSat
+	}
+	if npReq.FeeLimitMsat != nil && *npReq.FeeLimitMsat != 0 {
+		params["maxfee"] = *npReq.FeeLimitMsat
+	}
+	if npReq.TimeOutSecs != 0 {
+		params["retry_for"] = npReq.TimeOutSecs
+	}
+
+	creationDate := time.Now().UTC()
+	var resp cln_connect.PayResponse
+	err := client.Call(ctx, "pay", params, &resp)
+	if err != nil {
+		var rpcError *cln_connect.RpcError
+		if !errors.As(err, &rpcError) {
+			return errors.Wrap(err, "Sending payment")
+		}
+		switch rpcError.Code {
+		case clnPayInvalidParams:
+			if npReq.AmtMSat == nil {
+				return errors.New("AMOUNT_REQUIRED")
+			}
+			return errors.New("INVALID_PAYMENT_REQUEST")
+		case clnPayRhashAlreadyUsed, clnPayInProgress:
+			return errors.New("ALREADY_PAID")
+		}
+		if eventChannel != nil {
+			eventChannel <- commons.NewPaymentResponse{
+				ReqId:          reqId,
+				Request:        npReq,
+				Status:         lnrpc.Payment_FAILED.String(),
+				FailureReason:  getClnFailureReason(rpcError.Code).String(),
+				PaymentRequest: *npReq.Invoice,
+				CreationDate:   creationDate,
+			}
+		}
+		return nil
+	}
+
+	if eventChannel != nil {
+		eventChannel <- processClnResponse(resp, npReq, reqId)
+	}
+	return nil
+}
+
+func getClnFailureReason(code int) lnrpc.PaymentFailureReason {
+	switch code {
+	case clnPayNoRouteFound:
+		return lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE
+	case clnPayDestinationPermFail:
+		return lnrpc.PaymentFailureReason_FAILURE_REASON_INCORRECT_PAYMENT_DETAILS
+	case clnPayInvoiceExpired, clnPayStoppedRetrying:
+		return lnrpc.PaymentFailureReason_FAILURE_REASON_TIMEOUT
+	}
+	return lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR
+}
+
+func processClnResponse(p cln_connect.PayResponse, req commons.NewPaymentRequest, reqId string) commons.NewPaymentResponse {
+	status := lnrpc.Payment_IN_FLIGHT
+	switch p.Status {
+	case "complete":
+		status = lnrpc.Payment_SUCCEEDED
+	case "failed":
+		status = lnrpc.Payment_FAILED
+	}
+	r := commons.NewPaymentResponse{
+		ReqId:        reqId,
+		Request:      req,
+		Status:       status.String(),
+		Hash:         p.PaymentHash,
+		Preimage:     p.PaymentPreimage,
+		AmountMsat:   int64(p.AmountMsat),
+		CreationDate: time.Unix(0, int64(p.CreatedAt*float64(time.Second))),
+		FeePaidMsat:  int64(p.AmountSentMsat) - int64(p.AmountMsat),
+	}
+	if req.Invoice != nil {
+		r.PaymentRequest = *req.Invoice
+	}
+	if status == lnrpc.Payment_SUCCEEDED {
+		r.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_NONE.String()
+	}
+	return r
+}
+
+func (actions clnPaymentActions) sendPayment(ctx context.Context, npReq commons.NewPaymentRequest,
+	eventChannel chan interface{}, reqId string) error {
+	return sendPaymentCln(ctx, actions.client, npReq, eventChannel, reqId)
+}
@@ -15,9 +15,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 
-	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 )
 
 type rrpcClientSendPayment interface {
@@ -45,20 +43,16 @@ func SendNewPayment(
 		return errors.New("Node id is missing")
 	}
 
-	connectionDetails, err := settings.GetConnectionDetailsById(db, npReq.NodeId)
+	actions, err := getPaymentActions(db, npReq.NodeId)
 	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
-	}
-	conn, err := lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
-	if err != nil {
-		return errors.Wrap(err, "Getting node connection details from the db")
+		return err
 	}
-	defer conn.Close()
-	client := routerrpc.NewRouterClient(conn)
-	return sendPayment(client, npReq, eventChannel, reqId)
+	return actions.sendPayment(context.Background(), npReq, eventChannel, reqId)
+}
+
+func (actions lndPaymentActions) sendPayment(ctx context.Context, npReq commons.NewPaymentRequest,
+	eventChannel chan interface{}, reqId string) error {
+	return sendPayment(ctx, routerrpc.NewRouterClient(actions.conn), npReq, eventChannel, reqId)
 }
 
 func newSendPaymentRequest(npReq commons.NewPaymentRequest) (r *routerrpc.SendPaymentRequest, err error) {
@@ -96,7 +90,7 @@ func newSendPaymentRequest(npReq commons.NewPaymentRequest) (r *routerrpc.SendPa
 	return newPayReq, nil
 }
 
-func sendPayment(client rrpcClientSendPayment, npReq commons.NewPaymentRequest, eventChannel chan interface{}, reqId string) (err error) {
+func sendPayment(ctx context.Context, client rrpcClientSendPayment, npReq commons.NewPaymentRequest, eventChannel chan interface{}, reqId string) (err error) {
 
 	// Create and validate payment request details
 	newPayReq, err := newSendPaymentRequest(npReq)
@@ -104,7 +98,6 @@ func sendPayment(client rrpcClientSendPayment, npReq commons.NewPaymentRequest,
 		return err
 	}
 
-	ctx := context.Background()
 	req, err := client.SendPaymentV2(ctx, newPayReq)
 	if err != nil {
 		return errors.Wrap(err, "Sending payment")
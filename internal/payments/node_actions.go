@@ -0,0 +1,40 @@
+package payments
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// paymentActions the payment actions of a node, implemented per node implementation
+type paymentActions interface {
+	sendPayment(ctx context.Context, npReq commons.NewPaymentRequest, eventChannel chan interface{}, reqId string) error
+}
+
+type lndPaymentActions struct {
+	conn *grpc.ClientConn
+}
+
+type clnPaymentActions struct {
+	client cln_connect.RpcClient
+}
+
+func getPaymentActions(db *sqlx.DB, nodeId int) (paymentActions, error) {
+	connection, err := settings.GetNodeConnection(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Connecting to the node")
+	}
+	switch connection := connection.(type) {
+	case settings.LndConnection:
+		return lndPaymentActions{conn: connection.Conn}, nil
+	case settings.ClnConnection:
+		return clnPaymentActions{client: connection.Client}, nil
+	}
+	return nil, errors.Newf("Payments are not supported for node %v", nodeId)
+}
@@ -0,0 +1,162 @@
+package peers
+
+import (
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type peerChannelFee struct {
+	PeerNodeId       int    `db:"peer_node_id"`
+	Disabled         bool   `db:"disabled"`
+	FeeBaseMsat      uint64 `db:"fee_base_msat"`
+	FeeRateMilliMsat uint64 `db:"fee_rate_mill_msat"`
+}
+
+type FeeDistribution struct {
+	Min    uint64  `json:"min"`
+	P25    uint64  `json:"p25"`
+	Median uint64  `json:"median"`
+	P75    uint64  `json:"p75"`
+	Max    uint64  `json:"max"`
+	Mean   float64 `json:"mean"`
+}
+
+type LocalChannelFee struct {
+	ChannelId        int    `json:"channelId"`
+	FeeBaseMsat      uint64 `json:"feeBaseMsat"`
+	FeeRateMilliMsat uint64 `json:"feeRateMilliMsat"`
+}
+
+// PeerFeeDistribution the outbound fees the peer charges on its channels with other nodes. Only the latest policy of
+// every channel is used and disabled channels are left out of the distributions.
+type PeerFeeDistribution struct {
+	NodeId     int `json:"nodeId"`
+	PeerNodeId int `json:"peerNodeId"`
+	// ChannelCount the enabled channels of the peer with a stored policy
+	ChannelCount         int `json:"channelCount"`
+	DisabledChannelCount int `json:"disabledChannelCount"`
+	// FeeRateMilliMsat and FeeBaseMsat are nil when all the channels are disabled
+	FeeRateMilliMsat *FeeDistribution `json:"feeRateMilliMsat"`
+	FeeBaseMsat      *FeeDistribution `json:"feeBaseMsat"`
+	// LocalChannels our fees on the channels with the peer
+	LocalChannels []LocalChannelFee `json:"localChannels"`
+	// LocalFeeRateRank the share of the enabled channels of the peer that charge less than our highest fee rate
+	// towards the peer, nil without enabled channels
+	LocalFeeRateRank *float64 `json:"localFeeRateRank"`
+}
+
+// GetPeerFeeDistributions the fee distributions of the peers of the node, by peer node id. Peer channel policies are
+// only stored when the node has opted in with commons.StorePeerChannelPolicies.
+func GetPeerFeeDistributions(db *sqlx.DB, nodeId int) (map[int]PeerFeeDistribution, error) {
+	localChannelsByPeer := make(map[int][]LocalChannelFee)
+	for _, channelState := range commons.GetChannelStates(nodeId, true) {
+		localChannelsByPeer[channelState.RemoteNodeId] = append(localChannelsByPeer[channelState.RemoteNodeId],
+			LocalChannelFee{
+				ChannelId:        channelState.ChannelId,
+				FeeBaseMsat:      channelState.LocalFeeBaseMsat,
+				FeeRateMilliMsat: channelState.LocalFeeRateMilliMsat,
+			})
+	}
+	// Only the current peers are reported, the policies of former peers are no longer updated
+	peerNodeIds := []int64{}
+	for peerNodeId := range localChannelsByPeer {
+		peerNodeIds = append(peerNodeIds, int64(peerNodeId))
+	}
+	var fees []peerChannelFee
+	err := db.Select(&fees, `
+		SELECT peer_node_id, disabled, fee_base_msat, fee_rate_mill_msat
+		FROM (
+			SELECT DISTINCT ON (lnd_short_channel_id, peer_node_id)
+				peer_node_id, disabled, fee_base_msat, fee_rate_mill_msat
+			FROM peer_channel_policy
+			WHERE node_id = $1 AND peer_node_id = ANY($2)
+			ORDER BY lnd_short_channel_id, peer_node_id, ts DESC
+		) AS latest;`, nodeId, pq.Array(peerNodeIds))
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	feesByPeer := make(map[int][]peerChannelFee)
+	for _, fee := range fees {
+		feesByPeer[fee.PeerNodeId] = append(feesByPeer[fee.PeerNodeId], fee)
+	}
+	result := make(map[int]PeerFeeDistribution, len(feesByPeer))
+	for peerNodeId, peerFees := range feesByPeer {
+		distribution := computePeerFeeDistribution(peerFees, localChannelsByPeer[peerNodeId])
+		distribution.NodeId = nodeId
+		distribution.PeerNodeId = peerNodeId
+		result[peerNodeId] = distribution
+	}
+	return result, nil
+}
+
+func computePeerFeeDistribution(fees []peerChannelFee, localChannels []LocalChannelFee) PeerFeeDistribution {
+	distribution := PeerFeeDistribution{LocalChannels: localChannels}
+	if distribution.LocalChannels == nil {
+		distribution.LocalChannels = []LocalChannelFee{}
+	}
+	var feeRates []uint64
+	var feeBases []uint64
+	for _, fee := range fees {
+		if fee.Disabled {
+			distribution.DisabledChannelCount++
+			continue
+		}
+		feeRates = append(feeRates, fee.FeeRateMilliMsat)
+		feeBases = append(feeBases, fee.FeeBaseMsat)
+	}
+	distribution.ChannelCount = len(feeRates)
+	if len(feeRates) == 0 {
+		return distribution
+	}
+	distribution.FeeRateMilliMsat = computeFeeDistribution(feeRates)
+	distribution.FeeBaseMsat = computeFeeDistribution(feeBases)
+	if len(localChannels) != 0 {
+		var localFeeRate uint64
+		for _, localChannel := range localChannels {
+			if localChannel.FeeRateMilliMsat > localFeeRate {
+				localFeeRate = localChannel.FeeRateMilliMsat
+			}
+		}
+		cheaper := 0
+		for _, feeRate := range feeRates {
+			if feeRate < localFeeRate {
+				cheaper++
+			}
+		}
+		rank := float64(cheaper) / float64(len(feeRates))
+		distribution.LocalFeeRateRank = &rank
+	}
+	return distribution
+}
+
+// computeFeeDistribution the percentiles use the nearest rank
+func computeFeeDistribution(values []uint64) *FeeDistribution {
+	sorted := make([]uint64, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum float64
+	for _, value := range sorted {
+		sum += float64(value)
+	}
+	percentile := func(p int) uint64 {
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+	return &FeeDistribution{
+		Min:    sorted[0],
+		P25:    percentile(25),
+		Median: percentile(50),
+		P75:    percentile(75),
+		Max:    sorted[len(sorted)-1],
+		Mean:   sum / float64(len(sorted)),
+	}
+}
@@ -0,0 +1,35 @@
+package peers
+
+import (
+	"testing"
+)
+
+func TestComputePeerFeeDistribution(t *testing.T) {
+	fees := []peerChannelFee{
+		{FeeRateMilliMsat: 500, FeeBaseMsat: 1000},
+		{FeeRateMilliMsat: 100, FeeBaseMsat: 0},
+		{FeeRateMilliMsat: 300, FeeBaseMsat: 1000},
+		{FeeRateMilliMsat: 200, FeeBaseMsat: 0},
+		{FeeRateMilliMsat: 5000, Disabled: true},
+	}
+	distribution := computePeerFeeDistribution(fees, []LocalChannelFee{{ChannelId: 1, FeeRateMilliMsat: 250}})
+	if distribution.ChannelCount != 4 || distribution.DisabledChannelCount != 1 {
+		t.Fatalf("unexpected channel counts %+v", distribution)
+	}
+	feeRate := distribution.FeeRateMilliMsat
+	if feeRate.Min != 100 || feeRate.P25 != 100 || feeRate.Median != 200 || feeRate.P75 != 300 ||
+		feeRate.Max != 500 || feeRate.Mean != 275 {
+		t.Errorf("unexpected fee rate distribution %+v", feeRate)
+	}
+	if distribution.FeeBaseMsat.Median != 0 || distribution.FeeBaseMsat.Max != 1000 {
+		t.Errorf("unexpected fee base distribution %+v", distribution.FeeBaseMsat)
+	}
+	if distribution.LocalFeeRateRank == nil || *distribution.LocalFeeRateRank != 0.5 {
+		t.Errorf("expected half of the peer channels to be cheaper got %v", distribution.LocalFeeRateRank)
+	}
+
+	distribution = computePeerFeeDistribution([]peerChannelFee{{Disabled: true}}, nil)
+	if distribution.FeeRateMilliMsat != nil || distribution.LocalFeeRateRank != nil || distribution.LocalChannels == nil {
+		t.Errorf("expected no distribution for only disabled channels got %+v", distribution)
+	}
+}
@@ -38,6 +38,8 @@ type peer struct {
 	FlapCount       int32              `json:"flapCount"`
 	LastFlapNs      int64              `json:"lastFlapNs"`
 	LastPingPayload []byte             `json:"lastPingPayload"`
+	// Uptime over the last DefaultUptimeDays from the stored peer events
+	Uptime *PeerUptime `json:"uptime"`
 }
 
 func ListPeers(client lnrpc.LightningClient, ctx context.Context, latestErr string) (r []peer, err error) {
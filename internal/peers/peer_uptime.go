@@ -0,0 +1,191 @@
+package peers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const DefaultUptimeDays = 7
+
+type peerEvent struct {
+	EventNodeId int       `db:"event_node_id"`
+	Time        time.Time `db:"time"`
+	EventType   string    `db:"event_type"`
+}
+
+type PeerUptime struct {
+	NodeId      int       `json:"nodeId"`
+	EventNodeId int       `json:"eventNodeId"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	// UptimePercent of the observed time, nil when the peer was never observed
+	UptimePercent *float64 `json:"uptimePercent"`
+	// ObservedSeconds the part of the window after the first stored event of the peer
+	ObservedSeconds      int64 `json:"observedSeconds"`
+	Disconnects          int64 `json:"disconnects"`
+	LongestOutageSeconds int64 `json:"longestOutageSeconds"`
+	// Online the state at the end of the window, nil when the peer was never observed
+	Online *bool `json:"online"`
+}
+
+type cachedPeerUptimes struct {
+	uptimes map[int]PeerUptime
+	on      time.Time
+}
+
+var (
+	peerUptimeCacheMutex sync.Mutex                        //nolint:gochecknoglobals
+	peerUptimeCache      = make(map[int]cachedPeerUptimes) //nolint:gochecknoglobals
+)
+
+// GetCachedPeerUptimes the uptime of the peers over the last DefaultUptimeDays, the uptime is only computed again
+// when the cached result is older than PEER_UPTIME_CACHE_SECONDS
+func GetCachedPeerUptimes(db *sqlx.DB, nodeId int) (map[int]PeerUptime, error) {
+	peerUptimeCacheMutex.Lock()
+	cached, exists := peerUptimeCache[nodeId]
+	peerUptimeCacheMutex.Unlock()
+	if exists && time.Since(cached.on) < commons.PEER_UPTIME_CACHE_SECONDS*time.Second {
+		return cached.uptimes, nil
+	}
+	to := time.Now().UTC()
+	uptimes, err := GetPeerUptimes(db, nodeId, to.AddDate(0, 0, -DefaultUptimeDays), to)
+	if err != nil {
+		return nil, err
+	}
+	peerUptimeCacheMutex.Lock()
+	peerUptimeCache[nodeId] = cachedPeerUptimes{uptimes: uptimes, on: time.Now()}
+	peerUptimeCacheMutex.Unlock()
+	return uptimes, nil
+}
+
+// GetPeerUptimes the uptime of every peer of the node that has stored events, by peer node id
+func GetPeerUptimes(db *sqlx.DB, nodeId int, from time.Time, to time.Time) (map[int]PeerUptime, error) {
+	events, err := getPeerEvents(db, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "Obtaining peer events")
+	}
+	eventsByPeer := make(map[int][]peerEvent)
+	for _, event := range events {
+		eventsByPeer[event.EventNodeId] = append(eventsByPeer[event.EventNodeId], event)
+	}
+	result := make(map[int]PeerUptime, len(eventsByPeer))
+	for eventNodeId, peerEvents := range eventsByPeer {
+		uptime := computePeerUptime(peerEvents, from, to)
+		uptime.NodeId = nodeId
+		uptime.EventNodeId = eventNodeId
+		result[eventNodeId] = uptime
+	}
+	return result, nil
+}
+
+// computePeerUptime events are ordered by time and can start with the last event before the window.
+// The time before the first event is not observed so it doesn't count as uptime nor as outage.
+// Repeated events of the same type are ignored.
+func computePeerUptime(events []peerEvent, from time.Time, to time.Time) PeerUptime {
+	uptime := PeerUptime{From: from, To: to}
+	var online *bool
+	var cursor time.Time
+	var onlineDuration, observedDuration, outageDuration, longestOutage time.Duration
+
+	// advance accounts the time between the cursor and the given time to the current state
+	advance := func(till time.Time) {
+		if online == nil || !till.After(cursor) {
+			return
+		}
+		duration := till.Sub(cursor)
+		observedDuration += duration
+		if *online {
+			onlineDuration += duration
+		} else {
+			outageDuration += duration
+			if outageDuration > longestOutage {
+				longestOutage = outageDuration
+			}
+		}
+		cursor = till
+	}
+
+	for _, event := range events {
+		if event.Time.After(to) {
+			break
+		}
+		eventTime := event.Time
+		if eventTime.Before(from) {
+			eventTime = from
+		}
+		isOnline := event.EventType == lnrpc.PeerEvent_PEER_ONLINE.String()
+		advance(eventTime)
+		if online == nil {
+			cursor = eventTime
+		} else if *online == isOnline {
+			continue
+		}
+		if !isOnline {
+			outageDuration = 0
+			if !event.Time.Before(from) && online != nil {
+				uptime.Disconnects++
+			}
+		}
+		online = &isOnline
+	}
+	advance(to)
+
+	uptime.Online = online
+	uptime.ObservedSeconds = int64(observedDuration.Seconds())
+	uptime.LongestOutageSeconds = int64(longestOutage.Seconds())
+	if observedDuration > 0 {
+		uptimePercent := onlineDuration.Seconds() / observedDuration.Seconds() * 100
+		uptime.UptimePercent = &uptimePercent
+	}
+	return uptime
+}
+
+// getPeerEvents the events of the window ordered by time, preceded by the last event before the window per peer
+func getPeerEvents(db *sqlx.DB, nodeId int, from time.Time, to time.Time) ([]peerEvent, error) {
+	var events []peerEvent
+	err := db.Select(&events, `
+		SELECT event_node_id, time, event_type
+		FROM (
+			SELECT DISTINCT ON (event_node_id) event_node_id, time, event_type
+			FROM peer_event
+			WHERE node_id = $1 AND time < $2
+			ORDER BY event_node_id, time DESC
+		) AS before_window
+		UNION ALL
+		SELECT event_node_id, time, event_type
+		FROM peer_event
+		WHERE node_id = $1 AND time >= $2 AND time < $3
+		ORDER BY time;`, nodeId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return events, nil
+}
+
+// GetOfflinePeers the peers of the node that are offline according to their last stored event, with the time they
+// went offline, by peer node id
+func GetOfflinePeers(db *sqlx.DB, nodeId int) (map[int]time.Time, error) {
+	var events []peerEvent
+	err := db.Select(&events, `
+		SELECT DISTINCT ON (event_node_id) event_node_id, time, event_type
+		FROM peer_event
+		WHERE node_id = $1
+		ORDER BY event_node_id, time DESC;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	result := make(map[int]time.Time)
+	for _, event := range events {
+		if event.EventType == lnrpc.PeerEvent_PEER_OFFLINE.String() {
+			result[event.EventNodeId] = event.Time
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package peers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputePeerUptime(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(100 * time.Hour)
+	online := "PEER_ONLINE"
+	offline := "PEER_OFFLINE"
+	tests := []struct {
+		name                 string
+		events               []peerEvent
+		uptimePercent        *float64
+		observedSeconds      int64
+		disconnects          int64
+		longestOutageSeconds int64
+		online               *bool
+	}{
+		{name: "no events"},
+		{
+			name:                 "offline before the window",
+			events:               []peerEvent{{Time: from.Add(-time.Hour), EventType: offline}},
+			uptimePercent:        floatPointer(0),
+			observedSeconds:      100 * 3600,
+			longestOutageSeconds: 100 * 3600,
+			online:               boolPointer(false),
+		},
+		{
+			name: "disconnects during the window",
+			events: []peerEvent{
+				{Time: from.Add(-time.Hour), EventType: online},
+				{Time: from.Add(10 * time.Hour), EventType: offline},
+				{Time: from.Add(20 * time.Hour), EventType: online},
+				{Time: from.Add(50 * time.Hour), EventType: offline},
+				{Time: from.Add(80 * time.Hour), EventType: online},
+			},
+			uptimePercent:        floatPointer(60),
+			observedSeconds:      100 * 3600,
+			disconnects:          2,
+			longestOutageSeconds: 30 * 3600,
+			online:               boolPointer(true),
+		},
+		{
+			name: "first observed during the window",
+			events: []peerEvent{
+				{Time: from.Add(50 * time.Hour), EventType: online},
+				{Time: from.Add(75 * time.Hour), EventType: offline},
+			},
+			uptimePercent:        floatPointer(50),
+			observedSeconds:      50 * 3600,
+			disconnects:          1,
+			longestOutageSeconds: 25 * 3600,
+			online:               boolPointer(false),
+		},
+		{
+			name: "repeated events",
+			events: []peerEvent{
+				{Time: from.Add(-time.Hour), EventType: offline},
+				{Time: from.Add(40 * time.Hour), EventType: offline},
+				{Time: from.Add(60 * time.Hour), EventType: online},
+				{Time: from.Add(70 * time.Hour), EventType: online},
+			},
+			uptimePercent:        floatPointer(40),
+			observedSeconds:      100 * 3600,
+			longestOutageSeconds: 60 * 3600,
+			online:               boolPointer(true),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := computePeerUptime(test.events, from, to)
+			if (result.UptimePercent == nil) != (test.uptimePercent == nil) ||
+				(result.UptimePercent != nil && math.Abs(*result.UptimePercent-*test.uptimePercent) > 0.0001) {
+				t.Errorf("expected uptime %v, got %v", test.uptimePercent, result.UptimePercent)
+			}
+			if result.ObservedSeconds != test.observedSeconds {
+				t.Errorf("expected %v observed seconds, got %v", test.observedSeconds, result.ObservedSeconds)
+			}
+			if result.Disconnects != test.disconnects {
+				t.Errorf("expected %v disconnects, got %v", test.disconnects, result.Disconnects)
+			}
+			if result.LongestOutageSeconds != test.longestOutageSeconds {
+				t.Errorf("expected a longest outage of %v seconds, got %v", test.longestOutageSeconds,
+					result.LongestOutageSeconds)
+			}
+			if (result.Online == nil) != (test.online == nil) || (result.Online != nil && *result.Online != *test.online) {
+				t.Errorf("expected online %v, got %v", test.online, result.Online)
+			}
+		})
+	}
+}
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func boolPointer(value bool) *bool {
+	return &value
+}
@@ -2,8 +2,11 @@ package peers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
@@ -12,8 +15,9 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 
+	"github.com/lncapital/torq/internal/audit"
 	"github.com/lncapital/torq/internal/settings"
-	"github.com/lncapital/torq/pkg/lnd_connect"
+	"github.com/lncapital/torq/pkg/commons"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -32,6 +36,110 @@ type ConnectPeerRequest struct {
 func RegisterPeerRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("", func(c *gin.Context) { listPeersHandler(c, db) })
 	r.POST("", func(c *gin.Context) { connectPeerHandler(c, db) })
+	// i.e. uptime?nodeId=1&from=2023-01-01&to=2023-02-01
+	r.GET("uptime", func(c *gin.Context) { getPeerUptimeHandler(c, db) })
+	// i.e. fee-distribution?nodeId=1
+	r.GET("fee-distribution", func(c *gin.Context) { getPeerFeeDistributionHandler(c, db) })
+}
+
+func getPeerFeeDistributionHandler(c *gin.Context, db *sqlx.DB) {
+	nodeIds := commons.GetAllActiveTorqNodeIds(nil, nil)
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequestFromError(c, errors.Wrap(err, "Getting node id"))
+			return
+		}
+		nodeIds = []int{nodeId}
+	}
+	result := []PeerFeeDistribution{}
+	for _, nodeId := range nodeIds {
+		distributions, err := GetPeerFeeDistributions(db, nodeId)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting peer fee distribution for nodeId: %v", nodeId))
+			return
+		}
+		for _, distribution := range distributions {
+			result = append(result, distribution)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].NodeId != result[j].NodeId {
+			return result[i].NodeId < result[j].NodeId
+		}
+		return result[i].PeerNodeId < result[j].PeerNodeId
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+func getPeerUptimeHandler(c *gin.Context, db *sqlx.DB) {
+	from, to, err := getUptimeWindow(c)
+	if err != nil {
+		server_errors.SendBadRequestFromError(c, err)
+		return
+	}
+	nodeIds := commons.GetAllActiveTorqNodeIds(nil, nil)
+	if c.Query("nodeId") != "" {
+		nodeId, err := strconv.Atoi(c.Query("nodeId"))
+		if err != nil {
+			server_errors.SendBadRequestFromError(c, errors.Wrap(err, "Getting node id"))
+			return
+		}
+		nodeIds = []int{nodeId}
+	}
+	result := []PeerUptime{}
+	for _, nodeId := range nodeIds {
+		uptimes, err := GetPeerUptimes(db, nodeId, from, to)
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting peer uptime for nodeId: %v", nodeId))
+			return
+		}
+		for _, uptime := range uptimes {
+			result = append(result, uptime)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].NodeId != result[j].NodeId {
+			return result[i].NodeId < result[j].NodeId
+		}
+		return result[i].EventNodeId < result[j].EventNodeId
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// getUptimeWindow from and to are dates or RFC3339 times, by default the window is the last DefaultUptimeDays
+func getUptimeWindow(c *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if c.Query("to") != "" {
+		var err error
+		to, err = parseUptimeTime(c.Query("to"))
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.Wrap(err, "Parsing to")
+		}
+	}
+	from := to.AddDate(0, 0, -DefaultUptimeDays)
+	if c.Query("from") != "" {
+		var err error
+		from, err = parseUptimeTime(c.Query("from"))
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.Wrap(err, "Parsing from")
+		}
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from should be before to")
+	}
+	return from, to, nil
+}
+
+func parseUptimeTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "Parsing time")
+	}
+	return parsed, nil
 }
 
 func connectPeerHandler(c *gin.Context, db *sqlx.DB) {
@@ -54,12 +162,18 @@ func connectPeerHandler(c *gin.Context, db *sqlx.DB) {
 		server_errors.WrapLogAndSendServerError(c, err, "can't connect to LND")
 		return
 	}
-	defer conn.Close()
 
 	client := lnrpc.NewLightningClient(conn)
 	ctx := context.Background()
 
+	auditEvent, err := audit.Start(db, audit.ContextActor(c, ""), audit.ConnectPeer, requestBody.NodeId, nil,
+		requestBody)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Recording audit event")
+		return
+	}
 	resp, err := ConnectPeer(client, ctx, requestBody)
+	audit.Finish(db, auditEvent, resp, err)
 	if err != nil {
 		server_errors.WrapLogAndSendServerError(c, err, "LND")
 		return
@@ -85,7 +199,6 @@ func listPeersHandler(c *gin.Context, db *sqlx.DB) {
 		server_errors.WrapLogAndSendServerError(c, err, "Connecting to LND")
 		return
 	}
-	defer conn.Close()
 
 	client := lnrpc.NewLightningClient(conn)
 	ctx := context.Background()
@@ -96,24 +209,27 @@ func listPeersHandler(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
+	to := time.Now().UTC()
+	uptimes, err := GetPeerUptimes(db, nodeId, to.AddDate(0, 0, -DefaultUptimeDays), to)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting peer uptime")
+		return
+	}
+	nodeSettings := commons.GetNodeSettingsByNodeId(nodeId)
+	for i := range resp {
+		eventNodeId := commons.GetNodeIdByPublicKey(resp[i].PubKey, nodeSettings.Chain, nodeSettings.Network)
+		if uptime, exists := uptimes[eventNodeId]; exists {
+			resp[i].Uptime = &uptime
+		}
+	}
+
 	c.JSON(http.StatusOK, resp)
 }
 
 func connectLND(db *sqlx.DB, nodeId int) (conn *grpc.ClientConn, err error) {
-	connectionDetails, err := settings.GetConnectionDetailsById(db, nodeId)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "Getting node connection details from the db")
-	}
-
-	conn, err = lnd_connect.Connect(
-		connectionDetails.GRPCAddress,
-		connectionDetails.TLSFileBytes,
-		connectionDetails.MacaroonFileBytes)
+	conn, err = settings.GetLndConnection(db, nodeId)
 	if err != nil {
 		return nil, errors.Wrap(err, "Connecting to LND")
 	}
-
 	return conn, nil
-
 }
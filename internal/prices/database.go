@@ -0,0 +1,81 @@
+package prices
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getFiatPrices(db *sqlx.DB, currency string, from time.Time, to time.Time) ([]FiatPrice, error) {
+	var fiatPrices []FiatPrice
+	err := db.Select(&fiatPrices, `
+		SELECT * FROM fiat_price
+		WHERE currency=$1 AND time >= $2 AND time < $3
+		ORDER BY time;`, currency, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return fiatPrices, nil
+}
+
+func getCurrencies(db *sqlx.DB) ([]string, error) {
+	var currencies []string
+	err := db.Select(&currencies, `SELECT DISTINCT currency FROM fiat_price ORDER BY currency;`)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return currencies, nil
+}
+
+func isKnownCurrency(db *sqlx.DB, currency string) (bool, error) {
+	var exists bool
+	err := db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM fiat_price WHERE currency=$1);`, currency)
+	if err != nil {
+		return false, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return exists, nil
+}
+
+// addFiatPrices an existing price for the same currency and time is replaced
+func addFiatPrices(db *sqlx.DB, fiatPrices []FiatPrice) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	now := time.Now().UTC()
+	for _, fiatPrice := range fiatPrices {
+		_, err = tx.Exec(`
+			INSERT INTO fiat_price (currency, time, price, source, created_on, updated_on)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			ON CONFLICT (currency, time) DO UPDATE SET price=EXCLUDED.price, source=EXCLUDED.source,
+				updated_on=EXCLUDED.updated_on;`,
+			fiatPrice.Currency, fiatPrice.Time, fiatPrice.Price, fiatPrice.Source, now)
+		if err != nil {
+			if rb := tx.Rollback(); rb != nil {
+				log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+			}
+			return errors.Wrap(err, database.SqlExecutionError)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return nil
+}
+
+func removeFiatPrices(db *sqlx.DB, currency string) (int64, error) {
+	res, err := db.Exec(`DELETE FROM fiat_price WHERE currency=$1;`, currency)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,141 @@
+package prices
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const csvSource = "csv"
+
+var currencyRegex = regexp.MustCompile("^[A-Z]{3,5}$") //nolint:gochecknoglobals
+
+// FiatPrice Price is the price of one BTC in the Currency
+type FiatPrice struct {
+	Currency  string    `json:"currency" db:"currency"`
+	Time      time.Time `json:"time" db:"time"`
+	Price     float64   `json:"price" db:"price"`
+	Source    string    `json:"source" db:"source"`
+	CreatedOn time.Time `json:"createdOn" db:"created_on"`
+	UpdatedOn time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+type FiatPriceImportResponse struct {
+	Currencies []string `json:"currencies"`
+	Count      int      `json:"count"`
+}
+
+// FiatPriceSql returns the sql expression of the price of one BTC at (or before) the time in the currency parameter.
+// The result is NULL when the currency parameter is NULL or when no price is known yet.
+func FiatPriceSql(timeExpression string, currencyParameter string) string {
+	return fmt.Sprintf(`(
+		SELECT fp.price FROM fiat_price fp
+		WHERE fp.currency = %v AND fp.time <= %v
+		ORDER BY fp.time DESC LIMIT 1)`, currencyParameter, timeExpression)
+}
+
+// FiatAmountSql returns the sql expression that converts the msat amount to fiat with the price at the time
+func FiatAmountSql(amountMsatExpression string, timeExpression string, currencyParameter string) string {
+	return fmt.Sprintf("(%v * %v / 100000000000)", amountMsatExpression,
+		FiatPriceSql(timeExpression, currencyParameter))
+}
+
+func NormaliseCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !currencyRegex.MatchString(currency) {
+		return "", errors.Newf("Invalid currency: %v", currency)
+	}
+	return currency, nil
+}
+
+// parsePriceCsv the csv requires a header with the columns time and price and optionally currency.
+// When the currency column is missing the defaultCurrency is used.
+// Time is either RFC3339, yyyy-mm-dd (UTC) or a unix timestamp in seconds.
+func parsePriceCsv(r io.Reader, defaultCurrency string) ([]FiatPrice, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "Reading the csv header")
+	}
+	timeIndex, priceIndex, currencyIndex := -1, -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "time", "date", "timestamp":
+			timeIndex = i
+		case "price":
+			priceIndex = i
+		case "currency":
+			currencyIndex = i
+		}
+	}
+	if timeIndex == -1 || priceIndex == -1 {
+		return nil, errors.New("The csv header requires a time and a price column")
+	}
+	if currencyIndex == -1 && defaultCurrency == "" {
+		return nil, errors.New("The csv requires a currency column or a currency parameter")
+	}
+
+	var fiatPrices []FiatPrice
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, errors.Wrapf(err, "Reading csv line %v", line)
+		}
+		fiatPrice := FiatPrice{Currency: defaultCurrency, Source: csvSource}
+		if currencyIndex != -1 {
+			fiatPrice.Currency, err = NormaliseCurrency(record[currencyIndex])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Parsing currency on csv line %v", line)
+			}
+		}
+		fiatPrice.Time, err = parsePriceTime(record[timeIndex])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Parsing time on csv line %v", line)
+		}
+		fiatPrice.Price, err = strconv.ParseFloat(strings.TrimSpace(record[priceIndex]), 64)
+		if err != nil || fiatPrice.Price <= 0 {
+			return nil, errors.Newf("Invalid price on csv line %v", line)
+		}
+		fiatPrices = append(fiatPrices, fiatPrice)
+	}
+	return fiatPrices, nil
+}
+
+func parsePriceTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Newf("Unknown time format: %v", value)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+func getImportedCurrencies(fiatPrices []FiatPrice) []string {
+	var currencies []string
+	known := make(map[string]bool)
+	for _, fiatPrice := range fiatPrices {
+		if !known[fiatPrice.Currency] {
+			known[fiatPrice.Currency] = true
+			currencies = append(currencies, fiatPrice.Currency)
+		}
+	}
+	return currencies
+}
@@ -0,0 +1,114 @@
+package prices
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormaliseCurrency(t *testing.T) {
+	currency, err := NormaliseCurrency(" eur ")
+	if err != nil || currency != "EUR" {
+		t.Errorf("expected EUR, got %v (%v)", currency, err)
+	}
+	for _, invalid := range []string{"", "E", "EURO12", "E-R", "'; DROP TABLE"} {
+		if _, err := NormaliseCurrency(invalid); err == nil {
+			t.Errorf("expected %q to be an invalid currency", invalid)
+		}
+	}
+}
+
+func TestParsePriceCsv(t *testing.T) {
+	fiatPrices, err := parsePriceCsv(strings.NewReader(
+		"date,price\n2022-11-01,20000.5\n2022-11-02T12:00:00Z,21000\n1667433600,22000\n"), "eur")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fiatPrices) != 3 {
+		t.Fatalf("expected 3 prices, got %v", len(fiatPrices))
+	}
+	expectedTimes := []time.Time{
+		time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC),
+		time.Date(2022, 11, 3, 0, 0, 0, 0, time.UTC),
+	}
+	for i, fiatPrice := range fiatPrices {
+		if !fiatPrice.Time.Equal(expectedTimes[i]) {
+			t.Errorf("line %v expected time %v, got %v", i+2, expectedTimes[i], fiatPrice.Time)
+		}
+		if fiatPrice.Source != csvSource {
+			t.Errorf("line %v expected source %v, got %v", i+2, csvSource, fiatPrice.Source)
+		}
+	}
+	if fiatPrices[0].Price != 20000.5 || fiatPrices[0].Currency != "eur" {
+		t.Errorf("unexpected first price %+v", fiatPrices[0])
+	}
+
+	fiatPrices, err = parsePriceCsv(strings.NewReader(
+		"currency,time,price\nusd,2022-11-01,20000\nEUR,2022-11-01,19000\n"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	currencies := getImportedCurrencies(fiatPrices)
+	if len(currencies) != 2 || currencies[0] != "USD" || currencies[1] != "EUR" {
+		t.Errorf("expected the currencies USD and EUR, got %v", currencies)
+	}
+}
+
+func TestParsePriceCsvErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		csv             string
+		defaultCurrency string
+	}{
+		{"missing price column", "time,value\n2022-11-01,20000\n", "EUR"},
+		{"missing currency", "time,price\n2022-11-01,20000\n", ""},
+		{"invalid time", "time,price\nyesterday,20000\n", "EUR"},
+		{"invalid price", "time,price\n2022-11-01,abc\n", "EUR"},
+		{"negative price", "time,price\n2022-11-01,-1\n", "EUR"},
+		{"invalid currency", "currency,time,price\neuro1,2022-11-01,20000\n", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parsePriceCsv(strings.NewReader(test.csv), test.defaultCurrency); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestCoinGeckoProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coins/bitcoin/market_chart/range" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("vs_currency") != "eur" || r.URL.Query().Get("from") != "1667260800" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"prices":[[1667260800000,20000.5],[1667347200000,21000]]}`))
+	}))
+	defer server.Close()
+
+	provider := NewCoinGeckoProvider(server.Client(), server.URL+"/")
+	from := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	fiatPrices, err := provider.GetPrices(context.Background(), "EUR", from, from.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fiatPrices) != 2 {
+		t.Fatalf("expected 2 prices, got %v", len(fiatPrices))
+	}
+	if !fiatPrices[0].Time.Equal(from) || fiatPrices[0].Price != 20000.5 || fiatPrices[0].Currency != "EUR" ||
+		fiatPrices[0].Source != provider.Name() {
+		t.Errorf("unexpected first price %+v", fiatPrices[0])
+	}
+
+	if _, err = provider.GetPrices(context.Background(), "USD", from, from.AddDate(0, 0, 2)); err == nil {
+		t.Errorf("expected an error when the provider doesn't return status OK")
+	}
+}
@@ -0,0 +1,73 @@
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const coinGeckoBaseUrl = "https://api.coingecko.com/api/v3"
+
+// PriceProvider obtains historical BTC prices in the currency
+type PriceProvider interface {
+	Name() string
+	GetPrices(ctx context.Context, currency string, from time.Time, to time.Time) ([]FiatPrice, error)
+}
+
+type coinGeckoProvider struct {
+	client  *http.Client
+	baseUrl string
+}
+
+func NewCoinGeckoProvider(client *http.Client, baseUrl string) PriceProvider {
+	if baseUrl == "" {
+		baseUrl = coinGeckoBaseUrl
+	}
+	return coinGeckoProvider{client: client, baseUrl: strings.TrimSuffix(baseUrl, "/")}
+}
+
+func (p coinGeckoProvider) Name() string {
+	return "coingecko"
+}
+
+// GetPrices the granularity depends on the range (5 minutes up to 1 day, hourly up to 90 days, daily beyond)
+func (p coinGeckoProvider) GetPrices(ctx context.Context, currency string, from time.Time,
+	to time.Time) ([]FiatPrice, error) {
+
+	url := fmt.Sprintf("%v/coins/bitcoin/market_chart/range?vs_currency=%v&from=%v&to=%v",
+		p.baseUrl, strings.ToLower(currency), from.Unix(), to.Unix())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating price request")
+	}
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "Requesting prices")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Newf("Price provider returned status code %v", response.StatusCode)
+	}
+	var marketChart struct {
+		// [[unix ms, price], ...]
+		Prices [][2]float64 `json:"prices"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&marketChart); err != nil {
+		return nil, errors.Wrap(err, "Decoding prices")
+	}
+	fiatPrices := make([]FiatPrice, 0, len(marketChart.Prices))
+	for _, price := range marketChart.Prices {
+		fiatPrices = append(fiatPrices, FiatPrice{
+			Currency: currency,
+			Time:     time.UnixMilli(int64(price[0])).UTC(),
+			Price:    price[1],
+			Source:   p.Name(),
+		})
+	}
+	return fiatPrices, nil
+}
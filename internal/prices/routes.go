@@ -0,0 +1,155 @@
+package prices
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+type fetchFiatPricesRequest struct {
+	Currency string    `json:"currency"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+}
+
+func RegisterPriceRoutes(r *gin.RouterGroup, db *sqlx.DB, provider PriceProvider) {
+	r.GET("", func(c *gin.Context) { getFiatPricesHandler(c, db) })
+	r.GET("currencies", func(c *gin.Context) { getCurrenciesHandler(c, db) })
+	// import expects a csv as request body or as multipart file with the name file
+	r.POST("import", func(c *gin.Context) { importFiatPricesHandler(c, db) })
+	r.POST("fetch", func(c *gin.Context) { fetchFiatPricesHandler(c, db, provider) })
+	r.DELETE(":currency", func(c *gin.Context) { removeFiatPricesHandler(c, db) })
+}
+
+// GetCurrencyParameter returns the currency query parameter, nil when not provided.
+// When the message is not empty it should be returned as bad request.
+func GetCurrencyParameter(c *gin.Context, db *sqlx.DB) (*string, string, error) {
+	if c.Query("currency") == "" {
+		return nil, "", nil
+	}
+	currency, err := NormaliseCurrency(c.Query("currency"))
+	if err != nil {
+		return nil, err.Error(), nil
+	}
+	known, err := isKnownCurrency(db, currency)
+	if err != nil {
+		return nil, "", err
+	}
+	if !known {
+		return nil, fmt.Sprintf("No prices available for currency %v, import or fetch them first.", currency), nil
+	}
+	return &currency, "", nil
+}
+
+func getFiatPricesHandler(c *gin.Context, db *sqlx.DB) {
+	currency, err := NormaliseCurrency(c.Query("currency"))
+	if err != nil {
+		server_errors.SendBadRequest(c, err.Error())
+		return
+	}
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Can't process from")
+		return
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Can't process to")
+		return
+	}
+	fiatPrices, err := getFiatPrices(db, currency, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting fiat prices.")
+		return
+	}
+	c.JSON(http.StatusOK, fiatPrices)
+}
+
+func getCurrenciesHandler(c *gin.Context, db *sqlx.DB) {
+	currencies, err := getCurrencies(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting currencies.")
+		return
+	}
+	c.JSON(http.StatusOK, currencies)
+}
+
+func importFiatPricesHandler(c *gin.Context, db *sqlx.DB) {
+	var defaultCurrency string
+	if c.Query("currency") != "" {
+		var err error
+		defaultCurrency, err = NormaliseCurrency(c.Query("currency"))
+		if err != nil {
+			server_errors.SendBadRequest(c, err.Error())
+			return
+		}
+	}
+	var body io.Reader = c.Request.Body
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			server_errors.WrapLogAndSendServerError(c, err, "Opening the uploaded csv.")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	fiatPrices, err := parsePriceCsv(body, defaultCurrency)
+	if err != nil {
+		server_errors.SendUnprocessableEntity(c, err.Error())
+		return
+	}
+	if err = addFiatPrices(db, fiatPrices); err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Storing fiat prices.")
+		return
+	}
+	c.JSON(http.StatusOK, FiatPriceImportResponse{Currencies: getImportedCurrencies(fiatPrices), Count: len(fiatPrices)})
+}
+
+func fetchFiatPricesHandler(c *gin.Context, db *sqlx.DB, provider PriceProvider) {
+	var request fetchFiatPricesRequest
+	if err := c.BindJSON(&request); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	currency, err := NormaliseCurrency(request.Currency)
+	if err != nil {
+		server_errors.SendUnprocessableEntity(c, err.Error())
+		return
+	}
+	if request.From.IsZero() || !request.To.After(request.From) {
+		server_errors.SendUnprocessableEntity(c, "To must be after from.")
+		return
+	}
+	fiatPrices, err := provider.GetPrices(c.Request.Context(), currency, request.From, request.To)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Fetching fiat prices from %v.", provider.Name()))
+		return
+	}
+	if err = addFiatPrices(db, fiatPrices); err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Storing fiat prices.")
+		return
+	}
+	c.JSON(http.StatusOK, FiatPriceImportResponse{Currencies: getImportedCurrencies(fiatPrices), Count: len(fiatPrices)})
+}
+
+func removeFiatPricesHandler(c *gin.Context, db *sqlx.DB) {
+	currency, err := NormaliseCurrency(c.Param("currency"))
+	if err != nil {
+		server_errors.SendBadRequest(c, err.Error())
+		return
+	}
+	count, err := removeFiatPrices(db, currency)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing fiat prices for currency: %v", currency))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v fiat price(s).", count)})
+}
@@ -0,0 +1,123 @@
+package rebalances
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+func getRebalance(db *sqlx.DB, rebalanceId int) (Rebalance, error) {
+	var r Rebalance
+	err := db.Get(&r, `SELECT * FROM rebalance WHERE rebalance_id=$1;`, rebalanceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rebalance{}, nil
+		}
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	r.Results, err = getRebalanceResults(db, rebalanceId)
+	if err != nil {
+		return Rebalance{}, errors.Wrap(err, "Obtaining rebalance results")
+	}
+	return r, nil
+}
+
+func getRebalances(db *sqlx.DB) ([]Rebalance, error) {
+	var rs []Rebalance
+	err := db.Select(&rs, `SELECT * FROM rebalance ORDER BY created_on DESC;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Rebalance{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return rs, nil
+}
+
+func getRebalanceResults(db *sqlx.DB, rebalanceId int) ([]RebalanceResult, error) {
+	var results []RebalanceResult
+	err := db.Select(&results, `
+		SELECT * FROM rebalance_result WHERE rebalance_id=$1 ORDER BY rebalance_result_id;`, rebalanceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []RebalanceResult{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return results, nil
+}
+
+func addRebalance(db *sqlx.DB, r Rebalance) (Rebalance, error) {
+	r.CreatedOn = time.Now().UTC()
+	r.UpdateOn = r.CreatedOn
+	err := db.QueryRowx(`
+		INSERT INTO rebalance (node_id, status, outgoing_channel_ids, outgoing_category_id, outgoing_tag_id,
+			incoming_channel_ids, incoming_category_id, incoming_tag_id, amount_msat, total_amount_msat, max_fee_ppm,
+			fee_budget_msat, max_attempts, rebalanced_amount_msat, spent_fee_msat, attempts, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING rebalance_id;`,
+		r.NodeId, r.Status, r.OutgoingChannelIds, r.OutgoingCategoryId, r.OutgoingTagId,
+		r.IncomingChannelIds, r.IncomingCategoryId, r.IncomingTagId, r.AmountMsat, r.TotalAmountMsat, r.MaxFeePpm,
+		r.FeeBudgetMsat, r.MaxAttempts, r.RebalancedAmountMsat, r.SpentFeeMsat, r.Attempts, r.CreatedOn, r.UpdateOn).
+		Scan(&r.RebalanceId)
+	if err != nil {
+		return Rebalance{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return r, nil
+}
+
+func setRebalanceProgress(db *sqlx.DB, r Rebalance) error {
+	_, err := db.Exec(`
+		UPDATE rebalance
+		SET status=$1, rebalanced_amount_msat=$2, spent_fee_msat=$3, attempts=$4, updated_on=$5
+		WHERE rebalance_id=$6;`,
+		r.Status, r.RebalancedAmountMsat, r.SpentFeeMsat, r.Attempts, time.Now().UTC(), r.RebalanceId)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func addRebalanceResult(db *sqlx.DB, result RebalanceResult) error {
+	_, err := db.Exec(`
+		INSERT INTO rebalance_result (rebalance_id, node_id, outgoing_channel_id, incoming_channel_id, amount_msat,
+			fee_msat, status, payment_hash, failure_reason, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`,
+		result.RebalanceId, result.NodeId, result.OutgoingChannelId, result.IncomingChannelId, result.AmountMsat,
+		result.FeeMsat, result.Status, result.PaymentHash, result.FailureReason, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func getChannelIdsByCategoryId(db *sqlx.DB, categoryId int) ([]int, error) {
+	var channelIds []int
+	err := db.Select(&channelIds, `SELECT DISTINCT channel_id FROM channel_group WHERE category_id=$1;`, categoryId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return channelIds, nil
+}
+
+func getChannelIdsByTagId(db *sqlx.DB, tagId int) ([]int, error) {
+	var channelIds []int
+	err := db.Select(&channelIds, `SELECT DISTINCT channel_id FROM channel_group WHERE tag_id=$1;`, tagId)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return channelIds, nil
+}
+
+func getPublicKeyByNodeId(db *sqlx.DB, nodeId int) (string, error) {
+	var publicKey string
+	err := db.Get(&publicKey, `SELECT public_key FROM node WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return "", errors.Wrap(err, database.SqlExecutionError)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,65 @@
+package rebalances
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+const rebalanceMemo = "Torq rebalance"
+
+// maxPairFailures is the amount of failed attempts after which a channel pair is no longer used
+const maxPairFailures = 3
+
+// Rebalance status is Active while attempts are being made and Inactive once done.
+type Rebalance struct {
+	RebalanceId          int               `json:"rebalanceId" db:"rebalance_id"`
+	NodeId               int               `json:"nodeId" db:"node_id"`
+	Status               commons.Status    `json:"status" db:"status"`
+	OutgoingChannelIds   pq.Int64Array     `json:"outgoingChannelIds" db:"outgoing_channel_ids"`
+	OutgoingCategoryId   *int              `json:"outgoingCategoryId" db:"outgoing_category_id"`
+	OutgoingTagId        *int              `json:"outgoingTagId" db:"outgoing_tag_id"`
+	IncomingChannelIds   pq.Int64Array     `json:"incomingChannelIds" db:"incoming_channel_ids"`
+	IncomingCategoryId   *int              `json:"incomingCategoryId" db:"incoming_category_id"`
+	IncomingTagId        *int              `json:"incomingTagId" db:"incoming_tag_id"`
+	AmountMsat           uint64            `json:"amountMsat" db:"amount_msat"`
+	TotalAmountMsat      uint64            `json:"totalAmountMsat" db:"total_amount_msat"`
+	MaxFeePpm            uint64            `json:"maxFeePpm" db:"max_fee_ppm"`
+	FeeBudgetMsat        uint64            `json:"feeBudgetMsat" db:"fee_budget_msat"`
+	MaxAttempts          int               `json:"maxAttempts" db:"max_attempts"`
+	RebalancedAmountMsat uint64            `json:"rebalancedAmountMsat" db:"rebalanced_amount_msat"`
+	SpentFeeMsat         uint64            `json:"spentFeeMsat" db:"spent_fee_msat"`
+	Attempts             int               `json:"attempts" db:"attempts"`
+	CreatedOn            time.Time         `json:"createdOn" db:"created_on"`
+	UpdateOn             time.Time         `json:"updatedOn" db:"updated_on"`
+	Results              []RebalanceResult `json:"results"`
+}
+
+type RebalanceResult struct {
+	RebalanceResultId int       `json:"rebalanceResultId" db:"rebalance_result_id"`
+	RebalanceId       int       `json:"rebalanceId" db:"rebalance_id"`
+	NodeId            int       `json:"nodeId" db:"node_id"`
+	OutgoingChannelId int       `json:"outgoingChannelId" db:"outgoing_channel_id"`
+	IncomingChannelId int       `json:"incomingChannelId" db:"incoming_channel_id"`
+	AmountMsat        int64     `json:"amountMsat" db:"amount_msat"`
+	FeeMsat           int64     `json:"feeMsat" db:"fee_msat"`
+	Status            string    `json:"status" db:"status"`
+	PaymentHash       *string   `json:"paymentHash" db:"payment_hash"`
+	FailureReason     *string   `json:"failureReason" db:"failure_reason"`
+	CreatedOn         time.Time `json:"createdOn" db:"created_on"`
+}
+
+type rebalanceChannel struct {
+	ChannelId         int
+	LndShortChannelId uint64
+	RemotePublicKey   string
+	LocalBalance      int64
+	RemoteBalance     int64
+}
+
+type channelPair struct {
+	OutgoingChannelId int
+	IncomingChannelId int
+}
@@ -0,0 +1,476 @@
+package rebalances
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/invoicesrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type lightningClientAddInvoice interface {
+	AddInvoice(ctx context.Context, in *lnrpc.Invoice, opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error)
+}
+
+type rrpcClientSendPayment interface {
+	SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
+		opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client, error)
+	TrackPaymentV2(ctx context.Context, in *routerrpc.TrackPaymentRequest,
+		opts ...grpc.CallOption) (routerrpc.Router_TrackPaymentV2Client, error)
+}
+
+type invoicesClientCancelInvoice interface {
+	CancelInvoice(ctx context.Context, in *invoicesrpc.CancelInvoiceMsg,
+		opts ...grpc.CallOption) (*invoicesrpc.CancelInvoiceResp, error)
+}
+
+// SendRebalance - circular rebalance from the outgoing channels to the incoming channels
+// Every attempt pays an invoice of our own node leaving through one of the outgoing channels (OutgoingChanIds)
+// and arriving through one of the incoming channels (LastHopPubkey).
+// Attempts continue until the total amount is rebalanced, the fee budget is spent or max attempts is reached.
+// The progress is reported with RebalanceResponse events.
+func SendRebalance(
+	eventChannel chan interface{},
+	db *sqlx.DB,
+	c *gin.Context,
+	rbReq commons.RebalanceRequest,
+	reqId string,
+) error {
+
+	if err := validateRebalanceRequest(rbReq); err != nil {
+		return err
+	}
+
+	connection, err := settings.GetNodeConnection(db, rbReq.NodeId)
+	if err != nil {
+		return errors.Wrap(err, "Connecting to the node")
+	}
+	lndConnection, ok := connection.(settings.LndConnection)
+	if !ok {
+		return errors.New("Rebalancing is only supported for LND nodes")
+	}
+	conn := lndConnection.Conn
+	// The rebalance outlives the request so it stops with the LND service instead
+	ctx, running := commons.RunningServices[commons.LndService].GetContext(rbReq.NodeId)
+	if !running {
+		return errors.New("The LND service of the node is not running")
+	}
+
+	outgoingChannels, err := getRebalanceChannels(db, rbReq.NodeId,
+		rbReq.OutgoingChannelIds, rbReq.OutgoingCategoryId, rbReq.OutgoingTagId)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining outgoing channels")
+	}
+	incomingChannels, err := getRebalanceChannels(db, rbReq.NodeId,
+		rbReq.IncomingChannelIds, rbReq.IncomingCategoryId, rbReq.IncomingTagId)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining incoming channels")
+	}
+	if len(outgoingChannels) == 0 || len(incomingChannels) == 0 {
+		return errors.New("No open outgoing and/or incoming channels found")
+	}
+
+	r, err := addRebalance(db, Rebalance{
+		NodeId:             rbReq.NodeId,
+		Status:             commons.Active,
+		OutgoingChannelIds: toInt64Array(rbReq.OutgoingChannelIds),
+		OutgoingCategoryId: rbReq.OutgoingCategoryId,
+		OutgoingTagId:      rbReq.OutgoingTagId,
+		IncomingChannelIds: toInt64Array(rbReq.IncomingChannelIds),
+		IncomingCategoryId: rbReq.IncomingCategoryId,
+		IncomingTagId:      rbReq.IncomingTagId,
+		AmountMsat:         rbReq.AmountMsat,
+		TotalAmountMsat:    rbReq.TotalAmountMsat,
+		MaxFeePpm:          rbReq.MaxFeePpm,
+		FeeBudgetMsat:      rbReq.FeeBudgetMsat,
+		MaxAttempts:        rbReq.MaxAttempts,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Storing rebalance")
+	}
+
+	return rebalance(ctx, db, rebalanceClients{
+		lightning: lnrpc.NewLightningClient(conn),
+		router:    routerrpc.NewRouterClient(conn),
+		invoices:  invoicesrpc.NewInvoicesClient(conn),
+	}, r, rbReq, outgoingChannels, incomingChannels, eventChannel, reqId)
+}
+
+func validateRebalanceRequest(rbReq commons.RebalanceRequest) error {
+	if rbReq.NodeId == 0 {
+		return errors.New("Node id is missing")
+	}
+	if len(rbReq.OutgoingChannelIds) == 0 && rbReq.OutgoingCategoryId == nil && rbReq.OutgoingTagId == nil {
+		return errors.New("Outgoing channels are missing")
+	}
+	if len(rbReq.IncomingChannelIds) == 0 && rbReq.IncomingCategoryId == nil && rbReq.IncomingTagId == nil {
+		return errors.New("Incoming channels are missing")
+	}
+	if rbReq.AmountMsat == 0 {
+		return errors.New("Amount is missing")
+	}
+	if rbReq.TotalAmountMsat < rbReq.AmountMsat {
+		return errors.New("Total amount cannot be smaller than the amount of an attempt")
+	}
+	if rbReq.MaxFeePpm == 0 {
+		return errors.New("Max fee ppm is missing")
+	}
+	if rbReq.FeeBudgetMsat == 0 {
+		return errors.New("Fee budget is missing")
+	}
+	if rbReq.MaxAttempts <= 0 {
+		return errors.New("Max attempts is missing")
+	}
+	return nil
+}
+
+type rebalanceClients struct {
+	lightning lightningClientAddInvoice
+	router    rrpcClientSendPayment
+	invoices  invoicesClientCancelInvoice
+}
+
+func rebalance(ctx context.Context, db *sqlx.DB, clients rebalanceClients, r Rebalance, rbReq commons.RebalanceRequest,
+	outgoingChannels []rebalanceChannel, incomingChannels []rebalanceChannel,
+	eventChannel chan interface{}, reqId string) error {
+
+	failures := make(map[channelPair]int)
+	doneStatus := "COMPLETED"
+	for {
+		if ctx.Err() != nil {
+			doneStatus = "CANCELLED"
+			break
+		}
+		if r.RebalancedAmountMsat >= r.TotalAmountMsat {
+			break
+		}
+		if r.Attempts >= r.MaxAttempts {
+			doneStatus = "MAX_ATTEMPTS_REACHED"
+			break
+		}
+		amountMsat := r.AmountMsat
+		if r.TotalAmountMsat-r.RebalancedAmountMsat < amountMsat {
+			amountMsat = r.TotalAmountMsat - r.RebalancedAmountMsat
+		}
+		feeLimitMsat := getFeeLimitMsat(amountMsat, r.MaxFeePpm, r.FeeBudgetMsat, r.SpentFeeMsat)
+		if feeLimitMsat == 0 {
+			doneStatus = "FEE_BUDGET_SPENT"
+			break
+		}
+		refreshBalances(r.NodeId, outgoingChannels)
+		refreshBalances(r.NodeId, incomingChannels)
+		outgoingChannel, incomingChannel, exists :=
+			selectChannelPair(outgoingChannels, incomingChannels, failures, amountMsat)
+		if !exists {
+			doneStatus = "NO_CHANNEL_PAIR_AVAILABLE"
+			break
+		}
+
+		r.Attempts++
+		result, err := sendRebalanceAttempt(ctx, clients, r, rbReq,
+			outgoingChannel, incomingChannel, amountMsat, feeLimitMsat, eventChannel, reqId)
+		if err != nil {
+			log.Error().Err(err).Msgf("Rebalance attempt failed (rebalanceId: %v)", r.RebalanceId)
+			failureReason := err.Error()
+			result.FailureReason = &failureReason
+		}
+		if result.Status == lnrpc.Payment_SUCCEEDED.String() {
+			r.RebalancedAmountMsat += amountMsat
+			r.SpentFeeMsat += uint64(result.FeeMsat)
+		} else {
+			failures[channelPair{
+				OutgoingChannelId: outgoingChannel.ChannelId,
+				IncomingChannelId: incomingChannel.ChannelId}]++
+		}
+		err = addRebalanceResult(db, result)
+		if err != nil {
+			return errors.Wrap(err, "Storing rebalance result")
+		}
+		err = setRebalanceProgress(db, r)
+		if err != nil {
+			return errors.Wrap(err, "Storing rebalance progress")
+		}
+		// The fee of an unresolved payment is unknown so the budget can't be respected by another attempt
+		if result.Status == lnrpc.Payment_IN_FLIGHT.String() {
+			doneStatus = "PAYMENT_IN_FLIGHT"
+			break
+		}
+	}
+
+	r.Status = commons.Inactive
+	err := setRebalanceProgress(db, r)
+	if err != nil {
+		return errors.Wrap(err, "Storing rebalance progress")
+	}
+	if eventChannel != nil {
+		eventChannel <- commons.RebalanceResponse{
+			ReqId:                reqId,
+			Request:              rbReq,
+			RebalanceId:          r.RebalanceId,
+			Attempt:              r.Attempts,
+			Status:               doneStatus,
+			RebalancedAmountMsat: r.RebalancedAmountMsat,
+			SpentFeeMsat:         r.SpentFeeMsat,
+			Done:                 true,
+		}
+	}
+	return nil
+}
+
+// sendRebalanceAttempt pays a new invoice of our own node. When the payment stream ends before the payment is
+// resolved the payment is tracked until it is. The invoice is cancelled when the payment didn't succeed.
+func sendRebalanceAttempt(ctx context.Context, clients rebalanceClients, r Rebalance, rbReq commons.RebalanceRequest,
+	outgoingChannel rebalanceChannel, incomingChannel rebalanceChannel,
+	amountMsat uint64, feeLimitMsat uint64,
+	eventChannel chan interface{}, reqId string) (RebalanceResult, error) {
+
+	result := RebalanceResult{
+		RebalanceId:       r.RebalanceId,
+		NodeId:            r.NodeId,
+		OutgoingChannelId: outgoingChannel.ChannelId,
+		IncomingChannelId: incomingChannel.ChannelId,
+		AmountMsat:        int64(amountMsat),
+		Status:            lnrpc.Payment_FAILED.String(),
+	}
+
+	invoice, err := clients.lightning.AddInvoice(ctx, &lnrpc.Invoice{
+		Memo:      rebalanceMemo,
+		ValueMsat: int64(amountMsat),
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "Adding rebalance invoice")
+	}
+	paymentHash := hex.EncodeToString(invoice.RHash)
+	result.PaymentHash = &paymentHash
+
+	result, err = payRebalanceInvoice(ctx, clients.router, invoice, result, r, rbReq,
+		outgoingChannel, incomingChannel, feeLimitMsat, eventChannel, reqId)
+	if result.Status != lnrpc.Payment_SUCCEEDED.String() {
+		_, cancelErr := clients.invoices.CancelInvoice(ctx, &invoicesrpc.CancelInvoiceMsg{PaymentHash: invoice.RHash})
+		if cancelErr != nil {
+			log.Error().Err(cancelErr).Msgf("Cancelling rebalance invoice (paymentHash: %v)", paymentHash)
+		}
+	}
+	return result, err
+}
+
+func payRebalanceInvoice(ctx context.Context, routerClient rrpcClientSendPayment, invoice *lnrpc.AddInvoiceResponse,
+	result RebalanceResult, r Rebalance, rbReq commons.RebalanceRequest,
+	outgoingChannel rebalanceChannel, incomingChannel rebalanceChannel, feeLimitMsat uint64,
+	eventChannel chan interface{}, reqId string) (RebalanceResult, error) {
+
+	lastHopPubkey, err := hex.DecodeString(incomingChannel.RemotePublicKey)
+	if err != nil {
+		return result, errors.Wrap(err, "Decoding the public key of the incoming channel's peer")
+	}
+	timeOutSecs := rbReq.TimeOutSecs
+	if timeOutSecs == 0 {
+		timeOutSecs = 60
+	}
+	req, err := routerClient.SendPaymentV2(ctx, &routerrpc.SendPaymentRequest{
+		PaymentRequest:   invoice.PaymentRequest,
+		TimeoutSeconds:   timeOutSecs,
+		FeeLimitMsat:     int64(feeLimitMsat),
+		OutgoingChanIds:  []uint64{outgoingChannel.LndShortChannelId},
+		LastHopPubkey:    lastHopPubkey,
+		AllowSelfPayment: true,
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "Sending rebalance payment")
+	}
+	// From here on the payment can be in flight until it's resolved
+	result.Status = lnrpc.Payment_IN_FLIGHT.String()
+
+	for {
+		resp, err := req.Recv()
+		if err != nil {
+			if err != io.EOF {
+				log.Error().Err(err).Msgf("Receiving rebalance payment updates (rebalanceId: %v)", r.RebalanceId)
+			}
+			return trackRebalancePayment(ctx, routerClient, invoice.RHash, result)
+		}
+		result.Status = resp.Status.String()
+		result.FeeMsat = resp.FeeMsat
+		if resp.FailureReason != lnrpc.PaymentFailureReason_FAILURE_REASON_NONE {
+			failureReason := resp.FailureReason.String()
+			result.FailureReason = &failureReason
+		}
+		if eventChannel != nil {
+			eventChannel <- commons.RebalanceResponse{
+				ReqId:                reqId,
+				Request:              rbReq,
+				RebalanceId:          r.RebalanceId,
+				Attempt:              r.Attempts,
+				OutgoingChannelId:    outgoingChannel.ChannelId,
+				IncomingChannelId:    incomingChannel.ChannelId,
+				Status:               resp.Status.String(),
+				FailureReason:        resp.FailureReason.String(),
+				Hash:                 resp.PaymentHash,
+				AmountMsat:           resp.ValueMsat,
+				FeePaidMsat:          resp.FeeMsat,
+				RebalancedAmountMsat: r.RebalancedAmountMsat,
+				SpentFeeMsat:         r.SpentFeeMsat,
+			}
+		}
+		if resp.Status == lnrpc.Payment_SUCCEEDED || resp.Status == lnrpc.Payment_FAILED {
+			return result, nil
+		}
+	}
+}
+
+// trackRebalancePayment waits for the resolution of a payment that was still in flight when its stream ended
+func trackRebalancePayment(ctx context.Context, routerClient rrpcClientSendPayment, paymentHash []byte,
+	result RebalanceResult) (RebalanceResult, error) {
+
+	req, err := routerClient.TrackPaymentV2(ctx, &routerrpc.TrackPaymentRequest{
+		PaymentHash:       paymentHash,
+		NoInflightUpdates: true,
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "Tracking rebalance payment")
+	}
+	for {
+		resp, err := req.Recv()
+		if err != nil {
+			return result, errors.Wrap(err, "Receiving tracked rebalance payment updates")
+		}
+		result.Status = resp.Status.String()
+		result.FeeMsat = resp.FeeMsat
+		if resp.FailureReason != lnrpc.PaymentFailureReason_FAILURE_REASON_NONE {
+			failureReason := resp.FailureReason.String()
+			result.FailureReason = &failureReason
+		}
+		if resp.Status == lnrpc.Payment_SUCCEEDED || resp.Status == lnrpc.Payment_FAILED {
+			return result, nil
+		}
+	}
+}
+
+// getFeeLimitMsat returns the max fee of an attempt limited by the max fee ppm and the remaining budget
+func getFeeLimitMsat(amountMsat uint64, maxFeePpm uint64, feeBudgetMsat uint64, spentFeeMsat uint64) uint64 {
+	if spentFeeMsat >= feeBudgetMsat {
+		return 0
+	}
+	feeLimitMsat := amountMsat * maxFeePpm / 1_000_000
+	if feeBudgetMsat-spentFeeMsat < feeLimitMsat {
+		feeLimitMsat = feeBudgetMsat - spentFeeMsat
+	}
+	return feeLimitMsat
+}
+
+// selectChannelPair returns the pair with the least failures and most liquidity to move.
+// The outgoing channel needs enough local balance and the incoming channel enough remote balance.
+func selectChannelPair(outgoingChannels []rebalanceChannel, incomingChannels []rebalanceChannel,
+	failures map[channelPair]int, amountMsat uint64) (rebalanceChannel, rebalanceChannel, bool) {
+
+	var bestOutgoing rebalanceChannel
+	var bestIncoming rebalanceChannel
+	bestFailures := maxPairFailures
+	var bestLiquidity int64
+	exists := false
+	for _, outgoing := range outgoingChannels {
+		if uint64(outgoing.LocalBalance)*1000 < amountMsat {
+			continue
+		}
+		for _, incoming := range incomingChannels {
+			if incoming.ChannelId == outgoing.ChannelId || uint64(incoming.RemoteBalance)*1000 < amountMsat {
+				continue
+			}
+			pairFailures := failures[channelPair{
+				OutgoingChannelId: outgoing.ChannelId,
+				IncomingChannelId: incoming.ChannelId}]
+			liquidity := outgoing.LocalBalance + incoming.RemoteBalance
+			if pairFailures < bestFailures || (pairFailures == bestFailures && exists && liquidity > bestLiquidity) {
+				bestOutgoing = outgoing
+				bestIncoming = incoming
+				bestFailures = pairFailures
+				bestLiquidity = liquidity
+				exists = true
+			}
+		}
+	}
+	return bestOutgoing, bestIncoming, exists
+}
+
+// getRebalanceChannels returns the open channels of the node out of the channels and the channel groups
+func getRebalanceChannels(db *sqlx.DB, nodeId int, channelIds []int, categoryId *int, tagId *int) ([]rebalanceChannel, error) {
+	allChannelIds := make([]int, 0, len(channelIds))
+	allChannelIds = append(allChannelIds, channelIds...)
+	if categoryId != nil {
+		categoryChannelIds, err := getChannelIdsByCategoryId(db, *categoryId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Obtaining channels for categoryId: %v", *categoryId)
+		}
+		allChannelIds = append(allChannelIds, categoryChannelIds...)
+	}
+	if tagId != nil {
+		tagChannelIds, err := getChannelIdsByTagId(db, *tagId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Obtaining channels for tagId: %v", *tagId)
+		}
+		allChannelIds = append(allChannelIds, tagChannelIds...)
+	}
+
+	processed := make(map[int]bool)
+	var channels []rebalanceChannel
+	for _, channelId := range allChannelIds {
+		if processed[channelId] {
+			continue
+		}
+		processed[channelId] = true
+		channelSettings := commons.GetChannelSettingByChannelId(channelId)
+		if channelSettings.Status != commons.Open || channelSettings.LndShortChannelId == 0 {
+			continue
+		}
+		remoteNodeId := channelSettings.FirstNodeId
+		if remoteNodeId == nodeId {
+			remoteNodeId = channelSettings.SecondNodeId
+		} else if channelSettings.SecondNodeId != nodeId {
+			continue
+		}
+		remotePublicKey, err := getPublicKeyByNodeId(db, remoteNodeId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Obtaining public key for nodeId: %v", remoteNodeId)
+		}
+		channels = append(channels, rebalanceChannel{
+			ChannelId:         channelId,
+			LndShortChannelId: channelSettings.LndShortChannelId,
+			RemotePublicKey:   remotePublicKey,
+		})
+	}
+	return channels, nil
+}
+
+// refreshBalances takes pending HTLCs into account so in flight attempts are not rebalanced twice
+func refreshBalances(nodeId int, channels []rebalanceChannel) {
+	for i := range channels {
+		balanceState := commons.GetChannelBalanceState(nodeId, channels[i].ChannelId, false,
+			commons.PENDING_HTLCS_LOCAL_AND_REMOTE_BALANCE_ADJUSTED_DOWNWARDS)
+		if balanceState == nil {
+			channels[i].LocalBalance = 0
+			channels[i].RemoteBalance = 0
+			continue
+		}
+		channels[i].LocalBalance = balanceState.LocalBalance
+		channels[i].RemoteBalance = balanceState.RemoteBalance
+	}
+}
+
+func toInt64Array(values []int) pq.Int64Array {
+	result := pq.Int64Array{}
+	for _, value := range values {
+		result = append(result, int64(value))
+	}
+	return result
+}
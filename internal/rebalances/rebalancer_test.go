@@ -0,0 +1,232 @@
+package rebalances
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/invoicesrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type stubRouterSendPaymentV2Client struct {
+	grpc.ClientStream
+	Payments []*lnrpc.Payment
+}
+
+func (s *stubRouterSendPaymentV2Client) Recv() (*lnrpc.Payment, error) {
+	if len(s.Payments) == 0 {
+		return nil, io.EOF
+	}
+	var payment *lnrpc.Payment
+	payment, s.Payments = s.Payments[0], s.Payments[1:]
+	return payment, nil
+}
+
+type stubRebalanceClient struct {
+	Payments         []*lnrpc.Payment
+	TrackedPayments  []*lnrpc.Payment
+	Request          *routerrpc.SendPaymentRequest
+	CancelledInvoice []byte
+}
+
+func (c *stubRebalanceClient) AddInvoice(
+	ctx context.Context, in *lnrpc.Invoice, opts ...grpc.CallOption) (*lnrpc.AddInvoiceResponse, error) {
+	return &lnrpc.AddInvoiceResponse{RHash: []byte{1, 2, 3}, PaymentRequest: "lnbcrt1"}, nil
+}
+
+func (c *stubRebalanceClient) SendPaymentV2(ctx context.Context, in *routerrpc.SendPaymentRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_SendPaymentV2Client, error) {
+	c.Request = in
+	return &stubRouterSendPaymentV2Client{Payments: c.Payments}, nil
+}
+
+func (c *stubRebalanceClient) TrackPaymentV2(ctx context.Context, in *routerrpc.TrackPaymentRequest,
+	opts ...grpc.CallOption) (routerrpc.Router_TrackPaymentV2Client, error) {
+	return &stubRouterSendPaymentV2Client{Payments: c.TrackedPayments}, nil
+}
+
+func (c *stubRebalanceClient) CancelInvoice(ctx context.Context, in *invoicesrpc.CancelInvoiceMsg,
+	opts ...grpc.CallOption) (*invoicesrpc.CancelInvoiceResp, error) {
+	c.CancelledInvoice = in.PaymentHash
+	return &invoicesrpc.CancelInvoiceResp{}, nil
+}
+
+func stubRebalanceClients(client *stubRebalanceClient) rebalanceClients {
+	return rebalanceClients{lightning: client, router: client, invoices: client}
+}
+
+func TestGetFeeLimitMsat(t *testing.T) {
+	tests := []struct {
+		name          string
+		amountMsat    uint64
+		maxFeePpm     uint64
+		feeBudgetMsat uint64
+		spentFeeMsat  uint64
+		want          uint64
+	}{
+		{"Limited by ppm", 1_000_000_000, 500, 1_000_000, 0, 500_000},
+		{"Limited by remaining budget", 1_000_000_000, 500, 1_000_000, 800_000, 200_000},
+		{"Budget spent", 1_000_000_000, 500, 1_000_000, 1_000_000, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getFeeLimitMsat(test.amountMsat, test.maxFeePpm, test.feeBudgetMsat, test.spentFeeMsat)
+			if got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectChannelPair(t *testing.T) {
+	outgoing := []rebalanceChannel{
+		{ChannelId: 1, LocalBalance: 900_000, RemoteBalance: 100_000},
+		{ChannelId: 2, LocalBalance: 600_000, RemoteBalance: 400_000},
+		{ChannelId: 3, LocalBalance: 10_000, RemoteBalance: 990_000},
+	}
+	incoming := []rebalanceChannel{
+		{ChannelId: 1, LocalBalance: 900_000, RemoteBalance: 100_000},
+		{ChannelId: 4, LocalBalance: 100_000, RemoteBalance: 900_000},
+		{ChannelId: 5, LocalBalance: 300_000, RemoteBalance: 700_000},
+	}
+	amountMsat := uint64(50_000_000)
+
+	o, i, exists := selectChannelPair(outgoing, incoming, map[channelPair]int{}, amountMsat)
+	if !exists || o.ChannelId != 1 || i.ChannelId != 4 {
+		t.Fatalf("expected pair 1->4, got %v->%v (exists: %v)", o.ChannelId, i.ChannelId, exists)
+	}
+
+	failures := map[channelPair]int{{OutgoingChannelId: 1, IncomingChannelId: 4}: 1}
+	o, i, exists = selectChannelPair(outgoing, incoming, failures, amountMsat)
+	if !exists || o.ChannelId != 1 || i.ChannelId != 5 {
+		t.Fatalf("expected pair 1->5 after a failure of 1->4, got %v->%v (exists: %v)", o.ChannelId, i.ChannelId, exists)
+	}
+
+	_, _, exists = selectChannelPair(outgoing, incoming, map[channelPair]int{}, 1_000_000_000)
+	if exists {
+		t.Fatalf("expected no pair when no channel has enough balance")
+	}
+
+	failures = map[channelPair]int{}
+	for _, oc := range outgoing {
+		for _, ic := range incoming {
+			failures[channelPair{OutgoingChannelId: oc.ChannelId, IncomingChannelId: ic.ChannelId}] = maxPairFailures
+		}
+	}
+	_, _, exists = selectChannelPair(outgoing, incoming, failures, amountMsat)
+	if exists {
+		t.Fatalf("expected no pair when all pairs failed too often")
+	}
+}
+
+func TestValidateRebalanceRequest(t *testing.T) {
+	valid := commons.RebalanceRequest{
+		NodeId:             1,
+		OutgoingChannelIds: []int{1},
+		IncomingChannelIds: []int{2},
+		AmountMsat:         100_000_000,
+		TotalAmountMsat:    500_000_000,
+		MaxFeePpm:          500,
+		FeeBudgetMsat:      250_000,
+		MaxAttempts:        10,
+	}
+	if err := validateRebalanceRequest(valid); err != nil {
+		t.Fatalf("expected a valid request, got: %v", err)
+	}
+	invalid := valid
+	invalid.IncomingChannelIds = nil
+	if err := validateRebalanceRequest(invalid); err == nil {
+		t.Fatalf("expected an error without incoming channels")
+	}
+	invalid = valid
+	invalid.TotalAmountMsat = 1
+	if err := validateRebalanceRequest(invalid); err == nil {
+		t.Fatalf("expected an error when the total amount is smaller than the amount")
+	}
+}
+
+func TestSendRebalanceAttempt(t *testing.T) {
+	remotePublicKey := "02a1633cafcc01ebfb6d78e39f687a1f0995c62fc95f51ead10a02ee0be551b5dc"
+	client := &stubRebalanceClient{Payments: []*lnrpc.Payment{
+		{PaymentHash: "010203", Status: lnrpc.Payment_IN_FLIGHT, ValueMsat: 100_000_000},
+		{PaymentHash: "010203", Status: lnrpc.Payment_SUCCEEDED, ValueMsat: 100_000_000, FeeMsat: 1_500},
+	}}
+	eventChannel := make(chan interface{}, 10)
+	r := Rebalance{RebalanceId: 7, NodeId: 1, Attempts: 1}
+	result, err := sendRebalanceAttempt(context.Background(), stubRebalanceClients(client), r, commons.RebalanceRequest{},
+		rebalanceChannel{ChannelId: 1, LndShortChannelId: 123},
+		rebalanceChannel{ChannelId: 2, RemotePublicKey: remotePublicKey},
+		100_000_000, 50_000, eventChannel, "reqId")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != lnrpc.Payment_SUCCEEDED.String() || result.FeeMsat != 1_500 {
+		t.Errorf("unexpected result: %v (fee %v)", result.Status, result.FeeMsat)
+	}
+	if result.PaymentHash == nil || *result.PaymentHash != "010203" {
+		t.Errorf("expected the payment hash to be stored")
+	}
+	if len(client.Request.OutgoingChanIds) != 1 || client.Request.OutgoingChanIds[0] != 123 {
+		t.Errorf("expected the outgoing channel restriction, got %v", client.Request.OutgoingChanIds)
+	}
+	if hex.EncodeToString(client.Request.LastHopPubkey) != remotePublicKey {
+		t.Errorf("expected the last hop restriction, got %x", client.Request.LastHopPubkey)
+	}
+	if !client.Request.AllowSelfPayment || client.Request.FeeLimitMsat != 50_000 {
+		t.Errorf("unexpected payment request %v", client.Request)
+	}
+	if len(eventChannel) != 2 {
+		t.Errorf("expected 2 progress events, got %v", len(eventChannel))
+	}
+}
+
+func TestSendRebalanceAttemptResolvesInFlightPayments(t *testing.T) {
+	remotePublicKey := "02a1633cafcc01ebfb6d78e39f687a1f0995c62fc95f51ead10a02ee0be551b5dc"
+	outgoing := rebalanceChannel{ChannelId: 1, LndShortChannelId: 123}
+	incoming := rebalanceChannel{ChannelId: 2, RemotePublicKey: remotePublicKey}
+	r := Rebalance{RebalanceId: 7, NodeId: 1, Attempts: 1}
+
+	// The stream ends while the payment is in flight
+	client := &stubRebalanceClient{
+		Payments: []*lnrpc.Payment{{PaymentHash: "010203", Status: lnrpc.Payment_IN_FLIGHT}},
+		TrackedPayments: []*lnrpc.Payment{
+			{PaymentHash: "010203", Status: lnrpc.Payment_SUCCEEDED, ValueMsat: 100_000_000, FeeMsat: 2_000}},
+	}
+	result, err := sendRebalanceAttempt(context.Background(), stubRebalanceClients(client), r,
+		commons.RebalanceRequest{}, outgoing, incoming, 100_000_000, 50_000, nil, "reqId")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != lnrpc.Payment_SUCCEEDED.String() || result.FeeMsat != 2_000 {
+		t.Errorf("expected the tracked payment to be resolved, got %v (fee %v)", result.Status, result.FeeMsat)
+	}
+	if client.CancelledInvoice != nil {
+		t.Errorf("expected the invoice of a succeeded attempt to stay open")
+	}
+
+	// The tracked payment can't be resolved
+	client = &stubRebalanceClient{}
+	result, err = sendRebalanceAttempt(context.Background(), stubRebalanceClients(client), r,
+		commons.RebalanceRequest{}, outgoing, incoming, 100_000_000, 50_000, nil, "reqId")
+	if err == nil || result.Status != lnrpc.Payment_IN_FLIGHT.String() {
+		t.Errorf("expected an unresolved in flight payment, got %v (%v)", result.Status, err)
+	}
+
+	// The invoice of a failed attempt is cancelled
+	client = &stubRebalanceClient{Payments: []*lnrpc.Payment{{PaymentHash: "010203", Status: lnrpc.Payment_FAILED,
+		FailureReason: lnrpc.PaymentFailureReason_FAILURE_REASON_NO_ROUTE}}}
+	result, err = sendRebalanceAttempt(context.Background(), stubRebalanceClients(client), r,
+		commons.RebalanceRequest{}, outgoing, incoming, 100_000_000, 50_000, nil, "reqId")
+	if err != nil || result.Status != lnrpc.Payment_FAILED.String() {
+		t.Fatalf("expected a failed attempt, got %v (%v)", result.Status, err)
+	}
+	if hex.EncodeToString(client.CancelledInvoice) != "010203" {
+		t.Errorf("expected the invoice of the failed attempt to be cancelled")
+	}
+}
@@ -0,0 +1,41 @@
+package rebalances
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+// RegisterRebalanceRoutes only exposes the rebalance history, rebalances are started via the websocket.
+func RegisterRebalanceRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("get/:rebalanceId", func(c *gin.Context) { getRebalanceHandler(c, db) })
+	r.GET("all", func(c *gin.Context) { getRebalancesHandler(c, db) })
+}
+
+func getRebalanceHandler(c *gin.Context, db *sqlx.DB) {
+	rebalanceId, err := strconv.Atoi(c.Param("rebalanceId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse rebalanceId in the request.")
+		return
+	}
+	r, err := getRebalance(db, rebalanceId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting rebalance for rebalanceId: %v", rebalanceId))
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func getRebalancesHandler(c *gin.Context, db *sqlx.DB) {
+	rs, err := getRebalances(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting rebalances.")
+		return
+	}
+	c.JSON(http.StatusOK, rs)
+}
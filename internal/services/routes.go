@@ -10,12 +10,29 @@ import (
 	"github.com/lncapital/torq/build"
 	"github.com/lncapital/torq/internal/settings"
 	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+	"github.com/lncapital/torq/pkg/server_errors"
 )
 
 func RegisterUnauthenticatedRoutes(r *gin.RouterGroup, db *sqlx.DB) {
 	r.GET("status", func(c *gin.Context) { getServicesHandler(c, db) })
 }
 
+func RegisterServiceRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("reconciliation", func(c *gin.Context) { getReconciliationHandler(c, db) })
+}
+
+// getReconciliationHandler returns the latest reconciliation report of every node, a node that has no report yet
+// didn't finish its first reconciliation
+func getReconciliationHandler(c *gin.Context, db *sqlx.DB) {
+	reports, err := lnd.GetReconciliationReports(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting reconciliation reports")
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
 func getServicesHandler(c *gin.Context, db *sqlx.DB) {
 	result := Services{}
 	torqService := commons.RunningServices[commons.TorqService]
@@ -26,6 +43,13 @@ func getServicesHandler(c *gin.Context, db *sqlx.DB) {
 		},
 		Version: build.Version(),
 	}
+	maintenanceService := commons.RunningServices[commons.MaintenanceService]
+	result.MaintenanceService = MaintenanceService{
+		Service: Service{
+			Status:   maintenanceService.GetStatus(commons.TorqDummyNodeId),
+			BootTime: maintenanceService.GetBootTime(commons.TorqDummyNodeId),
+		},
+	}
 	lndService := commons.RunningServices[commons.LndService]
 	for _, torqNodeId := range commons.GetAllActiveTorqNodeIds(nil, nil) {
 		result.LndServices = append(result.LndServices, LndService{
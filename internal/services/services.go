@@ -48,9 +48,15 @@ type AmbossService struct {
 	NodeId int `json:"nodeId"`
 }
 
+// MaintenanceService the reports are served by the authenticated maintenance routes
+type MaintenanceService struct {
+	Service
+}
+
 type Services struct {
-	TorqService    TorqService     `json:"torqService"`
-	LndServices    []LndService    `json:"lndServices,omitempty"`
-	VectorServices []VectorService `json:"vectorServices,omitempty"`
-	AmbossServices []AmbossService `json:"ambossServices,omitempty"`
+	TorqService        TorqService        `json:"torqService"`
+	LndServices        []LndService       `json:"lndServices,omitempty"`
+	VectorServices     []VectorService    `json:"vectorServices,omitempty"`
+	AmbossServices     []AmbossService    `json:"ambossServices,omitempty"`
+	MaintenanceService MaintenanceService `json:"maintenanceService"`
 }
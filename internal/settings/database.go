@@ -16,12 +16,14 @@ import (
 func getSettings(db *sqlx.DB) (settings, error) {
 	var settingsData settings
 	err := db.Get(&settingsData, `
-		SELECT default_date_range, default_language, preferred_timezone, week_starts_on
+		SELECT default_date_range, default_language, preferred_timezone, week_starts_on,
+			channel_risk_htlc_warning_blocks, channel_risk_htlc_critical_blocks,
+			channel_risk_peer_offline_warning_seconds, channel_risk_peer_offline_critical_seconds
 		FROM settings
 		LIMIT 1;`)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return settings{}, nil
+			return settings{ChannelRiskSettings: commons.DefaultChannelRiskSettings()}, nil
 		}
 		return settings{}, errors.Wrap(err, database.SqlExecutionError)
 	}
@@ -33,7 +35,7 @@ func InitializeManagedSettingsCache(db *sqlx.DB) error {
 	if err == nil {
 		log.Debug().Msg("Pushing settings to ManagedSettings cache.")
 		commons.SetSettings(settingsData.DefaultDateRange, settingsData.DefaultLanguage, settingsData.WeekStartsOn,
-			settingsData.PreferredTimezone)
+			settingsData.PreferredTimezone, settingsData.ChannelRiskSettings)
 	} else {
 		log.Error().Err(err).Msg("Failed to obtain settings for ManagedSettings cache.")
 	}
@@ -58,13 +60,19 @@ func updateSettings(db *sqlx.DB, settings settings) (err error) {
 		  default_language = $2,
 		  preferred_timezone = $3,
 		  week_starts_on = $4,
-		  updated_on = $5;`,
+		  channel_risk_htlc_warning_blocks = $5,
+		  channel_risk_htlc_critical_blocks = $6,
+		  channel_risk_peer_offline_warning_seconds = $7,
+		  channel_risk_peer_offline_critical_seconds = $8,
+		  updated_on = $9;`,
 		settings.DefaultDateRange, settings.DefaultLanguage, settings.PreferredTimezone, settings.WeekStartsOn,
-		time.Now().UTC())
+		settings.HtlcWarningBlocks, settings.HtlcCriticalBlocks, settings.PeerOfflineWarningSeconds,
+		settings.PeerOfflineCriticalSeconds, time.Now().UTC())
 	if err != nil {
 		return errors.Wrap(err, database.SqlExecutionError)
 	}
-	commons.SetSettings(settings.DefaultDateRange, settings.DefaultLanguage, settings.WeekStartsOn, settings.PreferredTimezone)
+	commons.SetSettings(settings.DefaultDateRange, settings.DefaultLanguage, settings.WeekStartsOn, settings.PreferredTimezone,
+		settings.ChannelRiskSettings)
 	return nil
 }
 
@@ -0,0 +1,170 @@
+package settings
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd_connect"
+)
+
+// LndConnectionHealth the state of the shared gRPC connection of a node
+type LndConnectionHealth struct {
+	NodeId int `json:"nodeId"`
+	// State is the gRPC connectivity state (i.e. READY) or DISCONNECTED when there is no connection
+	State       string     `json:"state"`
+	ConnectedOn *time.Time `json:"connectedOn"`
+	LastError   *string    `json:"lastError"`
+	LastErrorOn *time.Time `json:"lastErrorOn"`
+}
+
+type lndConnection struct {
+	conn        *grpc.ClientConn
+	grpcAddress string
+	tlsCert     []byte
+	macaroon    []byte
+	connectedOn time.Time
+}
+
+type lndConnectionError struct {
+	message string
+	on      time.Time
+}
+
+var (
+	lndConnectionsMutex sync.Mutex                         //nolint:gochecknoglobals
+	lndConnections      = make(map[int]*lndConnection)     //nolint:gochecknoglobals
+	lndConnectionErrors = make(map[int]lndConnectionError) //nolint:gochecknoglobals
+	dialLnd             = lnd_connect.Connect              //nolint:gochecknoglobals
+)
+
+// GetLndConnection the shared connection of the node, the connection details are only loaded from the database
+// when the node has no connection yet. The connection must not be closed by the caller.
+func GetLndConnection(db *sqlx.DB, nodeId int) (*grpc.ClientConn, error) {
+	if conn, exists := getSharedLndConnection(nodeId); exists {
+		return conn, nil
+	}
+	connectionDetails, err := GetConnectionDetailsById(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Getting node connection details from the db")
+	}
+	if connectionDetails.Implementation != commons.LND {
+		return nil, errors.Newf("Node %v is not an LND node", nodeId)
+	}
+	return ConnectLnd(connectionDetails)
+}
+
+// getSharedLndConnection the shared connection of the node when it has a usable one
+func getSharedLndConnection(nodeId int) (*grpc.ClientConn, bool) {
+	lndConnectionsMutex.Lock()
+	connection, exists := lndConnections[nodeId]
+	lndConnectionsMutex.Unlock()
+	if exists && usable(connection.conn) {
+		return connection.conn, true
+	}
+	return nil, false
+}
+
+// ConnectLnd returns the shared connection of the node when it was made with the same connection details,
+// otherwise it dials and replaces the shared connection. The replaced connection is closed so it's only replaced
+// when the connection details changed.
+func ConnectLnd(connectionDetails ConnectionDetails) (*grpc.ClientConn, error) {
+	nodeId := connectionDetails.NodeId
+	lndConnectionsMutex.Lock()
+	connection, exists := lndConnections[nodeId]
+	lndConnectionsMutex.Unlock()
+	if exists && usable(connection.conn) && connection.sameDetails(connectionDetails) {
+		return connection.conn, nil
+	}
+	// Dialing blocks so it's done without holding the lock
+	conn, err := dialLnd(connectionDetails.GRPCAddress, connectionDetails.TLSFileBytes,
+		connectionDetails.MacaroonFileBytes)
+	lndConnectionsMutex.Lock()
+	defer lndConnectionsMutex.Unlock()
+	if err != nil {
+		lndConnectionErrors[nodeId] = lndConnectionError{message: err.Error(), on: time.Now().UTC()}
+		return nil, errors.Wrap(err, "Connecting to LND")
+	}
+	if previous, exists := lndConnections[nodeId]; exists {
+		// Another caller dialed at the same time, the connection it shared could already be in use
+		if usable(previous.conn) && previous.sameDetails(connectionDetails) {
+			closeLndConnection(nodeId, &lndConnection{conn: conn})
+			return previous.conn, nil
+		}
+		closeLndConnection(nodeId, previous)
+	}
+	lndConnections[nodeId] = &lndConnection{
+		conn:        conn,
+		grpcAddress: connectionDetails.GRPCAddress,
+		tlsCert:     connectionDetails.TLSFileBytes,
+		macaroon:    connectionDetails.MacaroonFileBytes,
+		connectedOn: time.Now().UTC(),
+	}
+	delete(lndConnectionErrors, nodeId)
+	return conn, nil
+}
+
+func (connection *lndConnection) sameDetails(connectionDetails ConnectionDetails) bool {
+	return connection.grpcAddress == connectionDetails.GRPCAddress &&
+		bytes.Equal(connection.tlsCert, connectionDetails.TLSFileBytes) &&
+		bytes.Equal(connection.macaroon, connectionDetails.MacaroonFileBytes)
+}
+
+// SetLndConnection shares the connection as the connection of the node without dialing (i.e. a connection to an
+// in-process LND in tests), the previous connection of the node is closed
+func SetLndConnection(nodeId int, conn *grpc.ClientConn) {
+	lndConnectionsMutex.Lock()
+	defer lndConnectionsMutex.Unlock()
+	if previous, exists := lndConnections[nodeId]; exists && previous.conn != conn {
+		closeLndConnection(nodeId, previous)
+	}
+	lndConnections[nodeId] = &lndConnection{conn: conn, connectedOn: time.Now().UTC()}
+	delete(lndConnectionErrors, nodeId)
+}
+
+// RemoveLndConnection closes the shared connection of the node i.e. when the connection details changed
+func RemoveLndConnection(nodeId int) {
+	lndConnectionsMutex.Lock()
+	defer lndConnectionsMutex.Unlock()
+	if connection, exists := lndConnections[nodeId]; exists {
+		closeLndConnection(nodeId, connection)
+		delete(lndConnections, nodeId)
+	}
+	delete(lndConnectionErrors, nodeId)
+}
+
+func GetLndConnectionHealth(nodeId int) LndConnectionHealth {
+	lndConnectionsMutex.Lock()
+	defer lndConnectionsMutex.Unlock()
+	health := LndConnectionHealth{NodeId: nodeId, State: "DISCONNECTED"}
+	if connection, exists := lndConnections[nodeId]; exists {
+		health.State = connection.conn.GetState().String()
+		connectedOn := connection.connectedOn
+		health.ConnectedOn = &connectedOn
+	}
+	if connectionError, exists := lndConnectionErrors[nodeId]; exists {
+		health.LastError = &connectionError.message
+		health.LastErrorOn = &connectionError.on
+	}
+	return health
+}
+
+// usable gRPC reconnects by itself from a transient failure so only a closed connection is dialed again.
+// Shared connections are never closed on their state since the subscriptions and handlers still use them.
+func usable(conn *grpc.ClientConn) bool {
+	return conn.GetState() != connectivity.Shutdown
+}
+
+func closeLndConnection(nodeId int, connection *lndConnection) {
+	err := connection.conn.Close()
+	if err != nil {
+		log.Debug().Err(err).Msgf("Closing the LND connection of nodeId: %v", nodeId)
+	}
+}
@@ -0,0 +1,82 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestLndConnections(t *testing.T) {
+	dials := 0
+	originalDialLnd := dialLnd
+	defer func() { dialLnd = originalDialLnd }()
+	dialLnd = func(host string, tlsCert []byte, macaroonBytes []byte) (*grpc.ClientConn, error) {
+		dials++
+		if host == "" {
+			return nil, errors.New("cannot dial to lnd")
+		}
+		// Not blocking so nothing has to listen
+		return grpc.Dial(host, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	defer RemoveLndConnection(1)
+
+	details := ConnectionDetails{NodeId: 1, GRPCAddress: "localhost:10009", TLSFileBytes: []byte("tls"),
+		MacaroonFileBytes: []byte("macaroon")}
+	conn, err := ConnectLnd(details)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := ConnectLnd(details)
+	if err != nil || again != conn || dials != 1 {
+		t.Errorf("expected the shared connection to be reused, got %v dials", dials)
+	}
+	// The handlers don't need the database while the connection is shared
+	shared, err := GetLndConnection(nil, 1)
+	if err != nil || shared != conn {
+		t.Errorf("expected the shared connection, got %v", err)
+	}
+	nodeConnection, err := GetNodeConnection(nil, 1)
+	if err != nil || nodeConnection.(LndConnection).Conn != conn {
+		t.Errorf("expected the shared connection as node connection, got %v", err)
+	}
+
+	// A transient failure is recovered by gRPC itself, only a closed connection is dialed again
+	if !usable(conn) {
+		t.Errorf("expected a connection that isn't closed to be usable")
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err = ConnectLnd(details)
+	if err != nil || conn.GetState() == connectivity.Shutdown || dials != 2 {
+		t.Errorf("expected a closed connection to be dialed again, got %v dials", dials)
+	}
+
+	details.MacaroonFileBytes = []byte("new macaroon")
+	replaced, err := ConnectLnd(details)
+	if err != nil || replaced == conn || dials != 3 {
+		t.Errorf("expected a new connection for new connection details, got %v dials", dials)
+	}
+	if conn.GetState() != connectivity.Shutdown {
+		t.Errorf("expected the replaced connection to be closed, got %v", conn.GetState())
+	}
+	if health := GetLndConnectionHealth(1); health.ConnectedOn == nil || health.LastError != nil {
+		t.Errorf("expected a healthy connection, got %+v", health)
+	}
+
+	RemoveLndConnection(1)
+	if replaced.GetState() != connectivity.Shutdown || GetLndConnectionHealth(1).State != "DISCONNECTED" {
+		t.Errorf("expected the connection to be removed")
+	}
+
+	_, err = ConnectLnd(ConnectionDetails{NodeId: 1})
+	if err == nil {
+		t.Fatalf("expected a dial error")
+	}
+	if health := GetLndConnectionHealth(1); health.LastError == nil || health.State != "DISCONNECTED" {
+		t.Errorf("expected the dial error in the health, got %+v", health)
+	}
+}
@@ -66,7 +66,11 @@ func GetNodeIdByGRPC(db *sqlx.DB, grpcAddress string) (int, error) {
 
 func AddNodeToDB(db *sqlx.DB, implementation commons.Implementation,
 	grpcAddress string, tlsDataBytes []byte, macaroonDataBytes []byte) (NodeConnectionDetails, error) {
-	publicKey, chain, network, err := getInformationFromLndNode(grpcAddress, tlsDataBytes, macaroonDataBytes)
+	backend, err := getNodeBackend(implementation)
+	if err != nil {
+		return NodeConnectionDetails{}, err
+	}
+	publicKey, chain, network, err := backend.getInformation(grpcAddress, tlsDataBytes, macaroonDataBytes)
 	if err != nil {
 		return NodeConnectionDetails{}, errors.Wrap(err, "Getting public key from node")
 	}
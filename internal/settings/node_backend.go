@@ -0,0 +1,193 @@
+package settings
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd_connect"
+)
+
+// NodeConnection the connection to a node. There is an implementation per node implementation (LndConnection and
+// ClnConnection), the packages with node actions resolve their own implementation of the actions from it.
+type NodeConnection interface {
+	GetNodeId() int
+}
+
+type LndConnection struct {
+	NodeId int
+	// Conn is shared, it must not be closed by the caller
+	Conn *grpc.ClientConn
+}
+
+func (connection LndConnection) GetNodeId() int {
+	return connection.NodeId
+}
+
+type ClnConnection struct {
+	NodeId int
+	Client *cln_connect.Client
+}
+
+func (connection ClnConnection) GetNodeId() int {
+	return connection.NodeId
+}
+
+// nodeBackend the node implementation specific handling of the node connection details
+type nodeBackend interface {
+	// requiresCredentials the TLS certificate and macaroon are required to connect
+	requiresCredentials() bool
+	// getInformation the public key, chain and network reported by the node
+	getInformation(address string, tlsCert []byte, macaroon []byte) (string, commons.Chain, commons.Network, error)
+	// supportsPingServices the Amboss and Vector ping services can sign with the node
+	supportsPingServices() bool
+	connect(connectionDetails ConnectionDetails) (NodeConnection, error)
+}
+
+var nodeBackends = map[commons.Implementation]nodeBackend{ //nolint:gochecknoglobals
+	commons.LND: lndNodeBackend{},
+	commons.CLN: clnNodeBackend{},
+}
+
+func getNodeBackend(implementation commons.Implementation) (nodeBackend, error) {
+	backend, exists := nodeBackends[implementation]
+	if !exists {
+		return nil, errors.Newf("Unsupported node implementation %v", implementation)
+	}
+	return backend, nil
+}
+
+// ConnectNode connects to the node with the backend of its implementation
+func ConnectNode(connectionDetails ConnectionDetails) (NodeConnection, error) {
+	backend, err := getNodeBackend(connectionDetails.Implementation)
+	if err != nil {
+		return nil, err
+	}
+	return backend.connect(connectionDetails)
+}
+
+// GetNodeConnection the shared connection of an LND node, otherwise it loads the connection details of the node
+// and connects to it
+func GetNodeConnection(db *sqlx.DB, nodeId int) (NodeConnection, error) {
+	if conn, exists := getSharedLndConnection(nodeId); exists {
+		return LndConnection{NodeId: nodeId, Conn: conn}, nil
+	}
+	connectionDetails, err := GetConnectionDetailsById(db, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "Getting node connection details from the db")
+	}
+	return ConnectNode(connectionDetails)
+}
+
+type lndNodeBackend struct{}
+
+func (lndNodeBackend) requiresCredentials() bool {
+	return true
+}
+
+func (lndNodeBackend) supportsPingServices() bool {
+	return true
+}
+
+func (lndNodeBackend) connect(connectionDetails ConnectionDetails) (NodeConnection, error) {
+	conn, err := ConnectLnd(connectionDetails)
+	if err != nil {
+		return nil, err
+	}
+	return LndConnection{NodeId: connectionDetails.NodeId, Conn: conn}, nil
+}
+
+func (lndNodeBackend) getInformation(grpcAddress string, tlsCert []byte, macaroonFile []byte) (
+	string, commons.Chain, commons.Network, error) {
+	conn, err := lnd_connect.Connect(grpcAddress, tlsCert, macaroonFile)
+	if err != nil {
+		return "", 0, 0, errors.Wrap(err,
+			"Can't connect to node to verify public key, check all details including TLS Cert and Macaroon")
+	}
+	defer func(conn *grpc.ClientConn) {
+		err := conn.Close()
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to close gRPC connection.")
+		}
+	}(conn)
+
+	client := lnrpc.NewLightningClient(conn)
+	ctx := context.Background()
+	info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		return "", 0, 0, errors.Wrap(err, "Obtaining information from LND")
+	}
+	if len(info.Chains) != 1 {
+		return "", 0, 0, errors.Wrapf(err, "Obtaining chains from LND %v", info.Chains)
+	}
+
+	var chain commons.Chain
+	switch info.Chains[0].Chain {
+	case "bitcoin":
+		chain = commons.Bitcoin
+	case "litecoin":
+		chain = commons.Litecoin
+	default:
+		return "", 0, 0, errors.Wrapf(err, "Obtaining chain from LND %v", info.Chains[0].Chain)
+	}
+
+	var network commons.Network
+	switch info.Chains[0].Network {
+	case "mainnet":
+		network = commons.MainNet
+	case "testnet":
+		network = commons.TestNet
+	case "signet":
+		network = commons.SigNet
+	case "simnet":
+		network = commons.SimNet
+	case "regtest":
+		network = commons.RegTest
+	default:
+		return "", 0, 0, errors.Wrapf(err, "Obtaining network from LND %v", info.Chains[0].Network)
+	}
+	return info.IdentityPubkey, chain, network, nil
+}
+
+type clnNodeBackend struct{}
+
+// requiresCredentials the lightning-rpc socket is protected by its file permissions
+func (clnNodeBackend) requiresCredentials() bool {
+	return false
+}
+
+func (clnNodeBackend) supportsPingServices() bool {
+	return false
+}
+
+func (clnNodeBackend) connect(connectionDetails ConnectionDetails) (NodeConnection, error) {
+	client, err := cln_connect.Connect(connectionDetails.GRPCAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "Connecting to CLN")
+	}
+	return ClnConnection{NodeId: connectionDetails.NodeId, Client: client}, nil
+}
+
+func (clnNodeBackend) getInformation(address string, _ []byte, _ []byte) (
+	string, commons.Chain, commons.Network, error) {
+	client, err := cln_connect.Connect(address)
+	if err != nil {
+		return "", 0, 0, errors.Wrap(err, "Can't connect to node to verify public key, check the RPC address")
+	}
+	var info cln_connect.GetInfoResponse
+	err = client.Call(context.Background(), "getinfo", nil, &info)
+	if err != nil {
+		return "", 0, 0, errors.Wrap(err, "Obtaining information from CLN")
+	}
+	chain, network, err := cln_connect.GetChainAndNetwork(info.Network)
+	if err != nil {
+		return "", 0, 0, errors.Wrap(err, "Obtaining chain and network from CLN")
+	}
+	return info.Id, chain, network, nil
+}
@@ -0,0 +1,25 @@
+package settings
+
+import (
+	"testing"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestNodeBackends(t *testing.T) {
+	lnd, err := getNodeBackend(commons.LND)
+	if err != nil || !lnd.requiresCredentials() || !lnd.supportsPingServices() {
+		t.Errorf("expected the LND backend to require credentials and support ping services, got %v", err)
+	}
+	cln, err := getNodeBackend(commons.CLN)
+	if err != nil || cln.requiresCredentials() || cln.supportsPingServices() {
+		t.Errorf("expected the CLN backend without credentials and ping services, got %v", err)
+	}
+	if _, err := ConnectNode(ConnectionDetails{NodeId: 1, Implementation: commons.Implementation(99)}); err == nil {
+		t.Errorf("expected an error for an unsupported implementation")
+	}
+	if _, err := ConnectNode(ConnectionDetails{NodeId: 1, Implementation: commons.CLN,
+		GRPCAddress: "localhost:9735"}); err == nil {
+		t.Errorf("expected the CLN backend to refuse an address that isn't a unix socket")
+	}
+}
@@ -1,7 +1,6 @@
 package settings
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,13 +10,10 @@ import (
 	"github.com/cockroachdb/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	"github.com/lightningnetwork/lnd/lnrpc"
-	"github.com/rs/zerolog/log"
-	"google.golang.org/grpc"
 
+	"github.com/lncapital/torq/internal/auth"
 	"github.com/lncapital/torq/internal/nodes"
 	"github.com/lncapital/torq/pkg/commons"
-	"github.com/lncapital/torq/pkg/lnd_connect"
 	"github.com/lncapital/torq/pkg/server_errors"
 )
 
@@ -26,6 +22,7 @@ type settings struct {
 	DefaultLanguage   string `json:"defaultLanguage" db:"default_language"`
 	PreferredTimezone string `json:"preferredTimezone" db:"preferred_timezone"`
 	WeekStartsOn      string `json:"weekStartsOn" db:"week_starts_on"`
+	commons.ChannelRiskSettings
 }
 
 type timeZone struct {
@@ -35,6 +32,7 @@ type timeZone struct {
 type ConnectionDetails struct {
 	NodeId            int
 	Name              string
+	Implementation    commons.Implementation
 	GRPCAddress       string
 	TLSFileBytes      []byte
 	MacaroonFileBytes []byte
@@ -118,8 +116,12 @@ func startServiceOrRestartWhenRunning(serviceChannel chan commons.ServiceChannel
 func RegisterSettingRoutes(r *gin.RouterGroup, db *sqlx.DB, serviceChannel chan commons.ServiceChannelMessage) {
 	r.GET("", func(c *gin.Context) { getSettingsHandler(c, db) })
 	r.PUT("", func(c *gin.Context) { updateSettingsHandler(c, db) })
-	r.GET("nodeConnectionDetails", func(c *gin.Context) { getAllNodeConnectionDetailsHandler(c, db) })
-	r.GET("nodeConnectionDetails/:nodeId", func(c *gin.Context) { getNodeConnectionDetailsHandler(c, db) })
+	// The connection details contain the macaroons so only admins can read them
+	r.GET("nodeConnectionDetails", auth.RoleRequired(auth.Admin),
+		func(c *gin.Context) { getAllNodeConnectionDetailsHandler(c, db) })
+	r.GET("nodeConnectionDetails/:nodeId", auth.RoleRequired(auth.Admin),
+		func(c *gin.Context) { getNodeConnectionDetailsHandler(c, db) })
+	r.GET("nodeConnectionDetails/:nodeId/health", func(c *gin.Context) { getLndConnectionHealthHandler(c) })
 	r.POST("nodeConnectionDetails", func(c *gin.Context) { addNodeConnectionDetailsHandler(c, db, serviceChannel) })
 	r.PUT("nodeConnectionDetails", func(c *gin.Context) { setNodeConnectionDetailsHandler(c, db, serviceChannel) })
 	r.PUT("nodeConnectionDetails/:nodeId/:statusId", func(c *gin.Context) { setNodeConnectionDetailsStatusHandler(c, db, serviceChannel) })
@@ -152,6 +154,10 @@ func updateSettingsHandler(c *gin.Context, db *sqlx.DB) {
 		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
 		return
 	}
+	if err := validateChannelRiskSettings(settings.ChannelRiskSettings); err != nil {
+		server_errors.SendBadRequestFromError(c, err)
+		return
+	}
 	err := updateSettings(db, settings)
 	if err != nil {
 		server_errors.LogAndSendServerError(c, err)
@@ -160,6 +166,18 @@ func updateSettingsHandler(c *gin.Context, db *sqlx.DB) {
 	c.JSON(http.StatusOK, settings)
 }
 
+func validateChannelRiskSettings(channelRisk commons.ChannelRiskSettings) error {
+	if channelRisk.HtlcCriticalBlocks <= 0 || channelRisk.HtlcWarningBlocks < channelRisk.HtlcCriticalBlocks {
+		return errors.New("The HTLC warning blocks must be at least the HTLC critical blocks which must be positive")
+	}
+	if channelRisk.PeerOfflineWarningSeconds <= 0 ||
+		channelRisk.PeerOfflineCriticalSeconds < channelRisk.PeerOfflineWarningSeconds {
+		return errors.New("The peer offline critical seconds must be at least the peer offline warning seconds " +
+			"which must be positive")
+	}
+	return nil
+}
+
 func getAllNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB) {
 	node, err := getAllNodeConnectionDetails(db, false)
 	if err != nil {
@@ -183,6 +201,15 @@ func getNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB) {
 	c.JSON(http.StatusOK, ncd)
 }
 
+func getLndConnectionHealthHandler(c *gin.Context) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	c.JSON(http.StatusOK, GetLndConnectionHealth(nodeId))
+}
+
 func addNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB,
 	serviceChannel chan commons.ServiceChannelMessage) {
 
@@ -193,43 +220,57 @@ func addNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB,
 		return
 	}
 
-	if ncd.TLSFile == nil || ncd.MacaroonFile == nil || ncd.GRPCAddress == nil || *ncd.GRPCAddress == "" {
+	if ncd.GRPCAddress == nil || *ncd.GRPCAddress == "" {
 		server_errors.SendBadRequest(c, "All node details are required to add new node connection details")
 		return
 	}
-	tlsDataFile, err := ncd.TLSFile.Open()
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	tlsCert, err := io.ReadAll(tlsDataFile)
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	if len(tlsCert) == 0 {
-		server_errors.SendBadRequest(c, "Can't check new gRPC details without TLS Cert")
-		return
-	}
 
-	macaroonDataFile, err := ncd.MacaroonFile.Open()
+	backend, err := getNodeBackend(ncd.Implementation)
 	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	macaroonFile, err := io.ReadAll(macaroonDataFile)
-	if err != nil {
-		server_errors.LogAndSendServerError(c, err)
-		return
-	}
-	if len(macaroonFile) == 0 {
-		server_errors.SendBadRequest(c, "Can't check new gRPC details without Macaroon File")
+		server_errors.SendBadRequest(c, "Unsupported node implementation")
 		return
 	}
+	var tlsCert []byte
+	var macaroonFile []byte
+	if backend.requiresCredentials() {
+		if ncd.TLSFile == nil || ncd.MacaroonFile == nil {
+			server_errors.SendBadRequest(c, "All node details are required to add new node connection details")
+			return
+		}
+		tlsDataFile, err := ncd.TLSFile.Open()
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		tlsCert, err = io.ReadAll(tlsDataFile)
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		if len(tlsCert) == 0 {
+			server_errors.SendBadRequest(c, "Can't check new gRPC details without TLS Cert")
+			return
+		}
 
-	publicKey, chain, network, err := getInformationFromLndNode(*ncd.GRPCAddress, tlsCert, macaroonFile)
+		macaroonDataFile, err := ncd.MacaroonFile.Open()
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		macaroonFile, err = io.ReadAll(macaroonDataFile)
+		if err != nil {
+			server_errors.LogAndSendServerError(c, err)
+			return
+		}
+		if len(macaroonFile) == 0 {
+			server_errors.SendBadRequest(c, "Can't check new gRPC details without Macaroon File")
+			return
+		}
+
+	}
+	publicKey, chain, network, err := backend.getInformation(*ncd.GRPCAddress, tlsCert, macaroonFile)
 	if err != nil {
-		server_errors.WrapLogAndSendServerError(c, err, "Obtaining publicKey/chain/network from gRPC (gRPC connection fails)")
+		server_errors.WrapLogAndSendServerError(c, err, "Obtaining publicKey/chain/network from the node (connection fails)")
 		return
 	}
 	node, err := nodes.GetNodeByPublicKey(db, publicKey)
@@ -330,55 +371,30 @@ func setNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB,
 		ncd.TLSFileName = existingNcd.TLSFileName
 	}
 
+	backend, err := getNodeBackend(ncd.Implementation)
+	if err != nil {
+		server_errors.SendBadRequest(c, "Unsupported node implementation")
+		return
+	}
+
 	// if gRPC details have changed we need to check that the public keys (if existing) matches
 	if existingNcd.GRPCAddress != ncd.GRPCAddress {
-		var tlsCert []byte
-		if ncd.TLSFile != nil {
-			tlsDataFile, err := ncd.TLSFile.Open()
-			if err != nil {
-				server_errors.WrapLogAndSendServerError(c, err, "Opening TLS file")
-				return
-			}
-			tlsData, err := io.ReadAll(tlsDataFile)
-			if err != nil {
-				server_errors.WrapLogAndSendServerError(c, err, "Reading TLS file")
-				return
-			}
-			tlsCert = tlsData
-		}
-		if len(tlsCert) == 0 && len(existingNcd.TLSDataBytes) != 0 {
-			tlsCert = existingNcd.TLSDataBytes
-		}
-		if len(tlsCert) == 0 {
-			server_errors.LogAndSendServerError(c, errors.New("Can't check new gRPC details without TLS Cert"))
+		if ncd.GRPCAddress == nil || *ncd.GRPCAddress == "" {
+			server_errors.SendBadRequest(c, "Can't check new node details without an address")
 			return
 		}
-
+		var tlsCert []byte
 		var macaroonFile []byte
-		if ncd.MacaroonFile != nil {
-			macaroonDataFile, err := ncd.MacaroonFile.Open()
-			if err != nil {
-				server_errors.WrapLogAndSendServerError(c, err, "Opening Macaroon file")
-				return
-			}
-			macaroonData, err := io.ReadAll(macaroonDataFile)
+		if backend.requiresCredentials() {
+			tlsCert, macaroonFile, err = getUpdatedCredentials(ncd, existingNcd)
 			if err != nil {
-				server_errors.WrapLogAndSendServerError(c, err, "Reading Macaroon file")
+				server_errors.LogAndSendServerError(c, err)
 				return
 			}
-			macaroonFile = macaroonData
-		}
-		if len(macaroonFile) == 0 && len(existingNcd.MacaroonDataBytes) != 0 {
-			macaroonFile = existingNcd.MacaroonDataBytes
 		}
-		if len(macaroonFile) == 0 {
-			server_errors.LogAndSendServerError(c, errors.New("Can't check new gRPC details without Macaroon File"))
-			return
-		}
-
-		publicKey, chain, network, err := getInformationFromLndNode(*ncd.GRPCAddress, tlsCert, macaroonFile)
+		publicKey, chain, network, err := backend.getInformation(*ncd.GRPCAddress, tlsCert, macaroonFile)
 		if err != nil {
-			server_errors.WrapLogAndSendServerError(c, err, "Obtaining publicKey/chain/network from gRPC (gRPC connection fails)")
+			server_errors.WrapLogAndSendServerError(c, err, "Obtaining publicKey/chain/network from the node (connection fails)")
 			return
 		}
 
@@ -410,7 +426,13 @@ func setNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB,
 		server_errors.LogAndSendServerError(c, errors.New("Vector Ping Service is only allowed on Bitcoin Mainnet."))
 		return
 	}
+	if !backend.supportsPingServices() &&
+		(ncd.HasNotificationType(commons.Amboss) || ncd.HasNotificationType(commons.Vector)) {
+		server_errors.SendBadRequest(c, "Ping Services are only supported for LND nodes.")
+		return
+	}
 	commons.RunningServices[commons.LndService].SetIncludeIncomplete(ncd.NodeId, ncd.HasNodeConnectionDetailCustomSettings(commons.ImportFailedPayments))
+	commons.RunningServices[commons.LndService].SetStorePeerChannelPolicies(ncd.NodeId, ncd.HasNodeConnectionDetailCustomSettings(commons.StorePeerChannelPolicies))
 
 	lndDone := startServiceOrRestartWhenRunning(serviceChannel, commons.LndService, ncd.NodeId, ncd.Status == commons.Active)
 	ambossDone := startServiceOrRestartWhenRunning(serviceChannel, commons.AmbossService, ncd.NodeId, ncd.HasNotificationType(commons.Amboss))
@@ -421,6 +443,8 @@ func setNodeConnectionDetailsHandler(c *gin.Context, db *sqlx.DB,
 			server_errors.WrapLogAndSendServerError(c, err, "Updating connection details")
 			return
 		}
+		// The handlers reuse the shared connection without checking the details
+		RemoveLndConnection(ncd.NodeId)
 	} else {
 		server_errors.LogAndSendServerError(c, errors.New("Service could not be stopped please try again."))
 		return
@@ -450,6 +474,9 @@ func setNodeConnectionDetailsStatusHandler(c *gin.Context, db *sqlx.DB,
 			server_errors.LogAndSendServerError(c, err)
 			return
 		}
+		if commons.Status(statusId) != commons.Active {
+			RemoveLndConnection(nodeId)
+		}
 	} else {
 		server_errors.LogAndSendServerError(c, errors.New("Service could not be stopped please try again."))
 		return
@@ -537,11 +564,19 @@ func GetVectorPingNodesConnectionDetails(db *sqlx.DB) ([]ConnectionDetails, erro
 func processConnectionDetails(ncds []NodeConnectionDetails) []ConnectionDetails {
 	var processedNodes []ConnectionDetails
 	for _, ncd := range ncds {
-		if ncd.GRPCAddress == nil || ncd.TLSDataBytes == nil || ncd.MacaroonDataBytes == nil {
+		if ncd.GRPCAddress == nil {
+			continue
+		}
+		backend, err := getNodeBackend(ncd.Implementation)
+		if err != nil {
+			continue
+		}
+		if backend.requiresCredentials() && (ncd.TLSDataBytes == nil || ncd.MacaroonDataBytes == nil) {
 			continue
 		}
 		processedNodes = append(processedNodes, ConnectionDetails{
 			NodeId:            ncd.NodeId,
+			Implementation:    ncd.Implementation,
 			GRPCAddress:       *ncd.GRPCAddress,
 			TLSFileBytes:      ncd.TLSDataBytes,
 			MacaroonFileBytes: ncd.MacaroonDataBytes,
@@ -561,6 +596,7 @@ func GetConnectionDetailsById(db *sqlx.DB, nodeId int) (ConnectionDetails, error
 	}
 	cd := ConnectionDetails{
 		NodeId:            ncd.NodeId,
+		Implementation:    ncd.Implementation,
 		TLSFileBytes:      ncd.TLSDataBytes,
 		MacaroonFileBytes: ncd.MacaroonDataBytes,
 		Name:              ncd.Name,
@@ -574,56 +610,44 @@ func GetConnectionDetailsById(db *sqlx.DB, nodeId int) (ConnectionDetails, error
 	return cd, nil
 }
 
-func getInformationFromLndNode(grpcAddress string, tlsCert []byte, macaroonFile []byte) (
-	string, commons.Chain, commons.Network, error) {
-	conn, err := lnd_connect.Connect(grpcAddress, tlsCert, macaroonFile)
-	if err != nil {
-		return "", 0, 0, errors.Wrap(err,
-			"Can't connect to node to verify public key, check all details including TLS Cert and Macaroon")
-	}
-	defer func(conn *grpc.ClientConn) {
-		err := conn.Close()
+// getUpdatedCredentials the uploaded TLS Cert and Macaroon falling back to the stored ones
+func getUpdatedCredentials(ncd NodeConnectionDetails, existingNcd NodeConnectionDetails) ([]byte, []byte, error) {
+	var tlsCert []byte
+	if ncd.TLSFile != nil {
+		tlsDataFile, err := ncd.TLSFile.Open()
 		if err != nil {
-			log.Debug().Err(err).Msg("Failed to close gRPC connection.")
+			return nil, nil, errors.Wrap(err, "Opening TLS file")
 		}
-	}(conn)
+		tlsCert, err = io.ReadAll(tlsDataFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Reading TLS file")
+		}
+	}
+	if len(tlsCert) == 0 && len(existingNcd.TLSDataBytes) != 0 {
+		tlsCert = existingNcd.TLSDataBytes
+	}
+	if len(tlsCert) == 0 {
+		return nil, nil, errors.New("Can't check new gRPC details without TLS Cert")
+	}
 
-	client := lnrpc.NewLightningClient(conn)
-	ctx := context.Background()
-	info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
-	if err != nil {
-		return "", 0, 0, errors.Wrap(err, "Obtaining information from LND")
-	}
-	if len(info.Chains) != 1 {
-		return "", 0, 0, errors.Wrapf(err, "Obtaining chains from LND %v", info.Chains)
-	}
-
-	var chain commons.Chain
-	switch info.Chains[0].Chain {
-	case "bitcoin":
-		chain = commons.Bitcoin
-	case "litecoin":
-		chain = commons.Litecoin
-	default:
-		return "", 0, 0, errors.Wrapf(err, "Obtaining chain from LND %v", info.Chains[0].Chain)
-	}
-
-	var network commons.Network
-	switch info.Chains[0].Network {
-	case "mainnet":
-		network = commons.MainNet
-	case "testnet":
-		network = commons.TestNet
-	case "signet":
-		network = commons.SigNet
-	case "simnet":
-		network = commons.SimNet
-	case "regtest":
-		network = commons.RegTest
-	default:
-		return "", 0, 0, errors.Wrapf(err, "Obtaining network from LND %v", info.Chains[0].Network)
-	}
-	return info.IdentityPubkey, chain, network, nil
+	var macaroonFile []byte
+	if ncd.MacaroonFile != nil {
+		macaroonDataFile, err := ncd.MacaroonFile.Open()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Opening Macaroon file")
+		}
+		macaroonFile, err = io.ReadAll(macaroonDataFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Reading Macaroon file")
+		}
+	}
+	if len(macaroonFile) == 0 && len(existingNcd.MacaroonDataBytes) != 0 {
+		macaroonFile = existingNcd.MacaroonDataBytes
+	}
+	if len(macaroonFile) == 0 {
+		return nil, nil, errors.New("Can't check new gRPC details without Macaroon File")
+	}
+	return tlsCert, macaroonFile, nil
 }
 
 func processTLS(ncd NodeConnectionDetails) (NodeConnectionDetails, error) {
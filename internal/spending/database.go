@@ -0,0 +1,148 @@
+package spending
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/database"
+)
+
+// spendingLockKey the first key of the advisory lock, the second key is the node id
+const spendingLockKey = 64
+
+func getSpendingLimits(db *sqlx.DB) ([]SpendingLimit, error) {
+	spendingLimits := []SpendingLimit{}
+	err := db.Select(&spendingLimits, `SELECT * FROM spending_limit ORDER BY node_id;`)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return spendingLimits, nil
+}
+
+func getSpendingLimit(tx *sqlx.Tx, nodeId int) (SpendingLimit, bool, error) {
+	var spendingLimit SpendingLimit
+	err := tx.Get(&spendingLimit, `SELECT * FROM spending_limit WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SpendingLimit{}, false, nil
+		}
+		return SpendingLimit{}, false, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return spendingLimit, true, nil
+}
+
+func setSpendingLimit(db *sqlx.DB, spendingLimit SpendingLimit) (SpendingLimit, error) {
+	now := time.Now().UTC()
+	err := db.QueryRowx(`
+		INSERT INTO spending_limit (node_id, max_payment_sat, max_on_chain_sat, max_channel_open_sat, max_daily_sat,
+			created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (node_id) DO UPDATE SET max_payment_sat=EXCLUDED.max_payment_sat,
+			max_on_chain_sat=EXCLUDED.max_on_chain_sat, max_channel_open_sat=EXCLUDED.max_channel_open_sat,
+			max_daily_sat=EXCLUDED.max_daily_sat, updated_on=EXCLUDED.updated_on
+		RETURNING created_on, updated_on;`,
+		spendingLimit.NodeId, spendingLimit.MaxPaymentSat, spendingLimit.MaxOnChainSat,
+		spendingLimit.MaxChannelOpenSat, spendingLimit.MaxDailySat, now).
+		Scan(&spendingLimit.CreatedOn, &spendingLimit.UpdatedOn)
+	if err != nil {
+		return SpendingLimit{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return spendingLimit, nil
+}
+
+func removeSpendingLimit(db *sqlx.DB, nodeId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM spending_limit WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+// getSpentSat the total of the requests that were executed (or are being executed) since the time
+func getSpentSat(tx *sqlx.Tx, nodeId int, since time.Time) (int64, error) {
+	var spentSat int64
+	err := tx.QueryRowx(`
+		SELECT COALESCE(SUM(amount_sat), 0)
+		FROM spending_request
+		WHERE node_id=$1 AND status IN ($2, $3) AND COALESCE(decided_on, created_on) > $4;`,
+		nodeId, Approved, Executed, since).Scan(&spentSat)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return spentSat, nil
+}
+
+func getSpendingRequests(db *sqlx.DB, status *Status) ([]SpendingRequest, error) {
+	spendingRequests := []SpendingRequest{}
+	err := db.Select(&spendingRequests, `
+		SELECT *
+		FROM spending_request
+		WHERE $1::TEXT IS NULL OR status=$1
+		ORDER BY created_on DESC
+		LIMIT 1000;`, status)
+	if err != nil {
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return spendingRequests, nil
+}
+
+func getSpendingRequestForUpdate(tx *sqlx.Tx, spendingRequestId int) (SpendingRequest, error) {
+	var spendingRequest SpendingRequest
+	err := tx.Get(&spendingRequest, `SELECT * FROM spending_request WHERE spending_request_id=$1 FOR UPDATE;`,
+		spendingRequestId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SpendingRequest{}, errors.Wrapf(err, "Spending request %v not found", spendingRequestId)
+		}
+		return SpendingRequest{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return spendingRequest, nil
+}
+
+func addSpendingRequest(tx *sqlx.Tx, request SpendingRequest) (SpendingRequest, error) {
+	err := tx.QueryRowx(`
+		INSERT INTO spending_request (node_id, request_type, amount_sat, request, req_id, reasons, status,
+			requested_by, requested_by_user_id, requested_by_api_token_id, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING spending_request_id;`,
+		request.NodeId, request.RequestType, request.AmountSat, request.Request, request.ReqId, request.Reasons,
+		request.Status, request.RequestedBy, request.RequestedByUserId, request.RequestedByApiTokenId,
+		request.CreatedOn, request.UpdatedOn).
+		Scan(&request.SpendingRequestId)
+	if err != nil {
+		return SpendingRequest{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return request, nil
+}
+
+func setSpendingRequestDecision(tx *sqlx.Tx, request SpendingRequest) error {
+	_, err := tx.Exec(`
+		UPDATE spending_request
+		SET status=$2, decided_by=$3, decided_by_user_id=$4, decided_by_api_token_id=$5, decided_on=$6, updated_on=$6
+		WHERE spending_request_id=$1;`,
+		request.SpendingRequestId, request.Status, request.DecidedBy, request.DecidedByUserId,
+		request.DecidedByApiTokenId, request.DecidedOn)
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
+
+func setSpendingRequestOutcome(db *sqlx.DB, spendingRequestId int, status Status, errorMessage *string) error {
+	_, err := db.Exec(`
+		UPDATE spending_request
+		SET status=$2, error=$3, updated_on=$4
+		WHERE spending_request_id=$1;`,
+		spendingRequestId, status, errorMessage, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	return nil
+}
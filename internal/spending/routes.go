@@ -0,0 +1,114 @@
+package spending
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterSpendingRoutes(r *gin.RouterGroup, db *sqlx.DB, eventChannel chan interface{}, executors Executors) {
+	r.GET("limits", func(c *gin.Context) { getSpendingLimitsHandler(c, db) })
+	r.PUT("limits", auth.RoleRequired(auth.Admin), func(c *gin.Context) { setSpendingLimitHandler(c, db) })
+	r.DELETE("limits/:nodeId", auth.RoleRequired(auth.Admin), func(c *gin.Context) { removeSpendingLimitHandler(c, db) })
+	// i.e. the approval queue: requests?status=PENDING
+	r.GET("requests", func(c *gin.Context) { getSpendingRequestsHandler(c, db) })
+	r.POST("requests/:spendingRequestId/approve",
+		func(c *gin.Context) { decideSpendingRequestHandler(c, db, eventChannel, executors, true) })
+	r.POST("requests/:spendingRequestId/reject",
+		func(c *gin.Context) { decideSpendingRequestHandler(c, db, eventChannel, executors, false) })
+}
+
+func getSpendingLimitsHandler(c *gin.Context, db *sqlx.DB) {
+	spendingLimits, err := getSpendingLimits(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting spending limits.")
+		return
+	}
+	c.JSON(http.StatusOK, spendingLimits)
+}
+
+func setSpendingLimitHandler(c *gin.Context, db *sqlx.DB) {
+	var spendingLimit SpendingLimit
+	if err := c.BindJSON(&spendingLimit); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if spendingLimit.NodeId == 0 {
+		server_errors.SendBadRequest(c, "Failed to find nodeId in the request.")
+		return
+	}
+	for _, limit := range []*int64{spendingLimit.MaxPaymentSat, spendingLimit.MaxOnChainSat,
+		spendingLimit.MaxChannelOpenSat, spendingLimit.MaxDailySat} {
+		if limit != nil && *limit < 0 {
+			server_errors.SendUnprocessableEntity(c, "Spending limits can't be negative.")
+			return
+		}
+	}
+	spendingLimit, err := setSpendingLimit(db, spendingLimit)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Setting spending limit.")
+		return
+	}
+	c.JSON(http.StatusOK, spendingLimit)
+}
+
+func removeSpendingLimitHandler(c *gin.Context, db *sqlx.DB) {
+	nodeId, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse nodeId in the request.")
+		return
+	}
+	count, err := removeSpendingLimit(db, nodeId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing spending limit for nodeId: %v", nodeId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v spending limit(s).", count)})
+}
+
+func getSpendingRequestsHandler(c *gin.Context, db *sqlx.DB) {
+	var status *Status
+	if c.Query("status") != "" {
+		statusValue := Status(c.Query("status"))
+		status = &statusValue
+	}
+	spendingRequests, err := getSpendingRequests(db, status)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting spending requests.")
+		return
+	}
+	c.JSON(http.StatusOK, spendingRequests)
+}
+
+func decideSpendingRequestHandler(c *gin.Context, db *sqlx.DB, eventChannel chan interface{}, executors Executors,
+	approve bool) {
+
+	spendingRequestId, err := strconv.Atoi(c.Param("spendingRequestId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse spendingRequestId in the request.")
+		return
+	}
+	actor, ok := auth.GetActor(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	request, err := decide(db, eventChannel, executors, actor, spendingRequestId, approve)
+	if err != nil {
+		if errors.Is(err, errNotPending) || errors.Is(err, errSameApprover) {
+			server_errors.SendUnprocessableEntity(c, err.Error())
+			return
+		}
+		server_errors.WrapLogAndSendServerError(c, err,
+			fmt.Sprintf("Deciding spending request for spendingRequestId: %v", spendingRequestId))
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
@@ -0,0 +1,395 @@
+package spending
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type RequestType string
+
+const (
+	Payment          = RequestType("PAYMENT")
+	OnChain          = RequestType("ON_CHAIN")
+	ChannelOpen      = RequestType("CHANNEL_OPEN")
+	BatchChannelOpen = RequestType("BATCH_CHANNEL_OPEN")
+	// Rebalance only the fee budget leaves the node, the rebalanced amount returns to it
+	Rebalance = RequestType("REBALANCE")
+)
+
+type Status string
+
+const (
+	// Pending the request exceeded a limit and waits for the approval of another user (or token)
+	Pending = Status("PENDING")
+	// Approved the request is being executed, either it was within the limits or it was approved
+	Approved = Status("APPROVED")
+	Rejected = Status("REJECTED")
+	Executed = Status("EXECUTED")
+	Failed   = Status("FAILED")
+)
+
+// dailyWindow the period of the rolling daily total
+const dailyWindow = 24 * time.Hour
+
+// SpendingLimit when a limit is nil it's not applied
+type SpendingLimit struct {
+	NodeId            int    `json:"nodeId" db:"node_id"`
+	MaxPaymentSat     *int64 `json:"maxPaymentSat" db:"max_payment_sat"`
+	MaxOnChainSat     *int64 `json:"maxOnChainSat" db:"max_on_chain_sat"`
+	MaxChannelOpenSat *int64 `json:"maxChannelOpenSat" db:"max_channel_open_sat"`
+	// MaxDailySat the maximum of the rolling total of the last 24 hours
+	MaxDailySat *int64    `json:"maxDailySat" db:"max_daily_sat"`
+	CreatedOn   time.Time `json:"createdOn" db:"created_on"`
+	UpdatedOn   time.Time `json:"updatedOn" db:"updated_on"`
+}
+
+type SpendingRequest struct {
+	SpendingRequestId int         `json:"spendingRequestId" db:"spending_request_id"`
+	NodeId            int         `json:"nodeId" db:"node_id"`
+	RequestType       RequestType `json:"requestType" db:"request_type"`
+	// AmountSat is nil when the amount is unknown (i.e. sending all on-chain funds)
+	AmountSat *int64         `json:"amountSat" db:"amount_sat"`
+	Request   types.JSONText `json:"request" db:"request"`
+	// ReqId the websocket request id so the responses of an approved request reach the requester
+	ReqId                 *string        `json:"reqId" db:"req_id"`
+	Reasons               pq.StringArray `json:"reasons" db:"reasons"`
+	Status                Status         `json:"status" db:"status"`
+	RequestedBy           string         `json:"requestedBy" db:"requested_by"`
+	RequestedByUserId     *int           `json:"requestedByUserId" db:"requested_by_user_id"`
+	RequestedByApiTokenId *int           `json:"requestedByApiTokenId" db:"requested_by_api_token_id"`
+	DecidedBy             *string        `json:"decidedBy" db:"decided_by"`
+	DecidedByUserId       *int           `json:"decidedByUserId" db:"decided_by_user_id"`
+	DecidedByApiTokenId   *int           `json:"decidedByApiTokenId" db:"decided_by_api_token_id"`
+	DecidedOn             *time.Time     `json:"decidedOn" db:"decided_on"`
+	Error                 *string        `json:"error" db:"error"`
+	CreatedOn             time.Time      `json:"createdOn" db:"created_on"`
+	UpdatedOn             time.Time      `json:"updatedOn" db:"updated_on"`
+}
+
+// Requester returns the actor that requested the spending, the role is unknown
+func (request SpendingRequest) Requester() auth.Actor {
+	return auth.Actor{
+		UserId:     request.RequestedByUserId,
+		Username:   request.RequestedBy,
+		ApiTokenId: request.RequestedByApiTokenId,
+	}
+}
+
+// Executor executes an approved request, the responses are sent to the event channel
+type Executor func(request SpendingRequest, eventChannel chan interface{}) error
+
+type Executors map[RequestType]Executor
+
+func CheckPayment(db *sqlx.DB, eventChannel chan interface{}, actor auth.Actor, npReq commons.NewPaymentRequest,
+	reqId string) (SpendingRequest, error) {
+
+	amountsSat, err := paymentAmountsSat(npReq, commons.GetNodeSettingsByNodeId(npReq.NodeId).Network)
+	if err != nil {
+		return SpendingRequest{}, err
+	}
+	return check(db, eventChannel, actor, npReq.NodeId, Payment, amountsSat, npReq, reqId)
+}
+
+func CheckOnChain(db *sqlx.DB, eventChannel chan interface{}, actor auth.Actor,
+	req commons.PayOnChainRequest) (SpendingRequest, error) {
+
+	var amountsSat []int64
+	if req.SendAll == nil || !*req.SendAll {
+		amountsSat = []int64{req.AmountSat}
+	}
+	return check(db, eventChannel, actor, req.NodeId, OnChain, amountsSat, req, "")
+}
+
+func CheckChannelOpen(db *sqlx.DB, eventChannel chan interface{}, actor auth.Actor, req commons.OpenChannelRequest,
+	reqId string) (SpendingRequest, error) {
+
+	return check(db, eventChannel, actor, req.NodeId, ChannelOpen, []int64{req.LocalFundingAmount}, req, reqId)
+}
+
+func CheckBatchChannelOpen(db *sqlx.DB, eventChannel chan interface{}, actor auth.Actor,
+	req commons.BatchOpenRequest) (SpendingRequest, error) {
+
+	amountsSat := make([]int64, 0, len(req.Channels))
+	for _, channel := range req.Channels {
+		amountsSat = append(amountsSat, channel.LocalFundingAmount)
+	}
+	return check(db, eventChannel, actor, req.NodeId, BatchChannelOpen, amountsSat, req, "")
+}
+
+func CheckRebalance(db *sqlx.DB, eventChannel chan interface{}, actor auth.Actor, req commons.RebalanceRequest,
+	reqId string) (SpendingRequest, error) {
+
+	feeBudgetSat := int64((req.FeeBudgetMsat + 999) / 1000)
+	return check(db, eventChannel, actor, req.NodeId, Rebalance, []int64{feeBudgetSat}, req, reqId)
+}
+
+// check records the spending request of the actor. When the request exceeds one of the limits of the node its status
+// is PENDING and it's announced on the event channel, it's executed once another user (or token) approves it.
+// Otherwise its status is APPROVED and the caller executes it and records the outcome with Finish.
+func check(db *sqlx.DB, eventChannel chan interface{}, actor auth.Actor, nodeId int, requestType RequestType,
+	amountsSat []int64, request interface{}, reqId string) (SpendingRequest, error) {
+
+	requestJson, err := json.Marshal(request)
+	if err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "JSON marshal")
+	}
+	now := time.Now().UTC()
+	spendingRequest := SpendingRequest{
+		NodeId:                nodeId,
+		RequestType:           requestType,
+		Request:               types.JSONText(requestJson),
+		Reasons:               pq.StringArray{},
+		Status:                Approved,
+		RequestedBy:           actor.Username,
+		RequestedByUserId:     actor.UserId,
+		RequestedByApiTokenId: actor.ApiTokenId,
+		CreatedOn:             now,
+		UpdatedOn:             now,
+	}
+	if amountsSat != nil {
+		amountSat := sum(amountsSat)
+		spendingRequest.AmountSat = &amountSat
+	}
+	if reqId != "" {
+		spendingRequest.ReqId = &reqId
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Starting the transaction")
+	}
+	defer func() {
+		// Rollback is a no-op when the transaction is committed
+		_ = tx.Rollback()
+	}()
+	// Concurrent requests of the same node are serialized so they can't both stay within the daily total
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1, $2);`, spendingLockKey, nodeId); err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Locking the spending requests of the node")
+	}
+	limit, exists, err := getSpendingLimit(tx, nodeId)
+	if err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Getting the spending limit")
+	}
+	if exists {
+		spentSat, err := getSpentSat(tx, nodeId, now.Add(-dailyWindow))
+		if err != nil {
+			return SpendingRequest{}, errors.Wrap(err, "Getting the daily total")
+		}
+		spendingRequest.Reasons = evaluate(limit, requestType, amountsSat, spentSat)
+		if len(spendingRequest.Reasons) != 0 {
+			spendingRequest.Status = Pending
+		}
+	}
+	spendingRequest, err = addSpendingRequest(tx, spendingRequest)
+	if err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Recording the spending request")
+	}
+	if err = tx.Commit(); err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Committing the spending request")
+	}
+	if spendingRequest.Status == Pending {
+		announce(eventChannel, spendingRequest)
+	}
+	return spendingRequest, nil
+}
+
+// Finish records the outcome of an APPROVED request
+func Finish(db *sqlx.DB, request SpendingRequest, executionErr error) {
+	status := Executed
+	var errorMessage *string
+	if executionErr != nil {
+		status = Failed
+		message := executionErr.Error()
+		errorMessage = &message
+	}
+	if err := setSpendingRequestOutcome(db, request.SpendingRequestId, status, errorMessage); err != nil {
+		log.Error().Err(err).Msgf("Failed to record the outcome of spending request %v", request.SpendingRequestId)
+	}
+}
+
+// evaluate returns the limits the request exceeds, when amountsSat is nil the amount is unknown
+func evaluate(limit SpendingLimit, requestType RequestType, amountsSat []int64, spentSat int64) []string {
+	maximum, description := limit.maximum(requestType)
+	reasons := []string{}
+	if amountsSat == nil {
+		if maximum != nil || limit.MaxDailySat != nil {
+			reasons = append(reasons, "the amount is unknown")
+		}
+		return reasons
+	}
+	for _, amountSat := range amountsSat {
+		if maximum != nil && amountSat > *maximum {
+			reasons = append(reasons, fmt.Sprintf("%v sat exceeds the maximum of %v sat per %v",
+				amountSat, *maximum, description))
+		}
+	}
+	if limit.MaxDailySat != nil && spentSat+sum(amountsSat) > *limit.MaxDailySat {
+		remainingSat := *limit.MaxDailySat - spentSat
+		if remainingSat < 0 {
+			remainingSat = 0
+		}
+		reasons = append(reasons, fmt.Sprintf("%v sat exceeds the remaining daily total of %v sat",
+			sum(amountsSat), remainingSat))
+	}
+	return reasons
+}
+
+func (limit SpendingLimit) maximum(requestType RequestType) (*int64, string) {
+	switch requestType {
+	case Payment, Rebalance:
+		return limit.MaxPaymentSat, "payment"
+	case OnChain:
+		return limit.MaxOnChainSat, "on-chain send"
+	case ChannelOpen, BatchChannelOpen:
+		return limit.MaxChannelOpenSat, "channel open"
+	}
+	return nil, ""
+}
+
+// paymentAmountsSat the amount of the payment including the fee limit (rounded up), nil when the amount is unknown
+func paymentAmountsSat(npReq commons.NewPaymentRequest, network commons.Network) ([]int64, error) {
+	var amountMsat int64
+	if npReq.AmtMSat != nil {
+		amountMsat = *npReq.AmtMSat
+	} else if npReq.Invoice != nil {
+		invoice, err := zpay32.Decode(*npReq.Invoice, chainParams(network))
+		if err != nil {
+			return nil, errors.Wrap(err, "Decoding the invoice")
+		}
+		if invoice.MilliSat != nil {
+			amountMsat = int64(*invoice.MilliSat)
+		}
+	}
+	if amountMsat == 0 {
+		return nil, nil
+	}
+	if npReq.FeeLimitMsat != nil {
+		amountMsat += *npReq.FeeLimitMsat
+	}
+	return []int64{(amountMsat + 999) / 1000}, nil
+}
+
+func chainParams(network commons.Network) *chaincfg.Params {
+	switch network {
+	case commons.TestNet:
+		return &chaincfg.TestNet3Params
+	case commons.RegTest:
+		return &chaincfg.RegressionNetParams
+	case commons.SigNet:
+		return &chaincfg.SigNetParams
+	case commons.SimNet:
+		return &chaincfg.SimNetParams
+	}
+	return &chaincfg.MainNetParams
+}
+
+func sum(amountsSat []int64) int64 {
+	var total int64
+	for _, amountSat := range amountsSat {
+		total += amountSat
+	}
+	return total
+}
+
+// isSameApprover the user has to be different, a token of the requester counts as the requester
+func isSameApprover(request SpendingRequest, actor auth.Actor) bool {
+	if request.RequestedBy == actor.Username {
+		return true
+	}
+	return request.RequestedByUserId != nil && actor.UserId != nil && *request.RequestedByUserId == *actor.UserId
+}
+
+func announce(eventChannel chan interface{}, request SpendingRequest) {
+	if eventChannel == nil {
+		return
+	}
+	eventChannel <- commons.SpendingRequestEvent{
+		EventData: commons.EventData{
+			EventTime: time.Now().UTC(),
+			NodeId:    request.NodeId,
+		},
+		SpendingRequestId: request.SpendingRequestId,
+		RequestType:       string(request.RequestType),
+		AmountSat:         request.AmountSat,
+		Reasons:           request.Reasons,
+		Status:            string(request.Status),
+		RequestedBy:       request.RequestedBy,
+		DecidedBy:         request.DecidedBy,
+	}
+}
+
+var (
+	errNotPending   = errors.New("the spending request is not pending")
+	errSameApprover = errors.New("the spending request has to be decided by another user or token")
+)
+
+// decide approves or rejects a PENDING request. The requester can reject (cancel) its own request but only another
+// user (or token) can approve it. An approved request is executed in the background by the executor of its type.
+func decide(db *sqlx.DB, eventChannel chan interface{}, executors Executors, actor auth.Actor, spendingRequestId int,
+	approve bool) (SpendingRequest, error) {
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Starting the transaction")
+	}
+	defer func() {
+		// Rollback is a no-op when the transaction is committed
+		_ = tx.Rollback()
+	}()
+	request, err := getSpendingRequestForUpdate(tx, spendingRequestId)
+	if err != nil {
+		return SpendingRequest{}, err
+	}
+	if request.Status != Pending {
+		return SpendingRequest{}, errNotPending
+	}
+	execute := executors[request.RequestType]
+	if approve {
+		if isSameApprover(request, actor) {
+			return SpendingRequest{}, errSameApprover
+		}
+		if execute == nil {
+			return SpendingRequest{}, errors.Newf("No executor for %v requests", request.RequestType)
+		}
+	}
+	now := time.Now().UTC()
+	request.Status = Rejected
+	if approve {
+		request.Status = Approved
+	}
+	request.DecidedBy = &actor.Username
+	request.DecidedByUserId = actor.UserId
+	request.DecidedByApiTokenId = actor.ApiTokenId
+	request.DecidedOn = &now
+	request.UpdatedOn = now
+	if err = setSpendingRequestDecision(tx, request); err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Recording the decision")
+	}
+	if err = tx.Commit(); err != nil {
+		return SpendingRequest{}, errors.Wrap(err, "Committing the decision")
+	}
+	announce(eventChannel, request)
+
+	if approve {
+		go func() {
+			err := execute(request, eventChannel)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to execute approved spending request %v", request.SpendingRequestId)
+			}
+			Finish(db, request, err)
+		}()
+	}
+	return request, nil
+}
@@ -0,0 +1,105 @@
+package spending
+
+import (
+	"testing"
+
+	"github.com/lncapital/torq/internal/auth"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestEvaluate(t *testing.T) {
+	maxPaymentSat := int64(10000)
+	maxChannelOpenSat := int64(1000000)
+	maxDailySat := int64(50000)
+	limit := SpendingLimit{MaxPaymentSat: &maxPaymentSat, MaxChannelOpenSat: &maxChannelOpenSat, MaxDailySat: &maxDailySat}
+
+	tests := []struct {
+		name        string
+		limit       SpendingLimit
+		requestType RequestType
+		amountsSat  []int64
+		spentSat    int64
+		reasons     int
+	}{
+		{"within the limits", limit, Payment, []int64{10000}, 0, 0},
+		{"above the payment maximum", limit, Payment, []int64{10001}, 0, 1},
+		{"above the daily total", limit, Payment, []int64{5000}, 46000, 1},
+		{"above both", limit, Payment, []int64{20000}, 40000, 2},
+		{"rebalance fee budget within the payment maximum", limit, Rebalance, []int64{250}, 0, 0},
+		{"rebalance fee budget above the payment maximum", limit, Rebalance, []int64{10001}, 0, 1},
+		{"no on-chain maximum", limit, OnChain, []int64{40000}, 0, 0},
+		{"unknown amount", limit, OnChain, nil, 0, 1},
+		{"unknown amount without limits", SpendingLimit{}, OnChain, nil, 0, 0},
+		{"batch channel open per channel", SpendingLimit{MaxChannelOpenSat: &maxChannelOpenSat}, BatchChannelOpen,
+			[]int64{600000, 600000}, 0, 0},
+		{"batch channel open above the maximum", SpendingLimit{MaxChannelOpenSat: &maxChannelOpenSat},
+			BatchChannelOpen, []int64{600000, 1200000}, 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reasons := evaluate(test.limit, test.requestType, test.amountsSat, test.spentSat)
+			if len(reasons) != test.reasons {
+				t.Errorf("expected %v reasons, got %v", test.reasons, reasons)
+			}
+		})
+	}
+}
+
+func TestPaymentAmountsSat(t *testing.T) {
+	invoice := "lnbc142250n1psju2jfpp5jvgszhxwanal28cfxe7yr5tjmayfh4ehlk4ms504nyswx2qjf0psdz0235x2grsw" +
+		"fjhqcted4jkuapqvehhygrpyp3ksctwdejkcgr0wpjku6twvusxzapqf38yy6289e3k7mgcqzpgxqrpxasp58zj7e3f4dadfsrz" +
+		"wdv92e4j6vcst5ykvrxa47y9vp7x0h05r0fss9qy9qsq5dxkhqfj7ledlq8q7l9xfnlzwfvzwj2zv9u7sewumjxc0q2p4dmptah" +
+		"n9xdkqcxumd0z6ks3ms7jf86hllm44hv7xkgmzys72xc4zqqp96ut9z"
+	feeLimitMsat := int64(1500)
+	amountsSat, err := paymentAmountsSat(commons.NewPaymentRequest{Invoice: &invoice, FeeLimitMsat: &feeLimitMsat},
+		commons.MainNet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(amountsSat) != 1 || amountsSat[0] != 14227 {
+		t.Errorf("expected the invoice amount with the fee limit rounded up, got %v", amountsSat)
+	}
+
+	amtMsat := int64(2000)
+	amountsSat, err = paymentAmountsSat(commons.NewPaymentRequest{AmtMSat: &amtMsat}, commons.MainNet)
+	if err != nil || len(amountsSat) != 1 || amountsSat[0] != 2 {
+		t.Errorf("expected 2 sat, got %v (%v)", amountsSat, err)
+	}
+
+	if amountsSat, err = paymentAmountsSat(commons.NewPaymentRequest{}, commons.MainNet); err != nil || amountsSat != nil {
+		t.Errorf("expected an unknown amount, got %v (%v)", amountsSat, err)
+	}
+
+	if _, err = paymentAmountsSat(commons.NewPaymentRequest{Invoice: &invoice}, commons.TestNet); err == nil {
+		t.Errorf("expected an error for an invoice of another network")
+	}
+}
+
+func TestIsSameApprover(t *testing.T) {
+	tokenId := 3
+	otherTokenId := 4
+	userId := 7
+	otherUserId := 8
+	request := SpendingRequest{RequestedBy: "alice", RequestedByUserId: &userId}
+	if !isSameApprover(request, auth.Actor{Username: "alice", UserId: &userId}) {
+		t.Errorf("expected the requester to be the same approver")
+	}
+	if isSameApprover(request, auth.Actor{Username: "bob", UserId: &otherUserId}) {
+		t.Errorf("expected another user to be another approver")
+	}
+	if !isSameApprover(request, auth.Actor{Username: "alice", UserId: &userId, ApiTokenId: &tokenId}) {
+		t.Errorf("expected a token of the requester to be the same approver")
+	}
+	request.RequestedByApiTokenId = &tokenId
+	if !isSameApprover(request, auth.Actor{Username: "alice", UserId: &userId, ApiTokenId: &otherTokenId}) {
+		t.Errorf("expected the same user with a different token to be the same approver")
+	}
+	if !isSameApprover(request, auth.Actor{Username: "alice", UserId: &userId}) {
+		t.Errorf("expected the same user without a token to be the same approver")
+	}
+	// The built-in admin user has no user id
+	request = SpendingRequest{RequestedBy: "admin"}
+	if !isSameApprover(request, auth.Actor{Username: "admin", ApiTokenId: &tokenId}) {
+		t.Errorf("expected the built-in admin with a token to be the same approver")
+	}
+}
@@ -0,0 +1,154 @@
+package workflows
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/database"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func getWorkflow(db *sqlx.DB, workflowId int) (Workflow, error) {
+	var wf Workflow
+	err := db.Get(&wf, `SELECT * FROM workflow WHERE workflow_id=$1;`, workflowId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Workflow{}, nil
+		}
+		return Workflow{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return wf, nil
+}
+
+func getWorkflows(db *sqlx.DB) ([]Workflow, error) {
+	var wfs []Workflow
+	err := db.Select(&wfs, `SELECT * FROM workflow ORDER BY workflow_id;`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Workflow{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return wfs, nil
+}
+
+func getActiveWorkflowsByNodeId(db *sqlx.DB, nodeId int) ([]Workflow, error) {
+	var wfs []Workflow
+	err := db.Select(&wfs, `SELECT * FROM workflow WHERE node_id=$1 AND status=$2 ORDER BY workflow_id;`,
+		nodeId, commons.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []Workflow{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return wfs, nil
+}
+
+func addWorkflow(db *sqlx.DB, wf Workflow) (Workflow, error) {
+	wf.CreatedOn = time.Now().UTC()
+	wf.UpdateOn = wf.CreatedOn
+	err := db.QueryRowx(`
+		INSERT INTO workflow (name, node_id, status, trigger_type, trigger_interval_seconds, trigger_event_type,
+			filter, actions, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING workflow_id;`,
+		wf.Name, wf.NodeId, wf.Status, wf.TriggerType, wf.TriggerIntervalSeconds, wf.TriggerEventType,
+		wf.Filter, wf.Actions, wf.CreatedOn, wf.UpdateOn).Scan(&wf.WorkflowId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return Workflow{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return Workflow{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return wf, nil
+}
+
+func setWorkflow(db *sqlx.DB, wf Workflow) (Workflow, error) {
+	wf.UpdateOn = time.Now().UTC()
+	_, err := db.Exec(`
+		UPDATE workflow
+		SET name=$1, node_id=$2, status=$3, trigger_type=$4, trigger_interval_seconds=$5, trigger_event_type=$6,
+			filter=$7, actions=$8, updated_on=$9
+		WHERE workflow_id=$10;`,
+		wf.Name, wf.NodeId, wf.Status, wf.TriggerType, wf.TriggerIntervalSeconds, wf.TriggerEventType,
+		wf.Filter, wf.Actions, wf.UpdateOn, wf.WorkflowId)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == "23505" {
+				return Workflow{}, errors.Wrap(err, database.SqlUniqueConstraintError)
+			}
+		}
+		return Workflow{}, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return wf, nil
+}
+
+func removeWorkflow(db *sqlx.DB, workflowId int) (int64, error) {
+	res, err := db.Exec(`DELETE FROM workflow WHERE workflow_id=$1;`, workflowId)
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlExecutionError)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, database.SqlAffectedRowsCheckError)
+	}
+	return rowsAffected, nil
+}
+
+func getWorkflowLogs(db *sqlx.DB, workflowId int) ([]WorkflowLog, error) {
+	var logs []WorkflowLog
+	err := db.Select(&logs, `
+		SELECT * FROM workflow_log WHERE workflow_id=$1 ORDER BY started_on DESC, workflow_log_id DESC;`, workflowId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []WorkflowLog{}, nil
+		}
+		return nil, errors.Wrap(err, database.SqlExecutionError)
+	}
+	return logs, nil
+}
+
+// addWorkflowLog stores the run and only keeps the last WORKFLOW_LOG_COUNT runs of the workflow.
+func addWorkflowLog(db *sqlx.DB, wfl WorkflowLog) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, database.SqlBeginTransactionError)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO workflow_log (workflow_id, node_id, trigger_type, trigger_event,
+			candidate_channel_ids, filtered_channel_ids, outcomes, status, error, started_on, ended_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);`,
+		wfl.WorkflowId, wfl.NodeId, wfl.TriggerType, wfl.TriggerEvent,
+		wfl.CandidateChannelIds, wfl.FilteredChannelIds, wfl.Outcomes, wfl.Status, wfl.Error, wfl.StartedOn, wfl.EndedOn)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	_, err = tx.Exec(`
+		DELETE FROM workflow_log
+		WHERE workflow_id=$1 AND workflow_log_id NOT IN (
+			SELECT workflow_log_id FROM workflow_log WHERE workflow_id=$1
+			ORDER BY started_on DESC, workflow_log_id DESC LIMIT $2);`,
+		wfl.WorkflowId, commons.WORKFLOW_LOG_COUNT)
+	if err != nil {
+		if rb := tx.Rollback(); rb != nil {
+			log.Error().Err(rb).Msg(database.SqlRollbackTransactionError)
+		}
+		return errors.Wrap(err, database.SqlExecutionError)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, database.SqlCommitTransactionError)
+	}
+	return nil
+}
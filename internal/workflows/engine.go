@@ -0,0 +1,295 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/audit"
+	"github.com/lncapital/torq/internal/channel_groups"
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/broadcast"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type closingChannelKey struct {
+	nodeId    int
+	channelId int
+}
+
+var (
+	// closingChannels the channels that are being closed by a workflow. The channel stays open until the closing
+	// transaction confirms so every run of the workflow would select it (and close it) again.
+	closingChannels = &sync.Map{}           //nolint:gochecknoglobals
+	closeChannel    = channels.CloseChannel //nolint:gochecknoglobals
+)
+
+// WorkflowEngine runs the active workflows of the node on their interval or when a matching event is broadcasted.
+func WorkflowEngine(ctx context.Context, db *sqlx.DB, nodeSettings commons.ManagedNodeSettings,
+	broadcaster broadcast.BroadcastServer, eventChannel chan interface{}) {
+
+	ticker := clock.New().Tick(commons.WORKFLOW_TICKER_SECONDS * time.Second)
+	lastRuns := make(map[int]time.Time)
+	// running guards against a workflow running multiple times in parallel (i.e. events caused by its own actions)
+	running := &sync.Map{}
+
+	workflows, err := getActiveWorkflowsByNodeId(db, nodeSettings.NodeId)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to obtain workflows for nodeId: %v", nodeSettings.NodeId)
+	}
+
+	listener := broadcaster.Subscribe()
+	defer broadcaster.CancelSubscription(listener)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+			workflows, err = getActiveWorkflowsByNodeId(db, nodeSettings.NodeId)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to obtain workflows for nodeId: %v", nodeSettings.NodeId)
+				continue
+			}
+			now := time.Now().UTC()
+			for _, wf := range workflows {
+				if wf.TriggerType != intervalTrigger || wf.TriggerIntervalSeconds == nil {
+					continue
+				}
+				lastRun, exists := lastRuns[wf.WorkflowId]
+				if exists && now.Sub(lastRun) < time.Duration(*wf.TriggerIntervalSeconds)*time.Second {
+					continue
+				}
+				lastRuns[wf.WorkflowId] = now
+				go runWorkflowOnce(db, wf, nil, nil, running, eventChannel)
+			}
+		case event, ok := <-listener:
+			if !ok {
+				return
+			}
+			eventType, eventNodeId, candidateChannelIds := processEvent(event)
+			if eventType == "" || eventNodeId != nodeSettings.NodeId {
+				continue
+			}
+			for _, wf := range workflows {
+				if wf.TriggerType != eventTrigger || wf.TriggerEventType == nil || *wf.TriggerEventType != eventType {
+					continue
+				}
+				go runWorkflowOnce(db, wf, event, candidateChannelIds, running, eventChannel)
+			}
+		}
+	}
+}
+
+// processEvent returns the trigger type, node and the channels involved in the event.
+func processEvent(event interface{}) (triggerEventType, int, []int) {
+	switch e := event.(type) {
+	case commons.ChannelEvent:
+		return channelEventTrigger, e.NodeId, []int{e.ChannelId}
+	case commons.ForwardEvent:
+		var channelIds []int
+		if e.IncomingChannelId != nil {
+			channelIds = append(channelIds, *e.IncomingChannelId)
+		}
+		if e.OutgoingChannelId != nil {
+			channelIds = append(channelIds, *e.OutgoingChannelId)
+		}
+		return forwardEventTrigger, e.NodeId, channelIds
+	case commons.PeerEvent:
+		channelIds := commons.GetChannelIdsByNodeId(e.EventNodeId)
+		if channelIds == nil {
+			channelIds = []int{}
+		}
+		return peerEventTrigger, e.NodeId, channelIds
+	case commons.ChannelGraphEvent:
+		if e.ChannelId == nil {
+			return channelGraphEventTrigger, e.NodeId, []int{}
+		}
+		return channelGraphEventTrigger, e.NodeId, []int{*e.ChannelId}
+	}
+	return "", 0, nil
+}
+
+func runWorkflowOnce(db *sqlx.DB, wf Workflow, event interface{}, candidateChannelIds []int,
+	running *sync.Map, eventChannel chan interface{}) {
+
+	if _, alreadyRunning := running.LoadOrStore(wf.WorkflowId, true); alreadyRunning {
+		log.Debug().Msgf("Workflow %v is already running", wf.WorkflowId)
+		return
+	}
+	defer running.Delete(wf.WorkflowId)
+	err := runWorkflow(db, wf, event, candidateChannelIds, eventChannel)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to store the run of workflow %v", wf.WorkflowId)
+	}
+}
+
+// runWorkflow filters the channels, executes the actions and stores the run
+func runWorkflow(db *sqlx.DB, wf Workflow, event interface{}, candidateChannelIds []int,
+	eventChannel chan interface{}) error {
+
+	wfl := WorkflowLog{
+		WorkflowId:         wf.WorkflowId,
+		NodeId:             wf.NodeId,
+		TriggerType:        wf.TriggerType,
+		FilteredChannelIds: pq.Int64Array{},
+		Outcomes:           types.JSONText("[]"),
+		Status:             commons.Active,
+		StartedOn:          time.Now().UTC(),
+	}
+	if event != nil {
+		eventJson, err := json.Marshal(event)
+		if err != nil {
+			return errors.Wrap(err, "JSON Marshal workflow trigger event")
+		}
+		triggerEvent := types.JSONText(eventJson)
+		wfl.TriggerEvent = &triggerEvent
+	}
+	if candidateChannelIds != nil {
+		wfl.CandidateChannelIds = toInt64Array(candidateChannelIds)
+	}
+
+	outcomes, filteredChannelIds, err := executeWorkflow(db, wf, candidateChannelIds, eventChannel)
+	if err != nil {
+		wfl.Status = commons.Inactive
+		errorMessage := err.Error()
+		wfl.Error = &errorMessage
+	}
+	wfl.FilteredChannelIds = toInt64Array(filteredChannelIds)
+	for _, outcome := range outcomes {
+		if outcome.Status == commons.Inactive {
+			wfl.Status = commons.Inactive
+		}
+	}
+	if len(outcomes) > 0 {
+		outcomesJson, err := json.Marshal(outcomes)
+		if err != nil {
+			return errors.Wrap(err, "JSON Marshal workflow outcomes")
+		}
+		wfl.Outcomes = outcomesJson
+	}
+	wfl.EndedOn = time.Now().UTC()
+	return addWorkflowLog(db, wfl)
+}
+
+func executeWorkflow(db *sqlx.DB, wf Workflow, candidateChannelIds []int,
+	eventChannel chan interface{}) ([]actionOutcome, []int, error) {
+
+	var actions []WorkflowAction
+	err := json.Unmarshal(wf.Actions, &actions)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "JSON unmarshal workflow actions")
+	}
+	if candidateChannelIds != nil && len(candidateChannelIds) == 0 {
+		return nil, nil, nil
+	}
+	var filter json.RawMessage
+	if wf.Filter != nil {
+		filter = json.RawMessage(*wf.Filter)
+	}
+	filteredChannelIds, err := filterChannels(db, wf.NodeId, candidateChannelIds, filter)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Filtering channels")
+	}
+	var outcomes []actionOutcome
+	for _, channelId := range filteredChannelIds {
+		for _, action := range actions {
+			outcome := actionOutcome{ChannelId: channelId, Type: action.Type, Status: commons.Active}
+			err := executeAction(db, wf.WorkflowId, wf.NodeId, channelId, action, eventChannel)
+			if err != nil {
+				outcome.Status = commons.Inactive
+				outcome.Error = err.Error()
+			}
+			if err == nil && action.Type == closeChannelAction {
+				outcome.Status = commons.Pending
+			}
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	return outcomes, filteredChannelIds, nil
+}
+
+func executeAction(db *sqlx.DB, workflowId int, nodeId int, channelId int, action WorkflowAction,
+	eventChannel chan interface{}) error {
+
+	actor := audit.AutomationActor(fmt.Sprintf("workflow %v", workflowId))
+	switch action.Type {
+	case updatePolicyAction:
+		updateChannelRequest := commons.UpdateChannelRequest{
+			NodeId:           nodeId,
+			ChannelId:        &channelId,
+			FeeRateMilliMsat: action.FeeRateMilliMsat,
+			FeeBaseMsat:      action.FeeBaseMsat,
+			MinHtlcMsat:      action.MinHtlcMsat,
+			MaxHtlcMsat:      action.MaxHtlcMsat,
+			TimeLockDelta:    action.TimeLockDelta,
+		}
+		auditEvent, err := audit.Start(db, actor, audit.UpdateChannels, nodeId, &channelId, updateChannelRequest)
+		if err != nil {
+			return errors.Wrap(err, "Recording audit event")
+		}
+		response, err := channels.UpdateChannels(db, updateChannelRequest, eventChannel)
+		audit.Finish(db, auditEvent, response, err)
+		if err != nil {
+			return err
+		}
+		if response.Status != commons.Active && len(response.FailedUpdates) > 0 {
+			return errors.New(response.FailedUpdates[0].Reason)
+		}
+		return nil
+	case addTagAction:
+		if action.TagId == nil {
+			return errors.New("Missing tagId")
+		}
+		return channel_groups.AddChannelTag(db, nodeId, channelId, *action.TagId)
+	case removeTagAction:
+		if action.TagId == nil {
+			return errors.New("Missing tagId")
+		}
+		return channel_groups.RemoveChannelTag(db, nodeId, channelId, *action.TagId)
+	case closeChannelAction:
+		// Closing only returns when the closing transaction confirms so this runs in the background
+		closeChannelRequest := commons.CloseChannelRequest{
+			NodeId:      nodeId,
+			ChannelId:   channelId,
+			Force:       action.Force,
+			SatPerVbyte: action.SatPerVbyte,
+		}
+		key := closingChannelKey{nodeId: nodeId, channelId: channelId}
+		if _, closing := closingChannels.LoadOrStore(key, true); closing {
+			log.Debug().Msgf("Workflow %v skipped closing channelId: %v, it's already being closed", workflowId,
+				channelId)
+			return nil
+		}
+		reqId := fmt.Sprintf("workflow-%v-%v", channelId, time.Now().UnixMilli())
+		go func() {
+			defer closingChannels.Delete(key)
+			err := audit.Execute(db, actor, audit.CloseChannel, nodeId, &channelId, closeChannelRequest, eventChannel,
+				func(eventChannel chan interface{}) error {
+					return closeChannel(eventChannel, db, nil, closeChannelRequest, reqId)
+				})
+			if err != nil {
+				log.Error().Err(err).Msgf("Workflow failed to close channelId: %v", channelId)
+			}
+		}()
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Unknown action type: %v", action.Type))
+}
+
+func toInt64Array(values []int) pq.Int64Array {
+	result := pq.Int64Array{}
+	for _, value := range values {
+		result = append(result, int64(value))
+	}
+	return result
+}
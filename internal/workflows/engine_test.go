@@ -0,0 +1,97 @@
+package workflows
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/internal/settings"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestCloseChannelWorkflowClosesOnce(t *testing.T) {
+	srv, err := testutil.InitTestDBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, cancel, err := srv.NewTestDatabase(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	err = settings.InitializeManagedSettingsCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedSettings cache: %v", err)
+	}
+	err = settings.InitializeManagedNodeCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedNode cache: %v", err)
+	}
+	err = channels.InitializeManagedChannelCache(db)
+	if err != nil {
+		t.Fatalf("Problem initializing ManagedChannel cache: %v", err)
+	}
+	nodeId := commons.GetNodeIdByPublicKey(testutil.TestPublicKey1, commons.Bitcoin, commons.SigNet)
+	channelId := commons.GetChannelIdByShortChannelId(commons.ConvertLNDShortChannelID(1111))
+	commons.SetChannelStates(nodeId, []commons.ManagedChannelStateSettings{
+		{NodeId: nodeId, ChannelId: channelId, LocalBalance: 500_000, RemoteBalance: 500_000}})
+	commons.SetChannelStateNodeStatus(nodeId, commons.Active)
+
+	// The close only returns when the closing transaction confirms
+	var closes int
+	var closesMutex sync.Mutex
+	confirmed := make(chan struct{})
+	originalCloseChannel := closeChannel
+	defer func() { closeChannel = originalCloseChannel }()
+	closeChannel = func(eventChannel chan interface{}, db *sqlx.DB, c *gin.Context,
+		ccReq commons.CloseChannelRequest, reqId string) error {
+		closesMutex.Lock()
+		closes++
+		closesMutex.Unlock()
+		<-confirmed
+		return nil
+	}
+
+	interval := 60
+	wf, err := addWorkflow(db, Workflow{Name: "Close", NodeId: nodeId, Status: commons.Active,
+		TriggerType: intervalTrigger, TriggerIntervalSeconds: &interval,
+		Actions: types.JSONText(`[{"type":"closeChannel"}]`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = runWorkflow(db, wf, nil, []int{channelId}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(confirmed)
+
+	var closeEvents int
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		_, closing := closingChannels.Load(closingChannelKey{nodeId: nodeId, channelId: channelId})
+		if !closing {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	err = db.Get(&closeEvents, `SELECT COUNT(*) FROM audit_event
+		WHERE action='CLOSE_CHANNEL' AND status='REQUESTED' AND channel_id=$1;`, channelId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closesMutex.Lock()
+	defer closesMutex.Unlock()
+	if closes != 1 || closeEvents != 1 {
+		t.Errorf("expected a single close while the channel is closing, got %v closes and %v audit events",
+			closes, closeEvents)
+	}
+}
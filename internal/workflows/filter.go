@@ -0,0 +1,146 @@
+package workflows
+
+import (
+	"encoding/json"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	qp "github.com/lncapital/torq/internal/query_parser"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// filterColumns are the channel data columns a workflow filter can use.
+var filterColumns = []string{ //nolint:gochecknoglobals
+	"channel_id",
+	"short_channel_id",
+	"capacity",
+	"private",
+	"status_id",
+	"remote_node_id",
+	"remote_public_key",
+	"local_balance",
+	"remote_balance",
+	"local_balance_per_mille",
+	"disabled",
+	"fee_rate_milli_msat",
+	"fee_base_msat",
+	"remote_fee_rate_milli_msat",
+	"remote_fee_base_msat",
+	"tag_ids",
+	"category_ids",
+}
+
+func parseFilter(filter json.RawMessage) (sq.Sqlizer, error) {
+	filterClauses := qp.FilterClauses{}
+	err := json.Unmarshal(filter, &filterClauses)
+	if err != nil {
+		return nil, errors.Wrap(err, "JSON unmarshal filters")
+	}
+	queryParser := qp.QueryParser{AllowedColumns: filterColumns}
+	return queryParser.ParseFilterClauses(filterClauses)
+}
+
+// filterChannels returns the channels (out of the candidates or all open channels when candidates is nil) that pass
+// the filter. The channel data is a combination of the database and the cached channel balances and policies.
+func filterChannels(db *sqlx.DB, nodeId int, candidateChannelIds []int, filter json.RawMessage) ([]int, error) {
+	var channelIds []int64
+	var localBalances []int64
+	var remoteBalances []int64
+	var localBalancePerMilles []int64
+	var disabled []bool
+	var feeRates []int64
+	var feeBases []int64
+	var remoteFeeRates []int64
+	var remoteFeeBases []int64
+
+	candidates := make(map[int]bool)
+	for _, channelId := range candidateChannelIds {
+		candidates[channelId] = true
+	}
+	channelStates := make(map[int]commons.ManagedChannelStateSettings)
+	for _, channelState := range commons.GetChannelStates(nodeId, false) {
+		channelStates[channelState.ChannelId] = channelState
+	}
+	for _, balanceState := range commons.GetChannelBalanceStates(nodeId, false, commons.ALL_CHANNELS,
+		commons.PENDING_HTLCS_IGNORED) {
+		if candidateChannelIds != nil && !candidates[balanceState.ChannelId] {
+			continue
+		}
+		channelState := channelStates[balanceState.ChannelId]
+		channelIds = append(channelIds, int64(balanceState.ChannelId))
+		localBalances = append(localBalances, balanceState.LocalBalance)
+		remoteBalances = append(remoteBalances, balanceState.RemoteBalance)
+		localBalancePerMilles = append(localBalancePerMilles, int64(balanceState.LocalBalancePerMilleRatio))
+		disabled = append(disabled, channelState.LocalDisabled)
+		feeRates = append(feeRates, int64(channelState.LocalFeeRateMilliMsat))
+		feeBases = append(feeBases, int64(channelState.LocalFeeBaseMsat))
+		remoteFeeRates = append(remoteFeeRates, int64(channelState.RemoteFeeRateMilliMsat))
+		remoteFeeBases = append(remoteFeeBases, int64(channelState.RemoteFeeBaseMsat))
+	}
+	if len(channelIds) == 0 {
+		return []int{}, nil
+	}
+
+	//language=PostgreSQL
+	qb := sq.Select("channel_id").
+		FromSelect(
+			sq.Select(`
+				c.channel_id,
+				c.short_channel_id,
+				c.capacity,
+				c.private,
+				c.status_id,
+				n.node_id AS remote_node_id,
+				n.public_key AS remote_public_key,
+				s.local_balance,
+				s.remote_balance,
+				s.local_balance_per_mille,
+				s.disabled,
+				s.fee_rate_milli_msat,
+				s.fee_base_msat,
+				s.remote_fee_rate_milli_msat,
+				s.remote_fee_base_msat,
+				ARRAY(SELECT DISTINCT cg.tag_id FROM channel_group cg
+					WHERE cg.channel_id=c.channel_id AND cg.tag_id IS NOT NULL) AS tag_ids,
+				ARRAY(SELECT DISTINCT cg.category_id FROM channel_group cg
+					WHERE cg.channel_id=c.channel_id AND cg.category_id IS NOT NULL) AS category_ids
+			`).
+				From("state s").
+				Join("channel c ON c.channel_id=s.channel_id").
+				Join("node n ON n.node_id=CASE WHEN c.first_node_id=? THEN c.second_node_id ELSE c.first_node_id END",
+					nodeId).
+				Prefix(`WITH state AS (
+					SELECT * FROM unnest(?::INTEGER[], ?::BIGINT[], ?::BIGINT[], ?::INTEGER[], ?::BOOLEAN[],
+						?::BIGINT[], ?::BIGINT[], ?::BIGINT[], ?::BIGINT[])
+					AS s(channel_id, local_balance, remote_balance, local_balance_per_mille, disabled,
+						fee_rate_milli_msat, fee_base_msat, remote_fee_rate_milli_msat, remote_fee_base_msat))`,
+					pq.Array(channelIds), pq.Array(localBalances), pq.Array(remoteBalances),
+					pq.Array(localBalancePerMilles), pq.Array(disabled), pq.Array(feeRates), pq.Array(feeBases),
+					pq.Array(remoteFeeRates), pq.Array(remoteFeeBases)),
+			"subquery").
+		PlaceholderFormat(sq.Dollar).
+		OrderBy("channel_id")
+
+	if len(filter) != 0 {
+		f, err := parseFilter(filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "Parsing workflow filter")
+		}
+		qb = qb.Where(f)
+	}
+
+	qs, args, err := qb.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "Compiling query to sql")
+	}
+
+	var filteredChannelIds []int
+	err = db.Select(&filteredChannelIds, qs, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Running workflow filter query")
+	}
+	return filteredChannelIds, nil
+}
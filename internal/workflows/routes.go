@@ -0,0 +1,177 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/server_errors"
+)
+
+func RegisterWorkflowRoutes(r *gin.RouterGroup, db *sqlx.DB) {
+	r.GET("get/:workflowId", func(c *gin.Context) { getWorkflowHandler(c, db) })
+	r.GET("all", func(c *gin.Context) { getWorkflowsHandler(c, db) })
+	r.POST("add", func(c *gin.Context) { addWorkflowHandler(c, db) })
+	r.PUT("set", func(c *gin.Context) { setWorkflowHandler(c, db) })
+	r.DELETE(":workflowId", func(c *gin.Context) { removeWorkflowHandler(c, db) })
+	// logs returns the last runs of the workflow (most recent first)
+	r.GET("logs/:workflowId", func(c *gin.Context) { getWorkflowLogsHandler(c, db) })
+}
+
+func getWorkflowHandler(c *gin.Context, db *sqlx.DB) {
+	workflowId, err := strconv.Atoi(c.Param("workflowId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse workflowId in the request.")
+		return
+	}
+	wf, err := getWorkflow(db, workflowId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting workflow for workflowId: %v", workflowId))
+		return
+	}
+	c.JSON(http.StatusOK, wf)
+}
+
+func getWorkflowsHandler(c *gin.Context, db *sqlx.DB) {
+	wfs, err := getWorkflows(db)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Getting workflows.")
+		return
+	}
+	c.JSON(http.StatusOK, wfs)
+}
+
+func addWorkflowHandler(c *gin.Context, db *sqlx.DB) {
+	var wf Workflow
+	if err := c.BindJSON(&wf); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if message := validateWorkflow(wf); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	storedWorkflow, err := addWorkflow(db, wf)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, "Adding workflow.")
+		return
+	}
+	c.JSON(http.StatusOK, storedWorkflow)
+}
+
+func setWorkflowHandler(c *gin.Context, db *sqlx.DB) {
+	var wf Workflow
+	if err := c.BindJSON(&wf); err != nil {
+		server_errors.SendBadRequestFromError(c, errors.Wrap(err, server_errors.JsonParseError))
+		return
+	}
+	if wf.WorkflowId == 0 {
+		server_errors.SendUnprocessableEntity(c, "Failed to find workflowId in the request.")
+		return
+	}
+	if message := validateWorkflow(wf); message != "" {
+		server_errors.SendUnprocessableEntity(c, message)
+		return
+	}
+	storedWorkflow, err := setWorkflow(db, wf)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Setting workflow for workflowId: %v", wf.WorkflowId))
+		return
+	}
+	c.JSON(http.StatusOK, storedWorkflow)
+}
+
+func removeWorkflowHandler(c *gin.Context, db *sqlx.DB) {
+	workflowId, err := strconv.Atoi(c.Param("workflowId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse workflowId in the request.")
+		return
+	}
+	count, err := removeWorkflow(db, workflowId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Removing workflow for workflowId: %v", workflowId))
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("Successfully deleted %v workflow(s).", count)})
+}
+
+func getWorkflowLogsHandler(c *gin.Context, db *sqlx.DB) {
+	workflowId, err := strconv.Atoi(c.Param("workflowId"))
+	if err != nil {
+		server_errors.SendBadRequest(c, "Failed to find/parse workflowId in the request.")
+		return
+	}
+	logs, err := getWorkflowLogs(db, workflowId)
+	if err != nil {
+		server_errors.WrapLogAndSendServerError(c, err, fmt.Sprintf("Getting workflow logs for workflowId: %v", workflowId))
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
+
+func validateWorkflow(wf Workflow) string {
+	if wf.Name == "" {
+		return "Failed to find name in the request."
+	}
+	if wf.NodeId == 0 {
+		return "Failed to find nodeId in the request."
+	}
+	switch wf.TriggerType {
+	case intervalTrigger:
+		if wf.TriggerIntervalSeconds == nil || *wf.TriggerIntervalSeconds <= 0 {
+			return "An interval trigger requires a positive triggerIntervalSeconds."
+		}
+	case eventTrigger:
+		if wf.TriggerEventType == nil {
+			return "An event trigger requires a triggerEventType."
+		}
+		switch *wf.TriggerEventType {
+		case channelEventTrigger, forwardEventTrigger, peerEventTrigger, channelGraphEventTrigger:
+		default:
+			return fmt.Sprintf("Unknown trigger event type: %v", *wf.TriggerEventType)
+		}
+	default:
+		return fmt.Sprintf("Unknown trigger type: %v", wf.TriggerType)
+	}
+	if wf.Filter != nil {
+		if _, err := parseFilter(json.RawMessage(*wf.Filter)); err != nil {
+			return fmt.Sprintf("Invalid filter: %v", err.Error())
+		}
+	}
+	var actions []WorkflowAction
+	if err := json.Unmarshal(wf.Actions, &actions); err != nil {
+		return "Failed to parse the actions in the request."
+	}
+	if len(actions) == 0 {
+		return "A workflow requires at least one action."
+	}
+	for _, action := range actions {
+		if message := validateAction(action); message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+func validateAction(action WorkflowAction) string {
+	switch action.Type {
+	case updatePolicyAction:
+		if action.FeeRateMilliMsat == nil && action.FeeBaseMsat == nil && action.MinHtlcMsat == nil &&
+			action.MaxHtlcMsat == nil && action.TimeLockDelta == nil {
+			return "An updatePolicy action requires at least one policy field."
+		}
+	case addTagAction, removeTagAction:
+		if action.TagId == nil {
+			return fmt.Sprintf("A %v action requires a tagId.", action.Type)
+		}
+	case closeChannelAction:
+	default:
+		return fmt.Sprintf("Unknown action type: %v", action.Type)
+	}
+	return ""
+}
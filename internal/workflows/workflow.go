@@ -0,0 +1,86 @@
+package workflows
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type triggerType int
+
+const (
+	intervalTrigger = triggerType(iota)
+	eventTrigger
+)
+
+type triggerEventType string
+
+const (
+	channelEventTrigger      = triggerEventType("ChannelEvent")
+	forwardEventTrigger      = triggerEventType("ForwardEvent")
+	peerEventTrigger         = triggerEventType("PeerEvent")
+	channelGraphEventTrigger = triggerEventType("ChannelGraphEvent")
+)
+
+type actionType string
+
+const (
+	updatePolicyAction = actionType("updatePolicy")
+	addTagAction       = actionType("addTag")
+	removeTagAction    = actionType("removeTag")
+	closeChannelAction = actionType("closeChannel")
+)
+
+type Workflow struct {
+	WorkflowId             int               `json:"workflowId" db:"workflow_id"`
+	Name                   string            `json:"name" db:"name"`
+	NodeId                 int               `json:"nodeId" db:"node_id"`
+	Status                 commons.Status    `json:"status" db:"status"`
+	TriggerType            triggerType       `json:"triggerType" db:"trigger_type"`
+	TriggerIntervalSeconds *int              `json:"triggerIntervalSeconds" db:"trigger_interval_seconds"`
+	TriggerEventType       *triggerEventType `json:"triggerEventType" db:"trigger_event_type"`
+	Filter                 *types.JSONText   `json:"filter" db:"filter"`
+	Actions                types.JSONText    `json:"actions" db:"actions"`
+	CreatedOn              time.Time         `json:"createdOn" db:"created_on"`
+	UpdateOn               time.Time         `json:"updatedOn" db:"updated_on"`
+}
+
+type WorkflowAction struct {
+	Type actionType `json:"type"`
+	// updatePolicy
+	FeeRateMilliMsat *uint64 `json:"feeRateMilliMsat,omitempty"`
+	FeeBaseMsat      *uint64 `json:"feeBaseMsat,omitempty"`
+	MinHtlcMsat      *uint64 `json:"minHtlcMsat,omitempty"`
+	MaxHtlcMsat      *uint64 `json:"maxHtlcMsat,omitempty"`
+	TimeLockDelta    *uint32 `json:"timeLockDelta,omitempty"`
+	// addTag and removeTag
+	TagId *int `json:"tagId,omitempty"`
+	// closeChannel
+	Force       *bool   `json:"force,omitempty"`
+	SatPerVbyte *uint64 `json:"satPerVbyte,omitempty"`
+}
+
+type WorkflowLog struct {
+	WorkflowLogId       int             `json:"workflowLogId" db:"workflow_log_id"`
+	WorkflowId          int             `json:"workflowId" db:"workflow_id"`
+	NodeId              int             `json:"nodeId" db:"node_id"`
+	TriggerType         triggerType     `json:"triggerType" db:"trigger_type"`
+	TriggerEvent        *types.JSONText `json:"triggerEvent" db:"trigger_event"`
+	CandidateChannelIds pq.Int64Array   `json:"candidateChannelIds" db:"candidate_channel_ids"`
+	FilteredChannelIds  pq.Int64Array   `json:"filteredChannelIds" db:"filtered_channel_ids"`
+	Outcomes            types.JSONText  `json:"outcomes" db:"outcomes"`
+	Status              commons.Status  `json:"status" db:"status"`
+	Error               *string         `json:"error" db:"error"`
+	StartedOn           time.Time       `json:"startedOn" db:"started_on"`
+	EndedOn             time.Time       `json:"endedOn" db:"ended_on"`
+}
+
+type actionOutcome struct {
+	ChannelId int            `json:"channelId"`
+	Type      actionType     `json:"type"`
+	Status    commons.Status `json:"status"`
+	Error     string         `json:"error,omitempty"`
+}
@@ -0,0 +1,118 @@
+package workflows
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx/types"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestProcessEvent(t *testing.T) {
+	incomingChannelId := 2
+	outgoingChannelId := 3
+	tests := []struct {
+		name       string
+		event      interface{}
+		eventType  triggerEventType
+		channelIds []int
+	}{
+		{
+			name:       "Channel event",
+			event:      commons.ChannelEvent{EventData: commons.EventData{NodeId: 1}, ChannelId: 5},
+			eventType:  channelEventTrigger,
+			channelIds: []int{5},
+		},
+		{
+			name: "Forward event",
+			event: commons.ForwardEvent{EventData: commons.EventData{NodeId: 1},
+				IncomingChannelId: &incomingChannelId, OutgoingChannelId: &outgoingChannelId},
+			eventType:  forwardEventTrigger,
+			channelIds: []int{2, 3},
+		},
+		{
+			name:       "Channel graph event without channel",
+			event:      commons.ChannelGraphEvent{},
+			eventType:  channelGraphEventTrigger,
+			channelIds: []int{},
+		},
+		{
+			name:       "Unsupported event",
+			event:      commons.ServiceEvent{},
+			eventType:  "",
+			channelIds: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			eventType, _, channelIds := processEvent(test.event)
+			if eventType != test.eventType {
+				t.Errorf("expected event type %v, got %v", test.eventType, eventType)
+			}
+			if !reflect.DeepEqual(channelIds, test.channelIds) {
+				t.Errorf("expected channelIds %v, got %v", test.channelIds, channelIds)
+			}
+		})
+	}
+}
+
+func TestValidateWorkflow(t *testing.T) {
+	interval := 60
+	eventType := channelEventTrigger
+	validFilter := types.JSONText(`{"$filter":{"funcName":"gte","key":"localBalancePerMille","parameter":800}}`)
+	invalidFilter := types.JSONText(`{"$filter":{"funcName":"eq","key":"password","parameter":"x"}}`)
+	tests := []struct {
+		name     string
+		workflow Workflow
+		valid    bool
+	}{
+		{
+			name: "Valid interval workflow",
+			workflow: Workflow{Name: "wf", NodeId: 1, TriggerType: intervalTrigger, TriggerIntervalSeconds: &interval,
+				Filter: &validFilter, Actions: types.JSONText(`[{"type":"addTag","tagId":1}]`)},
+			valid: true,
+		},
+		{
+			name: "Valid event workflow",
+			workflow: Workflow{Name: "wf", NodeId: 1, TriggerType: eventTrigger, TriggerEventType: &eventType,
+				Actions: types.JSONText(`[{"type":"updatePolicy","feeRateMilliMsat":100}]`)},
+			valid: true,
+		},
+		{
+			name: "Interval trigger without interval",
+			workflow: Workflow{Name: "wf", NodeId: 1, TriggerType: intervalTrigger,
+				Actions: types.JSONText(`[{"type":"closeChannel"}]`)},
+			valid: false,
+		},
+		{
+			name: "Filter on a column that is not allowed",
+			workflow: Workflow{Name: "wf", NodeId: 1, TriggerType: intervalTrigger, TriggerIntervalSeconds: &interval,
+				Filter: &invalidFilter, Actions: types.JSONText(`[{"type":"closeChannel"}]`)},
+			valid: false,
+		},
+		{
+			name: "Tag action without tag",
+			workflow: Workflow{Name: "wf", NodeId: 1, TriggerType: intervalTrigger, TriggerIntervalSeconds: &interval,
+				Actions: types.JSONText(`[{"type":"removeTag"}]`)},
+			valid: false,
+		},
+		{
+			name: "Unknown action",
+			workflow: Workflow{Name: "wf", NodeId: 1, TriggerType: intervalTrigger, TriggerIntervalSeconds: &interval,
+				Actions: types.JSONText(`[{"type":"rebalance"}]`)},
+			valid: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := validateWorkflow(test.workflow)
+			if test.valid && message != "" {
+				t.Errorf("expected workflow to be valid, got: %v", message)
+			}
+			if !test.valid && message == "" {
+				t.Errorf("expected workflow to be invalid")
+			}
+		})
+	}
+}
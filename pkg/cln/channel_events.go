@@ -0,0 +1,238 @@
+package cln
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+// getChannelStatus converts the state of a Core Lightning channel. Mutual closes pass through the CLOSINGD states
+// so when the previous state is known the closure type is derived from it otherwise the closer is used.
+func getChannelStatus(peerChannel cln_connect.PeerChannel, previousState string) commons.ChannelStatus {
+	switch peerChannel.State {
+	case "OPENINGD", "DUALOPEND_OPEN_INIT", "DUALOPEND_AWAITING_LOCKIN", "CHANNELD_AWAITING_LOCKIN":
+		return commons.Opening
+	case "CHANNELD_NORMAL":
+		return commons.Open
+	case "ONCHAIN", "CLOSED":
+		switch {
+		case previousState == "CLOSINGD_SIGEXCHANGE" || previousState == "CLOSINGD_COMPLETE":
+			return commons.CooperativeClosed
+		case peerChannel.Closer == "local":
+			return commons.LocalForceClosed
+		case peerChannel.Closer == "remote":
+			return commons.RemoteForceClosed
+		}
+		return commons.CooperativeClosed
+	}
+	return commons.Closing
+}
+
+// getChannelEventType returns the channel event for a state change or false when no event needs to be stored.
+func getChannelEventType(previousStatus *commons.ChannelStatus, status commons.ChannelStatus) (lnrpc.ChannelEventUpdate_UpdateType, bool) {
+	if previousStatus != nil && *previousStatus == status {
+		return 0, false
+	}
+	switch status {
+	case commons.Opening:
+		return lnrpc.ChannelEventUpdate_PENDING_OPEN_CHANNEL, true
+	case commons.Open:
+		return lnrpc.ChannelEventUpdate_OPEN_CHANNEL, true
+	case commons.Closing:
+		return 0, false
+	}
+	if previousStatus != nil && *previousStatus >= commons.CooperativeClosed {
+		return 0, false
+	}
+	return lnrpc.ChannelEventUpdate_CLOSED_CHANNEL, true
+}
+
+func storePeerChannel(db *sqlx.DB, peerChannel cln_connect.PeerChannel, status commons.ChannelStatus,
+	nodeSettings commons.ManagedNodeSettings) (channels.Channel, error) {
+
+	remoteNodeId, err := addNodeWhenNew(peerChannel.PeerId, nodeSettings, db)
+	if err != nil {
+		return channels.Channel{}, errors.Wrap(err, "Add Node When New")
+	}
+	channel := channels.Channel{
+		FundingTransactionHash: peerChannel.FundingTxId,
+		FundingOutputIndex:     peerChannel.FundingOutnum,
+		Capacity:               int64(peerChannel.TotalMsat / 1000),
+		Private:                peerChannel.Private,
+		FirstNodeId:            nodeSettings.NodeId,
+		SecondNodeId:           remoteNodeId,
+		Status:                 status,
+	}
+	switch peerChannel.Opener {
+	case "local":
+		channel.InitiatingNodeId = &nodeSettings.NodeId
+		channel.AcceptingNodeId = &remoteNodeId
+	case "remote":
+		channel.InitiatingNodeId = &remoteNodeId
+		channel.AcceptingNodeId = &nodeSettings.NodeId
+	}
+	switch peerChannel.Closer {
+	case "local":
+		channel.ClosingNodeId = &nodeSettings.NodeId
+	case "remote":
+		channel.ClosingNodeId = &remoteNodeId
+	}
+	if peerChannel.ShortChannelId != "" {
+		shortChannelId := peerChannel.ShortChannelId
+		channel.ShortChannelID = &shortChannelId
+		lndShortChannelId, err := commons.ConvertShortChannelIDToLND(shortChannelId)
+		if err == nil {
+			channel.LNDShortChannelID = &lndShortChannelId
+		}
+	}
+	channel.ChannelID, err = channels.AddChannelOrUpdateChannelStatus(db, channel)
+	if err != nil {
+		return channels.Channel{}, errors.Wrapf(err, "Adding or updating channel (shortChannelId: %v)", peerChannel.ShortChannelId)
+	}
+
+	commons.SetChannelNode(remoteNodeId, peerChannel.PeerId, nodeSettings.Chain, nodeSettings.Network, channel.Status)
+	commons.SetChannel(channel.ChannelID, channel.ShortChannelID, channel.Status,
+		channel.FundingTransactionHash, channel.FundingOutputIndex, channel.Capacity, channel.Private,
+		channel.FirstNodeId, channel.SecondNodeId, channel.InitiatingNodeId, channel.AcceptingNodeId)
+	return channel, nil
+}
+
+type channelState struct {
+	State         string
+	Status        commons.ChannelStatus
+	PeerConnected bool
+}
+
+func insertChannelEvent(db *sqlx.DB, eventType lnrpc.ChannelEventUpdate_UpdateType, channelId int,
+	peerChannel cln_connect.PeerChannel, nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{},
+	bootStrapping bool) error {
+
+	jsonByteArray, err := json.Marshal(peerChannel)
+	if err != nil {
+		return errors.Wrap(err, "JSON Marshall")
+	}
+	eventTime := time.Now().UTC()
+	channelEvent := commons.ChannelEvent{
+		EventData: commons.EventData{
+			EventTime: eventTime,
+			NodeId:    nodeSettings.NodeId,
+		},
+		Type: eventType,
+	}
+	if bootStrapping {
+		eventChannel = nil
+	}
+	return lnd.InsertChannelEvent(db, eventTime, eventType, nodeSettings.NodeId, channelId, bootStrapping, jsonByteArray,
+		channelEvent, eventChannel)
+}
+
+// processPeerChannels stores the channels and stores a channel event for every change compared to the previous run.
+// On the first run (bootstrapping) only the state of channels unknown to torq are stored as imported events.
+func processPeerChannels(db *sqlx.DB, peerChannels []cln_connect.PeerChannel, states map[string]channelState,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}, bootStrapping bool) error {
+
+	for _, peerChannel := range peerChannels {
+		if peerChannel.FundingTxId == "" {
+			continue
+		}
+		key := peerChannel.ChannelId
+		previous, exists := states[key]
+		var previousStatus *commons.ChannelStatus
+		if exists {
+			previousStatus = &previous.Status
+		} else if bootStrapping {
+			channelId := commons.GetChannelIdByFundingTransaction(peerChannel.FundingTxId, peerChannel.FundingOutnum)
+			if channelId != 0 {
+				knownStatus := commons.GetChannelStatusByChannelId(channelId)
+				previousStatus = &knownStatus
+			}
+		}
+		status := getChannelStatus(peerChannel, previous.State)
+		channel, err := storePeerChannel(db, peerChannel, status, nodeSettings)
+		if err != nil {
+			return err
+		}
+		eventType, changed := getChannelEventType(previousStatus, status)
+		if changed {
+			err = insertChannelEvent(db, eventType, channel.ChannelID, peerChannel, nodeSettings, eventChannel, bootStrapping)
+			if err != nil {
+				return errors.Wrapf(err, "Insert %v channel event", eventType.String())
+			}
+		}
+		if exists && status == commons.Open && previous.PeerConnected != peerChannel.PeerConnected {
+			eventType = lnrpc.ChannelEventUpdate_INACTIVE_CHANNEL
+			if peerChannel.PeerConnected {
+				eventType = lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL
+			}
+			err = insertChannelEvent(db, eventType, channel.ChannelID, peerChannel, nodeSettings, eventChannel, bootStrapping)
+			if err != nil {
+				return errors.Wrapf(err, "Insert %v channel event", eventType.String())
+			}
+		}
+		states[key] = channelState{
+			State:         peerChannel.State,
+			Status:        status,
+			PeerConnected: peerChannel.PeerConnected,
+		}
+	}
+	return nil
+}
+
+// SubscribeAndStoreChannelEvents polls Core Lightning's listpeerchannels and stores a channel event
+// for every channel state change.
+func SubscribeAndStoreChannelEvents(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
+
+	serviceStatus := commons.Inactive
+	bootStrapping := true
+	subscriptionStream := commons.ChannelEventStream
+	ticker := clock.New().Tick(commons.STREAM_CLN_CHANNELS_TICKER_SECONDS * time.Second)
+	states := make(map[string]channelState)
+
+	for {
+		if bootStrapping {
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Initializing, serviceStatus)
+		}
+		var response cln_connect.ListPeerChannelsResponse
+		err := client.Call(ctx, "listpeerchannels", nil, &response)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+			log.Error().Err(err).Msgf("Failed to obtain peer channels, will retry in %v seconds", commons.STREAM_CLN_CHANNELS_TICKER_SECONDS)
+		} else {
+			err = processPeerChannels(db, response.Channels, states, nodeSettings, eventChannel, bootStrapping)
+			if err != nil {
+				serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+				log.Error().Err(err).Msgf("Failed to store peer channels, will retry in %v seconds", commons.STREAM_CLN_CHANNELS_TICKER_SECONDS)
+			} else {
+				if bootStrapping {
+					err = channels.InitializeManagedChannelCache(db)
+					if err != nil {
+						log.Error().Err(err).Msgf("Failed to Initialize ManagedChannelCache.")
+					}
+				}
+				bootStrapping = false
+				serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cln
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestGetChannelStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		state         string
+		closer        string
+		previousState string
+		want          commons.ChannelStatus
+	}{
+		{"Awaiting lockin", "CHANNELD_AWAITING_LOCKIN", "", "", commons.Opening},
+		{"Normal", "CHANNELD_NORMAL", "", "", commons.Open},
+		{"Shutting down", "CHANNELD_SHUTTING_DOWN", "local", "", commons.Closing},
+		{"Mutual close", "ONCHAIN", "local", "CLOSINGD_COMPLETE", commons.CooperativeClosed},
+		{"Local force close", "ONCHAIN", "local", "AWAITING_UNILATERAL", commons.LocalForceClosed},
+		{"Remote force close", "ONCHAIN", "remote", "CHANNELD_NORMAL", commons.RemoteForceClosed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			peerChannel := cln_connect.PeerChannel{State: test.state, Closer: test.closer}
+			got := getChannelStatus(peerChannel, test.previousState)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetChannelEventType(t *testing.T) {
+	opening := commons.Opening
+	open := commons.Open
+	closing := commons.Closing
+	closed := commons.ChannelStatus(commons.CooperativeClosed)
+	tests := []struct {
+		name           string
+		previousStatus *commons.ChannelStatus
+		status         commons.ChannelStatus
+		want           lnrpc.ChannelEventUpdate_UpdateType
+		wantChanged    bool
+	}{
+		{"New pending channel", nil, commons.Opening, lnrpc.ChannelEventUpdate_PENDING_OPEN_CHANNEL, true},
+		{"Channel opened", &opening, commons.Open, lnrpc.ChannelEventUpdate_OPEN_CHANNEL, true},
+		{"No change", &open, commons.Open, 0, false},
+		{"Closing", &open, commons.Closing, 0, false},
+		{"Closed", &closing, commons.LocalForceClosed, lnrpc.ChannelEventUpdate_CLOSED_CHANNEL, true},
+		{"Already closed", &closed, commons.LocalForceClosed, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed := getChannelEventType(test.previousStatus, test.status)
+			if got != test.want || changed != test.wantChanged {
+				t.Errorf("got %v %v, want %v %v", got, changed, test.want, test.wantChanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package cln
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+type forward struct {
+	TimeNs            int64
+	FeeMsat           uint64
+	AmountInMsat      uint64
+	AmountOutMsat     uint64
+	IncomingChannelId *int
+	OutgoingChannelId *int
+}
+
+// convertForwards returns the settled forwards resolved after lastNs in the order they were resolved.
+func convertForwards(clnForwards []cln_connect.Forward, lastNs int64) []forward {
+	var forwards []forward
+	for _, clnForward := range clnForwards {
+		if clnForward.Status != "settled" {
+			continue
+		}
+		timeNs := convertSeconds(clnForward.ResolvedTime)
+		if timeNs <= lastNs {
+			continue
+		}
+		forwards = append(forwards, forward{
+			TimeNs:            timeNs,
+			FeeMsat:           uint64(clnForward.FeeMsat),
+			AmountInMsat:      uint64(clnForward.InMsat),
+			AmountOutMsat:     uint64(clnForward.OutMsat),
+			IncomingChannelId: getChannelIdByShortChannelId(clnForward.InChannel),
+			OutgoingChannelId: getChannelIdByShortChannelId(clnForward.OutChannel),
+		})
+	}
+	return forwards
+}
+
+func storeForwards(db *sqlx.DB, forwards []forward, nodeId int, eventChannel chan interface{}, bootStrapping bool) error {
+	if len(forwards) == 0 {
+		return nil
+	}
+	tx := db.MustBegin()
+	stmt, err := tx.Prepare(`INSERT INTO forward(time, time_ns, fee_msat,
+			incoming_amount_msat, outgoing_amount_msat, incoming_channel_id, outgoing_channel_id, node_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (time, time_ns) DO NOTHING;`)
+	if err != nil {
+		return errors.Wrap(err, "SQL Statement prepare")
+	}
+	for _, fwd := range forwards {
+		_, err = stmt.Exec(convertMicro(fwd.TimeNs), fwd.TimeNs, fwd.FeeMsat, fwd.AmountInMsat, fwd.AmountOutMsat,
+			fwd.IncomingChannelId, fwd.OutgoingChannelId, nodeId)
+		if err != nil {
+			return errors.Wrap(err, "SQL Statement exec")
+		}
+	}
+	err = stmt.Close()
+	if err != nil {
+		return errors.Wrap(err, "Close of prepared statement")
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "DB Commit")
+	}
+	if eventChannel != nil && !bootStrapping {
+		for _, fwd := range forwards {
+			eventChannel <- commons.ForwardEvent{
+				EventData: commons.EventData{
+					EventTime: time.Now().UTC(),
+					NodeId:    nodeId,
+				},
+				Timestamp:         convertMicro(fwd.TimeNs),
+				FeeMsat:           fwd.FeeMsat,
+				AmountInMsat:      fwd.AmountInMsat,
+				AmountOutMsat:     fwd.AmountOutMsat,
+				IncomingChannelId: fwd.IncomingChannelId,
+				OutgoingChannelId: fwd.OutgoingChannelId,
+			}
+		}
+	}
+	return nil
+}
+
+const clnForwardsPageSize = 1000
+
+// forwardPager pages listforwards by created index so every poll only fetches the forwards created since the
+// previous poll. The first poll pages through the whole history once, the last stored forward time filters what's
+// already stored. The pager restarts at the first forward that was still offered so it's stored once it settles.
+type forwardPager struct {
+	start uint64
+}
+
+func (pager *forwardPager) fetch(ctx context.Context, client cln_connect.RpcClient) ([]cln_connect.Forward, error) {
+	var forwards []cln_connect.Forward
+	start := pager.start
+	var offeredStart *uint64
+	for {
+		var response cln_connect.ListForwardsResponse
+		err := client.Call(ctx, "listforwards",
+			map[string]interface{}{"index": "created", "start": start, "limit": clnForwardsPageSize}, &response)
+		if err != nil {
+			return nil, errors.Wrap(err, "CLN listforwards")
+		}
+		for _, clnForward := range response.Forwards {
+			if clnForward.Status == "offered" && offeredStart == nil {
+				createdIndex := clnForward.CreatedIndex
+				offeredStart = &createdIndex
+			}
+			start = clnForward.CreatedIndex + 1
+		}
+		forwards = append(forwards, response.Forwards...)
+		if len(response.Forwards) < clnForwardsPageSize {
+			break
+		}
+	}
+	pager.start = start
+	if offeredStart != nil {
+		pager.start = *offeredStart
+	}
+	return forwards, nil
+}
+
+func fetchLastForwardTime(db *sqlx.DB, nodeId int) (int64, error) {
+	var lastNs int64
+	err := db.QueryRow("SELECT time_ns FROM forward WHERE node_id = $1 ORDER BY time_ns DESC LIMIT 1;", nodeId).Scan(&lastNs)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "Query row of last forward time")
+	}
+	return lastNs, nil
+}
+
+// SubscribeForwardingEvents polls Core Lightning for settled forwards and stores the ones newer than
+// the last forward stored in the database.
+func SubscribeForwardingEvents(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
+
+	serviceStatus := commons.Inactive
+	bootStrapping := true
+	subscriptionStream := commons.ForwardStream
+	ticker := clock.New().Tick(commons.STREAM_CLN_TICKER_SECONDS * time.Second)
+	pager := &forwardPager{}
+
+	for {
+		lastNs, err := fetchLastForwardTime(db, nodeSettings.NodeId)
+		if err != nil {
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+			log.Error().Err(err).Msgf("Failed to obtain last know forward, will retry in %v seconds", commons.STREAM_CLN_TICKER_SECONDS)
+		} else {
+			var clnForwards []cln_connect.Forward
+			clnForwards, err = pager.fetch(ctx, client)
+			if err != nil {
+				if errors.Is(ctx.Err(), context.Canceled) {
+					return
+				}
+				serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+				log.Error().Err(err).Msgf("Failed to obtain forwards, will retry in %v seconds", commons.STREAM_CLN_TICKER_SECONDS)
+			} else {
+				if bootStrapping {
+					serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Initializing, serviceStatus)
+				}
+				err = storeForwards(db, convertForwards(clnForwards, lastNs), nodeSettings.NodeId, eventChannel, bootStrapping)
+				if err != nil {
+					log.Error().Err(err).Msgf("Failed to store forward event")
+				}
+				bootStrapping = false
+				serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
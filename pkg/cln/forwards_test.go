@@ -0,0 +1,63 @@
+package cln
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+)
+
+type fakeForwardsClient struct {
+	forwards []cln_connect.Forward
+	starts   []uint64
+}
+
+func (client *fakeForwardsClient) Call(ctx context.Context, method string, params interface{},
+	result interface{}) error {
+
+	p := params.(map[string]interface{})
+	start := p["start"].(uint64)
+	limit := p["limit"].(int)
+	client.starts = append(client.starts, start)
+	response := cln_connect.ListForwardsResponse{Forwards: []cln_connect.Forward{}}
+	for _, forward := range client.forwards {
+		if forward.CreatedIndex >= start && len(response.Forwards) < limit {
+			response.Forwards = append(response.Forwards, forward)
+		}
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func TestForwardPager(t *testing.T) {
+	client := &fakeForwardsClient{}
+	for i := uint64(1); i <= clnForwardsPageSize+2; i++ {
+		client.forwards = append(client.forwards, cln_connect.Forward{CreatedIndex: i, Status: "settled"})
+	}
+	pager := &forwardPager{}
+	forwards, err := pager.fetch(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forwards) != clnForwardsPageSize+2 || len(client.starts) != 2 || pager.start != clnForwardsPageSize+3 {
+		t.Fatalf("expected two pages got %v forwards, starts %v and next start %v",
+			len(forwards), client.starts, pager.start)
+	}
+
+	// Only the new forwards are fetched, the pager waits at the forward that is still offered
+	client.starts = nil
+	client.forwards = append(client.forwards,
+		cln_connect.Forward{CreatedIndex: clnForwardsPageSize + 3, Status: "offered"},
+		cln_connect.Forward{CreatedIndex: clnForwardsPageSize + 4, Status: "settled"})
+	forwards, err = pager.fetch(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forwards) != 2 || client.starts[0] != clnForwardsPageSize+3 || pager.start != clnForwardsPageSize+3 {
+		t.Errorf("unexpected forwards %+v, starts %v and next start %v", forwards, client.starts, pager.start)
+	}
+}
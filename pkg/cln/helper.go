@@ -0,0 +1,64 @@
+package cln
+
+import (
+	"math"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/internal/nodes"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// convertSeconds converts the fractional unix timestamps of Core Lightning to nanoseconds rounded on microseconds.
+func convertSeconds(seconds float64) int64 {
+	return int64(math.Round(seconds*1e6)) * int64(time.Microsecond)
+}
+
+func convertMicro(ns int64) time.Time {
+	return time.Unix(0, ns).Round(time.Microsecond).UTC()
+}
+
+func getChannelIdByShortChannelId(shortChannelId string) *int {
+	if shortChannelId == "" {
+		return nil
+	}
+	channelId := commons.GetChannelIdByShortChannelId(shortChannelId)
+	if channelId == 0 {
+		return nil
+	}
+	return &channelId
+}
+
+func getChainParams(network commons.Network) *chaincfg.Params {
+	switch network {
+	case commons.TestNet:
+		return &chaincfg.TestNet3Params
+	case commons.RegTest:
+		return &chaincfg.RegressionNetParams
+	case commons.SigNet:
+		return &chaincfg.SigNetParams
+	case commons.SimNet:
+		return &chaincfg.SimNetParams
+	}
+	return &chaincfg.MainNetParams
+}
+
+func addNodeWhenNew(remotePublicKey string, nodeSettings commons.ManagedNodeSettings, db *sqlx.DB) (int, error) {
+	remoteNodeId := commons.GetNodeIdByPublicKey(remotePublicKey, nodeSettings.Chain, nodeSettings.Network)
+	if remoteNodeId == 0 {
+		newNode := nodes.Node{
+			PublicKey: remotePublicKey,
+			Chain:     nodeSettings.Chain,
+			Network:   nodeSettings.Network,
+		}
+		var err error
+		remoteNodeId, err = nodes.AddNodeWhenNew(db, newNode)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Adding node with public key: %v", remotePublicKey)
+		}
+	}
+	return remoteNodeId, nil
+}
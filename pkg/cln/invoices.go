@@ -0,0 +1,211 @@
+package cln
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+// CLN error code when waitanyinvoice times out
+const waitAnyInvoiceTimeoutCode = 904
+
+func getInvoiceState(status string) lnrpc.Invoice_InvoiceState {
+	switch status {
+	case "paid":
+		return lnrpc.Invoice_SETTLED
+	case "expired":
+		return lnrpc.Invoice_CANCELED
+	}
+	return lnrpc.Invoice_OPEN
+}
+
+// convertInvoice maps a Core Lightning invoice on the invoice table, created_index is used as add_index
+// and pay_index as settle_index.
+func convertInvoice(clnInvoice cln_connect.Invoice, nodeSettings commons.ManagedNodeSettings) lnd.Invoice {
+	now := time.Now().UTC()
+	invoice := lnd.Invoice{
+		Memo:            clnInvoice.Description,
+		RPreimage:       clnInvoice.PaymentPreimage,
+		RHash:           clnInvoice.PaymentHash,
+		ValueMsat:       int64(clnInvoice.AmountMsat),
+		PaymentRequest:  clnInvoice.Bolt11,
+		AddIndex:        clnInvoice.CreatedIndex,
+		SettleIndex:     clnInvoice.PayIndex,
+		AmtPaidSat:      int64(clnInvoice.AmountReceivedMsat / 1000),
+		AmtPaidMsat:     int64(clnInvoice.AmountReceivedMsat),
+		InvoiceState:    getInvoiceState(clnInvoice.Status).String(),
+		RouteHints:      []byte("null"),
+		Htlcs:           []byte("null"),
+		Features:        []byte("null"),
+		AmpInvoiceState: []byte("null"),
+		NodeId:          nodeSettings.NodeId,
+		CreatedOn:       now,
+		UpdatedOn:       now,
+	}
+	if clnInvoice.PaidAt != 0 {
+		invoice.SettleDate = time.Unix(clnInvoice.PaidAt, 0).UTC()
+	}
+	if clnInvoice.Bolt11 == "" {
+		return invoice
+	}
+	decoded, err := zpay32.Decode(clnInvoice.Bolt11, getChainParams(nodeSettings.Network))
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to decode CLN invoice with payment hash: %v", clnInvoice.PaymentHash)
+		return invoice
+	}
+	invoice.CreationDate = decoded.Timestamp.UTC()
+	invoice.Expiry = int64(decoded.Expiry().Seconds())
+	invoice.CltvExpiry = decoded.MinFinalCLTVExpiry()
+	if decoded.Destination != nil {
+		invoice.Destination = fmt.Sprintf("%x", decoded.Destination.SerializeCompressed())
+		destinationNodeId := commons.GetNodeIdByPublicKey(invoice.Destination, nodeSettings.Chain, nodeSettings.Network)
+		invoice.DestinationNodeId = &destinationNodeId
+	}
+	if decoded.DescriptionHash != nil {
+		invoice.DescriptionHash = decoded.DescriptionHash[:]
+	}
+	if decoded.PaymentAddr != nil {
+		invoice.PaymentAddr = hex.EncodeToString(decoded.PaymentAddr[:])
+	}
+	if decoded.FallbackAddr != nil {
+		invoice.FallbackAddr = decoded.FallbackAddr.String()
+	}
+	return invoice
+}
+
+func storeInvoice(db *sqlx.DB, clnInvoice cln_connect.Invoice, nodeSettings commons.ManagedNodeSettings,
+	eventChannel chan interface{}, bootStrapping bool) error {
+
+	invoice := convertInvoice(clnInvoice, nodeSettings)
+	err := lnd.StoreInvoice(db, invoice)
+	if err != nil {
+		return errors.Wrap(err, "Storing CLN invoice")
+	}
+	if eventChannel != nil && !bootStrapping {
+		invoiceEvent := commons.InvoiceEvent{
+			EventData: commons.EventData{
+				EventTime: time.Now().UTC(),
+				NodeId:    nodeSettings.NodeId,
+			},
+			AddIndex:          invoice.AddIndex,
+			ValueMSat:         uint64(invoice.ValueMsat),
+			State:             getInvoiceState(clnInvoice.Status),
+			DestinationNodeId: invoice.DestinationNodeId,
+		}
+		if invoiceEvent.State == lnrpc.Invoice_SETTLED {
+			invoiceEvent.AmountPaidMsat = uint64(invoice.AmtPaidMsat)
+			invoiceEvent.SettledDate = invoice.SettleDate
+		}
+		eventChannel <- invoiceEvent
+	}
+	return nil
+}
+
+func fetchLastInvoiceIndexes(db *sqlx.DB, nodeId int) (addIndex uint64, settleIndex uint64, err error) {
+	err = db.QueryRow(`
+		SELECT coalesce(max(add_index), 0), coalesce(max(settle_index), 0)
+		FROM invoice
+		WHERE node_id = $1;`, nodeId).Scan(&addIndex, &settleIndex)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "Obtaining last invoice indexes")
+	}
+	return addIndex, settleIndex, nil
+}
+
+func importInvoices(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}, bootStrapping bool) error {
+
+	addIndex, settleIndex, err := fetchLastInvoiceIndexes(db, nodeSettings.NodeId)
+	if err != nil {
+		return err
+	}
+
+	var response cln_connect.ListInvoicesResponse
+	err = client.Call(ctx, "listinvoices", map[string]interface{}{"index": "created", "start": addIndex + 1}, &response)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining new invoices")
+	}
+	for _, clnInvoice := range response.Invoices {
+		if clnInvoice.CreatedIndex <= addIndex {
+			continue
+		}
+		if clnInvoice.PayIndex != 0 && clnInvoice.PayIndex <= settleIndex {
+			// Already stored as settled invoice
+			continue
+		}
+		err = storeInvoice(db, clnInvoice, nodeSettings, eventChannel, bootStrapping)
+		if err != nil {
+			return err
+		}
+		if clnInvoice.PayIndex > settleIndex {
+			settleIndex = clnInvoice.PayIndex
+		}
+	}
+
+	// Settlements of invoices that were stored as open invoices
+	for {
+		var paidInvoice cln_connect.Invoice
+		err = client.Call(ctx, "waitanyinvoice", map[string]interface{}{"lastpay_index": settleIndex, "timeout": 0}, &paidInvoice)
+		if err != nil {
+			var rpcError *cln_connect.RpcError
+			if errors.As(err, &rpcError) && rpcError.Code == waitAnyInvoiceTimeoutCode {
+				return nil
+			}
+			return errors.Wrap(err, "Obtaining settled invoices")
+		}
+		if paidInvoice.PayIndex <= settleIndex {
+			return nil
+		}
+		err = storeInvoice(db, paidInvoice, nodeSettings, eventChannel, bootStrapping)
+		if err != nil {
+			return err
+		}
+		settleIndex = paidInvoice.PayIndex
+	}
+}
+
+// SubscribeAndStoreInvoices polls Core Lightning for new and settled invoices and stores them.
+// Requires Core Lightning v23.08 or newer (created_index).
+func SubscribeAndStoreInvoices(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
+
+	serviceStatus := commons.Inactive
+	bootStrapping := true
+	subscriptionStream := commons.InvoiceStream
+	ticker := clock.New().Tick(commons.STREAM_CLN_TICKER_SECONDS * time.Second)
+
+	for {
+		if bootStrapping {
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Initializing, serviceStatus)
+		}
+		err := importInvoices(ctx, client, db, nodeSettings, eventChannel, bootStrapping)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+			log.Error().Err(err).Msgf("Failed to import invoices, will retry in %v seconds", commons.STREAM_CLN_TICKER_SECONDS)
+		} else {
+			bootStrapping = false
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
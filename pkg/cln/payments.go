@@ -0,0 +1,202 @@
+package cln
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+type payment struct {
+	PaymentIndex    uint64
+	PaymentHash     string
+	PaymentPreimage string
+	PaymentRequest  string
+	Destination     string
+	CreationTimeNs  int64
+	ValueMsat       uint64
+	FeeMsat         uint64
+	Status          lnrpc.Payment_PaymentStatus
+	FailureReason   lnrpc.PaymentFailureReason
+	Parts           []cln_connect.SendPay
+}
+
+// convertPayments groups the sendpay parts of Core Lightning into payments, a payment is a payment_hash and groupid
+// combination. The lowest sendpay id of the group is used as payment index.
+func convertPayments(sendPays []cln_connect.SendPay) []payment {
+	paymentsByKey := make(map[string]*payment)
+	for _, sendPay := range sendPays {
+		key := fmt.Sprintf("%v:%v", sendPay.PaymentHash, sendPay.GroupId)
+		p, exists := paymentsByKey[key]
+		if !exists {
+			p = &payment{
+				PaymentIndex:   sendPay.Id,
+				PaymentHash:    sendPay.PaymentHash,
+				Destination:    sendPay.Destination,
+				CreationTimeNs: sendPay.CreatedAt * int64(time.Second),
+				Status:         lnrpc.Payment_FAILED,
+			}
+			paymentsByKey[key] = p
+		}
+		if sendPay.Id < p.PaymentIndex {
+			p.PaymentIndex = sendPay.Id
+		}
+		if sendPay.CreatedAt*int64(time.Second) < p.CreationTimeNs {
+			p.CreationTimeNs = sendPay.CreatedAt * int64(time.Second)
+		}
+		if sendPay.Bolt11 != "" {
+			p.PaymentRequest = sendPay.Bolt11
+		} else if sendPay.Bolt12 != "" {
+			p.PaymentRequest = sendPay.Bolt12
+		}
+		p.Parts = append(p.Parts, sendPay)
+		switch sendPay.Status {
+		case "complete":
+			p.Status = lnrpc.Payment_SUCCEEDED
+			if sendPay.PaymentPreimage != "" {
+				p.PaymentPreimage = sendPay.PaymentPreimage
+			}
+			p.ValueMsat += uint64(sendPay.AmountMsat)
+			p.FeeMsat += uint64(sendPay.AmountSentMsat) - uint64(sendPay.AmountMsat)
+		case "pending":
+			if p.Status != lnrpc.Payment_SUCCEEDED {
+				p.Status = lnrpc.Payment_IN_FLIGHT
+			}
+		}
+	}
+	var payments []payment
+	for _, p := range paymentsByKey {
+		if p.Status == lnrpc.Payment_FAILED {
+			p.FailureReason = lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR
+			for _, part := range p.Parts {
+				p.ValueMsat += uint64(part.AmountMsat)
+			}
+		}
+		payments = append(payments, *p)
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].PaymentIndex < payments[j].PaymentIndex
+	})
+	return payments
+}
+
+func storePayments(db *sqlx.DB, payments []payment, nodeSettings commons.ManagedNodeSettings,
+	eventChannel chan interface{}, bootStrapping bool) error {
+
+	const q = `INSERT INTO payment(
+				  payment_hash,
+				  creation_timestamp,
+				  payment_preimage,
+				  value_msat,
+				  payment_request,
+				  status,
+				  fee_msat,
+				  creation_time_ns,
+				  htlcs,
+				  payment_index,
+				  failure_reason,
+				  node_id,
+				  created_on)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			  ON CONFLICT (creation_timestamp, payment_index) DO NOTHING;`
+
+	includeIncomplete := commons.RunningServices[commons.LndService].GetIncludeIncomplete(nodeSettings.NodeId)
+	for _, p := range payments {
+		// In flight payments are only stored once they are resolved
+		if p.Status == lnrpc.Payment_IN_FLIGHT {
+			continue
+		}
+		if p.Status == lnrpc.Payment_FAILED && !includeIncomplete {
+			continue
+		}
+		htlcJson, err := json.Marshal(p.Parts)
+		if err != nil {
+			return errors.Wrap(err, "JSON Marshal the payment parts")
+		}
+		result, err := db.Exec(q,
+			p.PaymentHash,
+			convertMicro(p.CreationTimeNs),
+			p.PaymentPreimage,
+			p.ValueMsat,
+			p.PaymentRequest,
+			p.Status.String(),
+			p.FeeMsat,
+			p.CreationTimeNs,
+			htlcJson,
+			p.PaymentIndex,
+			p.FailureReason.String(),
+			nodeSettings.NodeId,
+			time.Now().UTC(),
+		)
+		if err != nil {
+			return errors.Wrap(err, "store payments: db exec")
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "store payments: rows affected")
+		}
+		if eventChannel != nil && !bootStrapping && rowsAffected != 0 {
+			eventChannel <- commons.PaymentEvent{
+				EventData: commons.EventData{
+					EventTime: time.Now().UTC(),
+					NodeId:    nodeSettings.NodeId,
+				},
+				AmountPaid:           int64(p.ValueMsat / 1000),
+				FeeMsat:              p.FeeMsat,
+				PaymentStatus:        p.Status,
+				PaymentFailureReason: p.FailureReason,
+			}
+		}
+	}
+	return nil
+}
+
+// SubscribeAndStorePayments polls Core Lightning for payments and stores the resolved ones.
+// Payments are only stored once resolved so there is no in flight payment stream for CLN.
+func SubscribeAndStorePayments(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
+
+	serviceStatus := commons.Inactive
+	bootStrapping := true
+	subscriptionStream := commons.PaymentStream
+	ticker := clock.New().Tick(commons.STREAM_PAYMENTS_TICKER_SECONDS * time.Second)
+
+	for {
+		var response cln_connect.ListSendPaysResponse
+		err := client.Call(ctx, "listsendpays", nil, &response)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+			log.Error().Err(err).Msgf("Failed to obtain payments, will retry in %v seconds", commons.STREAM_PAYMENTS_TICKER_SECONDS)
+		} else {
+			if bootStrapping {
+				serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Initializing, serviceStatus)
+			}
+			err = storePayments(db, convertPayments(response.Payments), nodeSettings, eventChannel, bootStrapping)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to store payments")
+			}
+			bootStrapping = false
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cln
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+)
+
+func TestConvertPayments(t *testing.T) {
+	sendPays := []cln_connect.SendPay{
+		// Multi part payment, one part failed and got retried
+		{Id: 3, GroupId: 1, PaymentHash: "aa", Status: "failed", AmountMsat: 500, AmountSentMsat: 510, CreatedAt: 20},
+		{Id: 2, GroupId: 1, PaymentHash: "aa", Status: "complete", AmountMsat: 500, AmountSentMsat: 505, CreatedAt: 10,
+			PaymentPreimage: "pre"},
+		{Id: 4, GroupId: 1, PaymentHash: "aa", Status: "complete", AmountMsat: 500, AmountSentMsat: 507, CreatedAt: 21},
+		// Retry of a payment in a new group
+		{Id: 1, GroupId: 0, PaymentHash: "bb", Status: "failed", AmountMsat: 1000, AmountSentMsat: 1001, CreatedAt: 5},
+		{Id: 5, GroupId: 1, PaymentHash: "bb", Status: "pending", AmountMsat: 1000, AmountSentMsat: 1001, CreatedAt: 30},
+	}
+
+	payments := convertPayments(sendPays)
+	if len(payments) != 3 {
+		t.Fatalf("expected 3 payments got %v", len(payments))
+	}
+
+	failed := payments[0]
+	if failed.PaymentIndex != 1 || failed.Status != lnrpc.Payment_FAILED ||
+		failed.FailureReason != lnrpc.PaymentFailureReason_FAILURE_REASON_ERROR || failed.ValueMsat != 1000 {
+		t.Errorf("unexpected failed payment %+v", failed)
+	}
+
+	succeeded := payments[1]
+	if succeeded.PaymentIndex != 2 || succeeded.Status != lnrpc.Payment_SUCCEEDED {
+		t.Errorf("unexpected succeeded payment %+v", succeeded)
+	}
+	if succeeded.ValueMsat != 1000 || succeeded.FeeMsat != 12 {
+		t.Errorf("expected value 1000 and fee 12 got %v and %v", succeeded.ValueMsat, succeeded.FeeMsat)
+	}
+	if succeeded.CreationTimeNs != 10_000_000_000 || succeeded.PaymentPreimage != "pre" || len(succeeded.Parts) != 3 {
+		t.Errorf("unexpected succeeded payment %+v", succeeded)
+	}
+
+	if payments[2].PaymentIndex != 5 || payments[2].Status != lnrpc.Payment_IN_FLIGHT {
+		t.Errorf("unexpected in flight payment %+v", payments[2])
+	}
+}
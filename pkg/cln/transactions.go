@@ -0,0 +1,217 @@
+package cln
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+
+	"github.com/lncapital/torq/pkg/cln_connect"
+	"github.com/lncapital/torq/pkg/commons"
+	"github.com/lncapital/torq/pkg/lnd"
+)
+
+const walletAccount = "wallet"
+
+type accountSummary struct {
+	Timestamp int64
+	Amount    int64
+	Fees      int64
+}
+
+// summarizeAccountEvents aggregates the bookkeeper events of the on-chain wallet per transaction.
+func summarizeAccountEvents(events []cln_connect.AccountEvent) map[string]accountSummary {
+	summaries := make(map[string]accountSummary)
+	for _, event := range events {
+		txId := event.TxId
+		if txId == "" && event.Outpoint != "" {
+			txId, _ = commons.ParseChannelPoint(event.Outpoint)
+		}
+		if event.SpendingTx != "" {
+			txId = event.SpendingTx
+		}
+		if txId == "" {
+			continue
+		}
+		summary := summaries[txId]
+		if summary.Timestamp == 0 || event.Timestamp < summary.Timestamp {
+			summary.Timestamp = event.Timestamp
+		}
+		switch {
+		case event.Tag == "onchain_fee":
+			summary.Fees += int64(event.DebitMsat/1000) - int64(event.CreditMsat/1000)
+		case event.Account == walletAccount:
+			summary.Amount += int64(event.CreditMsat/1000) - int64(event.DebitMsat/1000)
+		}
+		summaries[txId] = summary
+	}
+	return summaries
+}
+
+func getDestinationAddresses(outputs []cln_connect.TransactionOutput, nodeSettings commons.ManagedNodeSettings) []string {
+	var destinationAddresses []string
+	for _, output := range outputs {
+		script, err := hex.DecodeString(output.ScriptPubKey)
+		if err != nil {
+			continue
+		}
+		_, addresses, _, err := txscript.ExtractPkScriptAddrs(script, getChainParams(nodeSettings.Network))
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			destinationAddresses = append(destinationAddresses, address.EncodeAddress())
+		}
+	}
+	return destinationAddresses
+}
+
+func convertTransaction(clnTx cln_connect.Transaction, summary *accountSummary, blockHeight int32,
+	nodeSettings commons.ManagedNodeSettings) lnd.Tx {
+
+	txHash := clnTx.Hash
+	rawTx := clnTx.RawTx
+	txBlockHeight := clnTx.BlockHeight
+	destinationAddresses := getDestinationAddresses(clnTx.Outputs, nodeSettings)
+	tx := lnd.Tx{
+		Timestamp:            time.Now().UTC().Truncate(time.Second),
+		TransactionHash:      &txHash,
+		BlockHeight:          &txBlockHeight,
+		DestinationAddresses: &destinationAddresses,
+		RawTransactionHex:    &rawTx,
+		NodeId:               nodeSettings.NodeId,
+	}
+	if blockHeight >= clnTx.BlockHeight && clnTx.BlockHeight != 0 {
+		confirmations := blockHeight - clnTx.BlockHeight + 1
+		tx.NumberOfConfirmations = &confirmations
+	}
+	if summary != nil {
+		amount := summary.Amount
+		fees := summary.Fees
+		tx.Timestamp = time.Unix(summary.Timestamp, 0).UTC()
+		tx.Amount = &amount
+		tx.TotalFees = &fees
+	}
+	return tx
+}
+
+func fetchLastTxHeight(db *sqlx.DB, nodeId int) (int32, error) {
+	var txHeight int32
+	err := db.QueryRow(`SELECT coalesce(max(block_height), 0) FROM tx WHERE node_id = $1;`, nodeId).Scan(&txHeight)
+	if err != nil {
+		return 0, errors.Wrap(err, "SQL row scan for tx height")
+	}
+	return txHeight, nil
+}
+
+func importTransactions(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, blockHeight int32, eventChannel chan interface{}, bootStrapping bool) error {
+
+	lastHeight, err := fetchLastTxHeight(db, nodeSettings.NodeId)
+	if err != nil {
+		return err
+	}
+
+	var response cln_connect.ListTransactionsResponse
+	err = client.Call(ctx, "listtransactions", nil, &response)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining transactions")
+	}
+
+	// The bookkeeper plugin is optional, without it amount and fees are unknown
+	var accountEvents cln_connect.ListAccountEventsResponse
+	err = client.Call(ctx, "bkpr-listaccountevents", nil, &accountEvents)
+	if err != nil {
+		log.Debug().Err(err).Msgf("Bookkeeper is not available for nodeId: %v", nodeSettings.NodeId)
+	}
+	summaries := summarizeAccountEvents(accountEvents.Events)
+
+	for _, clnTx := range response.Transactions {
+		// Unconfirmed transactions are stored once they are mined
+		if clnTx.BlockHeight == 0 || clnTx.BlockHeight <= lastHeight {
+			continue
+		}
+		var summary *accountSummary
+		if s, exists := summaries[clnTx.Hash]; exists {
+			summary = &s
+		}
+		tx := convertTransaction(clnTx, summary, blockHeight, nodeSettings)
+		err = lnd.StoreTx(db, tx)
+		if err != nil {
+			return errors.Wrapf(err, "Storing transaction %v", clnTx.Hash)
+		}
+		if eventChannel != nil && !bootStrapping {
+			eventChannel <- commons.TransactionEvent{
+				EventData: commons.EventData{
+					EventTime: time.Now().UTC(),
+					NodeId:    nodeSettings.NodeId,
+				},
+				Timestamp:             tx.Timestamp,
+				TransactionHash:       tx.TransactionHash,
+				Amount:                tx.Amount,
+				NumberOfConfirmations: tx.NumberOfConfirmations,
+				BlockHeight:           tx.BlockHeight,
+				TotalFees:             tx.TotalFees,
+				DestinationAddresses:  tx.DestinationAddresses,
+				RawTransactionHex:     tx.RawTransactionHex,
+			}
+		}
+	}
+	return nil
+}
+
+// SubscribeAndStoreTransactions polls Core Lightning for new blocks and stores the on-chain transactions
+// of the wallet once they are confirmed.
+func SubscribeAndStoreTransactions(ctx context.Context, client cln_connect.RpcClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
+
+	serviceStatus := commons.Inactive
+	bootStrapping := true
+	subscriptionStream := commons.TransactionStream
+	ticker := clock.New().Tick(commons.STREAM_CLN_TICKER_SECONDS * time.Second)
+	var lastBlockHeight uint32
+
+	for {
+		if bootStrapping {
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Initializing, serviceStatus)
+		}
+		var info cln_connect.GetInfoResponse
+		err := client.Call(ctx, "getinfo", nil, &info)
+		if err == nil && info.BlockHeight != lastBlockHeight {
+			err = importTransactions(ctx, client, db, nodeSettings, int32(info.BlockHeight), eventChannel, bootStrapping)
+			if err == nil {
+				if eventChannel != nil && !bootStrapping {
+					eventChannel <- commons.BlockEvent{
+						EventData: commons.EventData{
+							EventTime: time.Now().UTC(),
+							NodeId:    nodeSettings.NodeId,
+						},
+						Height: info.BlockHeight,
+					}
+				}
+				lastBlockHeight = info.BlockHeight
+			}
+		}
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Pending, serviceStatus)
+			log.Error().Err(err).Msgf("Failed to import transactions, will retry in %v seconds", commons.STREAM_CLN_TICKER_SECONDS)
+		} else {
+			bootStrapping = false
+			serviceStatus = lnd.SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package cln_connect
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+// RpcClient is implemented by Client and allows the CLN importers and actions to be tested without a node.
+type RpcClient interface {
+	Call(ctx context.Context, method string, params interface{}, result interface{}) error
+}
+
+// Client talks JSON-RPC 2.0 to Core Lightning over the lightning-rpc unix socket
+// (e.g. unix:///home/bitcoin/.lightning/bitcoin/lightning-rpc).
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+	nextId  uint64
+}
+
+type rpcRequest struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Id     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RpcError       `json:"error"`
+}
+
+// RpcError is the error object returned by Core Lightning
+type RpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("CLN RPC error %v: %v", e.Code, e.Message)
+}
+
+// Connect verifies the Core Lightning RPC is reachable and returns a client for it.
+// The lightning-rpc socket has no authentication (access is controlled by the file permissions) so it is never
+// exposed over TCP, only a unix socket is accepted.
+func Connect(address string) (*Client, error) {
+	client := &Client{
+		network: "unix",
+		address: strings.TrimPrefix(address, "unix://"),
+		timeout: 15 * time.Second,
+	}
+	if !strings.HasPrefix(client.address, "/") {
+		return nil, errors.Newf("CLN address %v is not the path of the lightning-rpc unix socket", address)
+	}
+	conn, err := net.DialTimeout(client.network, client.address, client.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial to cln: %v", err)
+	}
+	err = conn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cannot close cln connection: %v", err)
+	}
+	return client, nil
+}
+
+// Call executes a single RPC call. Every call uses its own connection so the client is safe for concurrent use.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return errors.Wrapf(err, "Dialing CLN for %v", method)
+	}
+	defer conn.Close()
+
+	// Long polling calls (e.g. waitanyinvoice) are bound by the context only.
+	if deadline, ok := ctx.Deadline(); ok {
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			return errors.Wrap(err, "Setting CLN connection deadline")
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	request := rpcRequest{
+		JsonRpc: "2.0",
+		Id:      atomic.AddUint64(&c.nextId, 1),
+		Method:  method,
+		Params:  params,
+	}
+	err = json.NewEncoder(conn).Encode(request)
+	if err != nil {
+		return errors.Wrapf(err, "Sending CLN request %v", method)
+	}
+
+	var response rpcResponse
+	err = json.NewDecoder(bufio.NewReader(conn)).Decode(&response)
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "Receiving CLN response %v", method)
+		}
+		return errors.Wrapf(err, "Receiving CLN response %v", method)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+	err = json.Unmarshal(response.Result, result)
+	if err != nil {
+		return errors.Wrapf(err, "Parsing CLN response %v", method)
+	}
+	return nil
+}
+
+// Msat handles both the legacy "1000msat" string notation and the plain integer notation of Core Lightning.
+type Msat uint64
+
+func (m *Msat) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	value = strings.TrimSuffix(value, "msat")
+	if value == "" || value == "null" {
+		*m = 0
+		return nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "Parsing msat value %v", string(data))
+	}
+	*m = Msat(parsed)
+	return nil
+}
+
+// GetChainAndNetwork converts the network reported by Core Lightning's getinfo
+func GetChainAndNetwork(network string) (commons.Chain, commons.Network, error) {
+	switch network {
+	case "bitcoin":
+		return commons.Bitcoin, commons.MainNet, nil
+	case "testnet":
+		return commons.Bitcoin, commons.TestNet, nil
+	case "signet":
+		return commons.Bitcoin, commons.SigNet, nil
+	case "regtest":
+		return commons.Bitcoin, commons.RegTest, nil
+	case "litecoin":
+		return commons.Litecoin, commons.MainNet, nil
+	case "litecoin-testnet":
+		return commons.Litecoin, commons.TestNet, nil
+	}
+	return 0, 0, errors.Newf("Unknown CLN network %v", network)
+}
@@ -0,0 +1,92 @@
+package cln_connect
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lncapital/torq/testutil"
+)
+
+func TestCall(t *testing.T) {
+	server := testutil.NewFakeClnServer(t)
+	server.HandleResult("getinfo", map[string]interface{}{
+		"id":          "02a6d0e1d1c1c3d0a4a5e2b6c1e1f0d3b6a1e5c4d3b2a1f0e9d8c7b6a5f4e3d2c1",
+		"alias":       "cln",
+		"network":     "regtest",
+		"blockheight": 101,
+	})
+	server.Handle("listforwards", func(params json.RawMessage) (interface{}, *testutil.ClnError) {
+		return nil, &testutil.ClnError{Code: 42, Message: "broken"}
+	})
+
+	client, err := Connect(server.Address)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	var info GetInfoResponse
+	err = client.Call(context.Background(), "getinfo", nil, &info)
+	if err != nil {
+		t.Fatalf("getinfo: %v", err)
+	}
+	if info.Alias != "cln" || info.Network != "regtest" || info.BlockHeight != 101 {
+		t.Errorf("unexpected getinfo response %+v", info)
+	}
+
+	err = client.Call(context.Background(), "listforwards", map[string]interface{}{"status": "settled"}, &ListForwardsResponse{})
+	var rpcError *RpcError
+	if !errors.As(err, &rpcError) || rpcError.Code != 42 {
+		t.Errorf("expected RpcError with code 42, got %v", err)
+	}
+	calls := server.Calls("listforwards")
+	if len(calls) != 1 || string(calls[0]) != `{"status":"settled"}` {
+		t.Errorf("unexpected listforwards params %s", calls)
+	}
+
+	err = client.Call(context.Background(), "unknown", nil, &struct{}{})
+	if !errors.As(err, &rpcError) || rpcError.Code != -32601 {
+		t.Errorf("expected method not found, got %v", err)
+	}
+}
+
+func TestConnectRequiresUnixSocket(t *testing.T) {
+	for _, address := range []string{"localhost:9835", "127.0.0.1:9835", "unix://lightning-rpc", ""} {
+		if _, err := Connect(address); err == nil {
+			t.Errorf("expected address %v to be refused", address)
+		}
+	}
+}
+
+func TestMsatUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Msat
+		wantErr bool
+	}{
+		{`1000`, 1000, false},
+		{`"1000msat"`, 1000, false},
+		{`"1000"`, 1000, false},
+		{`"abc"`, 0, true},
+	}
+	for _, test := range tests {
+		var msat Msat
+		err := json.Unmarshal([]byte(test.input), &msat)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error = %v, wantErr %v", test.input, err, test.wantErr)
+			continue
+		}
+		if msat != test.want {
+			t.Errorf("%v: got %v, want %v", test.input, msat, test.want)
+		}
+	}
+}
+
+func TestGetChainAndNetwork(t *testing.T) {
+	_, _, err := GetChainAndNetwork("unknown")
+	if err == nil {
+		t.Errorf("expected an error for an unknown network")
+	}
+}
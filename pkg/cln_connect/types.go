@@ -0,0 +1,201 @@
+package cln_connect
+
+// Only the fields Torq uses are mapped, see https://docs.corelightning.org/reference for the full responses.
+
+type GetInfoResponse struct {
+	Id          string `json:"id"`
+	Alias       string `json:"alias"`
+	Color       string `json:"color"`
+	NumPeers    int    `json:"num_peers"`
+	BlockHeight uint32 `json:"blockheight"`
+	Network     string `json:"network"`
+	Version     string `json:"version"`
+}
+
+type Forward struct {
+	InChannel    string  `json:"in_channel"`
+	InHtlcId     uint64  `json:"in_htlc_id"`
+	OutChannel   string  `json:"out_channel"`
+	OutHtlcId    uint64  `json:"out_htlc_id"`
+	InMsat       Msat    `json:"in_msat"`
+	OutMsat      Msat    `json:"out_msat"`
+	FeeMsat      Msat    `json:"fee_msat"`
+	Status       string  `json:"status"`
+	FailCode     int     `json:"failcode"`
+	FailReason   string  `json:"failreason"`
+	ReceivedTime float64 `json:"received_time"`
+	ResolvedTime float64 `json:"resolved_time"`
+	CreatedIndex uint64  `json:"created_index"`
+}
+
+type ListForwardsResponse struct {
+	Forwards []Forward `json:"forwards"`
+}
+
+type SendPay struct {
+	Id              uint64 `json:"id"`
+	GroupId         uint64 `json:"groupid"`
+	PartId          uint64 `json:"partid"`
+	PaymentHash     string `json:"payment_hash"`
+	Status          string `json:"status"`
+	AmountMsat      Msat   `json:"amount_msat"`
+	AmountSentMsat  Msat   `json:"amount_sent_msat"`
+	Destination     string `json:"destination"`
+	CreatedAt       int64  `json:"created_at"`
+	CompletedAt     int64  `json:"completed_at"`
+	Label           string `json:"label"`
+	Bolt11          string `json:"bolt11"`
+	Bolt12          string `json:"bolt12"`
+	PaymentPreimage string `json:"payment_preimage"`
+	ErrorOnion      string `json:"erroronion"`
+}
+
+type ListSendPaysResponse struct {
+	Payments []SendPay `json:"payments"`
+}
+
+type Invoice struct {
+	Label              string `json:"label"`
+	Bolt11             string `json:"bolt11"`
+	Bolt12             string `json:"bolt12"`
+	PaymentHash        string `json:"payment_hash"`
+	Status             string `json:"status"`
+	Description        string `json:"description"`
+	ExpiresAt          int64  `json:"expires_at"`
+	AmountMsat         Msat   `json:"amount_msat"`
+	AmountReceivedMsat Msat   `json:"amount_received_msat"`
+	PayIndex           uint64 `json:"pay_index"`
+	PaidAt             int64  `json:"paid_at"`
+	PaymentPreimage    string `json:"payment_preimage"`
+	CreatedIndex       uint64 `json:"created_index"`
+	UpdatedIndex       uint64 `json:"updated_index"`
+}
+
+type ListInvoicesResponse struct {
+	Invoices []Invoice `json:"invoices"`
+}
+
+type PeerChannel struct {
+	PeerId          string `json:"peer_id"`
+	PeerConnected   bool   `json:"peer_connected"`
+	State           string `json:"state"`
+	ShortChannelId  string `json:"short_channel_id"`
+	ChannelId       string `json:"channel_id"`
+	FundingTxId     string `json:"funding_txid"`
+	FundingOutnum   int    `json:"funding_outnum"`
+	Private         bool   `json:"private"`
+	Opener          string `json:"opener"`
+	Closer          string `json:"closer"`
+	TotalMsat       Msat   `json:"total_msat"`
+	ToUsMsat        Msat   `json:"to_us_msat"`
+	FeeBaseMsat     Msat   `json:"fee_base_msat"`
+	FeeProportional uint64 `json:"fee_proportional_millionths"`
+}
+
+type ListPeerChannelsResponse struct {
+	Channels []PeerChannel `json:"channels"`
+}
+
+type TransactionOutput struct {
+	Index        uint32 `json:"index"`
+	AmountMsat   Msat   `json:"amount_msat"`
+	ScriptPubKey string `json:"scriptPubKey"`
+}
+
+type Transaction struct {
+	Hash        string              `json:"hash"`
+	RawTx       string              `json:"rawtx"`
+	BlockHeight int32               `json:"blockheight"`
+	TxIndex     int32               `json:"txindex"`
+	Outputs     []TransactionOutput `json:"outputs"`
+}
+
+type ListTransactionsResponse struct {
+	Transactions []Transaction `json:"transactions"`
+}
+
+type AccountEvent struct {
+	Account    string `json:"account"`
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	CreditMsat Msat   `json:"credit_msat"`
+	DebitMsat  Msat   `json:"debit_msat"`
+	Timestamp  int64  `json:"timestamp"`
+	TxId       string `json:"txid"`
+	Outpoint   string `json:"outpoint"`
+	SpendingTx string `json:"spending_txid"`
+}
+
+type ListAccountEventsResponse struct {
+	Events []AccountEvent `json:"events"`
+}
+
+type SetChannelResponse struct {
+	Channels []struct {
+		PeerId         string `json:"peer_id"`
+		ChannelId      string `json:"channel_id"`
+		ShortChannelId string `json:"short_channel_id"`
+		WarningHtlcMin string `json:"warning_htlcmin_too_low"`
+		WarningHtlcMax string `json:"warning_htlcmax_too_high"`
+	} `json:"channels"`
+}
+
+type FundChannelResponse struct {
+	Tx        string `json:"tx"`
+	TxId      string `json:"txid"`
+	Outnum    uint32 `json:"outnum"`
+	ChannelId string `json:"channel_id"`
+}
+
+type MultiFundChannelResponse struct {
+	Tx         string `json:"tx"`
+	TxId       string `json:"txid"`
+	ChannelIds []struct {
+		Id        string `json:"id"`
+		Outnum    uint32 `json:"outnum"`
+		ChannelId string `json:"channel_id"`
+	} `json:"channel_ids"`
+	Failed []struct {
+		Id     string `json:"id"`
+		Method string `json:"method"`
+		Error  struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"failed"`
+}
+
+type CloseResponse struct {
+	Type string `json:"type"`
+	Tx   string `json:"tx"`
+	TxId string `json:"txid"`
+}
+
+type PayResponse struct {
+	PaymentPreimage string  `json:"payment_preimage"`
+	PaymentHash     string  `json:"payment_hash"`
+	Destination     string  `json:"destination"`
+	CreatedAt       float64 `json:"created_at"`
+	Parts           uint32  `json:"parts"`
+	AmountMsat      Msat    `json:"amount_msat"`
+	AmountSentMsat  Msat    `json:"amount_sent_msat"`
+	Status          string  `json:"status"`
+}
+
+type NewAddrResponse struct {
+	Bech32 string `json:"bech32"`
+	P2tr   string `json:"p2tr"`
+}
+
+type WithdrawResponse struct {
+	Tx   string `json:"tx"`
+	TxId string `json:"txid"`
+}
+
+type ListPeersResponse struct {
+	Peers []struct {
+		Id        string   `json:"id"`
+		Connected bool     `json:"connected"`
+		NetAddr   []string `json:"netaddr"`
+	} `json:"peers"`
+}
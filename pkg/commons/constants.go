@@ -7,6 +7,7 @@ const (
 	VectorService
 	AmbossService
 	TorqService
+	MaintenanceService
 )
 
 const TorqDummyNodeId = -1337
@@ -46,6 +47,10 @@ const STREAM_PAYMENTS_TICKER_SECONDS = 10
 const STREAM_INFLIGHT_PAYMENTS_TICKER_SECONDS = 60
 const STREAM_FORWARDS_TICKER_SECONDS = 10
 
+// Core Lightning has no streaming RPCs so all CLN streams poll
+const STREAM_CLN_TICKER_SECONDS = 10
+const STREAM_CLN_CHANNELS_TICKER_SECONDS = 30
+
 const STREAM_ERROR_SLEEP_SECONDS = 60
 const SERVICES_ERROR_SLEEP_SECONDS = 60
 
@@ -56,6 +61,57 @@ const GENERIC_BOOTSTRAPPING_TIME_SECONDS = 60
 const CHANNELBALANCE_TICKER_SECONDS = 150
 const CHANNELBALANCE_BOOTSTRAP_TICKER_SECONDS = 10
 
+// CHANNELBALANCE_SNAPSHOT_SECONDS a balance snapshot is stored at least this often, even without balance changes
+const CHANNELBALANCE_SNAPSHOT_SECONDS = 60 * 60
+
+const FEE_POLICY_TICKER_SECONDS = 300
+
+const WORKFLOW_TICKER_SECONDS = 10
+
+const RECONCILIATION_TICKER_SECONDS = 6 * 60 * 60
+const RECONCILIATION_BOOTSTRAP_DELAY_SECONDS = 600
+const RECONCILIATION_DAYS = 7
+
+// RECONCILIATION_SETTLE_SECONDS events that are more recent are left to the streams
+const RECONCILIATION_SETTLE_SECONDS = 600
+
+const CHANNEL_LIFECYCLE_TICKER_SECONDS = 60
+
+// CHANNEL_LIFECYCLE_STUCK_SECONDS a pending transaction without confirmations for this long is reported as stuck
+const CHANNEL_LIFECYCLE_STUCK_SECONDS = 6 * 60 * 60
+
+// CHANNEL_LIFECYCLE_TRANSACTION_BLOCKS the recent blocks of wallet transactions that are checked for confirmations
+const CHANNEL_LIFECYCLE_TRANSACTION_BLOCKS = 1008
+
+const CHANNEL_RISK_TICKER_SECONDS = 60
+
+// The default channel risk thresholds, the thresholds in use are part of the settings.
+// CHANNEL_RISK_DEFAULT_HTLC_WARNING_BLOCKS pending HTLCs that expire within this many blocks are reported
+const CHANNEL_RISK_DEFAULT_HTLC_WARNING_BLOCKS = 72
+
+// CHANNEL_RISK_DEFAULT_HTLC_CRITICAL_BLOCKS LND force closes the channel shortly after this (the default broadcast
+// delta is 10)
+const CHANNEL_RISK_DEFAULT_HTLC_CRITICAL_BLOCKS = 18
+const CHANNEL_RISK_DEFAULT_PEER_OFFLINE_WARNING_SECONDS = 6 * 60 * 60
+const CHANNEL_RISK_DEFAULT_PEER_OFFLINE_CRITICAL_SECONDS = 48 * 60 * 60
+
+// CHANNEL_HEALTH_CACHE_SECONDS the channel list reuses the channel health for this long
+const CHANNEL_HEALTH_CACHE_SECONDS = 300
+
+// PEER_UPTIME_CACHE_SECONDS the channel list reuses the peer uptime for this long
+const PEER_UPTIME_CACHE_SECONDS = 300
+
+const MAINTENANCE_TICKER_SECONDS = 60 * 60
+const MAINTENANCE_BOOTSTRAP_DELAY_SECONDS = 300
+
+const NOTIFICATION_SINK_REFRESH_SECONDS = 30
+const NOTIFICATION_DELIVERY_COUNT = 1000
+
+// NOTIFICATION_QUEUE_SIZE events for a sink are dropped while its queue is full
+const NOTIFICATION_QUEUE_SIZE = 100
+const NOTIFICATION_DELIVERY_RETENTION_DAYS = 30
+const NOTIFICATION_DELIVERY_PRUNE_SECONDS = 60 * 60
+
 const AMBOSS_SLEEP_SECONDS = 25
 const VECTOR_SLEEP_SECONDS = 20
 
@@ -121,8 +177,10 @@ type NodeConnectionDetailCustomSettings byte
 
 const (
 	ImportFailedPayments NodeConnectionDetailCustomSettings = 1 << iota
+	// StorePeerChannelPolicies stores the policies of the other channels of the direct peers
+	StorePeerChannelPolicies
 )
-const NodeConnectionDetailCustomSettingsMax = int(ImportFailedPayments)*2 - 1
+const NodeConnectionDetailCustomSettingsMax = int(StorePeerChannelPolicies)*2 - 1
 
 type SubscriptionStream int
 
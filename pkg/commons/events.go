@@ -94,6 +94,27 @@ type PeerEvent struct {
 	EventNodeId int                       `json:"eventNodeId"`
 }
 
+// ChannelRiskEvent the risk level of a channel changed
+type ChannelRiskEvent struct {
+	EventData
+	ChannelId     int      `json:"channelId"`
+	RemoteNodeId  int      `json:"remoteNodeId"`
+	Level         string   `json:"level"`
+	PreviousLevel string   `json:"previousLevel"`
+	Reasons       []string `json:"reasons"`
+}
+
+// StuckTransactionEvent a funding or closing transaction is pending without confirmations for too long
+type StuckTransactionEvent struct {
+	EventData
+	ChannelLifecycleId int      `json:"channelLifecycleId"`
+	ChannelPoint       string   `json:"channelPoint"`
+	LifecycleType      string   `json:"lifecycleType"`
+	Txid               *string  `json:"txid"`
+	WaitingSeconds     int64    `json:"waitingSeconds"`
+	FeeRateSatPerVbyte *float64 `json:"feeRateSatPerVbyte"`
+}
+
 type PaymentEvent struct {
 	EventData
 	AmountPaid           int64                       `json:"amountPaid"`
@@ -111,6 +132,18 @@ type BlockEvent struct {
 	Hash   []byte `json:"hash"`
 }
 
+// SpendingRequestEvent is sent when a spending request requires approval and when it's approved or rejected
+type SpendingRequestEvent struct {
+	EventData
+	SpendingRequestId int      `json:"spendingRequestId"`
+	RequestType       string   `json:"requestType"`
+	AmountSat         *int64   `json:"amountSat"`
+	Reasons           []string `json:"reasons"`
+	Status            string   `json:"status"`
+	RequestedBy       string   `json:"requestedBy"`
+	DecidedBy         *string  `json:"decidedBy"`
+}
+
 type HtlcEvent struct {
 	EventData
 	Timestamp         time.Time `json:"timestamp"`
@@ -141,6 +174,16 @@ type ForwardEvent struct {
 	IncomingChannelId *int      `json:"incomingChannelId"`
 }
 
+// ChannelBalanceEvent is sent after the channel balance cache processed a balance update
+type ChannelBalanceEvent struct {
+	EventData
+	ChannelId                  int   `json:"channelId"`
+	LocalBalance               int64 `json:"localBalance"`
+	LocalBalancePerMilleRatio  int   `json:"localBalancePerMilleRatio"`
+	RemoteBalance              int64 `json:"remoteBalance"`
+	RemoteBalancePerMilleRatio int   `json:"remoteBalancePerMilleRatio"`
+}
+
 // GENERIC REQUEST/RESPONSE STRUCTS
 type FailedRequest struct {
 	Reason string `json:"reason"`
@@ -275,6 +318,40 @@ type NewPaymentResponse struct {
 	Attempt        Attempt           `json:"path"`
 }
 
+// REBALANCE
+type RebalanceRequest struct {
+	NodeId             int    `json:"nodeId"`
+	OutgoingChannelIds []int  `json:"outgoingChannelIds"`
+	OutgoingCategoryId *int   `json:"outgoingCategoryId"`
+	OutgoingTagId      *int   `json:"outgoingTagId"`
+	IncomingChannelIds []int  `json:"incomingChannelIds"`
+	IncomingCategoryId *int   `json:"incomingCategoryId"`
+	IncomingTagId      *int   `json:"incomingTagId"`
+	AmountMsat         uint64 `json:"amountMsat"`
+	TotalAmountMsat    uint64 `json:"totalAmountMsat"`
+	MaxFeePpm          uint64 `json:"maxFeePpm"`
+	FeeBudgetMsat      uint64 `json:"feeBudgetMsat"`
+	MaxAttempts        int    `json:"maxAttempts"`
+	TimeOutSecs        int32  `json:"timeoutSecs"`
+}
+
+type RebalanceResponse struct {
+	ReqId                string           `json:"reqId"`
+	Request              RebalanceRequest `json:"request"`
+	RebalanceId          int              `json:"rebalanceId"`
+	Attempt              int              `json:"attempt"`
+	OutgoingChannelId    int              `json:"outgoingChannelId"`
+	IncomingChannelId    int              `json:"incomingChannelId"`
+	Status               string           `json:"status"`
+	FailureReason        string           `json:"failureReason"`
+	Hash                 string           `json:"hash"`
+	AmountMsat           int64            `json:"amountMsat"`
+	FeePaidMsat          int64            `json:"feePaidMsat"`
+	RebalancedAmountMsat uint64           `json:"rebalancedAmountMsat"`
+	SpentFeeMsat         uint64           `json:"spentFeeMsat"`
+	Done                 bool             `json:"done"`
+}
+
 // PAY ONCHAIN
 type PayOnChainRequest struct {
 	NodeId           int     `json:"nodeId"`
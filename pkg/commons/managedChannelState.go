@@ -512,7 +512,7 @@ func GetChannelBalanceStates(nodeId int, forceResponse bool, channelStateInclude
 		ForceResponse:    forceResponse,
 		HtlcInclude:      htlcInclude,
 		StateInclude:     channelStateInclude,
-		Type:             READ_CHANNELBALANCESTATE,
+		Type:             READ_ALL_CHANNELBALANCESTATES,
 		BalanceStatesOut: channelBalanceStateResponseChannel,
 	}
 	ManagedChannelStateChannel <- managedChannelState
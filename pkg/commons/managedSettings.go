@@ -11,7 +11,7 @@ type ManagedSettingsCacheOperationType uint
 const (
 	// READ_SETTINGS please provide Out
 	READ_SETTINGS ManagedSettingsCacheOperationType = iota
-	// WRITE_SETTINGS please provide defaultLanguage, preferredTimeZone, defaultDateRange and weekStartsOn
+	// WRITE_SETTINGS please provide defaultLanguage, preferredTimeZone, defaultDateRange, weekStartsOn and channelRisk
 	WRITE_SETTINGS
 )
 
@@ -21,43 +21,58 @@ type ManagedSettings struct {
 	PreferredTimeZone string
 	DefaultDateRange  string
 	WeekStartsOn      string
+	ChannelRisk       ChannelRiskSettings
 	Out               chan ManagedSettings
 }
 
+// ChannelRiskSettings the thresholds of the channel risk monitor
+type ChannelRiskSettings struct {
+	// HtlcWarningBlocks pending HTLCs that expire within this many blocks are reported
+	HtlcWarningBlocks int64 `json:"channelRiskHtlcWarningBlocks" db:"channel_risk_htlc_warning_blocks"`
+	// HtlcCriticalBlocks pending HTLCs that expire within this many blocks are critical
+	HtlcCriticalBlocks         int64 `json:"channelRiskHtlcCriticalBlocks" db:"channel_risk_htlc_critical_blocks"`
+	PeerOfflineWarningSeconds  int64 `json:"channelRiskPeerOfflineWarningSeconds" db:"channel_risk_peer_offline_warning_seconds"`
+	PeerOfflineCriticalSeconds int64 `json:"channelRiskPeerOfflineCriticalSeconds" db:"channel_risk_peer_offline_critical_seconds"`
+}
+
+func DefaultChannelRiskSettings() ChannelRiskSettings {
+	return ChannelRiskSettings{
+		HtlcWarningBlocks:          CHANNEL_RISK_DEFAULT_HTLC_WARNING_BLOCKS,
+		HtlcCriticalBlocks:         CHANNEL_RISK_DEFAULT_HTLC_CRITICAL_BLOCKS,
+		PeerOfflineWarningSeconds:  CHANNEL_RISK_DEFAULT_PEER_OFFLINE_WARNING_SECONDS,
+		PeerOfflineCriticalSeconds: CHANNEL_RISK_DEFAULT_PEER_OFFLINE_CRITICAL_SECONDS,
+	}
+}
+
 func ManagedSettingsCache(ch chan ManagedSettings, ctx context.Context) {
-	var defaultLanguage string
-	var preferredTimeZone string
-	var defaultDateRange string
-	var weekStartsOn string
+	settings := ManagedSettings{ChannelRisk: DefaultChannelRiskSettings()}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case managedSettings := <-ch:
-			defaultLanguage, preferredTimeZone, defaultDateRange, weekStartsOn =
-				processManagedSettings(managedSettings, defaultLanguage, preferredTimeZone, defaultDateRange, weekStartsOn)
+			settings = processManagedSettings(managedSettings, settings)
 		}
 	}
 }
 
-func processManagedSettings(managedSettings ManagedSettings,
-	defaultLanguage string, preferredTimeZone string, defaultDateRange string,
-	weekStartsOn string) (string, string, string, string) {
-
+func processManagedSettings(managedSettings ManagedSettings, settings ManagedSettings) ManagedSettings {
 	switch managedSettings.Type {
 	case READ_SETTINGS:
-		managedSettings.DefaultLanguage = defaultLanguage
-		managedSettings.PreferredTimeZone = preferredTimeZone
-		managedSettings.DefaultDateRange = defaultDateRange
-		managedSettings.WeekStartsOn = weekStartsOn
+		managedSettings.DefaultLanguage = settings.DefaultLanguage
+		managedSettings.PreferredTimeZone = settings.PreferredTimeZone
+		managedSettings.DefaultDateRange = settings.DefaultDateRange
+		managedSettings.WeekStartsOn = settings.WeekStartsOn
+		managedSettings.ChannelRisk = settings.ChannelRisk
 		SendToManagedSettingsChannel(managedSettings.Out, managedSettings)
 	case WRITE_SETTINGS:
-		defaultLanguage = managedSettings.DefaultLanguage
-		preferredTimeZone = managedSettings.PreferredTimeZone
-		defaultDateRange = managedSettings.DefaultDateRange
-		weekStartsOn = managedSettings.WeekStartsOn
+		settings.DefaultLanguage = managedSettings.DefaultLanguage
+		settings.PreferredTimeZone = managedSettings.PreferredTimeZone
+		settings.DefaultDateRange = managedSettings.DefaultDateRange
+		settings.WeekStartsOn = managedSettings.WeekStartsOn
+		settings.ChannelRisk = managedSettings.ChannelRisk
 	}
-	return defaultLanguage, preferredTimeZone, defaultDateRange, weekStartsOn
+	return settings
 }
 
 func SendToManagedSettingsChannel(ch chan ManagedSettings, managedSettings ManagedSettings) {
@@ -74,12 +89,14 @@ func GetSettings() ManagedSettings {
 	return <-settingsResponseChannel
 }
 
-func SetSettings(defaultDateRange, defaultLanguage, weekStartsOn, preferredTimeZone string) {
+func SetSettings(defaultDateRange, defaultLanguage, weekStartsOn, preferredTimeZone string,
+	channelRisk ChannelRiskSettings) {
 	managedSettings := ManagedSettings{
 		DefaultDateRange:  defaultDateRange,
 		DefaultLanguage:   defaultLanguage,
 		WeekStartsOn:      weekStartsOn,
 		PreferredTimeZone: preferredTimeZone,
+		ChannelRisk:       channelRisk,
 		Type:              WRITE_SETTINGS,
 	}
 	ManagedSettingsChannel <- managedSettings
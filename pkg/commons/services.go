@@ -1,6 +1,7 @@
 package commons
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -25,6 +26,9 @@ type Services struct {
 	streamBootTime               map[int]map[SubscriptionStream]time.Time
 	streamInitializationPingTime map[int]map[SubscriptionStream]time.Time
 	includeIncomplete            map[int]bool
+	storePeerChannelPolicies     map[int]bool
+	// serviceContext is cancelled when the service of the node stops
+	serviceContext map[int]context.Context
 }
 
 var RunningServices map[ServiceType]*Services //nolint:gochecknoglobals
@@ -56,6 +60,7 @@ func (rs *Services) RemoveSubscription(nodeId int, eventChannel chan interface{}
 		delete(rs.runningList, nodeId)
 		rs.serviceStatus[nodeId] = Inactive
 	}
+	delete(rs.serviceContext, nodeId)
 
 	if rs.ServiceType == LndService {
 		setStreamStatuses(nodeId, rs, Inactive)
@@ -329,6 +334,40 @@ func (rs *Services) SetIncludeIncomplete(nodeId int, includeIncomplete bool) {
 	rs.includeIncomplete[nodeId] = includeIncomplete
 }
 
+func (rs *Services) GetStorePeerChannelPolicies(nodeId int) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	initServiceMaps(rs, nodeId)
+	return rs.storePeerChannelPolicies[nodeId]
+}
+
+func (rs *Services) SetStorePeerChannelPolicies(nodeId int, storePeerChannelPolicies bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	initServiceMaps(rs, nodeId)
+	rs.storePeerChannelPolicies[nodeId] = storePeerChannelPolicies
+}
+
+// GetContext returns the context of the running service of the node, actions started by the user that outlive
+// their request use it so they stop with the service.
+func (rs *Services) GetContext(nodeId int) (context.Context, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	ctx, exists := rs.serviceContext[nodeId]
+	return ctx, exists
+}
+
+func (rs *Services) SetContext(nodeId int, ctx context.Context) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	initServiceMaps(rs, nodeId)
+	rs.serviceContext[nodeId] = ctx
+}
+
 func (rs *Services) Initialising(nodeId int, eventChannel chan interface{}) {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
@@ -389,6 +428,8 @@ func initServiceMaps(rs *Services, nodeId int) {
 		rs.streamBootTime = make(map[int]map[SubscriptionStream]time.Time)
 		rs.streamInitializationPingTime = make(map[int]map[SubscriptionStream]time.Time)
 		rs.includeIncomplete = make(map[int]bool)
+		rs.storePeerChannelPolicies = make(map[int]bool)
+		rs.serviceContext = make(map[int]context.Context)
 	}
 	_, exists := rs.streamStatus[nodeId]
 	if !exists {
@@ -25,6 +25,9 @@ func ChannelBalanceCacheMaintenance(ctx context.Context, lndClient lnrpc.Lightni
 	subscriptionStream := commons.ChannelBalanceCacheStream
 	lndSyncTicker := clock.New().Tick(commons.CHANNELBALANCE_TICKER_SECONDS * time.Second)
 	mutex := &sync.RWMutex{}
+	snapshotter := newChannelBalanceSnapshotter()
+	// The snapshots are stored outside the listener because the broadcaster waits for its listeners
+	balanceChanged := make(chan struct{}, 1)
 
 	bootStrapping, serviceStatus = synchronizeDataFromLnd(nodeSettings, bootStrapping,
 		serviceStatus, eventChannel, subscriptionStream, lndClient, db, mutex)
@@ -38,7 +41,12 @@ func ChannelBalanceCacheMaintenance(ctx context.Context, lndClient lnrpc.Lightni
 				return
 			default:
 			}
-			processBroadcastedEvent(event)
+			if processBroadcastedEvent(event, eventChannel) {
+				select {
+				case balanceChanged <- struct{}{}:
+				default:
+				}
+			}
 		}
 	}()
 
@@ -49,6 +57,13 @@ func ChannelBalanceCacheMaintenance(ctx context.Context, lndClient lnrpc.Lightni
 		case <-lndSyncTicker:
 			bootStrapping, serviceStatus = synchronizeDataFromLnd(nodeSettings, bootStrapping,
 				serviceStatus, eventChannel, subscriptionStream, lndClient, db, mutex)
+		case <-balanceChanged:
+		}
+		if !bootStrapping {
+			err := snapshotter.store(db, nodeSettings.NodeId, time.Now().UTC())
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to store the channel balance snapshots (nodeId: %v)", nodeSettings.NodeId)
+			}
 		}
 	}
 }
@@ -177,18 +192,19 @@ func initializeChannelBalanceFromLnd(lndClient lnrpc.LightningClient, nodeId int
 	return nil
 }
 
-func processBroadcastedEvent(event interface{}) {
+// processBroadcastedEvent returns true when the balance of a channel changed
+func processBroadcastedEvent(event interface{}, eventChannel chan interface{}) bool {
 	if serviceEvent, ok := event.(commons.ServiceEvent); ok {
 		if serviceEvent.NodeId == 0 || serviceEvent.Type != commons.LndService {
-			return
+			return false
 		}
 		if !serviceEvent.SubscriptionStream.IsChannelBalanceCache() {
-			return
+			return false
 		}
 		commons.SetChannelStateNodeStatus(serviceEvent.NodeId, serviceEvent.Status)
 	} else if channelEvent, ok := event.(commons.ChannelEvent); ok {
 		if channelEvent.NodeId == 0 || channelEvent.ChannelId == 0 {
-			return
+			return false
 		}
 
 		var status commons.Status
@@ -205,7 +221,7 @@ func processBroadcastedEvent(event interface{}) {
 		if channelGraphEvent.NodeId == 0 || channelGraphEvent.ChannelId == nil || *channelGraphEvent.ChannelId == 0 ||
 			channelGraphEvent.AnnouncingNodeId == nil || *channelGraphEvent.AnnouncingNodeId == 0 ||
 			channelGraphEvent.ConnectingNodeId == nil || *channelGraphEvent.ConnectingNodeId == 0 {
-			return
+			return false
 		}
 		local := *channelGraphEvent.AnnouncingNodeId == channelGraphEvent.NodeId
 		commons.SetChannelStateRoutingPolicy(channelGraphEvent.NodeId, *channelGraphEvent.ChannelId, local,
@@ -213,32 +229,40 @@ func processBroadcastedEvent(event interface{}) {
 			channelGraphEvent.MaxHtlcMsat, channelGraphEvent.FeeBaseMsat, channelGraphEvent.FeeRateMilliMsat)
 	} else if forwardEvent, ok := event.(commons.ForwardEvent); ok {
 		if forwardEvent.NodeId == 0 {
-			return
+			return false
 		}
 		if forwardEvent.IncomingChannelId != nil {
 			commons.SetChannelStateBalanceUpdateMsat(forwardEvent.NodeId, *forwardEvent.IncomingChannelId, true, forwardEvent.AmountInMsat)
+			sendChannelBalanceEvent(forwardEvent.NodeId, *forwardEvent.IncomingChannelId, eventChannel)
 		}
 		if forwardEvent.OutgoingChannelId != nil {
 			commons.SetChannelStateBalanceUpdateMsat(forwardEvent.NodeId, *forwardEvent.OutgoingChannelId, false, forwardEvent.AmountOutMsat)
+			sendChannelBalanceEvent(forwardEvent.NodeId, *forwardEvent.OutgoingChannelId, eventChannel)
 		}
+		return true
 	} else if invoiceEvent, ok := event.(commons.InvoiceEvent); ok {
 		if invoiceEvent.NodeId == 0 || invoiceEvent.State != lnrpc.Invoice_SETTLED {
-			return
+			return false
 		}
 		commons.SetChannelStateBalanceUpdateMsat(invoiceEvent.NodeId, invoiceEvent.ChannelId, true, invoiceEvent.AmountPaidMsat)
+		sendChannelBalanceEvent(invoiceEvent.NodeId, invoiceEvent.ChannelId, eventChannel)
+		return true
 	} else if paymentEvent, ok := event.(commons.PaymentEvent); ok {
 		if paymentEvent.NodeId == 0 || paymentEvent.OutgoingChannelId == nil || *paymentEvent.OutgoingChannelId == 0 || paymentEvent.PaymentStatus != lnrpc.Payment_SUCCEEDED {
-			return
+			return false
 		}
 		commons.SetChannelStateBalanceUpdate(paymentEvent.NodeId, *paymentEvent.OutgoingChannelId, false, paymentEvent.AmountPaid)
+		sendChannelBalanceEvent(paymentEvent.NodeId, *paymentEvent.OutgoingChannelId, eventChannel)
+		return true
 	} else if htlcEvent, ok := event.(commons.HtlcEvent); ok {
 		if htlcEvent.NodeId == 0 {
-			return
+			return false
 		}
 		commons.SetChannelStateBalanceHtlcEvent(htlcEvent)
+		return true
 	} else if peerEvent, ok := event.(commons.PeerEvent); ok {
 		if peerEvent.NodeId == 0 || peerEvent.EventNodeId == 0 {
-			return
+			return false
 		}
 		var status commons.Status
 		switch peerEvent.Type {
@@ -259,12 +283,12 @@ func processBroadcastedEvent(event interface{}) {
 		//	commons.SetChannelStateChannelStatus(openChannelEvent.Request.NodeId, openChannelEvent.ChannelId, commons.Inactive)
 	} else if closeChannelEvent, ok := event.(commons.CloseChannelResponse); ok {
 		if closeChannelEvent.Request.NodeId == 0 {
-			return
+			return false
 		}
 		commons.SetChannelStateChannelStatus(channelEvent.NodeId, channelEvent.ChannelId, commons.Deleted)
 	} else if updateChannelEvent, ok := event.(commons.UpdateChannelResponse); ok {
 		if updateChannelEvent.Request.NodeId == 0 || updateChannelEvent.Request.ChannelId == nil || *updateChannelEvent.Request.ChannelId == 0 {
-			return
+			return false
 		}
 		// Force Response because we don't care about balance accuracy
 		currentStates := commons.GetChannelState(updateChannelEvent.Request.NodeId, *updateChannelEvent.Request.ChannelId, true)
@@ -291,4 +315,30 @@ func processBroadcastedEvent(event interface{}) {
 		commons.SetChannelStateRoutingPolicy(updateChannelEvent.Request.NodeId, *updateChannelEvent.Request.ChannelId, true,
 			currentStates.LocalDisabled, timeLockDelta, minHtlcMsat, maxHtlcMsat, feeBaseMsat, feeRateMilliMsat)
 	}
+	return false
+}
+
+// sendChannelBalanceEvent is asynchronous because this runs inside a broadcast listener
+// and the broadcaster waits for its listeners.
+func sendChannelBalanceEvent(nodeId int, channelId int, eventChannel chan interface{}) {
+	if eventChannel == nil {
+		return
+	}
+	balanceState := commons.GetChannelBalanceState(nodeId, channelId, false, commons.PENDING_HTLCS_IGNORED)
+	if balanceState == nil {
+		return
+	}
+	go func() {
+		eventChannel <- commons.ChannelBalanceEvent{
+			EventData: commons.EventData{
+				EventTime: time.Now().UTC(),
+				NodeId:    nodeId,
+			},
+			ChannelId:                  channelId,
+			LocalBalance:               balanceState.LocalBalance,
+			LocalBalancePerMilleRatio:  balanceState.LocalBalancePerMilleRatio,
+			RemoteBalance:              balanceState.RemoteBalance,
+			RemoteBalancePerMilleRatio: balanceState.RemoteBalancePerMilleRatio,
+		}
+	}()
 }
@@ -0,0 +1,80 @@
+package lnd
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type channelBalanceSnapshot struct {
+	Time                      time.Time `db:"time"`
+	NodeId                    int       `db:"node_id"`
+	ChannelId                 int       `db:"channel_id"`
+	LocalBalance              int64     `db:"local_balance"`
+	RemoteBalance             int64     `db:"remote_balance"`
+	PendingIncomingHtlcAmount int64     `db:"pending_incoming_htlc_amount"`
+	PendingOutgoingHtlcAmount int64     `db:"pending_outgoing_htlc_amount"`
+}
+
+// channelBalanceSnapshotter remembers the last stored snapshot per channel of a node so only changes and the
+// periodic snapshots are stored.
+type channelBalanceSnapshotter struct {
+	lastSnapshots map[int]channelBalanceSnapshot
+}
+
+func newChannelBalanceSnapshotter() *channelBalanceSnapshotter {
+	return &channelBalanceSnapshotter{lastSnapshots: make(map[int]channelBalanceSnapshot)}
+}
+
+func (snapshotter *channelBalanceSnapshotter) store(db *sqlx.DB, nodeId int, now time.Time) error {
+	snapshots := snapshotter.snapshotsToStore(commons.GetChannelStates(nodeId, true), now)
+	if len(snapshots) == 0 {
+		return nil
+	}
+	_, err := db.NamedExec(`INSERT INTO channel_balance_snapshot (time, node_id, channel_id, local_balance,
+			remote_balance, pending_incoming_htlc_amount, pending_outgoing_htlc_amount)
+		VALUES (:time, :node_id, :channel_id, :local_balance,
+			:remote_balance, :pending_incoming_htlc_amount, :pending_outgoing_htlc_amount);`, snapshots)
+	if err != nil {
+		return errors.Wrap(err, "DB Exec")
+	}
+	for _, snapshot := range snapshots {
+		snapshotter.lastSnapshots[snapshot.ChannelId] = snapshot
+	}
+	return nil
+}
+
+// snapshotsToStore the channels with a balance change since the last snapshot or without a recent snapshot
+func (snapshotter *channelBalanceSnapshotter) snapshotsToStore(channelStates []commons.ManagedChannelStateSettings,
+	now time.Time) []channelBalanceSnapshot {
+
+	var result []channelBalanceSnapshot
+	for _, channelState := range channelStates {
+		snapshot := channelBalanceSnapshot{
+			Time:                      now,
+			NodeId:                    channelState.NodeId,
+			ChannelId:                 channelState.ChannelId,
+			LocalBalance:              channelState.LocalBalance,
+			RemoteBalance:             channelState.RemoteBalance,
+			PendingIncomingHtlcAmount: channelState.PendingIncomingHtlcAmount,
+			PendingOutgoingHtlcAmount: channelState.PendingOutgoingHtlcAmount,
+		}
+		last, exists := snapshotter.lastSnapshots[channelState.ChannelId]
+		if exists && sameBalance(last, snapshot) &&
+			now.Sub(last.Time) < commons.CHANNELBALANCE_SNAPSHOT_SECONDS*time.Second {
+			continue
+		}
+		result = append(result, snapshot)
+	}
+	return result
+}
+
+func sameBalance(snapshot channelBalanceSnapshot, other channelBalanceSnapshot) bool {
+	return snapshot.LocalBalance == other.LocalBalance &&
+		snapshot.RemoteBalance == other.RemoteBalance &&
+		snapshot.PendingIncomingHtlcAmount == other.PendingIncomingHtlcAmount &&
+		snapshot.PendingOutgoingHtlcAmount == other.PendingOutgoingHtlcAmount
+}
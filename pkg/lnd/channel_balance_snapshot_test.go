@@ -0,0 +1,42 @@
+package lnd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestSnapshotsToStore(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	snapshotter := newChannelBalanceSnapshotter()
+	snapshotter.lastSnapshots[1] = channelBalanceSnapshot{Time: now.Add(-time.Minute), ChannelId: 1,
+		LocalBalance: 100, RemoteBalance: 900}
+	snapshotter.lastSnapshots[2] = channelBalanceSnapshot{Time: now.Add(-time.Minute), ChannelId: 2,
+		LocalBalance: 100, RemoteBalance: 900}
+	snapshotter.lastSnapshots[3] = channelBalanceSnapshot{Time: now.Add(-commons.CHANNELBALANCE_SNAPSHOT_SECONDS * time.Second),
+		ChannelId: 3, LocalBalance: 100, RemoteBalance: 900}
+
+	snapshots := snapshotter.snapshotsToStore([]commons.ManagedChannelStateSettings{
+		// unchanged
+		{ChannelId: 1, LocalBalance: 100, RemoteBalance: 900},
+		// changed
+		{ChannelId: 2, LocalBalance: 200, RemoteBalance: 800},
+		// unchanged but the last snapshot is too old
+		{ChannelId: 3, LocalBalance: 100, RemoteBalance: 900},
+		// never stored
+		{ChannelId: 4, LocalBalance: 500, RemoteBalance: 500, PendingOutgoingHtlcAmount: 10},
+	}, now)
+
+	if len(snapshots) != 3 {
+		t.Fatalf("expected 3 snapshots, got %v", snapshots)
+	}
+	for i, channelId := range []int{2, 3, 4} {
+		if snapshots[i].ChannelId != channelId || !snapshots[i].Time.Equal(now) {
+			t.Errorf("expected a snapshot of channel %v at %v, got %+v", channelId, now, snapshots[i])
+		}
+	}
+	if snapshots[2].PendingOutgoingHtlcAmount != 10 {
+		t.Errorf("expected the pending HTLC amount to be stored, got %+v", snapshots[2])
+	}
+}
@@ -76,7 +76,7 @@ func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscri
 			channel.FundingTransactionHash, channel.FundingOutputIndex, channel.Capacity, channel.Private,
 			channel.FirstNodeId, channel.SecondNodeId, channel.InitiatingNodeId, channel.AcceptingNodeId)
 
-		err = insertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channel.ChannelID, false, jsonByteArray,
+		err = InsertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channel.ChannelID, false, jsonByteArray,
 			channelEvent, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Insert Open Channel Event")
@@ -112,7 +112,7 @@ func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscri
 			return errors.Wrap(err, "CLOSED_CHANNEL: JSON Marshall")
 		}
 
-		err = insertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channel.ChannelID, false, jsonByteArray,
+		err = InsertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channel.ChannelID, false, jsonByteArray,
 			channelEvent, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Insert Closed Channel Event")
@@ -141,7 +141,7 @@ func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscri
 		if err != nil {
 			return errors.Wrap(err, "ACTIVE_CHANNEL: JSON Marshall")
 		}
-		err = insertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
+		err = InsertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
 			channelEvent, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Insert Active Channel Event")
@@ -158,7 +158,7 @@ func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscri
 		if err != nil {
 			return errors.Wrap(err, "INACTIVE_CHANNEL: JSON Marshall")
 		}
-		err = insertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
+		err = InsertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
 			channelEvent, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Insert Inactive Channel Event")
@@ -174,7 +174,7 @@ func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscri
 		if err != nil {
 			return errors.Wrap(err, "FULLY_RESOLVED_CHANNEL: JSON Marshall")
 		}
-		err = insertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
+		err = InsertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
 			channelEvent, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Insert Fully Resolved Channel Event")
@@ -190,7 +190,7 @@ func storeChannelEvent(ctx context.Context, db *sqlx.DB, client lndClientSubscri
 		if err != nil {
 			return errors.Wrap(err, "PENDING_OPEN_CHANNEL: JSON Marshall")
 		}
-		err = insertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
+		err = InsertChannelEvent(db, timestampMs, ce.Type, nodeSettings.NodeId, channelId, false, jsonByteArray,
 			channelEvent, eventChannel)
 		if err != nil {
 			return errors.Wrap(err, "Insert Pending Open Channel Event")
@@ -515,7 +515,7 @@ icoLoop:
 			return errors.Wrap(err, "ImportedOpenChannels: JSON Marshal")
 		}
 
-		err = insertChannelEvent(db, time.Now().UTC(), lnrpc.ChannelEventUpdate_OPEN_CHANNEL, nodeSettings.NodeId,
+		err = InsertChannelEvent(db, time.Now().UTC(), lnrpc.ChannelEventUpdate_OPEN_CHANNEL, nodeSettings.NodeId,
 			channel.ChannelID, true, jsonByteArray, commons.ChannelEvent{}, nil)
 		if err != nil {
 			return errors.Wrap(err, "ImportedOpenChannels: Insert channel event")
@@ -591,7 +591,7 @@ icoLoop:
 			return errors.Wrap(err, "ImportedClosedChannels: JSON Marshal")
 		}
 
-		err = insertChannelEvent(db, time.Now().UTC(), lnrpc.ChannelEventUpdate_CLOSED_CHANNEL, nodeSettings.NodeId,
+		err = InsertChannelEvent(db, time.Now().UTC(), lnrpc.ChannelEventUpdate_CLOSED_CHANNEL, nodeSettings.NodeId,
 			channel.ChannelID, true, jsonByteArray, commons.ChannelEvent{}, nil)
 		if err != nil {
 			return errors.Wrap(err, "ImportedClosedChannels: Insert channel event")
@@ -602,7 +602,7 @@ icoLoop:
 	return nil
 }
 
-func insertChannelEvent(db *sqlx.DB, eventTime time.Time, eventType lnrpc.ChannelEventUpdate_UpdateType,
+func InsertChannelEvent(db *sqlx.DB, eventTime time.Time, eventType lnrpc.ChannelEventUpdate_UpdateType,
 	nodeId, channelId int, imported bool, jsonByteArray []byte,
 	channelEvent commons.ChannelEvent, eventChannel chan interface{}) error {
 
@@ -0,0 +1,246 @@
+package lnd
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type lightningClientChannelLifecycle interface {
+	GetInfo(ctx context.Context, in *lnrpc.GetInfoRequest,
+		opts ...grpc.CallOption) (*lnrpc.GetInfoResponse, error)
+	PendingChannels(ctx context.Context, in *lnrpc.PendingChannelsRequest,
+		opts ...grpc.CallOption) (*lnrpc.PendingChannelsResponse, error)
+	GetTransactions(ctx context.Context, in *lnrpc.GetTransactionsRequest,
+		opts ...grpc.CallOption) (*lnrpc.TransactionDetails, error)
+}
+
+// TrackChannelLifecycles keeps a record of the pending opens and closes of the node. Pending channels are only
+// reported by LND on request so they are polled.
+func TrackChannelLifecycles(ctx context.Context, client lightningClientChannelLifecycle, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
+
+	ticker := time.NewTicker(commons.CHANNEL_LIFECYCLE_TICKER_SECONDS * time.Second)
+	defer ticker.Stop()
+	for {
+		err := trackChannelLifecycles(ctx, client, db, nodeSettings.NodeId, time.Now().UTC(), eventChannel)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+			log.Error().Err(err).Msgf("Tracking pending channels failed for nodeId: %v", nodeSettings.NodeId)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func trackChannelLifecycles(ctx context.Context, client lightningClientChannelLifecycle, db *sqlx.DB,
+	nodeId int, now time.Time, eventChannel chan interface{}) error {
+
+	pendingChannels, err := client.PendingChannels(ctx, &lnrpc.PendingChannelsRequest{})
+	if err != nil {
+		return errors.Wrap(err, "LND PendingChannels")
+	}
+	info, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil {
+		return errors.Wrap(err, "LND GetInfo")
+	}
+	startHeight := int32(info.BlockHeight) - commons.CHANNEL_LIFECYCLE_TRANSACTION_BLOCKS
+	if startHeight < 0 {
+		startHeight = 0
+	}
+	transactions, err := client.GetTransactions(ctx, &lnrpc.GetTransactionsRequest{
+		StartHeight: startHeight,
+		// -1 includes the unconfirmed transactions
+		EndHeight: -1,
+	})
+	if err != nil {
+		return errors.Wrap(err, "LND GetTransactions")
+	}
+	transactionsByTxid := make(map[string]*lnrpc.Transaction, len(transactions.Transactions))
+	for _, transaction := range transactions.Transactions {
+		transactionsByTxid[transaction.TxHash] = transaction
+	}
+
+	pending := pendingLifecycles(pendingChannels, nodeId, now)
+	pendingKeys := make(map[string]bool, len(pending))
+	for _, lifecycle := range pending {
+		if lifecycle.Txid != nil {
+			addTransactionDetails(&lifecycle, transactionsByTxid[*lifecycle.Txid])
+		}
+		err = channels.UpsertChannelLifecycle(db, lifecycle)
+		if err != nil {
+			return errors.Wrapf(err, "Storing pending %v of %v", lifecycle.LifecycleType, lifecycle.ChannelPoint)
+		}
+		pendingKeys[lifecycleKey(lifecycle)] = true
+	}
+
+	stored, err := channels.GetChannelLifecycles(db, nodeId, channels.LifecyclePending)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining pending channel lifecycles")
+	}
+	for _, lifecycle := range stored {
+		if !pendingKeys[lifecycleKey(lifecycle)] {
+			err = channels.CompleteChannelLifecycle(db, lifecycle.ChannelLifecycleId, resolvedStatus(lifecycle), now)
+			if err != nil {
+				return errors.Wrapf(err, "Completing %v of %v", lifecycle.LifecycleType, lifecycle.ChannelPoint)
+			}
+			continue
+		}
+		if !isStuck(lifecycle, now) {
+			continue
+		}
+		err = channels.SetChannelLifecycleStuckAlerted(db, lifecycle.ChannelLifecycleId, now)
+		if err != nil {
+			return errors.Wrapf(err, "Marking %v of %v as stuck", lifecycle.LifecycleType, lifecycle.ChannelPoint)
+		}
+		if eventChannel != nil {
+			eventChannel <- commons.StuckTransactionEvent{
+				EventData:          commons.EventData{EventTime: now, NodeId: nodeId},
+				ChannelLifecycleId: lifecycle.ChannelLifecycleId,
+				ChannelPoint:       lifecycle.ChannelPoint,
+				LifecycleType:      string(lifecycle.LifecycleType),
+				Txid:               lifecycle.Txid,
+				WaitingSeconds:     int64(now.Sub(lifecycle.PendingOn).Seconds()),
+				FeeRateSatPerVbyte: lifecycle.FeeRateSatPerVbyte,
+			}
+		}
+	}
+	return nil
+}
+
+// pendingLifecycles the opens and closes LND reports as pending, without transaction details
+func pendingLifecycles(pendingChannels *lnrpc.PendingChannelsResponse, nodeId int,
+	now time.Time) []channels.ChannelLifecycle {
+
+	var result []channels.ChannelLifecycle
+	add := func(lifecycleType channels.ChannelLifecycleType, channel *lnrpc.PendingChannelsResponse_PendingChannel,
+		txid string) {
+		if channel == nil {
+			return
+		}
+		lifecycle := channels.ChannelLifecycle{
+			NodeId:          nodeId,
+			ChannelPoint:    channel.ChannelPoint,
+			LifecycleType:   lifecycleType,
+			Status:          channels.LifecyclePending,
+			RemotePublicKey: channel.RemoteNodePub,
+			Capacity:        channel.Capacity,
+			PendingOn:       now,
+			UpdatedOn:       now,
+		}
+		if txid != "" {
+			lifecycle.Txid = &txid
+		}
+		result = append(result, lifecycle)
+	}
+	for _, pendingOpen := range pendingChannels.PendingOpenChannels {
+		if pendingOpen.Channel != nil {
+			fundingTransactionHash, _ := commons.ParseChannelPoint(pendingOpen.Channel.ChannelPoint)
+			add(channels.LifecycleOpen, pendingOpen.Channel, fundingTransactionHash)
+		}
+	}
+	for _, waitingClose := range pendingChannels.WaitingCloseChannels {
+		add(channels.LifecycleClose, waitingClose.Channel, waitingClose.ClosingTxid)
+	}
+	for _, pendingClose := range pendingChannels.PendingClosingChannels {
+		add(channels.LifecycleClose, pendingClose.Channel, pendingClose.ClosingTxid)
+	}
+	for _, forceClose := range pendingChannels.PendingForceClosingChannels {
+		add(channels.LifecycleClose, forceClose.Channel, forceClose.ClosingTxid)
+	}
+	return result
+}
+
+// addTransactionDetails sets the confirmations, the fee (rate) and an output of the wallet to bump the fee with
+func addTransactionDetails(lifecycle *channels.ChannelLifecycle, transaction *lnrpc.Transaction) {
+	if transaction == nil {
+		return
+	}
+	lifecycle.Confirmations = int64(transaction.NumConfirmations)
+	for _, output := range transaction.OutputDetails {
+		if output.IsOurAddress {
+			outputIndex := int(output.OutputIndex)
+			lifecycle.BumpOutputIndex = &outputIndex
+			break
+		}
+	}
+	// LND only knows the fee when the wallet funded the transaction
+	if transaction.TotalFees <= 0 {
+		return
+	}
+	feeSat := transaction.TotalFees
+	lifecycle.FeeSat = &feeSat
+	vsize, err := virtualSize(transaction.RawTxHex)
+	if err != nil {
+		log.Debug().Err(err).Msgf("Could not determine the size of transaction %v", transaction.TxHash)
+		return
+	}
+	feeRate := float64(feeSat) / float64(vsize)
+	lifecycle.FeeRateSatPerVbyte = &feeRate
+}
+
+func virtualSize(rawTxHex string) (int64, error) {
+	rawTx, err := hex.DecodeString(rawTxHex)
+	if err != nil {
+		return 0, errors.Wrap(err, "Decoding raw transaction hex")
+	}
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	err = msgTx.Deserialize(bytes.NewReader(rawTx))
+	if err != nil {
+		return 0, errors.Wrap(err, "Deserializing raw transaction")
+	}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(msgTx))
+	return (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor, nil
+}
+
+// isStuck a pending transaction without confirmations that was not reported since it became pending or was bumped
+func isStuck(lifecycle channels.ChannelLifecycle, now time.Time) bool {
+	if lifecycle.Status != channels.LifecyclePending || lifecycle.Txid == nil || lifecycle.Confirmations > 0 {
+		return false
+	}
+	since := lifecycle.PendingOn
+	if lifecycle.BumpedOn != nil {
+		since = *lifecycle.BumpedOn
+	}
+	if lifecycle.StuckAlertedOn != nil && !lifecycle.StuckAlertedOn.Before(since) {
+		return false
+	}
+	return now.Sub(since) >= commons.CHANNEL_LIFECYCLE_STUCK_SECONDS*time.Second
+}
+
+// resolvedStatus the status of a lifecycle that is no longer pending in LND
+func resolvedStatus(lifecycle channels.ChannelLifecycle) channels.ChannelLifecycleStatus {
+	if lifecycle.LifecycleType == channels.LifecycleOpen {
+		channelId := commons.GetChannelIdByChannelPoint(lifecycle.ChannelPoint)
+		if channelId == 0 {
+			return channels.LifecycleFailed
+		}
+		switch commons.GetChannelSettingByChannelId(channelId).Status {
+		case commons.FundingCancelledClosed, commons.AbandonedClosed:
+			return channels.LifecycleFailed
+		}
+	}
+	return channels.LifecycleCompleted
+}
+
+func lifecycleKey(lifecycle channels.ChannelLifecycle) string {
+	return string(lifecycle.LifecycleType) + "|" + lifecycle.ChannelPoint
+}
@@ -0,0 +1,115 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/lncapital/torq/internal/channels"
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+func TestPendingLifecycles(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	fundingTxid := "a3e2e5a9b5c0d8f7e6d5c4b3a29180706f5e4d3c2b1a09f8e7d6c5b4a3928170"
+	pendingChannels := &lnrpc.PendingChannelsResponse{
+		PendingOpenChannels: []*lnrpc.PendingChannelsResponse_PendingOpenChannel{
+			{Channel: &lnrpc.PendingChannelsResponse_PendingChannel{
+				RemoteNodePub: "remote1", ChannelPoint: fundingTxid + ":1", Capacity: 1000000}},
+		},
+		WaitingCloseChannels: []*lnrpc.PendingChannelsResponse_WaitingCloseChannel{
+			// The closing transaction is not broadcasted yet
+			{Channel: &lnrpc.PendingChannelsResponse_PendingChannel{RemoteNodePub: "remote2", ChannelPoint: "b:0"}},
+		},
+		PendingForceClosingChannels: []*lnrpc.PendingChannelsResponse_ForceClosedChannel{
+			{Channel: &lnrpc.PendingChannelsResponse_PendingChannel{RemoteNodePub: "remote3", ChannelPoint: "c:0"},
+				ClosingTxid: "closing"},
+		},
+	}
+	lifecycles := pendingLifecycles(pendingChannels, 1, now)
+	if len(lifecycles) != 3 {
+		t.Fatalf("expected 3 lifecycles, got %v", lifecycles)
+	}
+	if lifecycles[0].LifecycleType != channels.LifecycleOpen || lifecycles[0].Txid == nil ||
+		*lifecycles[0].Txid != fundingTxid || lifecycles[0].Capacity != 1000000 {
+		t.Errorf("expected the funding transaction of the pending open, got %+v", lifecycles[0])
+	}
+	if lifecycles[1].LifecycleType != channels.LifecycleClose || lifecycles[1].Txid != nil {
+		t.Errorf("expected a close without transaction, got %+v", lifecycles[1])
+	}
+	if lifecycles[2].Txid == nil || *lifecycles[2].Txid != "closing" || lifecycles[2].Status != channels.LifecyclePending {
+		t.Errorf("expected the pending force close, got %+v", lifecycles[2])
+	}
+}
+
+func TestAddTransactionDetails(t *testing.T) {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 0}, nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(1000000, make([]byte, 34)))
+	msgTx.AddTxOut(wire.NewTxOut(50000, make([]byte, 22)))
+	var rawTx bytes.Buffer
+	if err := msgTx.Serialize(&rawTx); err != nil {
+		t.Fatal(err)
+	}
+	// A transaction without witness data has a virtual size equal to its size
+	size := int64(rawTx.Len())
+
+	lifecycle := channels.ChannelLifecycle{}
+	addTransactionDetails(&lifecycle, &lnrpc.Transaction{
+		NumConfirmations: 0,
+		TotalFees:        size * 2,
+		RawTxHex:         hex.EncodeToString(rawTx.Bytes()),
+		OutputDetails: []*lnrpc.OutputDetail{
+			{OutputIndex: 0, IsOurAddress: false},
+			{OutputIndex: 1, IsOurAddress: true},
+		},
+	})
+	if lifecycle.FeeSat == nil || *lifecycle.FeeSat != size*2 {
+		t.Errorf("expected a fee of %v, got %v", size*2, lifecycle.FeeSat)
+	}
+	if lifecycle.FeeRateSatPerVbyte == nil || *lifecycle.FeeRateSatPerVbyte != 2 {
+		t.Errorf("expected a fee rate of 2 sat/vbyte, got %v", lifecycle.FeeRateSatPerVbyte)
+	}
+	if lifecycle.BumpOutputIndex == nil || *lifecycle.BumpOutputIndex != 1 {
+		t.Errorf("expected the change output to be used for bumping, got %v", lifecycle.BumpOutputIndex)
+	}
+
+	unknownFee := channels.ChannelLifecycle{}
+	addTransactionDetails(&unknownFee, &lnrpc.Transaction{NumConfirmations: 2})
+	if unknownFee.Confirmations != 2 || unknownFee.FeeSat != nil || unknownFee.FeeRateSatPerVbyte != nil {
+		t.Errorf("expected only the confirmations, got %+v", unknownFee)
+	}
+}
+
+func TestIsStuck(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	txid := "txid"
+	longAgo := now.Add(-2 * commons.CHANNEL_LIFECYCLE_STUCK_SECONDS * time.Second)
+	recently := now.Add(-time.Minute)
+	tests := []struct {
+		name      string
+		lifecycle channels.ChannelLifecycle
+		expected  bool
+	}{
+		{"recent", channels.ChannelLifecycle{Status: channels.LifecyclePending, Txid: &txid, PendingOn: recently}, false},
+		{"stuck", channels.ChannelLifecycle{Status: channels.LifecyclePending, Txid: &txid, PendingOn: longAgo}, true},
+		{"confirmed", channels.ChannelLifecycle{Status: channels.LifecyclePending, Txid: &txid, PendingOn: longAgo,
+			Confirmations: 1}, false},
+		{"not broadcasted", channels.ChannelLifecycle{Status: channels.LifecyclePending, PendingOn: longAgo}, false},
+		{"already alerted", channels.ChannelLifecycle{Status: channels.LifecyclePending, Txid: &txid, PendingOn: longAgo,
+			StuckAlertedOn: &recently}, false},
+		{"recently bumped", channels.ChannelLifecycle{Status: channels.LifecyclePending, Txid: &txid, PendingOn: longAgo,
+			StuckAlertedOn: &longAgo, BumpedOn: &recently}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := isStuck(test.lifecycle, now); result != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
@@ -86,6 +86,13 @@ func SubscribeAndStoreChannelGraph(ctx context.Context, client subscribeChannelG
 					continue
 				}
 			}
+			if commons.RunningServices[commons.LndService].GetStorePeerChannelPolicies(nodeSettings.NodeId) {
+				err = ImportPeerChannelPolicies(ctx, client, db, nodeSettings)
+				if err != nil {
+					// Only log the error, the policies are stored from the stream regardless
+					log.Error().Err(err).Msgf("Failed to import peer channel policies for nodeId: %v", nodeSettings.NodeId)
+				}
+			}
 			serviceStatus = SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
 		}
 
@@ -112,6 +119,13 @@ func SubscribeAndStoreChannelGraph(ctx context.Context, client subscribeChannelG
 			// TODO FIXME STORE THIS SOMEWHERE??? CHANNEL UPDATES ARE NOW IGNORED???
 			log.Error().Err(err).Msgf("Failed to store channel update events")
 		}
+
+		if commons.RunningServices[commons.LndService].GetStorePeerChannelPolicies(nodeSettings.NodeId) {
+			err = processPeerChannelUpdates(gpu.ChannelUpdates, gpu.ClosedChans, db, nodeSettings)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to store peer channel policies")
+			}
+		}
 	}
 }
 
@@ -0,0 +1,148 @@
+package lnd
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// describeGraphMaxMessageSize the full graph exceeds the default maximum message size of the connection
+const describeGraphMaxMessageSize = 200 << (10 * 2)
+
+type describeGraphClient interface {
+	DescribeGraph(ctx context.Context, in *lnrpc.ChannelGraphRequest,
+		opts ...grpc.CallOption) (*lnrpc.ChannelGraph, error)
+}
+
+type GraphSnapshot struct {
+	NodeId     int       `json:"nodeId" db:"node_id"`
+	ImportedOn time.Time `json:"importedOn" db:"imported_on"`
+	NodeCount  int       `json:"nodeCount" db:"node_count"`
+	EdgeCount  int       `json:"edgeCount" db:"edge_count"`
+}
+
+// ImportGraphSnapshot replaces the stored graph snapshot of the node with the full graph as known by LND
+func ImportGraphSnapshot(ctx context.Context, client describeGraphClient, db *sqlx.DB,
+	nodeId int) (GraphSnapshot, error) {
+
+	graph, err := client.DescribeGraph(ctx, &lnrpc.ChannelGraphRequest{IncludeUnannounced: false},
+		grpc.MaxCallRecvMsgSize(describeGraphMaxMessageSize))
+	if err != nil {
+		return GraphSnapshot{}, errors.Wrap(err, "LND DescribeGraph")
+	}
+	snapshot := GraphSnapshot{
+		NodeId:     nodeId,
+		ImportedOn: time.Now().UTC(),
+		NodeCount:  len(graph.Nodes),
+		EdgeCount:  len(graph.Edges),
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return GraphSnapshot{}, errors.Wrap(err, "DB Begin")
+	}
+	defer func() {
+		// Rollback is a no-op when the transaction is committed
+		_ = tx.Rollback()
+	}()
+
+	for _, table := range []string{"graph_snapshot_edge", "graph_snapshot_node", "graph_snapshot"} {
+		_, err = tx.Exec(`DELETE FROM `+table+` WHERE node_id=$1;`, nodeId)
+		if err != nil {
+			return GraphSnapshot{}, errors.Wrapf(err, "DB Exec delete %v", table)
+		}
+	}
+	err = copyGraphNodes(tx, nodeId, graph.Nodes)
+	if err != nil {
+		return GraphSnapshot{}, errors.Wrap(err, "Storing graph nodes")
+	}
+	err = copyGraphEdges(tx, nodeId, graph.Edges)
+	if err != nil {
+		return GraphSnapshot{}, errors.Wrap(err, "Storing graph edges")
+	}
+	_, err = tx.NamedExec(`
+		INSERT INTO graph_snapshot (node_id, imported_on, node_count, edge_count)
+		VALUES (:node_id, :imported_on, :node_count, :edge_count);`, snapshot)
+	if err != nil {
+		return GraphSnapshot{}, errors.Wrap(err, "DB Exec insert graph_snapshot")
+	}
+	err = tx.Commit()
+	if err != nil {
+		return GraphSnapshot{}, errors.Wrap(err, "DB Commit")
+	}
+	log.Info().Msgf("Imported graph snapshot with %v nodes and %v channels for nodeId: %v",
+		snapshot.NodeCount, snapshot.EdgeCount, nodeId)
+	return snapshot, nil
+}
+
+func copyGraphNodes(tx *sqlx.Tx, nodeId int, nodes []*lnrpc.LightningNode) error {
+	stmt, err := tx.Prepare(pq.CopyIn("graph_snapshot_node",
+		"node_id", "public_key", "alias", "last_update", "addresses"))
+	if err != nil {
+		return errors.Wrap(err, "DB Prepare copy graph_snapshot_node")
+	}
+	for _, node := range nodes {
+		addresses, err := json.Marshal(node.Addresses)
+		if err != nil {
+			_ = stmt.Close()
+			return errors.Wrap(err, "JSON Marshall node address map")
+		}
+		_, err = stmt.Exec(nodeId, node.PubKey, node.Alias, time.Unix(int64(node.LastUpdate), 0).UTC(),
+			string(addresses))
+		if err != nil {
+			_ = stmt.Close()
+			return errors.Wrap(err, "DB Exec copy graph_snapshot_node")
+		}
+	}
+	// Executing without arguments flushes the copy
+	_, err = stmt.Exec()
+	if err != nil {
+		_ = stmt.Close()
+		return errors.Wrap(err, "DB Exec flush graph_snapshot_node")
+	}
+	return errors.Wrap(stmt.Close(), "DB Close copy graph_snapshot_node")
+}
+
+func copyGraphEdges(tx *sqlx.Tx, nodeId int, edges []*lnrpc.ChannelEdge) error {
+	stmt, err := tx.Prepare(pq.CopyIn("graph_snapshot_edge",
+		"node_id", "lnd_short_channel_id", "capacity", "last_update", "node1_public_key", "node2_public_key",
+		"node1_fee_base_msat", "node1_fee_rate_mill_msat", "node1_disabled",
+		"node2_fee_base_msat", "node2_fee_rate_mill_msat", "node2_disabled"))
+	if err != nil {
+		return errors.Wrap(err, "DB Prepare copy graph_snapshot_edge")
+	}
+	for _, edge := range edges {
+		node1FeeBaseMsat, node1FeeRateMilliMsat, node1Disabled := graphPolicyValues(edge.Node1Policy)
+		node2FeeBaseMsat, node2FeeRateMilliMsat, node2Disabled := graphPolicyValues(edge.Node2Policy)
+		_, err = stmt.Exec(nodeId, edge.ChannelId, edge.Capacity, time.Unix(int64(edge.LastUpdate), 0).UTC(),
+			edge.Node1Pub, edge.Node2Pub,
+			node1FeeBaseMsat, node1FeeRateMilliMsat, node1Disabled,
+			node2FeeBaseMsat, node2FeeRateMilliMsat, node2Disabled)
+		if err != nil {
+			_ = stmt.Close()
+			return errors.Wrap(err, "DB Exec copy graph_snapshot_edge")
+		}
+	}
+	// Executing without arguments flushes the copy
+	_, err = stmt.Exec()
+	if err != nil {
+		_ = stmt.Close()
+		return errors.Wrap(err, "DB Exec flush graph_snapshot_edge")
+	}
+	return errors.Wrap(stmt.Close(), "DB Close copy graph_snapshot_edge")
+}
+
+// graphPolicyValues all values are nil when the policy was not announced
+func graphPolicyValues(policy *lnrpc.RoutingPolicy) (*int64, *int64, *bool) {
+	if policy == nil {
+		return nil, nil, nil
+	}
+	return &policy.FeeBaseMsat, &policy.FeeRateMilliMsat, &policy.Disabled
+}
@@ -0,0 +1,36 @@
+package lnd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/grpc"
+)
+
+type mockDescribeGraphClient struct {
+	opts []grpc.CallOption
+}
+
+func (c *mockDescribeGraphClient) DescribeGraph(ctx context.Context, in *lnrpc.ChannelGraphRequest,
+	opts ...grpc.CallOption) (*lnrpc.ChannelGraph, error) {
+	c.opts = opts
+	return nil, errors.New("unavailable")
+}
+
+func TestImportGraphSnapshotMaxMessageSize(t *testing.T) {
+	client := &mockDescribeGraphClient{}
+	// The database isn't used when DescribeGraph fails
+	_, err := ImportGraphSnapshot(context.Background(), client, nil, 1)
+	if err == nil {
+		t.Fatal("expected the DescribeGraph error")
+	}
+	for _, opt := range client.opts {
+		if recvSize, ok := opt.(grpc.MaxRecvMsgSizeCallOption); ok &&
+			recvSize.MaxRecvMsgSize == describeGraphMaxMessageSize {
+			return
+		}
+	}
+	t.Errorf("expected the maximum receive message size as call option, got %v", client.opts)
+}
@@ -431,6 +431,34 @@ func insertInvoice(db *sqlx.DB, invoice *lnrpc.Invoice, destination string, dest
 		UpdatedOn:         time.Now().UTC(),
 	}
 
+	err = StoreInvoice(db, i)
+	if err != nil {
+		return err
+	}
+
+	if eventChannel != nil && !bootStrapping {
+		invoiceEvent.AddIndex = invoice.AddIndex
+		invoiceEvent.ValueMSat = uint64(invoice.ValueMsat)
+		invoiceEvent.State = invoice.GetState()
+		// Add other info for settled and accepted states
+		//	Invoice_OPEN     = 0
+		//	Invoice_SETTLED  = 1
+		//	Invoice_CANCELED = 2
+		//	Invoice_ACCEPTED = 3
+		if invoice.State == 1 || invoice.State == 3 {
+			invoiceEvent.AmountPaidMsat = uint64(invoice.AmtPaidMsat)
+			invoiceEvent.SettledDate = time.Unix(invoice.SettleDate, 0)
+		}
+		if channelId != nil {
+			invoiceEvent.ChannelId = *channelId
+		}
+		eventChannel <- invoiceEvent
+	}
+	return nil
+}
+
+// StoreInvoice stores an invoice row, it's used by every node implementation.
+func StoreInvoice(db *sqlx.DB, invoice Invoice) error {
 	var sqlInvoice = `INSERT INTO invoice (
     memo,
     r_preimage,
@@ -500,30 +528,10 @@ func insertInvoice(db *sqlx.DB, invoice *lnrpc.Invoice, destination string, dest
     :updated_on
 );`
 
-	_, err = db.NamedExec(sqlInvoice, i)
-
+	_, err := db.NamedExec(sqlInvoice, invoice)
 	if err != nil {
 		log.Error().Msgf("insert invoice: %v", err)
 		return errors.Wrapf(err, "insert invoice")
 	}
-
-	if eventChannel != nil && !bootStrapping {
-		invoiceEvent.AddIndex = invoice.AddIndex
-		invoiceEvent.ValueMSat = uint64(invoice.ValueMsat)
-		invoiceEvent.State = invoice.GetState()
-		// Add other info for settled and accepted states
-		//	Invoice_OPEN     = 0
-		//	Invoice_SETTLED  = 1
-		//	Invoice_CANCELED = 2
-		//	Invoice_ACCEPTED = 3
-		if invoice.State == 1 || invoice.State == 3 {
-			invoiceEvent.AmountPaidMsat = uint64(invoice.AmtPaidMsat)
-			invoiceEvent.SettledDate = time.Unix(invoice.SettleDate, 0)
-		}
-		if channelId != nil {
-			invoiceEvent.ChannelId = *channelId
-		}
-		eventChannel <- invoiceEvent
-	}
 	return nil
 }
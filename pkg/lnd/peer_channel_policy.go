@@ -0,0 +1,241 @@
+package lnd
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type peerChannelPolicy struct {
+	Ts                  time.Time `db:"ts"`
+	LndShortChannelId   uint64    `db:"lnd_short_channel_id"`
+	PeerNodeId          int       `db:"peer_node_id"`
+	ConnectingPublicKey string    `db:"connecting_public_key"`
+	Disabled            bool      `db:"disabled"`
+	TimeLockDelta       uint32    `db:"time_lock_delta"`
+	MinHtlcMsat         int64     `db:"min_htlc_msat"`
+	MaxHtlcMsat         uint64    `db:"max_htlc_msat"`
+	FeeBaseMsat         int64     `db:"fee_base_msat"`
+	FeeRateMillMsat     int64     `db:"fee_rate_mill_msat"`
+	NodeId              int       `db:"node_id"`
+}
+
+// processPeerChannelUpdates stores the policies announced by the peers for their channels with other nodes
+// and removes the policies of the closed channels.
+func processPeerChannelUpdates(cus []*lnrpc.ChannelEdgeUpdate, closedChannels []*lnrpc.ClosedChannelUpdate,
+	db *sqlx.DB, nodeSettings commons.ManagedNodeSettings) error {
+
+	peerNodeIds := getPeerNodeIds(nodeSettings.NodeId)
+	var policies []peerChannelPolicy
+	for _, cu := range cus {
+		if cu.RoutingPolicy == nil || cu.AdvertisingNode == "" {
+			continue
+		}
+		peerNodeId := commons.GetNodeIdByPublicKey(cu.AdvertisingNode, nodeSettings.Chain, nodeSettings.Network)
+		if !peerNodeIds[peerNodeId] {
+			continue
+		}
+		policy, isPeerChannel := toPeerChannelPolicy(time.Now().UTC(), nodeSettings, peerNodeId, cu.ChanId,
+			cu.ConnectingNode, cu.RoutingPolicy)
+		if isPeerChannel {
+			policies = append(policies, policy)
+		}
+	}
+	err := storePeerChannelPolicies(db, policies)
+	if err != nil {
+		return errors.Wrap(err, "Storing peer channel policies")
+	}
+
+	if len(closedChannels) == 0 {
+		return nil
+	}
+	var lndShortChannelIds []int64
+	for _, closedChannel := range closedChannels {
+		lndShortChannelIds = append(lndShortChannelIds, int64(closedChannel.ChanId))
+	}
+	_, err = db.Exec(`DELETE FROM peer_channel_policy WHERE node_id=$1 AND lnd_short_channel_id=ANY($2);`,
+		nodeSettings.NodeId, pq.Array(lndShortChannelIds))
+	if err != nil {
+		return errors.Wrap(err, "DB Exec")
+	}
+	return nil
+}
+
+// ImportPeerChannelPolicies stores the current policies of the channels of all peers of the node
+func ImportPeerChannelPolicies(ctx context.Context, client subscribeChannelGraphClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings) error {
+
+	peerNodeIds := getPeerNodeIds(nodeSettings.NodeId)
+	if len(peerNodeIds) == 0 {
+		return nil
+	}
+	var nodeIds []int
+	for peerNodeId := range peerNodeIds {
+		nodeIds = append(nodeIds, peerNodeId)
+	}
+	var peers []struct {
+		NodeId    int    `db:"node_id"`
+		PublicKey string `db:"public_key"`
+	}
+	err := db.Select(&peers, `SELECT node_id, public_key FROM node WHERE node_id=ANY($1);`, pq.Array(nodeIds))
+	if err != nil {
+		return errors.Wrap(err, "DB Select")
+	}
+
+	now := time.Now().UTC()
+	for _, peer := range peers {
+		ni, err := client.GetNodeInfo(ctx, &lnrpc.NodeInfoRequest{PubKey: peer.PublicKey, IncludeChannels: true})
+		if err != nil {
+			if e, ok := status.FromError(err); ok && e.Code() == codes.NotFound {
+				log.Debug().Err(err).Msgf("Node info not found when importing peer channel policies for public key: %v", peer.PublicKey)
+				err = removeMissingPeerChannelPolicies(db, nodeSettings.NodeId, peer.NodeId, nil)
+				if err != nil {
+					return errors.Wrapf(err, "Removing peer channel policies for peer node id: %v", peer.NodeId)
+				}
+				continue
+			}
+			return errors.Wrap(err, "LND Get node info")
+		}
+		var policies []peerChannelPolicy
+		var lndShortChannelIds []int64
+		for _, edge := range ni.Channels {
+			lndShortChannelIds = append(lndShortChannelIds, int64(edge.ChannelId))
+			routingPolicy := edge.Node1Policy
+			connectingPublicKey := edge.Node2Pub
+			if edge.Node2Pub == peer.PublicKey {
+				routingPolicy = edge.Node2Policy
+				connectingPublicKey = edge.Node1Pub
+			}
+			if routingPolicy == nil {
+				continue
+			}
+			policy, isPeerChannel := toPeerChannelPolicy(now, nodeSettings, peer.NodeId, edge.ChannelId,
+				connectingPublicKey, routingPolicy)
+			if isPeerChannel {
+				policies = append(policies, policy)
+			}
+		}
+		err = storePeerChannelPolicies(db, policies)
+		if err != nil {
+			return errors.Wrapf(err, "Storing peer channel policies for peer node id: %v", peer.NodeId)
+		}
+		// Channels that closed while the node was offline are missing from the node info
+		err = removeMissingPeerChannelPolicies(db, nodeSettings.NodeId, peer.NodeId, lndShortChannelIds)
+		if err != nil {
+			return errors.Wrapf(err, "Removing peer channel policies for peer node id: %v", peer.NodeId)
+		}
+	}
+	return nil
+}
+
+// removeMissingPeerChannelPolicies removes the policies of the channels of the peer that are not in the graph
+func removeMissingPeerChannelPolicies(db *sqlx.DB, nodeId int, peerNodeId int, lndShortChannelIds []int64) error {
+	if lndShortChannelIds == nil {
+		lndShortChannelIds = []int64{}
+	}
+	_, err := db.Exec(`
+		DELETE FROM peer_channel_policy
+		WHERE node_id=$1 AND peer_node_id=$2 AND NOT (lnd_short_channel_id=ANY($3::NUMERIC[]));`,
+		nodeId, peerNodeId, pq.Array(lndShortChannelIds))
+	if err != nil {
+		return errors.Wrap(err, "DB Exec")
+	}
+	return nil
+}
+
+// toPeerChannelPolicy the channels between the peer and the node itself are already stored as routing policies
+func toPeerChannelPolicy(ts time.Time, nodeSettings commons.ManagedNodeSettings, peerNodeId int,
+	lndShortChannelId uint64, connectingPublicKey string, routingPolicy *lnrpc.RoutingPolicy) (peerChannelPolicy, bool) {
+
+	if connectingPublicKey == "" || connectingPublicKey == nodeSettings.PublicKey {
+		return peerChannelPolicy{}, false
+	}
+	return peerChannelPolicy{
+		Ts:                  ts,
+		LndShortChannelId:   lndShortChannelId,
+		PeerNodeId:          peerNodeId,
+		ConnectingPublicKey: connectingPublicKey,
+		Disabled:            routingPolicy.Disabled,
+		TimeLockDelta:       routingPolicy.TimeLockDelta,
+		MinHtlcMsat:         routingPolicy.MinHtlc,
+		MaxHtlcMsat:         routingPolicy.MaxHtlcMsat,
+		FeeBaseMsat:         routingPolicy.FeeBaseMsat,
+		FeeRateMillMsat:     routingPolicy.FeeRateMilliMsat,
+		NodeId:              nodeSettings.NodeId,
+	}, true
+}
+
+// storePeerChannelPolicies a policy is only stored when it differs from the last stored policy of the channel.
+// The policies are stored in a single statement, only the last policy of a channel in the batch is stored.
+func storePeerChannelPolicies(db *sqlx.DB, policies []peerChannelPolicy) error {
+	type channelKey struct {
+		lndShortChannelId uint64
+		peerNodeId        int
+	}
+	lastPolicies := make(map[channelKey]int)
+	for i, policy := range policies {
+		lastPolicies[channelKey{lndShortChannelId: policy.LndShortChannelId, peerNodeId: policy.PeerNodeId}] = i
+	}
+	if len(lastPolicies) == 0 {
+		return nil
+	}
+	var ts, lndShortChannelIds, connectingPublicKeys, maxHtlcMsats []string
+	var peerNodeIds, timeLockDeltas, minHtlcMsats, feeBaseMsats, feeRateMillMsats, nodeIds []int64
+	var disabled []bool
+	for i, policy := range policies {
+		if lastPolicies[channelKey{lndShortChannelId: policy.LndShortChannelId, peerNodeId: policy.PeerNodeId}] != i {
+			continue
+		}
+		ts = append(ts, policy.Ts.Format(time.RFC3339Nano))
+		lndShortChannelIds = append(lndShortChannelIds, strconv.FormatUint(policy.LndShortChannelId, 10))
+		peerNodeIds = append(peerNodeIds, int64(policy.PeerNodeId))
+		connectingPublicKeys = append(connectingPublicKeys, policy.ConnectingPublicKey)
+		disabled = append(disabled, policy.Disabled)
+		timeLockDeltas = append(timeLockDeltas, int64(policy.TimeLockDelta))
+		minHtlcMsats = append(minHtlcMsats, policy.MinHtlcMsat)
+		maxHtlcMsats = append(maxHtlcMsats, strconv.FormatUint(policy.MaxHtlcMsat, 10))
+		feeBaseMsats = append(feeBaseMsats, policy.FeeBaseMsat)
+		feeRateMillMsats = append(feeRateMillMsats, policy.FeeRateMillMsat)
+		nodeIds = append(nodeIds, int64(policy.NodeId))
+	}
+	_, err := db.Exec(`
+		INSERT INTO peer_channel_policy (ts, lnd_short_channel_id, peer_node_id, connecting_public_key,
+			disabled, time_lock_delta, min_htlc_msat, max_htlc_msat, fee_base_msat, fee_rate_mill_msat, node_id)
+		SELECT p.ts, p.lnd_short_channel_id, p.peer_node_id, p.connecting_public_key,
+			p.disabled, p.time_lock_delta, p.min_htlc_msat, p.max_htlc_msat, p.fee_base_msat, p.fee_rate_mill_msat,
+			p.node_id
+		FROM unnest($1::TIMESTAMPTZ[], $2::NUMERIC[], $3::INTEGER[], $4::TEXT[], $5::BOOLEAN[], $6::INTEGER[],
+			$7::BIGINT[], $8::NUMERIC[], $9::BIGINT[], $10::BIGINT[], $11::INTEGER[])
+			AS p(ts, lnd_short_channel_id, peer_node_id, connecting_public_key, disabled, time_lock_delta,
+				min_htlc_msat, max_htlc_msat, fee_base_msat, fee_rate_mill_msat, node_id)
+		WHERE NOT EXISTS (
+			SELECT 1
+			FROM (
+				SELECT disabled, time_lock_delta, min_htlc_msat, max_htlc_msat, fee_base_msat, fee_rate_mill_msat
+				FROM peer_channel_policy
+				WHERE node_id=p.node_id AND lnd_short_channel_id=p.lnd_short_channel_id AND peer_node_id=p.peer_node_id
+				ORDER BY ts DESC
+				LIMIT 1
+			) last
+			WHERE last.disabled=p.disabled AND last.time_lock_delta=p.time_lock_delta AND
+				last.min_htlc_msat=p.min_htlc_msat AND last.max_htlc_msat=p.max_htlc_msat AND
+				last.fee_base_msat=p.fee_base_msat AND last.fee_rate_mill_msat=p.fee_rate_mill_msat
+		);`,
+		pq.Array(ts), pq.Array(lndShortChannelIds), pq.Array(peerNodeIds), pq.Array(connectingPublicKeys),
+		pq.Array(disabled), pq.Array(timeLockDeltas), pq.Array(minHtlcMsats), pq.Array(maxHtlcMsats),
+		pq.Array(feeBaseMsats), pq.Array(feeRateMillMsats), pq.Array(nodeIds))
+	if err != nil {
+		return errors.Wrap(err, "DB Exec")
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
@@ -15,9 +16,13 @@ import (
 type peerEventsClient interface {
 	SubscribePeerEvents(ctx context.Context, in *lnrpc.PeerEventSubscription,
 		opts ...grpc.CallOption) (lnrpc.Lightning_SubscribePeerEventsClient, error)
+	ListPeers(ctx context.Context, in *lnrpc.ListPeersRequest,
+		opts ...grpc.CallOption) (*lnrpc.ListPeersResponse, error)
 }
 
-func SubscribePeerEvents(ctx context.Context, client peerEventsClient,
+// SubscribePeerEvents stores the online and offline transitions of the channel peers and sends the events of the known
+// nodes to the event channel
+func SubscribePeerEvents(ctx context.Context, client peerEventsClient, db *sqlx.DB,
 	nodeSettings commons.ManagedNodeSettings, eventChannel chan interface{}) {
 
 	var stream lnrpc.Lightning_SubscribePeerEventsClient
@@ -45,6 +50,11 @@ func SubscribePeerEvents(ctx context.Context, client peerEventsClient,
 				time.Sleep(commons.STREAM_ERROR_SLEEP_SECONDS * time.Second)
 				continue
 			}
+			// Transitions are missed while the stream is down
+			err = syncPeerStates(ctx, client, db, nodeSettings)
+			if err != nil {
+				log.Error().Err(err).Msgf("Synchronizing the peer states failed for nodeId: %v", nodeSettings.NodeId)
+			}
 			serviceStatus = SendStreamEvent(eventChannel, nodeSettings.NodeId, subscriptionStream, commons.Active, serviceStatus)
 		}
 
@@ -60,18 +70,111 @@ func SubscribePeerEvents(ctx context.Context, client peerEventsClient,
 			continue
 		}
 
+		eventTime := time.Now().UTC()
+		// Peers without a channel are not added as node, their events aren't sent either
+		eventNodeId := commons.GetNodeIdByPublicKey(peerEvent.PubKey, nodeSettings.Chain, nodeSettings.Network)
+		if eventNodeId == 0 {
+			continue
+		}
+		if getPeerNodeIds(nodeSettings.NodeId)[eventNodeId] {
+			err = storePeerEvent(db, eventTime, nodeSettings.NodeId, eventNodeId, peerEvent.Type)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to store peer event for nodeId: %v", nodeSettings.NodeId)
+			}
+		}
+
 		if eventChannel != nil {
-			eventNodeId := commons.GetNodeIdByPublicKey(peerEvent.PubKey, nodeSettings.Chain, nodeSettings.Network)
-			if eventNodeId != 0 {
-				eventChannel <- commons.PeerEvent{
-					EventData: commons.EventData{
-						EventTime: time.Now().UTC(),
-						NodeId:    nodeSettings.NodeId,
-					},
-					Type:        peerEvent.Type,
-					EventNodeId: eventNodeId,
-				}
+			eventChannel <- commons.PeerEvent{
+				EventData: commons.EventData{
+					EventTime: eventTime,
+					NodeId:    nodeSettings.NodeId,
+				},
+				Type:        peerEvent.Type,
+				EventNodeId: eventNodeId,
+			}
+		}
+	}
+}
+
+// syncPeerStates stores a transition for the channel peers of which the last stored state differs from the connected
+// peers
+func syncPeerStates(ctx context.Context, client peerEventsClient, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings) error {
+
+	listPeersResponse, err := client.ListPeers(ctx, &lnrpc.ListPeersRequest{})
+	if err != nil {
+		return errors.Wrap(err, "Listing peers")
+	}
+	lastEventTypes, err := getLastPeerEventTypes(db, nodeSettings.NodeId)
+	if err != nil {
+		return errors.Wrap(err, "Obtaining the last peer events")
+	}
+	now := time.Now().UTC()
+	channelPeers := getPeerNodeIds(nodeSettings.NodeId)
+	connected := make(map[int]bool)
+	for _, peer := range listPeersResponse.Peers {
+		eventNodeId := commons.GetNodeIdByPublicKey(peer.PubKey, nodeSettings.Chain, nodeSettings.Network)
+		if !channelPeers[eventNodeId] {
+			continue
+		}
+		connected[eventNodeId] = true
+		if lastEventTypes[eventNodeId] != lnrpc.PeerEvent_PEER_ONLINE.String() {
+			err = storePeerEvent(db, now, nodeSettings.NodeId, eventNodeId, lnrpc.PeerEvent_PEER_ONLINE)
+			if err != nil {
+				return err
 			}
 		}
 	}
+	for eventNodeId, eventType := range lastEventTypes {
+		if !connected[eventNodeId] && eventType == lnrpc.PeerEvent_PEER_ONLINE.String() {
+			err = storePeerEvent(db, now, nodeSettings.NodeId, eventNodeId, lnrpc.PeerEvent_PEER_OFFLINE)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getPeerNodeIds the remote nodes of the open channels of the node
+func getPeerNodeIds(nodeId int) map[int]bool {
+	peerNodeIds := make(map[int]bool)
+	for _, channelState := range commons.GetChannelStates(nodeId, true) {
+		peerNodeIds[channelState.RemoteNodeId] = true
+	}
+	return peerNodeIds
+}
+
+func storePeerEvent(db *sqlx.DB, eventTime time.Time, nodeId int, eventNodeId int,
+	eventType lnrpc.PeerEvent_EventType) error {
+
+	_, err := db.Exec(`INSERT INTO peer_event (time, node_id, event_node_id, event_type) VALUES ($1, $2, $3, $4);`,
+		eventTime, nodeId, eventNodeId, eventType.String())
+	if err != nil {
+		return errors.Wrap(err, "DB Exec")
+	}
+	return nil
+}
+
+// getLastPeerEventTypes the type of the last stored event per peer
+func getLastPeerEventTypes(db *sqlx.DB, nodeId int) (map[int]string, error) {
+	rows, err := db.Queryx(`
+		SELECT DISTINCT ON (event_node_id) event_node_id, event_type
+		FROM peer_event
+		WHERE node_id = $1
+		ORDER BY event_node_id, time DESC;`, nodeId)
+	if err != nil {
+		return nil, errors.Wrap(err, "DB Query")
+	}
+	defer rows.Close()
+	result := make(map[int]string)
+	for rows.Next() {
+		var eventNodeId int
+		var eventType string
+		if err = rows.Scan(&eventNodeId, &eventType); err != nil {
+			return nil, errors.Wrap(err, "SQL row scan for peer event")
+		}
+		result[eventNodeId] = eventType
+	}
+	return result, nil
 }
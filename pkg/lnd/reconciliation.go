@@ -0,0 +1,519 @@
+package lnd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"github.com/lncapital/torq/pkg/commons"
+)
+
+type ReconciliationType string
+
+const (
+	ReconcileForwards = ReconciliationType("FORWARDS")
+	ReconcilePayments = ReconciliationType("PAYMENTS")
+	ReconcileInvoices = ReconciliationType("INVOICES")
+)
+
+const (
+	reconciliationPaymentsPageSize = 50
+	reconciliationInvoicesPageSize = 1000
+)
+
+type lightningClientReconciliation interface {
+	lightningClientForwardingHistory
+	lightningClient_ListPayments
+	ListInvoices(ctx context.Context, in *lnrpc.ListInvoiceRequest,
+		opts ...grpc.CallOption) (*lnrpc.ListInvoiceResponse, error)
+}
+
+// dailyTotal the number of events of a (UTC) day and the sum of their amounts.
+// Forwards use the outgoing amount, payments and invoices their value.
+type dailyTotal struct {
+	Day        time.Time `db:"day"`
+	Count      int64     `db:"count"`
+	AmountMsat int64     `db:"amount_msat"`
+	// Duplicates the number of stored rows that share their index with another row
+	Duplicates int64 `db:"duplicates"`
+}
+
+// ReconciliationDiscrepancy a day where the stored events didn't match the events of LND
+type ReconciliationDiscrepancy struct {
+	Type           ReconciliationType `json:"type"`
+	Day            time.Time          `json:"day"`
+	LndCount       int64              `json:"lndCount"`
+	LndAmountMsat  int64              `json:"lndAmountMsat"`
+	TorqCount      int64              `json:"torqCount"`
+	TorqAmountMsat int64              `json:"torqAmountMsat"`
+	DuplicateCount int64              `json:"duplicateCount"`
+	// Backfilled the number of events of the day that were missing and are now stored
+	Backfilled int64 `json:"backfilled"`
+	// Fixed the counts and amounts match after the backfill (duplicates are reported but not removed)
+	Fixed bool `json:"fixed"`
+}
+
+type ReconciliationReport struct {
+	NodeId        int                         `json:"nodeId"`
+	From          time.Time                   `json:"from"`
+	Till          time.Time                   `json:"till"`
+	StartedOn     time.Time                   `json:"startedOn"`
+	CompletedOn   *time.Time                  `json:"completedOn"`
+	Discrepancies []ReconciliationDiscrepancy `json:"discrepancies"`
+	Error         *string                     `json:"error"`
+	// ReconciledTill the till of the last reconciliation without error
+	ReconciledTill *time.Time `json:"reconciledTill"`
+}
+
+// GetReconciliationReports returns the latest reconciliation report of every node
+func GetReconciliationReports(db *sqlx.DB) ([]ReconciliationReport, error) {
+	rows, err := db.Query(`
+		SELECT node_id, from_time, till_time, started_on, completed_on, discrepancies, error, reconciled_till
+		FROM reconciliation_report
+		ORDER BY node_id;`)
+	if err != nil {
+		return nil, errors.Wrap(err, "DB Query")
+	}
+	defer rows.Close()
+	reports := []ReconciliationReport{}
+	for rows.Next() {
+		var report ReconciliationReport
+		var discrepancies []byte
+		err = rows.Scan(&report.NodeId, &report.From, &report.Till, &report.StartedOn, &report.CompletedOn,
+			&discrepancies, &report.Error, &report.ReconciledTill)
+		if err != nil {
+			return nil, errors.Wrap(err, "SQL row scan for reconciliation report")
+		}
+		err = json.Unmarshal(discrepancies, &report.Discrepancies)
+		if err != nil {
+			return nil, errors.Wrap(err, "JSON Unmarshal reconciliation discrepancies")
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func getReconciledTill(db *sqlx.DB, nodeId int) (*time.Time, error) {
+	var reconciledTill *time.Time
+	err := db.Get(&reconciledTill, `SELECT reconciled_till FROM reconciliation_report WHERE node_id=$1;`, nodeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "DB Get reconciled till")
+	}
+	return reconciledTill, nil
+}
+
+// storeReconciliationReport replaces the report of the node so the latest report survives a restart
+func storeReconciliationReport(db *sqlx.DB, report ReconciliationReport) error {
+	discrepancies, err := json.Marshal(report.Discrepancies)
+	if err != nil {
+		return errors.Wrap(err, "JSON Marshal reconciliation discrepancies")
+	}
+	_, err = db.Exec(`
+		INSERT INTO reconciliation_report (node_id, from_time, till_time, started_on, completed_on, discrepancies, error,
+			reconciled_till)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (node_id) DO UPDATE SET from_time=EXCLUDED.from_time, till_time=EXCLUDED.till_time,
+			started_on=EXCLUDED.started_on, completed_on=EXCLUDED.completed_on, discrepancies=EXCLUDED.discrepancies,
+			error=EXCLUDED.error, reconciled_till=EXCLUDED.reconciled_till;`,
+		report.NodeId, report.From, report.Till, report.StartedOn, report.CompletedOn, discrepancies, report.Error,
+		report.ReconciledTill)
+	if err != nil {
+		return errors.Wrap(err, "DB Exec")
+	}
+	return nil
+}
+
+// reconciliationFrom the start of the last RECONCILIATION_DAYS or the day of the last reconciliation without error
+// when that is longer ago, so an outage longer than RECONCILIATION_DAYS is reconciled as well
+func reconciliationFrom(till time.Time, reconciledTill *time.Time) time.Time {
+	from := till.Truncate(24*time.Hour).AddDate(0, 0, -(commons.RECONCILIATION_DAYS - 1))
+	if reconciledTill != nil && reconciledTill.Before(from) {
+		return reconciledTill.UTC().Truncate(24 * time.Hour)
+	}
+	return from
+}
+
+// ReconcileStoredEvents periodically compares the forwards, payments and invoices of the last days per day with the
+// stored events and backfills the days where events are missing (i.e. Torq was down or the node was restored).
+// When the last reconciliation without error is longer ago (i.e. Torq was down for longer) it starts from that day.
+func ReconcileStoredEvents(ctx context.Context, client lightningClientReconciliation, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings) {
+
+	// Give the streams the time to import the events first
+	delay := time.NewTimer(commons.RECONCILIATION_BOOTSTRAP_DELAY_SECONDS * time.Second)
+	defer delay.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-delay.C:
+	}
+
+	ticker := time.NewTicker(commons.RECONCILIATION_TICKER_SECONDS * time.Second)
+	defer ticker.Stop()
+	for {
+		till := time.Now().UTC().Add(-commons.RECONCILIATION_SETTLE_SECONDS * time.Second)
+		reconciledTill, err := getReconciledTill(db, nodeSettings.NodeId)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to obtain the last reconciliation for nodeId: %v", nodeSettings.NodeId)
+		}
+		report := reconcile(ctx, client, db, nodeSettings, reconciliationFrom(till, reconciledTill), till)
+		if ctx.Err() != nil {
+			return
+		}
+		report.ReconciledTill = reconciledTill
+		if report.Error == nil {
+			report.ReconciledTill = &till
+		}
+		err = storeReconciliationReport(db, report)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to store the reconciliation report for nodeId: %v", nodeSettings.NodeId)
+		}
+		if report.Error != nil {
+			log.Error().Msgf("Reconciliation failed for nodeId: %v (%v)", nodeSettings.NodeId, *report.Error)
+		} else if len(report.Discrepancies) != 0 {
+			log.Info().Msgf("Reconciliation found %v discrepancies for nodeId: %v",
+				len(report.Discrepancies), nodeSettings.NodeId)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func reconcile(ctx context.Context, client lightningClientReconciliation, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, from time.Time, till time.Time) ReconciliationReport {
+
+	report := ReconciliationReport{
+		NodeId:        nodeSettings.NodeId,
+		From:          from,
+		Till:          till,
+		StartedOn:     time.Now().UTC(),
+		Discrepancies: []ReconciliationDiscrepancy{},
+	}
+	reconcilers := []func(context.Context, lightningClientReconciliation, *sqlx.DB, commons.ManagedNodeSettings,
+		time.Time, time.Time) ([]ReconciliationDiscrepancy, error){
+		reconcileForwards,
+		reconcilePayments,
+		reconcileInvoices,
+	}
+	for _, reconciler := range reconcilers {
+		discrepancies, err := reconciler(ctx, client, db, nodeSettings, from, till)
+		if err != nil {
+			errorMessage := err.Error()
+			report.Error = &errorMessage
+			break
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+	}
+	completedOn := time.Now().UTC()
+	report.CompletedOn = &completedOn
+	return report
+}
+
+func reconcileForwards(ctx context.Context, client lightningClientReconciliation, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, from time.Time, till time.Time) ([]ReconciliationDiscrepancy, error) {
+
+	eventsByDay := make(map[time.Time][]*lnrpc.ForwardingEvent)
+	lndTotals := make(map[time.Time]dailyTotal)
+	var offset uint32
+	for {
+		fwh, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+			StartTime:    uint64(from.Unix()),
+			EndTime:      uint64(till.Unix()) + 1,
+			IndexOffset:  offset,
+			NumMaxEvents: commons.STREAM_LND_MAX_FORWARDS,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Obtaining the forwarding history")
+		}
+		for _, event := range fwh.ForwardingEvents {
+			timestamp := time.Unix(0, int64(event.TimestampNs)).UTC()
+			if timestamp.Before(from) || !timestamp.Before(till) {
+				continue
+			}
+			day := timestamp.Truncate(24 * time.Hour)
+			eventsByDay[day] = append(eventsByDay[day], event)
+			lndTotals[day] = addToDailyTotal(lndTotals[day], day, int64(event.AmtOutMsat))
+		}
+		if len(fwh.ForwardingEvents) < commons.STREAM_LND_MAX_FORWARDS {
+			break
+		}
+		offset = fwh.LastOffsetIndex
+	}
+
+	storedTotals := func() (map[time.Time]dailyTotal, error) {
+		return getStoredDailyTotals(db, `
+			SELECT date_trunc('day', time AT TIME ZONE 'UTC') AS day, COUNT(*) AS count,
+				COALESCE(SUM(outgoing_amount_msat), 0) AS amount_msat, 0 AS duplicates
+			FROM forward
+			WHERE node_id=$1 AND time>=$2 AND time<$3
+			GROUP BY 1;`, nodeSettings.NodeId, from, till)
+	}
+	// Forwards that are stored already are skipped
+	backfill := func(day time.Time) error {
+		return storeForwardingHistory(db, eventsByDay[day], nodeSettings.NodeId, nil, true)
+	}
+	return reconcileDays(ReconcileForwards, lndTotals, storedTotals, backfill)
+}
+
+func reconcilePayments(ctx context.Context, client lightningClientReconciliation, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, from time.Time, till time.Time) ([]ReconciliationDiscrepancy, error) {
+
+	paymentsByDay := make(map[time.Time][]*lnrpc.Payment)
+	lndTotals := make(map[time.Time]dailyTotal)
+	includeIncomplete := commons.RunningServices[commons.LndService].GetIncludeIncomplete(nodeSettings.NodeId)
+	// Payments can't be filtered by date so they are requested from the most recent one backwards
+	var offset uint64
+	for {
+		payments, err := client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{
+			IncludeIncomplete: includeIncomplete,
+			IndexOffset:       offset,
+			MaxPayments:       reconciliationPaymentsPageSize,
+			Reversed:          true,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Obtaining the payments")
+		}
+		reachedFrom := false
+		for _, payment := range payments.Payments {
+			timestamp := time.Unix(0, payment.CreationTimeNs).UTC()
+			if timestamp.Before(from) {
+				reachedFrom = true
+				continue
+			}
+			if !timestamp.Before(till) {
+				continue
+			}
+			day := timestamp.Truncate(24 * time.Hour)
+			paymentsByDay[day] = append(paymentsByDay[day], payment)
+			lndTotals[day] = addToDailyTotal(lndTotals[day], day, payment.ValueMsat)
+		}
+		if reachedFrom || len(payments.Payments) == 0 || payments.FirstIndexOffset <= 1 {
+			break
+		}
+		offset = payments.FirstIndexOffset
+	}
+
+	storedTotals := func() (map[time.Time]dailyTotal, error) {
+		return getStoredDailyTotals(db, `
+			SELECT day, COUNT(*) AS count, COALESCE(SUM(value_msat), 0) AS amount_msat,
+				COALESCE(SUM(copies - 1), 0) AS duplicates
+			FROM (
+				SELECT MIN(date_trunc('day', creation_timestamp AT TIME ZONE 'UTC')) AS day,
+					MIN(value_msat) AS value_msat, COUNT(*) AS copies
+				FROM payment
+				WHERE node_id=$1 AND creation_timestamp>=$2 AND creation_timestamp<$3
+				GROUP BY payment_index
+			) payments
+			GROUP BY day;`, nodeSettings.NodeId, from, till)
+	}
+	backfill := func(day time.Time) error {
+		var indexes []int64
+		for _, payment := range paymentsByDay[day] {
+			indexes = append(indexes, int64(payment.PaymentIndex))
+		}
+		stored, err := getStoredIndexes(db, `
+			SELECT DISTINCT payment_index FROM payment WHERE node_id=$1 AND payment_index=ANY($2);`,
+			nodeSettings.NodeId, indexes)
+		if err != nil {
+			return err
+		}
+		var missing []*lnrpc.Payment
+		for _, payment := range paymentsByDay[day] {
+			if !stored[payment.PaymentIndex] {
+				missing = append(missing, payment)
+			}
+		}
+		return storePayments(db, missing, nodeSettings, nil, true)
+	}
+	return reconcileDays(ReconcilePayments, lndTotals, storedTotals, backfill)
+}
+
+func reconcileInvoices(ctx context.Context, client lightningClientReconciliation, db *sqlx.DB,
+	nodeSettings commons.ManagedNodeSettings, from time.Time, till time.Time) ([]ReconciliationDiscrepancy, error) {
+
+	invoicesByDay := make(map[time.Time][]*lnrpc.Invoice)
+	lndTotals := make(map[time.Time]dailyTotal)
+	// Invoices can't be filtered by date so they are requested from the most recent one backwards
+	var offset uint64
+	for {
+		invoices, err := client.ListInvoices(ctx, &lnrpc.ListInvoiceRequest{
+			IndexOffset:    offset,
+			NumMaxInvoices: reconciliationInvoicesPageSize,
+			Reversed:       true,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Obtaining the invoices")
+		}
+		reachedFrom := false
+		for _, invoice := range invoices.Invoices {
+			timestamp := time.Unix(invoice.CreationDate, 0).UTC()
+			if timestamp.Before(from) {
+				reachedFrom = true
+				continue
+			}
+			if !timestamp.Before(till) {
+				continue
+			}
+			day := timestamp.Truncate(24 * time.Hour)
+			invoicesByDay[day] = append(invoicesByDay[day], invoice)
+			lndTotals[day] = addToDailyTotal(lndTotals[day], day, invoice.ValueMsat)
+		}
+		if reachedFrom || len(invoices.Invoices) == 0 || invoices.FirstIndexOffset <= 1 {
+			break
+		}
+		offset = invoices.FirstIndexOffset
+	}
+
+	// Every update of an invoice is stored as a new row so they are counted by add index
+	storedTotals := func() (map[time.Time]dailyTotal, error) {
+		return getStoredDailyTotals(db, `
+			SELECT day, COUNT(*) AS count, COALESCE(SUM(value_msat), 0) AS amount_msat, 0 AS duplicates
+			FROM (
+				SELECT MIN(date_trunc('day', creation_date AT TIME ZONE 'UTC')) AS day, MIN(value_msat) AS value_msat
+				FROM invoice
+				WHERE node_id=$1 AND creation_date>=$2 AND creation_date<$3
+				GROUP BY add_index
+			) invoices
+			GROUP BY day;`, nodeSettings.NodeId, from, till)
+	}
+	backfill := func(day time.Time) error {
+		var indexes []int64
+		for _, invoice := range invoicesByDay[day] {
+			indexes = append(indexes, int64(invoice.AddIndex))
+		}
+		stored, err := getStoredIndexes(db, `
+			SELECT DISTINCT add_index FROM invoice WHERE node_id=$1 AND add_index=ANY($2);`,
+			nodeSettings.NodeId, indexes)
+		if err != nil {
+			return err
+		}
+		for _, invoice := range invoicesByDay[day] {
+			if !stored[invoice.AddIndex] {
+				processInvoice(invoice, nodeSettings, db, nil, true)
+			}
+		}
+		return nil
+	}
+	return reconcileDays(ReconcileInvoices, lndTotals, storedTotals, backfill)
+}
+
+// reconcileDays backfills the days where the stored totals don't match the totals of LND and reports them
+func reconcileDays(reconciliationType ReconciliationType, lndTotals map[time.Time]dailyTotal,
+	storedTotals func() (map[time.Time]dailyTotal, error),
+	backfill func(day time.Time) error) ([]ReconciliationDiscrepancy, error) {
+
+	torqTotals, err := storedTotals()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Obtaining the stored %v", reconciliationType)
+	}
+	discrepancies := compareDailyTotals(reconciliationType, lndTotals, torqTotals)
+	backfilled := false
+	for _, discrepancy := range discrepancies {
+		if discrepancy.TorqCount >= discrepancy.LndCount {
+			continue
+		}
+		if err = backfill(discrepancy.Day); err != nil {
+			return nil, errors.Wrapf(err, "Backfilling the %v of %v", reconciliationType,
+				discrepancy.Day.Format("2006-01-02"))
+		}
+		backfilled = true
+	}
+	if backfilled {
+		torqTotals, err = storedTotals()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Obtaining the stored %v", reconciliationType)
+		}
+		for i, discrepancy := range discrepancies {
+			stored := torqTotals[discrepancy.Day]
+			if stored.Count > discrepancy.TorqCount {
+				discrepancies[i].Backfilled = stored.Count - discrepancy.TorqCount
+			}
+			discrepancies[i].Fixed = stored.Count == discrepancy.LndCount &&
+				stored.AmountMsat == discrepancy.LndAmountMsat
+		}
+	}
+	return discrepancies, nil
+}
+
+// compareDailyTotals returns the days (sorted) where the totals differ or where duplicates are stored
+func compareDailyTotals(reconciliationType ReconciliationType, lndTotals map[time.Time]dailyTotal,
+	torqTotals map[time.Time]dailyTotal) []ReconciliationDiscrepancy {
+
+	days := make(map[time.Time]bool)
+	for day := range lndTotals {
+		days[day] = true
+	}
+	for day := range torqTotals {
+		days[day] = true
+	}
+	discrepancies := []ReconciliationDiscrepancy{}
+	for day := range days {
+		lnd := lndTotals[day]
+		torq := torqTotals[day]
+		if lnd.Count == torq.Count && lnd.AmountMsat == torq.AmountMsat && torq.Duplicates == 0 {
+			continue
+		}
+		discrepancies = append(discrepancies, ReconciliationDiscrepancy{
+			Type:           reconciliationType,
+			Day:            day,
+			LndCount:       lnd.Count,
+			LndAmountMsat:  lnd.AmountMsat,
+			TorqCount:      torq.Count,
+			TorqAmountMsat: torq.AmountMsat,
+			DuplicateCount: torq.Duplicates,
+		})
+	}
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].Day.Before(discrepancies[j].Day) })
+	return discrepancies
+}
+
+func addToDailyTotal(total dailyTotal, day time.Time, amountMsat int64) dailyTotal {
+	total.Day = day
+	total.Count++
+	total.AmountMsat += amountMsat
+	return total
+}
+
+func getStoredDailyTotals(db *sqlx.DB, query string, nodeId int, from time.Time,
+	till time.Time) (map[time.Time]dailyTotal, error) {
+
+	var totals []dailyTotal
+	if err := db.Select(&totals, query, nodeId, from, till); err != nil {
+		return nil, errors.Wrap(err, "Obtaining the stored daily totals")
+	}
+	result := make(map[time.Time]dailyTotal, len(totals))
+	for _, total := range totals {
+		// date_trunc of a timestamp without time zone is scanned without location
+		total.Day = time.Date(total.Day.Year(), total.Day.Month(), total.Day.Day(), 0, 0, 0, 0, time.UTC)
+		result[total.Day] = total
+	}
+	return result, nil
+}
+
+// getStoredIndexes returns the indexes that are stored
+func getStoredIndexes(db *sqlx.DB, query string, nodeId int, indexes []int64) (map[uint64]bool, error) {
+	var storedIndexes []int64
+	if err := db.Select(&storedIndexes, query, nodeId, pq.Array(indexes)); err != nil {
+		return nil, errors.Wrap(err, "Obtaining the stored indexes")
+	}
+	stored := make(map[uint64]bool, len(storedIndexes))
+	for _, storedIndex := range storedIndexes {
+		stored[uint64(storedIndex)] = true
+	}
+	return stored, nil
+}
@@ -0,0 +1,92 @@
+package lnd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareDailyTotals(t *testing.T) {
+	day1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	day3 := day1.AddDate(0, 0, 2)
+	lndTotals := map[time.Time]dailyTotal{
+		day1: {Day: day1, Count: 2, AmountMsat: 3000},
+		day2: {Day: day2, Count: 1, AmountMsat: 1000},
+		day3: {Day: day3, Count: 1, AmountMsat: 500},
+	}
+	torqTotals := map[time.Time]dailyTotal{
+		day1: {Day: day1, Count: 2, AmountMsat: 3000},
+		day2: {Day: day2, Count: 1, AmountMsat: 1000, Duplicates: 1},
+	}
+	discrepancies := compareDailyTotals(ReconcilePayments, lndTotals, torqTotals)
+	if len(discrepancies) != 2 {
+		t.Fatalf("expected 2 discrepancies, got %v", discrepancies)
+	}
+	if !discrepancies[0].Day.Equal(day2) || discrepancies[0].DuplicateCount != 1 {
+		t.Errorf("expected the duplicates of day 2 first, got %+v", discrepancies[0])
+	}
+	if !discrepancies[1].Day.Equal(day3) || discrepancies[1].LndCount != 1 || discrepancies[1].TorqCount != 0 {
+		t.Errorf("expected the missing event of day 3, got %+v", discrepancies[1])
+	}
+}
+
+func TestReconcileDays(t *testing.T) {
+	day1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	lndTotals := map[time.Time]dailyTotal{
+		day1: {Day: day1, Count: 3, AmountMsat: 3000},
+		day2: {Day: day2, Count: 1, AmountMsat: 1000},
+	}
+	stored := map[time.Time]dailyTotal{
+		day1: {Day: day1, Count: 1, AmountMsat: 1000},
+		// more rows are stored than LND knows about, they are reported but not backfilled
+		day2: {Day: day2, Count: 2, AmountMsat: 2000},
+	}
+	var backfilledDays []time.Time
+	storedTotals := func() (map[time.Time]dailyTotal, error) {
+		result := make(map[time.Time]dailyTotal, len(stored))
+		for day, total := range stored {
+			result[day] = total
+		}
+		return result, nil
+	}
+	backfill := func(day time.Time) error {
+		backfilledDays = append(backfilledDays, day)
+		stored[day] = lndTotals[day]
+		return nil
+	}
+
+	discrepancies, err := reconcileDays(ReconcileForwards, lndTotals, storedTotals, backfill)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(backfilledDays) != 1 || !backfilledDays[0].Equal(day1) {
+		t.Fatalf("expected only day 1 to be backfilled, got %v", backfilledDays)
+	}
+	if len(discrepancies) != 2 {
+		t.Fatalf("expected 2 discrepancies, got %v", discrepancies)
+	}
+	if discrepancies[0].Backfilled != 2 || !discrepancies[0].Fixed {
+		t.Errorf("expected 2 backfilled forwards that fixed day 1, got %+v", discrepancies[0])
+	}
+	if discrepancies[1].Backfilled != 0 || discrepancies[1].Fixed {
+		t.Errorf("expected day 2 to be reported only, got %+v", discrepancies[1])
+	}
+}
+
+func TestReconciliationFrom(t *testing.T) {
+	till := time.Date(2023, 1, 20, 12, 0, 0, 0, time.UTC)
+	window := time.Date(2023, 1, 14, 0, 0, 0, 0, time.UTC)
+	if from := reconciliationFrom(till, nil); !from.Equal(window) {
+		t.Errorf("expected the window without a previous reconciliation, got %v", from)
+	}
+	recent := time.Date(2023, 1, 20, 6, 0, 0, 0, time.UTC)
+	if from := reconciliationFrom(till, &recent); !from.Equal(window) {
+		t.Errorf("expected the window after a recent reconciliation, got %v", from)
+	}
+	// Torq was down for longer than the window
+	old := time.Date(2023, 1, 2, 6, 0, 0, 0, time.UTC)
+	if from := reconciliationFrom(till, &old); !from.Equal(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the day of the last reconciliation, got %v", from)
+	}
+}
@@ -195,28 +195,40 @@ func storeTransaction(db *sqlx.DB, tx *lnrpc.Transaction, nodeId int) (Tx, error
 		NodeId:                nodeId,
 	}
 
+	err := StoreTx(db, storedTx)
+	if err != nil {
+		return Tx{}, err
+	}
+
+	return storedTx, nil
+}
+
+// StoreTx stores an on-chain transaction, it's used by every node implementation.
+func StoreTx(db *sqlx.DB, tx Tx) error {
 	var insertTx = `INSERT INTO tx (timestamp, tx_hash, amount, num_confirmations, block_hash, block_height,
                 total_fees, dest_addresses, raw_tx_hex, label, node_id)
 				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
                 ON CONFLICT (timestamp, tx_hash) DO NOTHING;`
 
+	var destinationAddresses []string
+	if tx.DestinationAddresses != nil {
+		destinationAddresses = *tx.DestinationAddresses
+	}
 	_, err := db.Exec(insertTx,
-		time.Unix(tx.TimeStamp, 0).UTC(),
-		tx.TxHash,
+		tx.Timestamp,
+		tx.TransactionHash,
 		tx.Amount,
-		tx.NumConfirmations,
+		tx.NumberOfConfirmations,
 		tx.BlockHash,
 		tx.BlockHeight,
 		tx.TotalFees,
 		pq.Array(destinationAddresses),
-		tx.RawTxHex,
+		tx.RawTransactionHex,
 		tx.Label,
-		nodeId,
+		tx.NodeId,
 	)
-
 	if err != nil {
-		return Tx{}, errors.Wrapf(err, `inserting transaction`)
+		return errors.Wrapf(err, `inserting transaction`)
 	}
-
-	return storedTx, nil
+	return nil
 }
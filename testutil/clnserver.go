@@ -0,0 +1,122 @@
+package testutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// ClnHandler produces the result (or an error) for a single Core Lightning RPC call
+type ClnHandler func(params json.RawMessage) (interface{}, *ClnError)
+
+type ClnError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// FakeClnServer is a minimal Core Lightning JSON-RPC server listening on a unix socket.
+type FakeClnServer struct {
+	Address  string
+	listener net.Listener
+	mu       sync.Mutex
+	handlers map[string]ClnHandler
+	calls    map[string][]json.RawMessage
+}
+
+type clnRequest struct {
+	Id     uint64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type clnResponse struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      uint64      `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *ClnError   `json:"error,omitempty"`
+}
+
+func NewFakeClnServer(t *testing.T) *FakeClnServer {
+	dir, err := os.MkdirTemp("", "cln")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(dir, "lightning-rpc")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &FakeClnServer{
+		Address:  "unix://" + socketPath,
+		listener: listener,
+		handlers: make(map[string]ClnHandler),
+		calls:    make(map[string][]json.RawMessage),
+	}
+	go server.serve()
+	t.Cleanup(func() {
+		server.listener.Close()
+		os.RemoveAll(dir)
+	})
+	return server
+}
+
+// Handle registers a handler for an RPC method
+func (s *FakeClnServer) Handle(method string, handler ClnHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// HandleResult registers a static result for an RPC method
+func (s *FakeClnServer) HandleResult(method string, result interface{}) {
+	s.Handle(method, func(params json.RawMessage) (interface{}, *ClnError) {
+		return result, nil
+	})
+}
+
+// Calls returns the parameters of every call made to an RPC method
+func (s *FakeClnServer) Calls(method string) []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *FakeClnServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConnection(conn)
+	}
+}
+
+func (s *FakeClnServer) serveConnection(conn net.Conn) {
+	defer conn.Close()
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	encoder := json.NewEncoder(conn)
+	for {
+		var request clnRequest
+		if err := decoder.Decode(&request); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.calls[request.Method] = append(s.calls[request.Method], request.Params)
+		handler, exists := s.handlers[request.Method]
+		s.mu.Unlock()
+
+		response := clnResponse{JsonRpc: "2.0", Id: request.Id}
+		if exists {
+			response.Result, response.Error = handler(request.Params)
+		} else {
+			response.Error = &ClnError{Code: -32601, Message: "Unknown command '" + request.Method + "'"}
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,592 @@
+package testutil
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const lndBufferSize = 1024 * 1024
+
+// FakeLndServer serves the lightning, router and chain notifier services in memory (over a bufconn listener).
+// The data returned by the list calls is set with the Set and Add functions, the streams are fed with the Send
+// functions. Methods that are not implemented return codes.Unimplemented like an LND without the subserver.
+type FakeLndServer struct {
+	lnrpc.UnimplementedLightningServer
+
+	listener *bufconn.Listener
+	server   *grpc.Server
+
+	mu              sync.Mutex
+	info            *lnrpc.GetInfoResponse
+	channels        []*lnrpc.Channel
+	closedChannels  []*lnrpc.ChannelCloseSummary
+	pendingChannels *lnrpc.PendingChannelsResponse
+	peers           []*lnrpc.Peer
+	forwards        []*lnrpc.ForwardingEvent
+	invoices        []*lnrpc.Invoice
+	payments        []*lnrpc.Payment
+	transactions    []*lnrpc.Transaction
+	graph           *lnrpc.ChannelGraph
+	calls           map[string][]interface{}
+
+	channelEvents *lndStream
+	invoiceEvents *lndStream
+	peerEvents    *lndStream
+	graphEvents   *lndStream
+	txEvents      *lndStream
+	htlcEvents    *lndStream
+	blockEvents   *lndStream
+}
+
+// fakeRouterServer is separate because the lightning and router services have methods with the same name
+type fakeRouterServer struct {
+	routerrpc.UnimplementedRouterServer
+	lnd *FakeLndServer
+}
+
+type fakeChainNotifierServer struct {
+	chainrpc.UnimplementedChainNotifierServer
+	lnd *FakeLndServer
+}
+
+// lndStream delivers events to every subscriber, events sent without subscribers are kept for the first one
+type lndStream struct {
+	mu          sync.Mutex
+	subscribers map[*lndSubscriber]bool
+	backlog     []interface{}
+}
+
+type lndSubscriber struct {
+	events chan interface{}
+	// done is closed when the client went away so sending doesn't block
+	done chan struct{}
+}
+
+func newLndStream() *lndStream {
+	return &lndStream{subscribers: make(map[*lndSubscriber]bool)}
+}
+
+func NewFakeLndServer(t *testing.T) *FakeLndServer {
+	server := &FakeLndServer{
+		listener:        bufconn.Listen(lndBufferSize),
+		server:          grpc.NewServer(),
+		info:            &lnrpc.GetInfoResponse{SyncedToChain: true, SyncedToGraph: true},
+		pendingChannels: &lnrpc.PendingChannelsResponse{},
+		graph:           &lnrpc.ChannelGraph{},
+		calls:           make(map[string][]interface{}),
+		channelEvents:   newLndStream(),
+		invoiceEvents:   newLndStream(),
+		peerEvents:      newLndStream(),
+		graphEvents:     newLndStream(),
+		txEvents:        newLndStream(),
+		htlcEvents:      newLndStream(),
+		blockEvents:     newLndStream(),
+	}
+	lnrpc.RegisterLightningServer(server.server, server)
+	routerrpc.RegisterRouterServer(server.server, &fakeRouterServer{lnd: server})
+	chainrpc.RegisterChainNotifierServer(server.server, &fakeChainNotifierServer{lnd: server})
+	go func() {
+		// Serve returns when the server is stopped
+		_ = server.server.Serve(server.listener)
+	}()
+	t.Cleanup(func() {
+		server.server.Stop()
+	})
+	return server
+}
+
+// Dial connects to the fake server, the connection is closed when the test ends
+func (s *FakeLndServer) Dial(t *testing.T) *grpc.ClientConn {
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+// Calls returns the requests of every call made to a method (i.e. "UpdateChannelPolicy")
+func (s *FakeLndServer) Calls(method string) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *FakeLndServer) record(method string, request interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method] = append(s.calls[method], request)
+}
+
+func (s *FakeLndServer) SetInfo(info *lnrpc.GetInfoResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info = info
+}
+
+func (s *FakeLndServer) SetChannels(channels ...*lnrpc.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = channels
+}
+
+func (s *FakeLndServer) SetClosedChannels(closedChannels ...*lnrpc.ChannelCloseSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closedChannels = closedChannels
+}
+
+func (s *FakeLndServer) SetPendingChannels(pendingChannels *lnrpc.PendingChannelsResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingChannels = pendingChannels
+}
+
+func (s *FakeLndServer) SetPeers(peers ...*lnrpc.Peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers = peers
+}
+
+func (s *FakeLndServer) SetGraph(graph *lnrpc.ChannelGraph) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graph = graph
+}
+
+// AddForwards appends to the forwarding history, the forwards must be added in chronological order
+func (s *FakeLndServer) AddForwards(forwards ...*lnrpc.ForwardingEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forwards = append(s.forwards, forwards...)
+}
+
+// AddInvoices appends invoices, the AddIndex is set when it's missing
+func (s *FakeLndServer) AddInvoices(invoices ...*lnrpc.Invoice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, invoice := range invoices {
+		if invoice.AddIndex == 0 {
+			invoice.AddIndex = uint64(len(s.invoices) + 1)
+		}
+		s.invoices = append(s.invoices, invoice)
+	}
+}
+
+// AddPayments appends payments, the PaymentIndex is set when it's missing
+func (s *FakeLndServer) AddPayments(payments ...*lnrpc.Payment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, payment := range payments {
+		if payment.PaymentIndex == 0 {
+			payment.PaymentIndex = uint64(len(s.payments) + 1)
+		}
+		s.payments = append(s.payments, payment)
+	}
+}
+
+func (s *FakeLndServer) AddTransactions(transactions ...*lnrpc.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transactions = append(s.transactions, transactions...)
+}
+
+func (s *FakeLndServer) SendChannelEvent(event *lnrpc.ChannelEventUpdate) {
+	s.channelEvents.send(event)
+}
+
+func (s *FakeLndServer) SendInvoiceEvent(invoice *lnrpc.Invoice) {
+	s.invoiceEvents.send(invoice)
+}
+
+func (s *FakeLndServer) SendPeerEvent(event *lnrpc.PeerEvent) {
+	s.peerEvents.send(event)
+}
+
+func (s *FakeLndServer) SendGraphEvent(event *lnrpc.GraphTopologyUpdate) {
+	s.graphEvents.send(event)
+}
+
+func (s *FakeLndServer) SendTransactionEvent(transaction *lnrpc.Transaction) {
+	s.txEvents.send(transaction)
+}
+
+func (s *FakeLndServer) SendHtlcEvent(event *routerrpc.HtlcEvent) {
+	s.htlcEvents.send(event)
+}
+
+// SendBlockEvent also updates the block height of GetInfo
+func (s *FakeLndServer) SendBlockEvent(block *chainrpc.BlockEpoch) {
+	s.mu.Lock()
+	// A copy because the previous response might still be marshalled
+	info := proto.Clone(s.info).(*lnrpc.GetInfoResponse)
+	info.BlockHeight = block.Height
+	s.info = info
+	s.mu.Unlock()
+	s.blockEvents.send(block)
+}
+
+// WaitForSubscribers waits till the streams have at least one subscriber each, the stream names are the
+// method names (i.e. "SubscribeChannelEvents").
+func (s *FakeLndServer) WaitForSubscribers(t *testing.T, methods ...string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for _, method := range methods {
+		stream := s.stream(method)
+		if stream == nil {
+			t.Fatalf("Unknown stream: %v", method)
+		}
+		for stream.subscriberCount() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("No subscriber for %v", method)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func (s *FakeLndServer) stream(method string) *lndStream {
+	switch method {
+	case "SubscribeChannelEvents":
+		return s.channelEvents
+	case "SubscribeInvoices":
+		return s.invoiceEvents
+	case "SubscribePeerEvents":
+		return s.peerEvents
+	case "SubscribeChannelGraph":
+		return s.graphEvents
+	case "SubscribeTransactions":
+		return s.txEvents
+	case "SubscribeHtlcEvents":
+		return s.htlcEvents
+	case "RegisterBlockEpochNtfn":
+		return s.blockEvents
+	}
+	return nil
+}
+
+func (stream *lndStream) send(event interface{}) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if len(stream.subscribers) == 0 {
+		stream.backlog = append(stream.backlog, event)
+		return
+	}
+	for subscriber := range stream.subscribers {
+		select {
+		case subscriber.events <- event:
+		case <-subscriber.done:
+		}
+	}
+}
+
+func (stream *lndStream) subscriberCount() int {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	return len(stream.subscribers)
+}
+
+// serve sends the events of the stream till the client goes away
+func (stream *lndStream) serve(ctx context.Context, send func(event interface{}) error) error {
+	stream.mu.Lock()
+	subscriber := &lndSubscriber{
+		events: make(chan interface{}, len(stream.backlog)+100),
+		done:   make(chan struct{}),
+	}
+	for _, event := range stream.backlog {
+		subscriber.events <- event
+	}
+	stream.backlog = nil
+	stream.subscribers[subscriber] = true
+	stream.mu.Unlock()
+	defer func() {
+		close(subscriber.done)
+		stream.mu.Lock()
+		delete(stream.subscribers, subscriber)
+		stream.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-subscriber.events:
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *FakeLndServer) GetInfo(ctx context.Context, req *lnrpc.GetInfoRequest) (*lnrpc.GetInfoResponse, error) {
+	s.record("GetInfo", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info, nil
+}
+
+func (s *FakeLndServer) ListChannels(ctx context.Context,
+	req *lnrpc.ListChannelsRequest) (*lnrpc.ListChannelsResponse, error) {
+
+	s.record("ListChannels", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var channels []*lnrpc.Channel
+	for _, channel := range s.channels {
+		if (req.ActiveOnly && !channel.Active) || (req.InactiveOnly && channel.Active) ||
+			(req.PublicOnly && channel.Private) || (req.PrivateOnly && !channel.Private) {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	return &lnrpc.ListChannelsResponse{Channels: channels}, nil
+}
+
+func (s *FakeLndServer) ClosedChannels(ctx context.Context,
+	req *lnrpc.ClosedChannelsRequest) (*lnrpc.ClosedChannelsResponse, error) {
+
+	s.record("ClosedChannels", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &lnrpc.ClosedChannelsResponse{Channels: s.closedChannels}, nil
+}
+
+func (s *FakeLndServer) PendingChannels(ctx context.Context,
+	req *lnrpc.PendingChannelsRequest) (*lnrpc.PendingChannelsResponse, error) {
+
+	s.record("PendingChannels", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pendingChannels, nil
+}
+
+func (s *FakeLndServer) ListPeers(ctx context.Context, req *lnrpc.ListPeersRequest) (*lnrpc.ListPeersResponse, error) {
+	s.record("ListPeers", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &lnrpc.ListPeersResponse{Peers: s.peers}, nil
+}
+
+func (s *FakeLndServer) DescribeGraph(ctx context.Context, req *lnrpc.ChannelGraphRequest) (*lnrpc.ChannelGraph, error) {
+	s.record("DescribeGraph", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.graph, nil
+}
+
+func (s *FakeLndServer) GetNodeInfo(ctx context.Context, req *lnrpc.NodeInfoRequest) (*lnrpc.NodeInfo, error) {
+	s.record("GetNodeInfo", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, node := range s.graph.Nodes {
+		if node.PubKey == req.PubKey {
+			return &lnrpc.NodeInfo{Node: node}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "unable to find node")
+}
+
+func (s *FakeLndServer) GetChanInfo(ctx context.Context, req *lnrpc.ChanInfoRequest) (*lnrpc.ChannelEdge, error) {
+	s.record("GetChanInfo", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, edge := range s.graph.Edges {
+		if edge.ChannelId == req.ChanId {
+			return edge, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "edge not found")
+}
+
+// ForwardingHistory pages like LND, the offset is the position in the forwards of the time window
+func (s *FakeLndServer) ForwardingHistory(ctx context.Context,
+	req *lnrpc.ForwardingHistoryRequest) (*lnrpc.ForwardingHistoryResponse, error) {
+
+	s.record("ForwardingHistory", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endTime := req.EndTime
+	if endTime == 0 {
+		endTime = uint64(time.Now().Unix())
+	}
+	var window []*lnrpc.ForwardingEvent
+	for _, forward := range s.forwards {
+		seconds := forward.TimestampNs / uint64(time.Second)
+		if seconds >= req.StartTime && seconds < endTime {
+			window = append(window, forward)
+		}
+	}
+	maxEvents := req.NumMaxEvents
+	if maxEvents == 0 {
+		maxEvents = 100
+	}
+	offset := req.IndexOffset
+	if offset > uint32(len(window)) {
+		offset = uint32(len(window))
+	}
+	end := offset + maxEvents
+	if end > uint32(len(window)) {
+		end = uint32(len(window))
+	}
+	return &lnrpc.ForwardingHistoryResponse{ForwardingEvents: window[offset:end], LastOffsetIndex: end}, nil
+}
+
+func (s *FakeLndServer) ListInvoices(ctx context.Context, req *lnrpc.ListInvoiceRequest) (*lnrpc.ListInvoiceResponse, error) {
+	s.record("ListInvoices", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maxInvoices := req.NumMaxInvoices
+	if maxInvoices == 0 {
+		maxInvoices = 100
+	}
+	response := &lnrpc.ListInvoiceResponse{}
+	for _, invoice := range s.invoices {
+		if invoice.AddIndex <= req.IndexOffset || (req.PendingOnly && invoice.State != lnrpc.Invoice_OPEN) {
+			continue
+		}
+		if uint64(len(response.Invoices)) == maxInvoices {
+			break
+		}
+		if len(response.Invoices) == 0 {
+			response.FirstIndexOffset = invoice.AddIndex
+		}
+		response.Invoices = append(response.Invoices, invoice)
+		response.LastIndexOffset = invoice.AddIndex
+	}
+	return response, nil
+}
+
+func (s *FakeLndServer) ListPayments(ctx context.Context, req *lnrpc.ListPaymentsRequest) (*lnrpc.ListPaymentsResponse, error) {
+	s.record("ListPayments", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maxPayments := req.MaxPayments
+	if maxPayments == 0 {
+		maxPayments = 100
+	}
+	response := &lnrpc.ListPaymentsResponse{}
+	for _, payment := range s.payments {
+		if payment.PaymentIndex <= req.IndexOffset ||
+			(!req.IncludeIncomplete && payment.Status != lnrpc.Payment_SUCCEEDED) {
+			continue
+		}
+		if uint64(len(response.Payments)) == maxPayments {
+			break
+		}
+		if len(response.Payments) == 0 {
+			response.FirstIndexOffset = payment.PaymentIndex
+		}
+		response.Payments = append(response.Payments, payment)
+		response.LastIndexOffset = payment.PaymentIndex
+	}
+	return response, nil
+}
+
+// GetTransactions an end height of -1 includes the unconfirmed transactions (block height 0)
+func (s *FakeLndServer) GetTransactions(ctx context.Context,
+	req *lnrpc.GetTransactionsRequest) (*lnrpc.TransactionDetails, error) {
+
+	s.record("GetTransactions", req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response := &lnrpc.TransactionDetails{}
+	for _, transaction := range s.transactions {
+		if transaction.BlockHeight == 0 {
+			if req.EndHeight == -1 {
+				response.Transactions = append(response.Transactions, transaction)
+			}
+			continue
+		}
+		if transaction.BlockHeight >= req.StartHeight && (req.EndHeight <= 0 || transaction.BlockHeight <= req.EndHeight) {
+			response.Transactions = append(response.Transactions, transaction)
+		}
+	}
+	return response, nil
+}
+
+func (s *FakeLndServer) UpdateChannelPolicy(ctx context.Context,
+	req *lnrpc.PolicyUpdateRequest) (*lnrpc.PolicyUpdateResponse, error) {
+
+	s.record("UpdateChannelPolicy", req)
+	return &lnrpc.PolicyUpdateResponse{}, nil
+}
+
+func (s *FakeLndServer) SubscribeChannelEvents(req *lnrpc.ChannelEventSubscription,
+	stream lnrpc.Lightning_SubscribeChannelEventsServer) error {
+
+	s.record("SubscribeChannelEvents", req)
+	return s.channelEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*lnrpc.ChannelEventUpdate))
+	})
+}
+
+func (s *FakeLndServer) SubscribeInvoices(req *lnrpc.InvoiceSubscription,
+	stream lnrpc.Lightning_SubscribeInvoicesServer) error {
+
+	s.record("SubscribeInvoices", req)
+	return s.invoiceEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*lnrpc.Invoice))
+	})
+}
+
+func (s *FakeLndServer) SubscribePeerEvents(req *lnrpc.PeerEventSubscription,
+	stream lnrpc.Lightning_SubscribePeerEventsServer) error {
+
+	s.record("SubscribePeerEvents", req)
+	return s.peerEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*lnrpc.PeerEvent))
+	})
+}
+
+func (s *FakeLndServer) SubscribeChannelGraph(req *lnrpc.GraphTopologySubscription,
+	stream lnrpc.Lightning_SubscribeChannelGraphServer) error {
+
+	s.record("SubscribeChannelGraph", req)
+	return s.graphEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*lnrpc.GraphTopologyUpdate))
+	})
+}
+
+func (s *FakeLndServer) SubscribeTransactions(req *lnrpc.GetTransactionsRequest,
+	stream lnrpc.Lightning_SubscribeTransactionsServer) error {
+
+	s.record("SubscribeTransactions", req)
+	return s.txEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*lnrpc.Transaction))
+	})
+}
+
+func (s *fakeRouterServer) SubscribeHtlcEvents(req *routerrpc.SubscribeHtlcEventsRequest,
+	stream routerrpc.Router_SubscribeHtlcEventsServer) error {
+
+	s.lnd.record("SubscribeHtlcEvents", req)
+	return s.lnd.htlcEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*routerrpc.HtlcEvent))
+	})
+}
+
+func (s *fakeChainNotifierServer) RegisterBlockEpochNtfn(req *chainrpc.BlockEpoch,
+	stream chainrpc.ChainNotifier_RegisterBlockEpochNtfnServer) error {
+
+	s.lnd.record("RegisterBlockEpochNtfn", req)
+	return s.lnd.blockEvents.serve(stream.Context(), func(event interface{}) error {
+		return stream.Send(event.(*chainrpc.BlockEpoch))
+	})
+}
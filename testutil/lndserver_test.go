@@ -0,0 +1,115 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/chainrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFakeLndServerListCalls(t *testing.T) {
+	server := NewFakeLndServer(t)
+	client := lnrpc.NewLightningClient(server.Dial(t))
+	ctx := context.Background()
+
+	server.SetChannels(&lnrpc.Channel{ChanId: 1, Active: true}, &lnrpc.Channel{ChanId: 2})
+	channels, err := client.ListChannels(ctx, &lnrpc.ListChannelsRequest{ActiveOnly: true})
+	if err != nil {
+		t.Fatalf("ListChannels: %v", err)
+	}
+	if len(channels.Channels) != 1 || channels.Channels[0].ChanId != 1 {
+		t.Errorf("expected the active channel only, got %v", channels.Channels)
+	}
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		server.AddForwards(&lnrpc.ForwardingEvent{
+			TimestampNs: uint64(start.Add(time.Duration(i) * time.Hour).UnixNano()), Fee: uint64(i)})
+	}
+	forwards, err := client.ForwardingHistory(ctx, &lnrpc.ForwardingHistoryRequest{
+		StartTime: uint64(start.Add(time.Hour).Unix()), IndexOffset: 1, NumMaxEvents: 2})
+	if err != nil {
+		t.Fatalf("ForwardingHistory: %v", err)
+	}
+	if len(forwards.ForwardingEvents) != 2 || forwards.ForwardingEvents[0].Fee != 2 || forwards.LastOffsetIndex != 3 {
+		t.Errorf("expected the second page of the time window, got %v (offset %v)",
+			forwards.ForwardingEvents, forwards.LastOffsetIndex)
+	}
+
+	server.AddPayments(&lnrpc.Payment{Status: lnrpc.Payment_SUCCEEDED}, &lnrpc.Payment{Status: lnrpc.Payment_FAILED},
+		&lnrpc.Payment{Status: lnrpc.Payment_SUCCEEDED})
+	payments, err := client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{IndexOffset: 1})
+	if err != nil {
+		t.Fatalf("ListPayments: %v", err)
+	}
+	if len(payments.Payments) != 1 || payments.LastIndexOffset != 3 {
+		t.Errorf("expected the last succeeded payment, got %v", payments.Payments)
+	}
+
+	_, err = client.GetNodeInfo(ctx, &lnrpc.NodeInfoRequest{PubKey: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected not found, got %v", err)
+	}
+	_, err = client.SignMessage(ctx, &lnrpc.SignMessageRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected unimplemented, got %v", err)
+	}
+
+	_, err = client.UpdateChannelPolicy(ctx, &lnrpc.PolicyUpdateRequest{FeeRatePpm: 100})
+	if err != nil {
+		t.Fatalf("UpdateChannelPolicy: %v", err)
+	}
+	calls := server.Calls("UpdateChannelPolicy")
+	if len(calls) != 1 || calls[0].(*lnrpc.PolicyUpdateRequest).FeeRatePpm != 100 {
+		t.Errorf("expected the policy update to be recorded, got %v", calls)
+	}
+}
+
+func TestFakeLndServerStreams(t *testing.T) {
+	server := NewFakeLndServer(t)
+	conn := server.Dial(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Events sent before the subscription are delivered to the first subscriber
+	server.SendChannelEvent(&lnrpc.ChannelEventUpdate{Type: lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL})
+	channelEvents, err := lnrpc.NewLightningClient(conn).SubscribeChannelEvents(ctx, &lnrpc.ChannelEventSubscription{})
+	if err != nil {
+		t.Fatalf("SubscribeChannelEvents: %v", err)
+	}
+	channelEvent, err := channelEvents.Recv()
+	if err != nil || channelEvent.Type != lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL {
+		t.Errorf("expected the channel event, got %v (%v)", channelEvent, err)
+	}
+
+	htlcEvents, err := routerrpc.NewRouterClient(conn).SubscribeHtlcEvents(ctx, &routerrpc.SubscribeHtlcEventsRequest{})
+	if err != nil {
+		t.Fatalf("SubscribeHtlcEvents: %v", err)
+	}
+	blockEvents, err := chainrpc.NewChainNotifierClient(conn).RegisterBlockEpochNtfn(ctx, &chainrpc.BlockEpoch{})
+	if err != nil {
+		t.Fatalf("RegisterBlockEpochNtfn: %v", err)
+	}
+	server.WaitForSubscribers(t, "SubscribeHtlcEvents", "RegisterBlockEpochNtfn")
+
+	server.SendHtlcEvent(&routerrpc.HtlcEvent{IncomingChannelId: 7})
+	htlcEvent, err := htlcEvents.Recv()
+	if err != nil || htlcEvent.IncomingChannelId != 7 {
+		t.Errorf("expected the htlc event, got %v (%v)", htlcEvent, err)
+	}
+
+	server.SendBlockEvent(&chainrpc.BlockEpoch{Height: 800000})
+	blockEvent, err := blockEvents.Recv()
+	if err != nil || blockEvent.Height != 800000 {
+		t.Errorf("expected the block event, got %v (%v)", blockEvent, err)
+	}
+	info, err := lnrpc.NewLightningClient(conn).GetInfo(ctx, &lnrpc.GetInfoRequest{})
+	if err != nil || info.BlockHeight != 800000 {
+		t.Errorf("expected the block height in GetInfo, got %v (%v)", info, err)
+	}
+}
@@ -292,6 +292,7 @@ func (srv *Server) NewTestDatabase(migrate bool) (*sqlx.DB, context.CancelFunc,
 		commons.RunningServices[commons.VectorService] = &commons.Services{ServiceType: commons.VectorService}
 		commons.RunningServices[commons.AmbossService] = &commons.Services{ServiceType: commons.AmbossService}
 		commons.RunningServices[commons.TorqService] = &commons.Services{ServiceType: commons.TorqService}
+		commons.RunningServices[commons.MaintenanceService] = &commons.Services{ServiceType: commons.MaintenanceService}
 
 	}
 
@@ -3,6 +3,10 @@ export interface settings {
   defaultLanguage: "en" | "nl";
   preferredTimezone: string;
   weekStartsOn: "saturday" | "sunday" | "monday";
+  channelRiskHtlcWarningBlocks: number;
+  channelRiskHtlcCriticalBlocks: number;
+  channelRiskPeerOfflineWarningSeconds: number;
+  channelRiskPeerOfflineCriticalSeconds: number;
 }
 
 export interface timeZone {
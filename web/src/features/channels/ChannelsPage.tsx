@@ -77,6 +77,12 @@ function useMaximums(data: Array<channel>): channel | undefined {
       remotePubkey: Math.max(prev.remotePubkey, current.remotePubkey),
       remoteTimeLockDelta: Math.max(prev.remoteTimeLockDelta, current.remoteTimeLockDelta),
       timeLockDelta: Math.max(prev.timeLockDelta, current.timeLockDelta),
+      healthScore: Math.max(prev.healthScore ?? 0, current.healthScore ?? 0),
+      annualizedRoi: Math.max(prev.annualizedRoi ?? 0, current.annualizedRoi ?? 0),
+      peerUptimePercent: Math.max(prev.peerUptimePercent ?? 0, current.peerUptimePercent ?? 0),
+      peerDisconnects: Math.max(prev.peerDisconnects, current.peerDisconnects),
+      peerLongestOutageSeconds: Math.max(prev.peerLongestOutageSeconds, current.peerLongestOutageSeconds),
+      closestHtlcExpiryBlocks: Math.max(prev.closestHtlcExpiryBlocks ?? 0, current.closestHtlcExpiryBlocks ?? 0),
       totalSatoshisReceived: Math.max(prev.totalSatoshisReceived, current.totalSatoshisReceived),
       totalSatoshisSent: Math.max(prev.totalSatoshisSent, current.totalSatoshisSent),
       unsettledBalance: Math.max(prev.unsettledBalance, current.unsettledBalance),
@@ -158,6 +158,54 @@ export const AllChannelsColumns: ColumnMetaData<channel>[] = [
     key: "commitFee",
     valueType: "number",
   },
+  {
+    heading: "Health Score",
+    type: "NumericCell",
+    key: "healthScore",
+    valueType: "number",
+  },
+  {
+    heading: "Annualized ROI",
+    type: "NumericCell",
+    key: "annualizedRoi",
+    valueType: "number",
+  },
+  {
+    heading: "Close Candidate",
+    type: "BooleanCell",
+    key: "closeCandidate",
+    valueType: "boolean",
+  },
+  {
+    heading: "Peer Uptime %",
+    type: "NumericCell",
+    key: "peerUptimePercent",
+    valueType: "number",
+  },
+  {
+    heading: "Peer Disconnects",
+    type: "NumericCell",
+    key: "peerDisconnects",
+    valueType: "number",
+  },
+  {
+    heading: "Peer Longest Outage (s)",
+    type: "NumericCell",
+    key: "peerLongestOutageSeconds",
+    valueType: "number",
+  },
+  {
+    heading: "Risk Level",
+    type: "LongTextCell",
+    key: "riskLevel",
+    valueType: "string",
+  },
+  {
+    heading: "Closest HTLC Expiry (blocks)",
+    type: "NumericCell",
+    key: "closestHtlcExpiryBlocks",
+    valueType: "number",
+  },
   {
     heading: "Node Name",
     type: "AliasCell",
@@ -231,6 +279,14 @@ const sortableColumns: Array<keyof channel> = [
   "totalSatoshisReceived",
   "unsettledBalance",
   "commitFee",
+  "healthScore",
+  "annualizedRoi",
+  "closeCandidate",
+  "peerUptimePercent",
+  "peerDisconnects",
+  "peerLongestOutageSeconds",
+  "riskLevel",
+  "closestHtlcExpiryBlocks",
   "feeBaseMsat",
   "minHtlcMsat",
   "maxHtlcMsat",
@@ -57,6 +57,15 @@ export interface channel {
   totalSatoshisReceived: number;
   totalSatoshisSent: number;
   unsettledBalance: number;
+  healthScore?: number;
+  annualizedRoi?: number;
+  closeCandidate: boolean;
+  peerUptimePercent?: number;
+  peerDisconnects: number;
+  peerLongestOutageSeconds: number;
+  riskLevel?: string;
+  riskReasons?: Array<string>;
+  closestHtlcExpiryBlocks?: number;
 }
 
 export type PolicyInterface = {
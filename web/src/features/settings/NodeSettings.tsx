@@ -209,6 +209,21 @@ const NodeSettings = React.forwardRef(function NodeSettings(
     }
   };
 
+  const handleStorePeerChannelPoliciesClick = () => {
+    const storePeerChannelPoliciesActive = Math.floor(nodeConfigurationState.customSettings / 2) % 2 >= 1;
+    if (storePeerChannelPoliciesActive) {
+      setNodeConfigurationState({
+        ...nodeConfigurationState,
+        customSettings: nodeConfigurationState.customSettings - 2,
+      });
+    } else {
+      setNodeConfigurationState({
+        ...nodeConfigurationState,
+        customSettings: nodeConfigurationState.customSettings + 2,
+      });
+    }
+  };
+
   const handleAddressChange = (value: string) => {
     setNodeConfigurationState({ ...nodeConfigurationState, grpcAddress: value });
   };
@@ -392,6 +407,16 @@ const NodeSettings = React.forwardRef(function NodeSettings(
                   {t.importFailedPayments}
                 </Note>
               </div>
+              <div className={styles.importFailedPayments}>
+                <Switch
+                  label={"Store peer channel policies"}
+                  checked={Math.floor(nodeConfigurationState.customSettings / 2) % 2 >= 1}
+                  onChange={handleStorePeerChannelPoliciesClick}
+                />
+                <Note title={"Peer Channel Policies"} noteType={NoteType.info}>
+                  {t.storePeerChannelPolicies}
+                </Note>
+              </div>
               <Button
                 id={"save-node"}
                 buttonColor={buttonColor.green}